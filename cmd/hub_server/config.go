@@ -0,0 +1,279 @@
+package main
+
+// 本文件提供 Server 中与 `config` 子命令相关的命令入口。
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+const configUsage = `usage:
+  hub_server config explain  [flags] [key ...]
+  hub_server config history  [flags]
+  hub_server config rollback [flags] <rev>`
+
+// runConfig 分发 `hub_server config <subcommand>`。
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	switch args[0] {
+	case "explain":
+		return runConfigExplain(args[1:])
+	case "history":
+		return runConfigHistory(args[1:])
+	case "rollback":
+		return runConfigRollback(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+}
+
+// configCommand 是 config 子命令共用的参数：有 admin 地址时访问运行中的 hub，否则按 workdir 离线处理。
+type configCommand struct {
+	fs        *flag.FlagSet
+	opts      hubruntime.Options
+	adminAddr string
+	timeout   time.Duration
+	asJSON    bool
+}
+
+func newConfigCommand(name string) *configCommand {
+	c := &configCommand{fs: flag.NewFlagSet("config "+name, flag.ContinueOnError), opts: hubruntime.DefaultOptionsFromEnv()}
+	c.fs.StringVar(&c.adminAddr, "admin-addr", c.opts.AdminAddr, "admin listen address of the running hub (default: $HUB_ADMIN_ADDR); empty works offline")
+	c.fs.StringVar(&c.opts.WorkDir, "workdir", c.opts.WorkDir, "working directory for relative paths (offline mode)")
+	c.fs.StringVar(&c.opts.ConfigFile, "config", c.opts.ConfigFile, "config file (offline mode)")
+	c.fs.BoolVar(&c.opts.ConfigFileReadOnly, "config-readonly", c.opts.ConfigFileReadOnly, "treat -config as a read-only layer (offline mode)")
+	c.fs.BoolVar(&c.asJSON, "json", false, "print JSON instead of a table")
+	c.fs.DurationVar(&c.timeout, "timeout", 3*time.Second, "request timeout (online mode)")
+	return c
+}
+
+func (c *configCommand) online() bool {
+	return c.adminAddr != ""
+}
+
+// adminRequest 请求运行中 hub 的 admin 接口并解码 JSON 响应；非 200 时返回响应中的 error。
+func (c *configCommand) adminRequest(method, path string, q url.Values, out any) error {
+	target, err := healthcheckURL(c.adminAddr, path)
+	if err != nil {
+		return err
+	}
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: c.timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(raw, &body)
+		return fmt.Errorf("admin returned %s: %s", resp.Status, body.Error)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// runConfigExplain 输出每个键在各配置层的取值与胜出层。
+//   - 给出 -admin-addr（或 HUB_ADMIN_ADDR）时查询运行中的 hub，包含 runtime overlay；
+//   - 否则按当前环境变量与 -workdir / -config 离线构造配置，与 hub 启动时的口径一致。
+func runConfigExplain(args []string) int {
+	c := newConfigCommand("explain")
+	prefix := c.fs.String("prefix", "", "only explain keys with this prefix when no key is given")
+	if err := c.fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var (
+		items []mgmtproto.ConfigExplainItem
+		err   error
+	)
+	if c.online() {
+		q := url.Values{}
+		for _, key := range c.fs.Args() {
+			q.Add("key", key)
+		}
+		if *prefix != "" {
+			q.Set("prefix", *prefix)
+		}
+		var body struct {
+			Items []mgmtproto.ConfigExplainItem `json:"items"`
+		}
+		err = c.adminRequest(http.MethodGet, "/config/explain", q, &body)
+		items = body.Items
+	} else {
+		items, err = hubruntime.ExplainConfig(c.opts, c.fs.Args(), *prefix)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config explain:", err)
+		return 1
+	}
+	if c.asJSON {
+		printJSON(items)
+		return 0
+	}
+	printConfigExplain(os.Stdout, items)
+	return 0
+}
+
+// printConfigExplain 以表格输出：每个键一行生效值，其下逐层列出取值，胜出层以 `*` 标记。
+func printConfigExplain(w io.Writer, items []mgmtproto.ConfigExplainItem) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, item := range items {
+		if !item.Found {
+			fmt.Fprintf(tw, "%s\t(not set)\t\n", item.Key)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t= %q\t(%s)\n", item.Key, item.Value, item.Source)
+		for _, layer := range item.Layers {
+			mark := " "
+			if layer.Layer == item.Source {
+				mark = "*"
+			}
+			where := ""
+			if layer.Path != "" {
+				where = "[" + layer.Path + "]"
+			}
+			fmt.Fprintf(tw, "  %s %s\t= %q\t%s\n", mark, layer.Layer, layer.Value, where)
+		}
+	}
+	_ = tw.Flush()
+}
+
+// runConfigHistory 列出持久配置变更历史（从新到旧）。
+func runConfigHistory(args []string) int {
+	c := newConfigCommand("history")
+	key := c.fs.String("key", "", "only show revisions of this key")
+	limit := c.fs.Int("limit", 20, "maximum number of revisions (0 = all retained)")
+	if err := c.fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var (
+		revisions []mgmtproto.ConfigRevision
+		err       error
+	)
+	if c.online() {
+		q := url.Values{"limit": {strconv.Itoa(*limit)}}
+		if *key != "" {
+			q.Set("key", *key)
+		}
+		var body struct {
+			Revisions []mgmtproto.ConfigRevision `json:"revisions"`
+		}
+		err = c.adminRequest(http.MethodGet, "/config/history", q, &body)
+		revisions = body.Revisions
+	} else {
+		revisions, err = hubruntime.ConfigHistory(c.opts, *key, *limit)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config history:", err)
+		return 1
+	}
+	if c.asJSON {
+		printJSON(revisions)
+		return 0
+	}
+	printConfigHistory(os.Stdout, revisions)
+	return 0
+}
+
+// printConfigHistory 以表格输出变更历史；键不存在的一侧显示为 `-`。
+func printConfigHistory(w io.Writer, revisions []mgmtproto.ConfigRevision) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REV\tTIME\tSOURCE\tACTOR\tKEY\tOLD\tNEW")
+	for _, rev := range revisions {
+		source := rev.Source
+		if rev.RollbackTo != 0 {
+			source += "@" + strconv.FormatInt(rev.RollbackTo, 10)
+		}
+		actor := "-"
+		if rev.Actor != 0 {
+			actor = strconv.FormatUint(uint64(rev.Actor), 10)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			rev.Rev, time.UnixMilli(rev.TimeMs).Format(time.RFC3339), source, actor, rev.Key,
+			historyCell(rev.Old), historyCell(rev.New))
+	}
+	_ = tw.Flush()
+}
+
+func historyCell(val *string) string {
+	if val == nil {
+		return "-"
+	}
+	return strconv.Quote(*val)
+}
+
+// runConfigRollback 把持久配置恢复到指定修订生效后的状态。
+// 只有离线模式：直接改写 workdir 下的持久文件，只应在 hub 未运行时使用；
+// admin HTTP 没有鉴权、不提供写接口，运行中的 hub 应经 management config_rollback 回滚。
+func runConfigRollback(args []string) int {
+	c := newConfigCommand("rollback")
+	if err := c.fs.Parse(args); err != nil {
+		return 2
+	}
+	if c.fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: hub_server config rollback [flags] <rev>")
+		return 2
+	}
+	rev, err := strconv.ParseInt(c.fs.Arg(0), 10, 64)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config rollback: invalid rev:", c.fs.Arg(0))
+		return 2
+	}
+
+	if c.online() {
+		fmt.Fprintln(os.Stderr, "config rollback: a running hub is rolled back through management config_rollback; pass -admin-addr= to roll back the stopped hub's workdir offline")
+		return 2
+	}
+	keys, err := hubruntime.RollbackConfig(c.opts, rev)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config rollback:", err)
+		return 1
+	}
+	if c.asJSON {
+		printJSON(map[string]any{"rev": rev, "keys": keys})
+		return 0
+	}
+	if len(keys) == 0 {
+		fmt.Printf("already at revision %d, nothing to roll back\n", rev)
+		return 0
+	}
+	fmt.Printf("rolled back to revision %d: %v\n", rev, keys)
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+// 本文件提供 Server 中与 `healthcheck` 相关的命令入口。
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runHealthcheck 请求本机 admin 监听的探针端点，成功返回 0，否则返回 1。
+// 供容器 HEALTHCHECK 使用：镜像内没有 curl / wget，直接复用 hub_server 二进制。
+// 默认探测存活（/healthz）：就绪受父链、状态后端等依赖影响，不应触发编排器重启。
+// 未启用 admin 监听时跳过探测并返回 0：连接数据面 listener 会在 hub 上产生一次连接 / 断开事件与日志，
+// 不适合作为周期性探针；进程退出仍由容器运行时直接感知。
+func runHealthcheck(args []string) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	addr := fs.String("admin-addr", os.Getenv("HUB_ADMIN_ADDR"), "admin listen address of the running hub (default: $HUB_ADMIN_ADDR)")
+	path := fs.String("path", "/healthz", "probe path: /healthz or /readyz")
+	timeout := fs.Duration("timeout", 3*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if strings.TrimSpace(*addr) == "" {
+		fmt.Fprintln(os.Stdout, "admin listener disabled, probe skipped")
+		return 0
+	}
+	target, err := healthcheckURL(*addr, *path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		return 2
+	}
+	client := http.Client{Timeout: *timeout}
+	resp, err := client.Get(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	fmt.Fprintln(os.Stdout, strings.TrimSpace(string(body)))
+	if resp.StatusCode != http.StatusOK {
+		return 1
+	}
+	return 0
+}
+
+// healthcheckURL 把监听地址（可能是 `:9100` 或 `0.0.0.0:9100`）转换为本机可访问的 URL。
+func healthcheckURL(addr, path string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return "", fmt.Errorf("admin addr required (-admin-addr or HUB_ADMIN_ADDR)")
+	}
+	target, err := loopbackAddr(addr)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "http://" + target + path, nil
+}
+
+// loopbackAddr 把通配监听地址（`:PORT`、`0.0.0.0:PORT`、`[::]:PORT`）换成本机回环地址。
+func loopbackAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("invalid addr %q: %w", addr, err)
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+// 本文件提供 Server 中与 `logging` 相关的命令入口。
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+)
+
+// logOptions 是进程级日志输出设置；级别由 runtime 配置（log.level / log.level.<component>）管理，可运行期修改。
+type logOptions struct {
+	Format     string
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// defaultLogOptionsFromEnv 读取 HUB_LOG_FORMAT / HUB_LOG_FILE / HUB_LOG_MAX_SIZE_MB / HUB_LOG_MAX_BACKUPS。
+func defaultLogOptionsFromEnv() logOptions {
+	opts := logOptions{Format: "text", MaxSizeMB: 100, MaxBackups: 5}
+	if v := strings.TrimSpace(os.Getenv("HUB_LOG_FORMAT")); v != "" {
+		opts.Format = v
+	}
+	opts.File = strings.TrimSpace(os.Getenv("HUB_LOG_FILE"))
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("HUB_LOG_MAX_SIZE_MB"))); err == nil {
+		opts.MaxSizeMB = n
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("HUB_LOG_MAX_BACKUPS"))); err == nil {
+		opts.MaxBackups = n
+	}
+	return opts
+}
+
+// setupLogger 初始化命令行版本使用的全局 logger：text / json 输出到 stdout，设置了日志文件时改为写入按大小轮转的文件。
+// 相对路径的日志文件基于 workDir。返回的 closer 在进程退出前调用。
+func setupLogger(opts logOptions, workDir string) (*slog.Logger, io.Closer, error) {
+	var out io.Writer = os.Stdout
+	var closer io.Closer = io.NopCloser(nil)
+	if path := strings.TrimSpace(opts.File); path != "" {
+		if !filepath.IsAbs(path) && strings.TrimSpace(workDir) != "" {
+			path = filepath.Join(workDir, path)
+		}
+		f, err := hubruntime.OpenRotatingFile(path, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = f, f
+	}
+
+	// 不设置 HandlerOptions.Level：runtime 的 levelRouter 负责全部级别判定，通过的记录直接交给本 handler 的 Handle。
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, nil)
+	case "json":
+		handler = slog.NewJSONHandler(out, nil)
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (want text or json)", opts.Format)
+	}
+	l := slog.New(handler)
+	slog.SetDefault(l)
+	return l, closer, nil
+}
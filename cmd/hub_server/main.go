@@ -5,6 +5,8 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -17,7 +19,18 @@ import (
 
 // main 负责把 env/flag 配置归一化后交给 hubruntime 启停。
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(runHealthcheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		os.Exit(runState(os.Args[2:]))
+	}
+
 	opts := hubruntime.DefaultOptionsFromEnv()
+	logOpts := defaultLogOptionsFromEnv()
 	nodeID := uint(opts.NodeID)
 
 	flag.BoolVar(&opts.TCPEnable, "tcp-enable", opts.TCPEnable, "enable tcp listener")
@@ -33,6 +46,8 @@ func main() {
 	flag.UintVar(&nodeID, "node-id", nodeID, "node id for this hub (0 means auto when parent+self-id enabled)")
 	flag.StringVar(&opts.ParentEndpoint, "parent-endpoint", opts.ParentEndpoint, "parent endpoint, e.g. tcp://127.0.0.1:9000 or bt+rfcomm://... or quic://127.0.0.1:9000?server_name=...")
 	flag.StringVar(&opts.ParentAddr, "parent", opts.ParentAddr, "parent address")
+	flag.StringVar(&opts.ParentEndpoints, "parent-endpoints", opts.ParentEndpoints, "ordered parent failover list (comma separated), entries may end with #priority=N; overrides -parent-endpoint")
+	flag.StringVar(&opts.ParentTransportPrefer, "parent-transport-prefer", opts.ParentTransportPrefer, "transport order for parent endpoints of equal priority, e.g. quic,tcp,bt+rfcomm")
 	flag.BoolVar(&opts.ParentEnable, "parent-enable", opts.ParentEnable, "enable parent link")
 	flag.IntVar(&opts.ParentReconnectSec, "parent-reconnect", opts.ParentReconnectSec, "parent reconnect seconds")
 	flag.BoolVar(&opts.RFCOMMEnable, "rfcomm-enable", opts.RFCOMMEnable, "enable bluetooth rfcomm listener")
@@ -52,12 +67,26 @@ func main() {
 	flag.StringVar(&opts.AuthNodeRoles, "auth-node-roles", opts.AuthNodeRoles, "node roles mapping, e.g. 1:admin;2:node")
 	flag.StringVar(&opts.AuthRolePerms, "auth-role-perms", opts.AuthRolePerms, "role perms mapping, e.g. admin:p1,p2;node:p3")
 	flag.StringVar(&opts.WorkDir, "workdir", opts.WorkDir, "working directory for relative paths (optional)")
+	flag.StringVar(&opts.ConfigFile, "config", opts.ConfigFile, "config file (json/yaml/toml, nested sections, ${ENV} / ${file:path} interpolation); replaces config/runtime_config.json unless -config-readonly")
+	flag.BoolVar(&opts.ConfigFileReadOnly, "config-readonly", opts.ConfigFileReadOnly, "load -config as a read-only layer below config/runtime_config.json")
 	flag.StringVar(&opts.SelfID, "self-id", opts.SelfID, "self device id (for parent self-register/bootstrap)")
+	flag.StringVar(&opts.AdminAddr, "admin-addr", opts.AdminAddr, "http admin listen address serving /metrics, /healthz and /readyz (optional, e.g. 127.0.0.1:9100)")
+	flag.StringVar(&opts.LogLevel, "log-level", opts.LogLevel, "global log level: debug|info|warn|error (per component: config key log.level.<component>)")
+	flag.StringVar(&logOpts.Format, "log-format", logOpts.Format, "log output format: text|json")
+	flag.StringVar(&logOpts.File, "log-file", logOpts.File, "write logs to this file instead of stdout, rotated by size (relative to -workdir)")
+	flag.IntVar(&logOpts.MaxSizeMB, "log-max-size-mb", logOpts.MaxSizeMB, "rotate the log file after this many MB (0 disables rotation)")
+	flag.IntVar(&logOpts.MaxBackups, "log-max-backups", logOpts.MaxBackups, "number of rotated log files to keep")
 	flag.Parse()
 
 	opts.NodeID = uint32(nodeID)
 	captureFlagOverrides(&opts)
-	opts.Logger = setupLogger()
+	logger, logCloser, err := setupLogger(logOpts, opts.WorkDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "setup logger failed:", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
+	opts.Logger = logger
 	opts.Normalize()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -66,41 +95,73 @@ func main() {
 	rt, err := hubruntime.New(opts)
 	if err != nil {
 		slog.Error("init runtime failed", "err", err)
-		os.Exit(1)
+		exit(logCloser, 1)
 	}
 	if err := rt.Start(ctx); err != nil {
 		slog.Error("start runtime failed", "err", err)
-		os.Exit(1)
+		exit(logCloser, 1)
 	}
 	st := rt.Status()
-	slog.Info("hub server started", "addr", st.Addr, "node_id", st.NodeID, "parent", st.ParentAddr)
+	slog.Info("hub server started", "addr", st.Addr, "node_id", st.NodeID, "parent", st.ParentAddr, "admin", st.AdminAddr)
 
-	waitSignal()
+	failed := make(chan string, 1)
+	cancelSub := rt.Subscribe(func(ev hubruntime.Event) {
+		if ev.Type == hubruntime.EventFailed {
+			select {
+			case failed <- ev.Message:
+			default:
+			}
+		}
+	})
+	defer cancelSub()
+	msg, ok := waitSignal(rt, failed)
 
 	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer stopCancel()
 	if err := rt.Stop(stopCtx); err != nil {
 		slog.Error("stop runtime failed", "err", err)
-		os.Exit(1)
+		exit(logCloser, 1)
+	}
+	if !ok {
+		slog.Error("hub runtime failed", "err", msg)
+		exit(logCloser, 1)
 	}
 	slog.Info("hub server stopped")
 }
 
-// setupLogger 初始化命令行版本使用的全局文本 logger。
-func setupLogger() *slog.Logger {
-	level := new(slog.LevelVar)
-	level.Set(slog.LevelInfo)
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
-	l := slog.New(handler)
-	slog.SetDefault(l)
-	return l
+// exit 在退出前关闭日志文件；os.Exit 不会执行 defer。
+func exit(logCloser io.Closer, code int) {
+	_ = logCloser.Close()
+	os.Exit(code)
 }
 
-// waitSignal 阻塞等待中断信号，作为 CLI 版 runtime 的退出钩子。
-func waitSignal() {
+// waitSignal 阻塞等待中断信号，作为 CLI 版 runtime 的退出钩子；SIGHUP 触发配置重载而不退出。
+// runtime 自行失败（runtime.failed）时返回错误文本与 false，调用方以非零码退出。
+func waitSignal(rt *hubruntime.Runtime, failed <-chan string) (string, bool) {
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	<-ch
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case sig := <-ch:
+			if sig == syscall.SIGHUP {
+				reloadConfig(rt)
+				continue
+			}
+			return "", true
+		case msg := <-failed:
+			return msg, false
+		}
+	}
+}
+
+// reloadConfig 重新读取持久配置文件并输出生效 / 需重启的键。
+func reloadConfig(rt *hubruntime.Runtime) {
+	res, err := rt.ReloadConfig()
+	if err != nil {
+		slog.Error("reload config failed", "err", err)
+		return
+	}
+	slog.Info("config reloaded", "changed", res.Changed, "applied", res.Applied, "restart_required", res.RestartRequired)
 }
 
 // captureFlagOverrides 把命令行明确传入的 key 标记成显式覆盖项。
@@ -114,6 +175,10 @@ func captureFlagOverrides(opts *hubruntime.Options) {
 			opts.AddConfigOverrideKeys("addr")
 		case "parent", "parent-endpoint":
 			opts.AddConfigOverrideKeys(coreconfig.KeyParentAddr)
+		case "parent-endpoints":
+			opts.AddConfigOverrideKeys("parent.endpoints")
+		case "parent-transport-prefer":
+			opts.AddConfigOverrideKeys("parent.transport_prefer")
 		case "parent-enable":
 			opts.AddConfigOverrideKeys(coreconfig.KeyParentEnable)
 		case "parent-reconnect":
@@ -140,6 +205,8 @@ func captureFlagOverrides(opts *hubruntime.Options) {
 			opts.AddConfigOverrideKeys(coreconfig.KeyAuthNodeRoles)
 		case "auth-role-perms":
 			opts.AddConfigOverrideKeys(coreconfig.KeyAuthRolePerms)
+		case "log-level":
+			opts.AddConfigOverrideKeys("log.level")
 		}
 	})
 }
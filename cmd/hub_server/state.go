@@ -0,0 +1,134 @@
+package main
+
+// 本文件提供 Server 中与 `state` 子命令相关的命令入口。
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+)
+
+const stateUsage = `usage:
+  hub_server state migrate [flags]
+  hub_server state migrate -kind flow|varstore|run_archive -from BACKEND -to BACKEND [flags]`
+
+// runState 分发 `hub_server state <subcommand>`。
+func runState(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, stateUsage)
+		return 2
+	}
+	switch args[0] {
+	case "migrate":
+		return runStateMigrate(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, stateUsage)
+		return 2
+	}
+}
+
+// runStateMigrate 按 hub 启动时的配置口径连接 pg 状态后端，把 schema 迁移到最新版本。
+// -dry-run 只列出各迁移的状态与待执行的语句，不做改动。
+// 给出 -kind 时改为在两个状态后端之间搬迁数据（见 runStateDataMigrate）。
+func runStateMigrate(args []string) int {
+	opts := hubruntime.DefaultOptionsFromEnv()
+	fs := flag.NewFlagSet("state migrate", flag.ContinueOnError)
+	fs.StringVar(&opts.WorkDir, "workdir", opts.WorkDir, "working directory for relative paths")
+	fs.StringVar(&opts.ConfigFile, "config", opts.ConfigFile, "config file")
+	fs.BoolVar(&opts.ConfigFileReadOnly, "config-readonly", opts.ConfigFileReadOnly, "treat -config as a read-only layer")
+	dryRun := fs.Bool("dry-run", false, "report pending migrations and their statements without applying them")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	timeout := fs.Duration("timeout", 60*time.Second, "overall timeout, including waiting for the migration lock")
+	kind := fs.String("kind", "", "migrate data of this kind between backends instead of the pg schema: flow|varstore|run_archive")
+	from := fs.String("from", "", "source backend for -kind")
+	to := fs.String("to", "", "destination backend for -kind")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *kind != "" || *from != "" || *to != "" {
+		if *kind == "" || *from == "" || *to == "" {
+			fmt.Fprintln(os.Stderr, "state migrate: -kind, -from and -to must be given together")
+			return 2
+		}
+		return runStateDataMigrate(opts, *kind, *from, *to, *dryRun, *asJSON, *timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	migrations, err := hubruntime.MigrateState(ctx, opts, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "state migrate:", err)
+		return 1
+	}
+	if *asJSON {
+		printJSON(migrations)
+		return 0
+	}
+	printStateMigrations(os.Stdout, migrations)
+	return 0
+}
+
+// printStateMigrations 以表格输出每条迁移的状态；dry-run 下待执行迁移的语句缩进列在其后。
+func printStateMigrations(w io.Writer, migrations []defaultset.StateMigration) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED_AT")
+	for _, m := range migrations {
+		at := "-"
+		if !m.AppliedAt.IsZero() {
+			at = m.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", m.Version, m.Name, m.State, at)
+	}
+	_ = tw.Flush()
+	for _, m := range migrations {
+		for _, stmt := range m.Statements {
+			fmt.Fprintf(w, "\n-- %d %s\n%s;\n", m.Version, m.Name, strings.TrimSpace(stmt))
+		}
+	}
+}
+
+// runStateDataMigrate 把 kind 类数据从 from 后端复制到 to 后端并核对数量与校验和；中断后以相同参数重跑即可续传。
+// -dry-run 只统计待写入与可跳过的记录，不写目标端。
+func runStateDataMigrate(opts hubruntime.Options, kind, from, to string, dryRun, asJSON bool, timeout time.Duration) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	result, err := hubruntime.MigrateStateData(ctx, opts, kind, from, to, dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "state migrate:", err)
+		if result.Copied > 0 {
+			fmt.Fprintf(os.Stderr, "state migrate: %d records written before the failure; rerun the same command to resume\n", result.Copied)
+		}
+		return 1
+	}
+	if asJSON {
+		printJSON(result)
+		return 0
+	}
+	printStateDataMigration(os.Stdout, result)
+	return 0
+}
+
+// printStateDataMigration 以表格输出一次数据搬迁的计数，校验和单独一行。
+func printStateDataMigration(w io.Writer, m defaultset.StateDataMigration) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tFROM\tTO\tSOURCE\tCOPIED\tSKIPPED\tEXTRA\tVERIFIED")
+	verified := strconv.Itoa(m.Verified)
+	if m.DryRun {
+		verified = "-"
+	}
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", m.Kind, m.From, m.To, m.Source, m.Copied, m.Skipped, m.Extra, verified)
+	_ = tw.Flush()
+	fmt.Fprintf(w, "checksum: %s\n", m.Checksum)
+	if m.DryRun {
+		fmt.Fprintln(w, "dry run: nothing was written")
+	}
+}
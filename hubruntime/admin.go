@@ -0,0 +1,200 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `admin` 相关的逻辑。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// adminReadHeaderTimeout 限制 admin 请求头读取时间，避免慢连接长期占用。
+const adminReadHeaderTimeout = 5 * time.Second
+
+// adminServer 是可选的 HTTP 管理监听（/metrics、/healthz、/readyz、/config/*），与 hub 数据面 listener 完全独立。
+// admin 监听没有鉴权，只提供只读接口；配置的修改与回滚只经 management 子协议进行。
+type adminServer struct {
+	srv  *http.Server
+	ln   net.Listener
+	done chan struct{}
+}
+
+// startAdminServer 同步绑定地址（便于启动期直接暴露端口冲突），随后在后台提供服务。
+func startAdminServer(addr string, handler http.Handler, log *slog.Logger) (*adminServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("admin listen: %w", err)
+	}
+	a := &adminServer{
+		srv: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: adminReadHeaderTimeout,
+		},
+		ln:   ln,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		if err := a.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warn("admin server exited", "addr", ln.Addr().String(), "err", err)
+		}
+	}()
+	log.Info("admin server started", "addr", ln.Addr().String())
+	return a, nil
+}
+
+// Addr 返回实际绑定的地址（配置为 :0 时可据此得到随机端口）。
+func (a *adminServer) Addr() string {
+	if a == nil {
+		return ""
+	}
+	return a.ln.Addr().String()
+}
+
+// Close 优雅关闭 admin server，ctx 到期后强制断开。
+func (a *adminServer) Close(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	err := a.srv.Shutdown(ctx)
+	if err != nil {
+		_ = a.srv.Close()
+	}
+	<-a.done
+	return err
+}
+
+// adminHandler 组装 admin 路由。
+func (r *Runtime) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", r.serveMetrics)
+	mux.HandleFunc("/healthz", r.serveHealthz)
+	mux.HandleFunc("/readyz", r.serveReadyz)
+	mux.HandleFunc("/config/explain", loopbackOnly(r.serveConfigExplain))
+	mux.HandleFunc("/config/history", loopbackOnly(r.serveConfigHistory))
+	return mux
+}
+
+// loopbackOnly 只允许本机回环地址访问：配置解释与历史会暴露部署细节，
+// admin 监听为了 /metrics、探针常绑定在非回环地址上，这些接口不随之对外。
+func loopbackOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			writeAdminJSON(w, http.StatusForbidden, map[string]any{"error": "config endpoints are only served to loopback clients"})
+			return
+		}
+		next(w, req)
+	}
+}
+
+// serveHealthz 是存活探针：runtime 处于运行状态即返回 200，不检查依赖。
+func (r *Runtime) serveHealthz(w http.ResponseWriter, req *http.Request) {
+	if !allowReadMethod(w, req) {
+		return
+	}
+	r.mu.Lock()
+	running := r.srv != nil
+	r.mu.Unlock()
+	if !running {
+		writeAdminJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "stopped"})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// serveReadyz 是就绪探针：Readiness 未满足时返回 503 并列出原因。
+func (r *Runtime) serveReadyz(w http.ResponseWriter, req *http.Request) {
+	if !allowReadMethod(w, req) {
+		return
+	}
+	rd := r.Readiness()
+	status := http.StatusOK
+	if !rd.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	reasons := rd.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	writeAdminJSON(w, status, map[string]any{"ready": rd.Ready, "reasons": reasons})
+}
+
+// serveMetrics 以 OpenMetrics 文本格式输出 Runtime.Metrics 快照。
+func (r *Runtime) serveMetrics(w http.ResponseWriter, req *http.Request) {
+	if !allowReadMethod(w, req) {
+		return
+	}
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, r.Metrics()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", OpenMetricsContentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// serveConfigExplain 输出配置来源解释：`?key=a&key=b` 指定键，或 `?prefix=parent.` 按前缀过滤。
+func (r *Runtime) serveConfigExplain(w http.ResponseWriter, req *http.Request) {
+	if !allowReadMethod(w, req) {
+		return
+	}
+	q := req.URL.Query()
+	items, err := r.ExplainConfig(q["key"], q.Get("prefix"))
+	if err != nil {
+		writeAdminJSON(w, http.StatusServiceUnavailable, map[string]any{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// serveConfigHistory 输出持久配置变更历史（从新到旧）：`?key=` 按键过滤，`?limit=` 限制条数。
+func (r *Runtime) serveConfigHistory(w http.ResponseWriter, req *http.Request) {
+	if !allowReadMethod(w, req) {
+		return
+	}
+	q := req.URL.Query()
+	limit := 0
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	revisions, err := r.ConfigHistory(q.Get("key"), limit)
+	if err != nil {
+		writeAdminJSON(w, http.StatusServiceUnavailable, map[string]any{"error": err.Error()})
+		return
+	}
+	if revisions == nil {
+		revisions = []mgmtproto.ConfigRevision{}
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]any{"revisions": revisions})
+}
+
+// allowReadMethod 只放行 GET / HEAD，其余方法返回 405。
+func allowReadMethod(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,158 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_actions` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/subproto/kit"
+	"github.com/yttydcs/myflowhub-server/modules"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// configActionsDispatcher 在注册 management handler 时套上 configActionsHandler。
+type configActionsDispatcher struct {
+	inner modules.Dispatcher
+	cfg   *layeredConfig
+}
+
+func (d configActionsDispatcher) RegisterHandler(h core.ISubProcess) error {
+	if h != nil && h.SubProto() == mgmtproto.SubProtoManagement {
+		h = &configActionsHandler{ISubProcess: h, cfg: d.cfg}
+	}
+	return d.inner.RegisterHandler(h)
+}
+
+func (d configActionsDispatcher) RegisterDefaultHandler(h core.ISubProcess) {
+	d.inner.RegisterDefaultHandler(h)
+}
+
+// configActionsHandler 在本地应答 runtime 提供的配置 action（config_schema / config_explain / config_history / config_rollback），
+// 并接管：
+//   - Secret 键的 config_get，使响应中不出现明文；
+//   - config_set，以便在变更历史中记录来源节点（语义与 management 的 config_set 一致）。
+//
+// 发往其他节点的请求与其余 action 仍交给 management handler。
+type configActionsHandler struct {
+	core.ISubProcess
+	cfg *layeredConfig
+}
+
+func (h *configActionsHandler) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	srv := core.ServerFromContext(ctx)
+	if srv == nil || hdr == nil || (hdr.TargetID() != 0 && hdr.TargetID() != srv.NodeID()) {
+		h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+		return
+	}
+	switch frameAction(mgmtproto.SubProtoManagement, payload) {
+	case mgmtproto.ActionConfigSchema:
+		var req mgmtproto.ConfigSchemaReq
+		decodeActionData(payload, &req)
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigSchemaResp,
+			mgmtproto.ConfigSchemaResp{Code: 1, Msg: "ok", Keys: ConfigSchema(req.Prefix)})
+	case mgmtproto.ActionConfigExplain:
+		var req mgmtproto.ConfigExplainReq
+		decodeActionData(payload, &req)
+		if h.cfg == nil {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigExplainResp,
+				mgmtproto.ConfigExplainResp{Code: 500, Msg: "config unavailable"})
+			return
+		}
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigExplainResp,
+			mgmtproto.ConfigExplainResp{Code: 1, Msg: "ok", Items: h.cfg.ExplainKeys(req.Keys, req.Prefix)})
+	case mgmtproto.ActionConfigGet:
+		var req mgmtproto.ConfigGetReq
+		decodeActionData(payload, &req)
+		key := strings.TrimSpace(req.Key)
+		if h.cfg == nil || !isSecretConfigKey(key) {
+			h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+			return
+		}
+		val, ok := h.cfg.Get(key)
+		if !ok {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigGetResp,
+				mgmtproto.ConfigResp{Code: 404, Msg: "not found", Key: key})
+			return
+		}
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigGetResp,
+			mgmtproto.ConfigResp{Code: 1, Msg: "ok", Key: key, Value: redactConfigValue(key, val)})
+	case mgmtproto.ActionConfigSet:
+		var req mgmtproto.ConfigSetReq
+		decodeActionData(payload, &req)
+		key := strings.TrimSpace(req.Key)
+		if h.cfg == nil {
+			h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+			return
+		}
+		if key == "" {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigSetResp,
+				mgmtproto.ConfigResp{Code: 400, Msg: "invalid key"})
+			return
+		}
+		if err := h.cfg.SetPersistentBy(key, req.Value, hdr.SourceID()); err != nil {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigSetResp,
+				mgmtproto.ConfigResp{Code: 500, Msg: err.Error(), Key: key})
+			return
+		}
+		// 回显原文：Secret 键写入成功意味着 Value 是引用而不是明文。
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigSetResp,
+			mgmtproto.ConfigResp{Code: 1, Msg: "ok", Key: key, Value: req.Value})
+	case mgmtproto.ActionConfigHistory:
+		var req mgmtproto.ConfigHistoryReq
+		decodeActionData(payload, &req)
+		if h.cfg == nil {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigHistoryResp,
+				mgmtproto.ConfigHistoryResp{Code: 500, Msg: "config unavailable"})
+			return
+		}
+		revisions, err := h.cfg.History(req.Key, req.Limit)
+		if err != nil {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigHistoryResp,
+				mgmtproto.ConfigHistoryResp{Code: 500, Msg: err.Error()})
+			return
+		}
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigHistoryResp,
+			mgmtproto.ConfigHistoryResp{Code: 1, Msg: "ok", Revisions: revisions})
+	case mgmtproto.ActionConfigRollback:
+		var req mgmtproto.ConfigRollbackReq
+		decodeActionData(payload, &req)
+		if h.cfg == nil {
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigRollbackResp,
+				mgmtproto.ConfigRollbackResp{Code: 500, Msg: "config unavailable", Rev: req.Rev})
+			return
+		}
+		keys, err := h.cfg.Rollback(req.Rev, hdr.SourceID())
+		if err != nil {
+			code := 500
+			if errors.Is(err, errUnknownConfigRevision) {
+				code = 404
+			}
+			sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigRollbackResp,
+				mgmtproto.ConfigRollbackResp{Code: code, Msg: err.Error(), Rev: req.Rev, Keys: keys})
+			return
+		}
+		sendConfigActionResp(ctx, conn, hdr, mgmtproto.ActionConfigRollbackResp,
+			mgmtproto.ConfigRollbackResp{Code: 1, Msg: "ok", Rev: req.Rev, Keys: keys})
+	default:
+		h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+	}
+}
+
+// decodeActionData 解析 `{"action","data"}` 中的 data；缺省或非法时保持 v 的零值。
+func decodeActionData(payload []byte, v any) {
+	var msg mgmtproto.Message
+	if err := json.Unmarshal(payload, &msg); err != nil || len(msg.Data) == 0 {
+		return
+	}
+	_ = json.Unmarshal(msg.Data, v)
+}
+
+func sendConfigActionResp(ctx context.Context, conn core.IConnection, hdr core.IHeader, action string, data any) {
+	raw, _ := json.Marshal(data)
+	body, _ := json.Marshal(mgmtproto.Message{Action: action, Data: raw})
+	kit.SendResponse(ctx, nil, conn, hdr, body, mgmtproto.SubProtoManagement)
+}
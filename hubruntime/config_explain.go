@@ -0,0 +1,113 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_explain` 相关的逻辑。
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// Explain 返回键在各层的取值与胜出层。
+// 只出现在 effective 中的键（Core 在构造配置时补齐的默认值）记为 default 层；Secret 键的取值一律脱敏。
+func (c *layeredConfig) Explain(key string) mgmtproto.ConfigExplainItem {
+	key = strings.TrimSpace(key)
+	item := mgmtproto.ConfigExplainItem{Key: key}
+	if c == nil || key == "" {
+		return item
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.explainLocked(&item)
+	return item
+}
+
+// ExplainKeys 解释指定的键；keys 为空时解释任一层出现过、且以 prefix 开头的全部键（按键排序）。
+func (c *layeredConfig) ExplainKeys(keys []string, prefix string) []mgmtproto.ConfigExplainItem {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(keys) == 0 {
+		keys = c.layerKeysLocked(strings.TrimSpace(prefix))
+	}
+	out := make([]mgmtproto.ConfigExplainItem, 0, len(keys))
+	for _, key := range keys {
+		item := mgmtproto.ConfigExplainItem{Key: strings.TrimSpace(key)}
+		c.explainLocked(&item)
+		out = append(out, item)
+	}
+	return out
+}
+
+func (c *layeredConfig) explainLocked(item *mgmtproto.ConfigExplainItem) {
+	layers := []struct {
+		name string
+		path string
+		data map[string]string
+	}{
+		{mgmtproto.ConfigLayerDefault, "", c.defaults},
+		{mgmtproto.ConfigLayerFile, c.filePath, c.file},
+		{mgmtproto.ConfigLayerPersistent, c.path, c.persistent},
+		{mgmtproto.ConfigLayerExplicit, "", c.explicit},
+		{mgmtproto.ConfigLayerRuntime, "", c.runtime},
+	}
+	for _, layer := range layers {
+		if val, ok := layer.data[item.Key]; ok {
+			item.Layers = append(item.Layers, mgmtproto.ConfigLayerValue{Layer: layer.name, Value: redactConfigValue(item.Key, val), Path: layer.path})
+			item.Source = layer.name
+		}
+	}
+	val, ok := c.effective[item.Key]
+	if !ok {
+		return
+	}
+	item.Found = true
+	item.Value = redactConfigValue(item.Key, val)
+	if item.Source == "" {
+		item.Source = mgmtproto.ConfigLayerDefault
+		item.Layers = append(item.Layers, mgmtproto.ConfigLayerValue{Layer: mgmtproto.ConfigLayerDefault, Value: item.Value})
+	}
+}
+
+func (c *layeredConfig) layerKeysLocked(prefix string) []string {
+	seen := make(map[string]struct{})
+	for _, m := range []map[string]string{c.effective, c.defaults, c.file, c.persistent, c.explicit, c.runtime} {
+		for key := range m {
+			if strings.HasPrefix(key, prefix) {
+				seen[key] = struct{}{}
+			}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExplainConfig 解释运行中 runtime 的配置来源（含 runtime overlay）。
+func (r *Runtime) ExplainConfig(keys []string, prefix string) ([]mgmtproto.ConfigExplainItem, error) {
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if cfg == nil {
+		return nil, errors.New("runtime not started")
+	}
+	return cfg.ExplainKeys(keys, prefix), nil
+}
+
+// ExplainConfig 按 Start 的口径（默认值、配置文件、持久配置、opts 中的显式覆盖）离线构造配置并解释来源，
+// 不启动 runtime，也没有 runtime overlay。供 CLI 在 hub 未运行或未开启 admin 监听时排查。
+func ExplainConfig(opts Options, keys []string, prefix string) ([]mgmtproto.ConfigExplainItem, error) {
+	opts.Normalize()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ExplainKeys(keys, prefix), nil
+}
@@ -0,0 +1,68 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_explain` 相关的行为。
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestLayeredConfigExplain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hub.yaml"), []byte("parent:\n  addr: tcp://file:9000\nnode:\n  display_name: File Hub\n"), 0o600); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.WorkDir = dir
+	opts.ConfigFile = "hub.yaml"
+	opts.ConfigFileReadOnly = true
+	opts.ParentAddr = "env-parent:9000"
+	opts.AddConfigOverrideKeys(coreconfig.KeyParentAddr)
+	opts.Normalize()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	if err := cfg.SetPersistent(coreconfig.KeyParentAddr, "tcp://set:9000"); err != nil {
+		t.Fatalf("SetPersistent: %v", err)
+	}
+	cfg.Set("node.display_name", "Runtime Hub")
+
+	// config_set 写入持久层，但被显式层（env / flag）遮蔽。
+	item := cfg.Explain(coreconfig.KeyParentAddr)
+	if !item.Found || item.Value != "env-parent:9000" || item.Source != mgmtproto.ConfigLayerExplicit {
+		t.Fatalf("unexpected explain: %+v", item)
+	}
+	wantLayers := []string{mgmtproto.ConfigLayerDefault, mgmtproto.ConfigLayerFile, mgmtproto.ConfigLayerPersistent, mgmtproto.ConfigLayerExplicit}
+	if len(item.Layers) != len(wantLayers) {
+		t.Fatalf("layers=%+v", item.Layers)
+	}
+	for i, layer := range item.Layers {
+		if layer.Layer != wantLayers[i] {
+			t.Fatalf("layer[%d]=%s want %s", i, layer.Layer, wantLayers[i])
+		}
+	}
+	if item.Layers[1].Path != filepath.Join(dir, "hub.yaml") || item.Layers[2].Value != "tcp://set:9000" {
+		t.Fatalf("unexpected layer detail: %+v", item.Layers)
+	}
+
+	if got := cfg.Explain("node.display_name"); got.Source != mgmtproto.ConfigLayerRuntime || got.Value != "Runtime Hub" {
+		t.Fatalf("runtime overlay explain: %+v", got)
+	}
+	// Core 补齐的默认值只出现在 effective 中。
+	if got := cfg.Explain(coreconfig.KeySendEnqueueTimeoutMS); got.Source != mgmtproto.ConfigLayerDefault || got.Value != "100" || len(got.Layers) != 1 {
+		t.Fatalf("core default explain: %+v", got)
+	}
+	if got := cfg.Explain("no.such_key"); got.Found || got.Source != "" {
+		t.Fatalf("missing key explain: %+v", got)
+	}
+
+	items := cfg.ExplainKeys(nil, "node.")
+	if len(items) != 1 || items[0].Key != "node.display_name" {
+		t.Fatalf("prefix explain: %+v", items)
+	}
+}
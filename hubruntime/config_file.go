@@ -0,0 +1,279 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_file` 相关的逻辑。
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件格式，按扩展名识别；无法识别的扩展名按 JSON 处理（兼容 runtime_config.json）。
+const (
+	configFormatJSON = "json"
+	configFormatYAML = "yaml"
+	configFormatTOML = "toml"
+)
+
+func configFileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return configFormatYAML
+	case ".toml":
+		return configFormatTOML
+	default:
+		return configFormatJSON
+	}
+}
+
+// loadConfigMap 读取配置文件并展平为点分键；文件不存在或为空时返回空表。
+// 值保持原文（不做 ${...} 插值），以便写回时不把环境变量或密钥文件内容落盘。
+func loadConfigMap(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return make(map[string]string), nil
+	}
+	tree := make(map[string]any)
+	switch configFileFormat(path) {
+	case configFormatYAML:
+		err = yaml.Unmarshal(raw, &tree)
+	case configFormatTOML:
+		err = toml.Unmarshal(raw, &tree)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		err = dec.Decode(&tree)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	out := make(map[string]string)
+	if err := flattenConfigTree("", tree, out); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// flattenConfigTree 把嵌套节展平为点分键：`state: {pg: {dsn: x}}` 与 `state.pg.dsn: x` 等价。
+// 列表按逗号拼接；同一键被嵌套与点分两种写法同时给出时报错。
+func flattenConfigTree(prefix string, node any, out map[string]string) error {
+	if m, ok := node.(map[string]any); ok {
+		for k, v := range m {
+			key := strings.TrimSpace(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := flattenConfigTree(key, v, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if prefix == "" {
+		return errors.New("top level must be a map")
+	}
+	val, err := configScalarString(node)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", prefix, err)
+	}
+	if _, dup := out[prefix]; dup {
+		return fmt.Errorf("key %s defined more than once", prefix)
+	}
+	out[prefix] = val
+	return nil
+}
+
+func configScalarString(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case int:
+		return strconv.Itoa(x), nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case uint64:
+		return strconv.FormatUint(x, 10), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case json.Number:
+		return x.String(), nil
+	case time.Time:
+		return x.Format(time.RFC3339), nil
+	case []any:
+		items := make([]string, 0, len(x))
+		for _, item := range x {
+			s, err := configScalarString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// saveConfigMap 按文件格式原子写回：JSON 保持扁平键（与历史 runtime_config.json 一致），
+// YAML / TOML 还原为嵌套节。写回会丢失原文件中的注释与排版。
+func saveConfigMap(path string, data map[string]string) error {
+	dir := filepath.Dir(path)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	var (
+		raw []byte
+		err error
+	)
+	switch configFileFormat(path) {
+	case configFormatYAML, configFormatTOML:
+		tree, terr := nestConfigMap(data)
+		if terr != nil {
+			return terr
+		}
+		if configFileFormat(path) == configFormatYAML {
+			raw, err = yaml.Marshal(tree)
+		} else {
+			var buf bytes.Buffer
+			err = toml.NewEncoder(&buf).Encode(tree)
+			raw = buf.Bytes()
+		}
+	default:
+		raw, err = json.MarshalIndent(data, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, raw, 0o600)
+}
+
+// nestConfigMap 是 flattenConfigTree 的逆过程；某个键同时是叶子与节时无法表示，返回错误。
+func nestConfigMap(data map[string]string) (map[string]any, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	root := make(map[string]any)
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		node := root
+		for i, part := range parts[:len(parts)-1] {
+			next, exists := node[part]
+			if !exists {
+				child := make(map[string]any)
+				node[part] = child
+				node = child
+				continue
+			}
+			child, ok := next.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("config key %s conflicts with %s", key, strings.Join(parts[:i+1], "."))
+			}
+			node = child
+		}
+		leaf := parts[len(parts)-1]
+		if _, exists := node[leaf]; exists {
+			return nil, fmt.Errorf("config key %s conflicts with a nested section", key)
+		}
+		node[leaf] = data[key]
+	}
+	return root, nil
+}
+
+// interpolateConfigMap 展开每个值中的 `${ENV_VAR}` 与 `${file:path}`，返回新表；原表不变。
+// 相对的 file 路径以配置文件所在目录为根。
+func interpolateConfigMap(data map[string]string, baseDir string) (map[string]string, error) {
+	out := make(map[string]string, len(data))
+	for key, val := range data {
+		expanded, err := expandConfigEntry(key, val, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", key, err)
+		}
+		out[key] = expanded
+	}
+	return out, nil
+}
+
+// expandConfigValue 展开单个值：
+//   - `${NAME}`：环境变量，未设置时报错（避免把空串当成有效配置）；
+//   - `${file:path}`：文件内容，去掉末尾换行，适配 Docker / Kubernetes secret 挂载；
+//   - `$${`：字面量 `${`。
+func expandConfigValue(val, baseDir string) (string, error) {
+	if !strings.Contains(val, "${") {
+		return val, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(val, "${")
+		if i < 0 {
+			b.WriteString(val)
+			return b.String(), nil
+		}
+		if i > 0 && val[i-1] == '$' {
+			b.WriteString(val[:i-1])
+			b.WriteString("${")
+			val = val[i+2:]
+			continue
+		}
+		end := strings.IndexByte(val[i:], '}')
+		if end < 0 {
+			return "", errors.New("unterminated ${")
+		}
+		b.WriteString(val[:i])
+		ref := strings.TrimSpace(val[i+2 : i+end])
+		resolved, err := resolveConfigRef(ref, baseDir)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(resolved)
+		val = val[i+end+1:]
+	}
+}
+
+func resolveConfigRef(ref, baseDir string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return "", errors.New("empty ${file:} path")
+		}
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", path, err)
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	}
+	if ref == "" {
+		return "", errors.New("empty ${} reference")
+	}
+	val, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return val, nil
+}
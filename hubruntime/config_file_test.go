@@ -0,0 +1,173 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_file` 相关的行为。
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestLoadConfigMapFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hub.yaml": "state:\n  pg:\n    dsn: postgres://x\nparent.endpoints:\n  - tcp://a:9000\n  - tcp://b:9000\nfile:\n  max_size_bytes: 1073741824\nparent:\n  enable: true\n",
+		"hub.toml": "\"parent.endpoints\" = [\"tcp://a:9000\", \"tcp://b:9000\"]\n[state.pg]\ndsn = \"postgres://x\"\n[file]\nmax_size_bytes = 1073741824\n[parent]\nenable = true\n",
+		"hub.json": `{"state":{"pg":{"dsn":"postgres://x"}},"parent.endpoints":["tcp://a:9000","tcp://b:9000"],"file":{"max_size_bytes":1073741824},"parent":{"enable":true}}`,
+	}
+	want := map[string]string{
+		"state.pg.dsn":        "postgres://x",
+		"parent.endpoints":    "tcp://a:9000,tcp://b:9000",
+		"file.max_size_bytes": "1073741824",
+		"parent.enable":       "true",
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		got, err := loadConfigMap(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v", name, got)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("%s: %s=%q want %q", name, k, got[k], v)
+			}
+		}
+	}
+
+	dup := filepath.Join(dir, "dup.yaml")
+	_ = os.WriteFile(dup, []byte("state.pg.dsn: a\nstate:\n  pg:\n    dsn: b\n"), 0o600)
+	if _, err := loadConfigMap(dup); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("duplicate key err=%v", err)
+	}
+}
+
+func TestExpandConfigValue(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dsn"), []byte("postgres://secret\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("HUBTEST_PG_HOST", "db.internal")
+
+	cases := []struct {
+		in, want, wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "postgres://${HUBTEST_PG_HOST}:5432/hub", want: "postgres://db.internal:5432/hub"},
+		{in: "${file:dsn}", want: "postgres://secret"},
+		{in: "${file:" + filepath.Join(dir, "dsn") + "}", want: "postgres://secret"},
+		{in: "$${HUBTEST_PG_HOST}", want: "${HUBTEST_PG_HOST}"},
+		{in: "${HUBTEST_UNSET_VAR}", wantErr: "not set"},
+		{in: "${HUBTEST_PG_HOST", wantErr: "unterminated"},
+		{in: "${file:missing}", wantErr: "missing"},
+	}
+	for _, tc := range cases {
+		got, err := expandConfigValue(tc.in, dir)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("%q: err=%v want %q", tc.in, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("%q: got %q err=%v want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestBuildConfigWithConfigFile(t *testing.T) {
+	t.Setenv("HUBTEST_DISPLAY", "From Env")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hub.yaml"), []byte("node:\n  display_name: ${HUBTEST_DISPLAY}\nprocess:\n  channel_count: 7\n"), 0o600); err != nil {
+		t.Fatalf("write yaml: %v", err)
+	}
+
+	// 只读层：位于持久层之下，config_set 写入 runtime_config.json。
+	opts := DefaultOptions()
+	opts.WorkDir = dir
+	opts.ConfigFile = "hub.yaml"
+	opts.ConfigFileReadOnly = true
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig read-only: %v", err)
+	}
+	assertConfigValue(t, cfg, "node.display_name", "From Env")
+	assertConfigValue(t, cfg, coreconfig.KeyProcChannelCount, "7")
+	if err := cfg.SetPersistent("node.display_name", "Override"); err != nil {
+		t.Fatalf("SetPersistent: %v", err)
+	}
+	assertConfigValue(t, cfg, "node.display_name", "Override")
+	assertStoredValue(t, filepath.Join(dir, runtimeConfigFile), "node.display_name", "Override")
+	if cfg.FileLayerPath() != filepath.Join(dir, "hub.yaml") {
+		t.Fatalf("file layer path=%q", cfg.FileLayerPath())
+	}
+
+	// 持久层：写回 YAML，保留运营方原文中的 ${...}；运行期写入的 ${...} 被拒绝。
+	opts.ConfigFileReadOnly = false
+	cfg, err = buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig persistent: %v", err)
+	}
+	if cfg.Path() != filepath.Join(dir, "hub.yaml") {
+		t.Fatalf("persistent path=%q", cfg.Path())
+	}
+	if err := cfg.SetPersistent("state.pg.dsn", "${HUBTEST_DISPLAY}"); err == nil || !strings.Contains(err.Error(), "operator config files") {
+		t.Fatalf("SetPersistent ${...} err=%v", err)
+	}
+	if err := cfg.SetPersistent("state.pg.dsn", "env:HUBTEST_DISPLAY"); err != nil {
+		t.Fatalf("SetPersistent: %v", err)
+	}
+	assertConfigValue(t, cfg, "state.pg.dsn", "From Env")
+	stored, err := loadConfigMap(filepath.Join(dir, "hub.yaml"))
+	if err != nil {
+		t.Fatalf("reload yaml: %v", err)
+	}
+	if stored["node.display_name"] != "${HUBTEST_DISPLAY}" || stored["state.pg.dsn"] != "env:HUBTEST_DISPLAY" || stored["process.channel_count"] != "7" {
+		t.Fatalf("unexpected yaml content: %v", stored)
+	}
+
+	opts.ConfigFile = "missing.yaml"
+	opts.ConfigFileReadOnly = true
+	if _, err := buildConfig(opts); err == nil {
+		t.Fatalf("missing read-only config file should fail")
+	}
+}
+
+func TestSetPersistentCannotReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "host-secret")
+	if err := os.WriteFile(secret, []byte("top-secret\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("HUBTEST_HOST_SECRET", "env-secret")
+	cfg, err := newLayeredConfig(filepath.Join(dir, runtimeConfigFile), configDataFromOptions(DefaultOptions()), nil)
+	if err != nil {
+		t.Fatalf("newLayeredConfig: %v", err)
+	}
+	for _, val := range []string{"${file:" + secret + "}", "${HUBTEST_HOST_SECRET}", "x-$${file:" + secret + "}"} {
+		if err := cfg.SetPersistentBy("node.display_name", val, 7); err == nil {
+			t.Fatalf("config_set %q should be rejected", val)
+		}
+		// 运行期 overlay 不做插值，原样保存。
+		cfg.Set("node.display_name", val)
+		if got, _ := cfg.Get("node.display_name"); got != val {
+			t.Fatalf("runtime set %q read back %q", val, got)
+		}
+	}
+	for _, key := range cfg.Keys() {
+		if got, _ := cfg.Get(key); strings.Contains(got, "top-secret") || strings.Contains(got, "env-secret") {
+			t.Fatalf("%s leaked %q", key, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, runtimeConfigFile)); !os.IsNotExist(err) {
+		t.Fatalf("rejected writes must not touch the persistent file: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_history` 相关的逻辑。
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+const (
+	configHistoryFile = "config/config_history.jsonl"
+	// configHistoryLimit 是历史文件保留的最大条数，超出后丢弃最旧的记录。
+	configHistoryLimit = 256
+)
+
+var errUnknownConfigRevision = errors.New("unknown config revision")
+
+// configChange 描述一次持久写入的来源，写入历史记录。
+type configChange struct {
+	source     string
+	actor      uint32
+	rollbackTo int64
+}
+
+// loadConfigHistory 读取历史文件；文件不存在时返回空。
+func loadConfigHistory(path string) ([]mgmtproto.ConfigRevision, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []mgmtproto.ConfigRevision
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	sc.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rev mgmtproto.ConfigRevision
+		if err := json.Unmarshal(text, &rev); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		out = append(out, rev)
+	}
+	return out, sc.Err()
+}
+
+// appendConfigHistory 为 entries 分配递增的 Rev 并追加到历史文件，超出上限时截掉最旧的记录。
+// 整个文件原子重写：历史条数有上限，代价可控，且不会留下半行。
+func appendConfigHistory(path string, entries []mgmtproto.ConfigRevision) error {
+	if path == "" || len(entries) == 0 {
+		return nil
+	}
+	history, err := loadConfigHistory(path)
+	if err != nil {
+		return err
+	}
+	var last int64
+	if len(history) > 0 {
+		last = history[len(history)-1].Rev
+	}
+	now := time.Now().UnixMilli()
+	for i := range entries {
+		last++
+		entries[i].Rev = last
+		entries[i].TimeMs = now
+	}
+	history = append(history, entries...)
+	if len(history) > configHistoryLimit {
+		history = history[len(history)-configHistoryLimit:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rev := range history {
+		if err := enc.Encode(rev); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0o600)
+}
+
+// filterConfigHistory 按 key 过滤，按 Rev 从新到旧返回最多 limit 条。
+func filterConfigHistory(history []mgmtproto.ConfigRevision, key string, limit int) []mgmtproto.ConfigRevision {
+	key = strings.TrimSpace(key)
+	out := make([]mgmtproto.ConfigRevision, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		if key != "" && history[i].Key != key {
+			continue
+		}
+		out = append(out, history[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// newConfigRevision 生成一条变更记录；Secret 键的明文（历史文件遗留）以脱敏值记录，避免复制到历史文件中。
+func newConfigRevision(key string, old, next *string, change configChange) mgmtproto.ConfigRevision {
+	return mgmtproto.ConfigRevision{
+		Key:        key,
+		Old:        historyValue(key, old),
+		New:        historyValue(key, next),
+		Source:     change.source,
+		Actor:      change.actor,
+		RollbackTo: change.rollbackTo,
+	}
+}
+
+func historyValue(key string, val *string) *string {
+	if val == nil {
+		return nil
+	}
+	out := *val
+	if out != "" && isSecretConfigKey(key) && !isConfigReference(out) {
+		out = mgmtproto.ConfigRedactedValue
+	}
+	return &out
+}
+
+// diffStoredRevisions 对比两份持久层原文，生成外部修改对应的变更记录（按键排序）。
+func diffStoredRevisions(prev, next map[string]string, change configChange) []mgmtproto.ConfigRevision {
+	keys := diffConfigKeys(prev, next)
+	out := make([]mgmtproto.ConfigRevision, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, newConfigRevision(key, lookupStored(prev, key), lookupStored(next, key), change))
+	}
+	return out
+}
+
+func lookupStored(data map[string]string, key string) *string {
+	val, ok := data[key]
+	if !ok {
+		return nil
+	}
+	return &val
+}
+
+// History 返回持久配置的变更历史（从新到旧）。
+func (c *layeredConfig) History(key string, limit int) ([]mgmtproto.ConfigRevision, error) {
+	if c == nil {
+		return nil, errors.New("config not initialized")
+	}
+	c.mu.RLock()
+	path := c.historyPath
+	c.mu.RUnlock()
+	if path == "" {
+		return nil, nil
+	}
+	history, err := loadConfigHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	return filterConfigHistory(history, key, limit), nil
+}
+
+// Rollback 把持久配置恢复到 rev 生效后的状态：对 rev 之后改动过的每个键，取其在 rev 之后第一次变更前的原值，
+// 经与 SetPersistent 相同的校验一次性写入持久层（每个键产生一条 rollback 记录）。
+// 任一键无法恢复（如 Secret 明文已脱敏）或落盘失败时整体不生效，不会留下回滚了一半的配置。返回实际回放的键。
+func (c *layeredConfig) Rollback(rev int64, actor uint32) ([]string, error) {
+	if c == nil {
+		return nil, errors.New("config not initialized")
+	}
+	c.mu.RLock()
+	path := c.historyPath
+	persistentPath := c.path
+	stored := cloneStringMap(c.stored)
+	c.mu.RUnlock()
+
+	history, err := loadConfigHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 || rev < history[0].Rev-1 || rev > history[len(history)-1].Rev {
+		return nil, fmt.Errorf("%w %d", errUnknownConfigRevision, rev)
+	}
+	targets := make(map[string]*string)
+	for _, entry := range history {
+		if entry.Rev <= rev {
+			continue
+		}
+		if _, seen := targets[entry.Key]; !seen {
+			targets[entry.Key] = entry.Old
+		}
+	}
+	keys := make([]string, 0, len(targets))
+	values := make(map[string]*string, len(targets))
+	for key, target := range targets {
+		cur := lookupStored(stored, key)
+		if (cur == nil) == (target == nil) && (cur == nil || *cur == *target) {
+			continue
+		}
+		if target != nil {
+			if _, err := preparePersistentValue(key, *target, filepath.Dir(persistentPath)); err != nil {
+				return nil, fmt.Errorf("rollback %s: %w", key, err)
+			}
+		}
+		keys = append(keys, key)
+		values[key] = target
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return keys, nil
+	}
+	change := configChange{source: mgmtproto.ConfigChangeRollback, actor: actor, rollbackTo: rev}
+	if err := c.writePersistentBatch(values, change); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ConfigHistory 返回运行中 runtime 的持久配置变更历史。
+func (r *Runtime) ConfigHistory(key string, limit int) ([]mgmtproto.ConfigRevision, error) {
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if cfg == nil {
+		return nil, errors.New("runtime not started")
+	}
+	return cfg.History(key, limit)
+}
+
+// RollbackConfig 把运行中 runtime 的持久配置恢复到 rev 生效后的状态，变更经热更新路径生效。
+func (r *Runtime) RollbackConfig(rev int64) ([]string, error) {
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if cfg == nil {
+		return nil, errors.New("runtime not started")
+	}
+	return cfg.Rollback(rev, 0)
+}
+
+// ConfigHistory 离线读取 WorkDir 下的变更历史。
+func ConfigHistory(opts Options, key string, limit int) ([]mgmtproto.ConfigRevision, error) {
+	opts.Normalize()
+	history, err := loadConfigHistory(workDir(opts.WorkDir).Resolve(configHistoryFile))
+	if err != nil {
+		return nil, err
+	}
+	return filterConfigHistory(history, key, limit), nil
+}
+
+// RollbackConfig 离线回滚：按 Start 的口径构造配置并改写持久文件。
+// 仅用于 hub 未运行时；运行中的 hub 应经 management config_rollback 或 admin 回滚。
+func RollbackConfig(opts Options, rev int64) ([]string, error) {
+	opts.Normalize()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Rollback(rev, 0)
+}
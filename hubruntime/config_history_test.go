@@ -0,0 +1,178 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_history` 相关的行为。
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestConfigHistoryAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.WorkDir = dir
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+
+	if err := cfg.SetPersistentBy("file.max_concurrent", "8", 42); err != nil { // rev 1
+		t.Fatalf("set: %v", err)
+	}
+	if err := cfg.SetPersistent("node.display_name", "Hub A"); err != nil { // rev 2
+		t.Fatalf("set: %v", err)
+	}
+	if err := cfg.SetPersistent("file.max_concurrent", "16"); err != nil { // rev 3
+		t.Fatalf("set: %v", err)
+	}
+	if err := cfg.SetPersistent("file.max_concurrent", "0"); err == nil {
+		t.Fatalf("invalid value should be rejected")
+	}
+
+	// 外部改动持久文件：重读时记为 reload。
+	if err := os.WriteFile(filepath.Join(dir, runtimeConfigFile), []byte(`{"file.max_concurrent":"16","node.display_name":"Edited"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := cfg.ReloadPersistent(); err != nil { // rev 4
+		t.Fatalf("ReloadPersistent: %v", err)
+	}
+
+	history, err := cfg.History("", 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 4 || history[0].Rev != 4 || history[3].Rev != 1 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	first := history[3]
+	if first.Key != "file.max_concurrent" || first.Old != nil || first.New == nil || *first.New != "8" || first.Actor != 42 || first.Source != mgmtproto.ConfigChangeSet {
+		t.Fatalf("unexpected first revision: %+v", first)
+	}
+	if history[0].Source != mgmtproto.ConfigChangeReload || history[0].Key != "node.display_name" || *history[0].Old != "Hub A" {
+		t.Fatalf("unexpected reload revision: %+v", history[0])
+	}
+	if got, _ := cfg.History("file.max_concurrent", 1); len(got) != 1 || got[0].Rev != 3 {
+		t.Fatalf("filtered history: %+v", got)
+	}
+
+	// 回到 rev 1：display_name 被删除，max_concurrent 恢复为 8。
+	keys, err := cfg.Rollback(1, 7)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "file.max_concurrent" || keys[1] != "node.display_name" {
+		t.Fatalf("rolled back keys=%v", keys)
+	}
+	assertStoredValue(t, filepath.Join(dir, runtimeConfigFile), "file.max_concurrent", "8")
+	assertStoredValue(t, filepath.Join(dir, runtimeConfigFile), "node.display_name", "")
+	history, _ = cfg.History("", 2)
+	for _, rev := range history {
+		if rev.Source != mgmtproto.ConfigChangeRollback || rev.RollbackTo != 1 || rev.Actor != 7 {
+			t.Fatalf("unexpected rollback revision: %+v", rev)
+		}
+	}
+
+	// 回到最初状态，再回到 rev 3（回滚本身也可被回滚）。
+	if _, err := cfg.Rollback(0, 0); err != nil {
+		t.Fatalf("Rollback(0): %v", err)
+	}
+	if stored, _ := loadConfigMap(filepath.Join(dir, runtimeConfigFile)); len(stored) != 0 {
+		t.Fatalf("rollback to 0 left persistent values: %v", stored)
+	}
+	if _, err := cfg.Rollback(3, 0); err != nil {
+		t.Fatalf("Rollback(3): %v", err)
+	}
+	assertConfigValue(t, cfg, "file.max_concurrent", "16")
+	assertConfigValue(t, cfg, "node.display_name", "Hub A")
+
+	if _, err := cfg.Rollback(999, 0); !errors.Is(err, errUnknownConfigRevision) {
+		t.Fatalf("unknown revision err=%v", err)
+	}
+
+	// 历史条数有上限，Rev 继续递增。
+	for i := 0; i < configHistoryLimit; i++ {
+		if err := appendConfigHistory(cfg.historyPath, []mgmtproto.ConfigRevision{{Key: "x", Source: mgmtproto.ConfigChangeSet}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	all, err := ConfigHistory(opts, "", 0)
+	if err != nil {
+		t.Fatalf("ConfigHistory: %v", err)
+	}
+	if len(all) != configHistoryLimit || all[len(all)-1].Rev <= 1 {
+		t.Fatalf("history not bounded: len=%d oldest=%d", len(all), all[len(all)-1].Rev)
+	}
+}
+
+func TestConfigRollbackAppliesAllKeysAtOnce(t *testing.T) {
+	opts := DefaultOptions()
+	opts.WorkDir = t.TempDir()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	for _, kv := range [][2]string{{"file.max_concurrent", "8"}, {"node.display_name", "Hub A"}, {"file.max_concurrent", "16"}} { // rev 1..3
+		if err := cfg.SetPersistent(kv[0], kv[1]); err != nil {
+			t.Fatalf("set %s: %v", kv[0], err)
+		}
+	}
+
+	var calls [][]string
+	cfg.SetChangeHook(func(keys []string) { calls = append(calls, keys) })
+	keys, err := cfg.Rollback(0, 0)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Rollback keys=%v err=%v", keys, err)
+	}
+	// 两个键在同一次写入中生效：变更回调只触发一次，且不存在只回滚了一个键的中间视图。
+	if len(calls) != 1 || len(calls[0]) != 2 {
+		t.Fatalf("change hook calls=%v, want one call with both keys", calls)
+	}
+	if history, _ := cfg.History("", 2); len(history) != 2 || history[0].Source != mgmtproto.ConfigChangeRollback || history[1].Source != mgmtproto.ConfigChangeRollback {
+		t.Fatalf("rollback revisions=%+v", history)
+	}
+}
+
+func TestAdminConfigEndpointsAreReadOnlyAndLoopbackOnly(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.WorkDir = dir
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	if err := cfg.SetPersistent("node.display_name", "Hub A"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	rt := &Runtime{opts: opts, cfg: cfg}
+	serve := func(method, target, remote string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		rt.adminHandler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, path := range []string{"/config/history", "/config/explain?key=node.display_name"} {
+		if code := serve(http.MethodGet, path, "192.0.2.10:40000"); code != http.StatusForbidden {
+			t.Fatalf("remote %s=%d want 403", path, code)
+		}
+		for _, remote := range []string{"127.0.0.1:40000", "[::1]:40000"} {
+			if code := serve(http.MethodGet, path, remote); code != http.StatusOK {
+				t.Fatalf("loopback %s from %s=%d want 200", path, remote, code)
+			}
+		}
+	}
+	if code := serve(http.MethodPost, "/config/rollback?rev=0", "127.0.0.1:40000"); code != http.StatusNotFound {
+		t.Fatalf("POST /config/rollback=%d want 404", code)
+	}
+	if got, _ := cfg.Get("node.display_name"); got != "Hub A" {
+		t.Fatalf("admin HTTP must not change config, display_name=%q", got)
+	}
+}
@@ -0,0 +1,267 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_reload` 相关的逻辑。
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	"github.com/yttydcs/myflowhub-server/modules"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// configWatchInterval 是持久配置文件变更检测的轮询间隔。
+const configWatchInterval = 2 * time.Second
+
+// ConfigReloadResult 描述一次配置重载的结果。
+//   - Changed：effective 值发生变化的全部键；
+//   - Applied：已在运行期生效（推送给 handler 或 runtime 组件）的键；
+//   - RestartRequired：需要重启 runtime 才会生效的键。
+type ConfigReloadResult struct {
+	Changed         []string
+	Applied         []string
+	RestartRequired []string
+}
+
+// restartRequiredConfigPrefixes 列出整组只在启动期读取的配置前缀：
+// dispatcher / send dispatcher 的通道与 worker、路由策略、状态后端连接参数，
+// 以及在构造时读取配置、未实现 ReloadConfig 的 flow / varstore / exec handler 的各项限制。
+// 只用于 schema 未登记的键；已登记的键以 schema 中的 Reload 为准。
+var restartRequiredConfigPrefixes = []string{
+	"process.",
+	"send.",
+	"routing.",
+	"state.",
+	"flow.",
+	"varstore.",
+	"exec.",
+}
+
+// configKeyRequiresRestart 判断配置键是否只能在重启后生效。
+func configKeyRequiresRestart(key string) bool {
+	if spec, ok := lookupConfigKey(key); ok {
+		return spec.Reload == mgmtproto.ConfigReloadRestart
+	}
+	for _, prefix := range restartRequiredConfigPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig 重新读取持久配置文件，把变化的键推送给运行中的组件，并返回分类结果。
+// 运行期只能启动时读取的键不会被静默忽略，而是出现在 RestartRequired 与 Status.ConfigRestartRequired 中。
+func (r *Runtime) ReloadConfig() (ConfigReloadResult, error) {
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if cfg == nil {
+		return ConfigReloadResult{}, errors.New("runtime not started")
+	}
+	changed, err := cfg.ReloadPersistent()
+	if err != nil {
+		r.storeErr(err)
+		return ConfigReloadResult{}, err
+	}
+	applied, restart := r.classifyConfigKeys(cfg, changed)
+	return ConfigReloadResult{
+		Changed:         changed,
+		Applied:         applied,
+		RestartRequired: restart,
+	}, nil
+}
+
+// onConfigChanged 是 layeredConfig 的变更回调：热更新可生效的键，并记录需要重启的键。
+// 文件重载、SIGHUP 与 management config_set 都会经过这里。
+func (r *Runtime) onConfigChanged(keys []string) {
+	r.mu.Lock()
+	cfg := r.cfg
+	set := r.set
+	pacer := r.parentPacer
+	tracer := r.tracer
+	log := r.log
+	r.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+
+	applied, restart := r.classifyConfigKeys(cfg, keys)
+	if len(applied) > 0 {
+		if containsString(applied, "addr") {
+			r.applyListenerAddr(trimmedConfigValue(cfg, "addr"))
+		}
+		if hasLogLevelKey(applied) {
+			r.applyLogLevels(cfg)
+		}
+		if hasTraceKey(applied) {
+			r.applyTracing(tracer, cfg)
+		}
+		if pacer != nil && containsString(applied, coreconfig.KeyParentReconnectSec) {
+			pacer.SetInterval(reconnectIntervalFromConfig(cfg))
+		}
+		modules.ReloadConfig(set, cfg, applied)
+	}
+	if len(restart) > 0 {
+		log.Warn("runtime config changed, restart required to apply", "keys", restart)
+	}
+	if len(applied) > 0 {
+		log.Info("runtime config applied", "keys", applied)
+	}
+	r.emit(Event{
+		Type:            EventConfigChanged,
+		Keys:            append([]string(nil), keys...),
+		RestartRequired: restart,
+	})
+}
+
+// classifyConfigKeys 把变化的键分成运行期生效与需要重启两类。
+// parent.reconnect_sec 特殊处理：Core 父链循环在启动时固定了基础间隔，
+// runtime 只能在拨号前追加等待，因此调大可以即时生效，调小到低于启动值时需要重启。
+func (r *Runtime) classifyConfigKeys(cfg core.IConfig, keys []string) (applied, restart []string) {
+	r.mu.Lock()
+	pacer := r.parentPacer
+	r.mu.Unlock()
+	for _, key := range keys {
+		switch {
+		case key == coreconfig.KeyParentReconnectSec:
+			if pacer != nil && reconnectIntervalFromConfig(cfg) < pacer.Base() {
+				restart = append(restart, key)
+			} else {
+				applied = append(applied, key)
+			}
+		case configKeyRequiresRestart(key):
+			restart = append(restart, key)
+		default:
+			applied = append(applied, key)
+		}
+	}
+	return applied, restart
+}
+
+// pendingRestartKeys 对比启动快照与当前 effective，返回仍需重启才能生效的键。
+// 改回启动时的取值后，对应键会自动从列表中消失。
+func (r *Runtime) pendingRestartKeys() []string {
+	r.mu.Lock()
+	cfg := r.cfg
+	startSnapshot := r.startConfig
+	r.mu.Unlock()
+	if cfg == nil {
+		return nil
+	}
+	changed := diffConfigKeys(startSnapshot, snapshotConfig(cfg))
+	_, restart := r.classifyConfigKeys(cfg, changed)
+	sort.Strings(restart)
+	return restart
+}
+
+// watchConfigFile 轮询持久配置文件的修改时间与大小，变化时触发一次重载。
+// 选择轮询而不是平台文件通知，是为了在 Android / 容器挂载卷上保持一致行为。
+func (r *Runtime) watchConfigFile(ctx context.Context, path string) {
+	last := statConfigFile(path)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stamp := statConfigFile(path)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+		if _, err := r.ReloadConfig(); err != nil {
+			r.log.Warn("reload runtime config failed", "path", path, "err", err)
+		}
+	}
+}
+
+type configFileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statConfigFile(path string) configFileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileStamp{}
+	}
+	return configFileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// parentDialPacer 包装父链拨号器，让 parent.reconnect_sec 可以在运行期调大。
+// Core 的父链循环在每次失败或断开后固定 sleep 启动时的间隔，再调用拨号器；
+// pacer 在除首次外的每次拨号前补足“新间隔 - 启动间隔”的差值。
+type parentDialPacer struct {
+	base     time.Duration
+	interval atomic.Int64
+	dialed   atomic.Bool
+}
+
+func newParentDialPacer(base time.Duration) *parentDialPacer {
+	p := &parentDialPacer{base: base}
+	p.interval.Store(int64(base))
+	return p
+}
+
+// Base 返回 Core 父链循环使用的固定基础间隔。
+func (p *parentDialPacer) Base() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.base
+}
+
+// SetInterval 更新期望的重连间隔；低于基础间隔的值按基础间隔处理。
+func (p *parentDialPacer) SetInterval(d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.interval.Store(int64(d))
+}
+
+// Wrap 返回带节流的拨号函数。
+func (p *parentDialPacer) Wrap(dial func(context.Context, string) (core.IConnection, error)) func(context.Context, string) (core.IConnection, error) {
+	return func(ctx context.Context, addr string) (core.IConnection, error) {
+		if p.dialed.Swap(true) {
+			if extra := time.Duration(p.interval.Load()) - p.base; extra > 0 {
+				timer := time.NewTimer(extra)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		return dial(ctx, addr)
+	}
+}
+
+// reconnectIntervalFromConfig 按 Core 的口径解析父链重连间隔（<=0 时回退 3s）。
+func reconnectIntervalFromConfig(cfg core.IConfig) time.Duration {
+	sec := parseIntValue(trimmedConfigValue(cfg, coreconfig.KeyParentReconnectSec), 0)
+	if sec <= 0 {
+		return 3 * time.Second
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_reload` 相关的行为。
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestClassifyConfigKeys(t *testing.T) {
+	r := &Runtime{parentPacer: newParentDialPacer(3 * time.Second)}
+	cfg := coreconfig.NewMap(map[string]string{
+		coreconfig.KeyParentReconnectSec: "10",
+	})
+	applied, restart := r.classifyConfigKeys(cfg, []string{
+		"addr",
+		coreconfig.KeyAuthRolePerms,
+		coreconfig.KeyProcChannelCount,
+		coreconfig.KeyParentReconnectSec,
+		"state.pg.dsn",
+		"node.display_name",
+		"flow.max_retained_runs",
+		"varstore.max_records",
+	})
+	wantApplied := []string{"addr", coreconfig.KeyAuthRolePerms, coreconfig.KeyParentReconnectSec, "node.display_name"}
+	wantRestart := []string{coreconfig.KeyProcChannelCount, "state.pg.dsn", "flow.max_retained_runs", "varstore.max_records"}
+	if !equalStrings(applied, wantApplied) {
+		t.Fatalf("applied=%v want=%v", applied, wantApplied)
+	}
+	if !equalStrings(restart, wantRestart) {
+		t.Fatalf("restart=%v want=%v", restart, wantRestart)
+	}
+
+	cfg.Set(coreconfig.KeyParentReconnectSec, "1")
+	_, restart = r.classifyConfigKeys(cfg, []string{coreconfig.KeyParentReconnectSec})
+	if !equalStrings(restart, []string{coreconfig.KeyParentReconnectSec}) {
+		t.Fatalf("lower reconnect interval should require restart, got %v", restart)
+	}
+}
+
+func TestParentDialPacerDelaysRedialOnly(t *testing.T) {
+	pacer := newParentDialPacer(0)
+	pacer.SetInterval(50 * time.Millisecond)
+	calls := 0
+	dial := pacer.Wrap(func(context.Context, string) (core.IConnection, error) {
+		calls++
+		return nil, nil
+	})
+
+	start := time.Now()
+	_, _ = dial(context.Background(), "tcp://parent:9000")
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("first dial should not wait, took %v", elapsed)
+	}
+	start = time.Now()
+	_, _ = dial(context.Background(), "tcp://parent:9000")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("redial should wait for the extra interval, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dial(ctx, "tcp://parent:9000"); err == nil {
+		t.Fatalf("expected ctx error while pacing")
+	}
+	if calls != 2 {
+		t.Fatalf("dial calls=%d want=2", calls)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
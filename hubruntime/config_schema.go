@@ -0,0 +1,210 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_schema` 相关的逻辑。
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// errUnknownConfigKey 表示写入的键不在 schema 中。
+var errUnknownConfigKey = errors.New("unknown config key")
+
+// configSchema 是 hub 识别的全部配置键。Default 为空时取 Options / Core 投影出的默认值；
+// Reload 为 restart 的键运行期修改只记录、不生效（见 configKeyRequiresRestart）。
+var configSchema = []mgmtproto.ConfigKeySchema{
+	{Key: "addr", Type: mgmtproto.ConfigTypeString, Description: "TCP 监听地址，修改后重新绑定 listener", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "node.display_name", Type: mgmtproto.ConfigTypeString, Description: "节点展示名，随 node_info 与父链 register 上报", Reload: mgmtproto.ConfigReloadLive},
+
+	{Key: coreconfig.KeyParentEnable, Type: mgmtproto.ConfigTypeBool, Description: "是否连接父节点", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyParentAddr, Type: mgmtproto.ConfigTypeString, Description: "父节点端点（scheme://host:port）", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyParentJoinPermit, Type: mgmtproto.ConfigTypeString, Description: "向父节点注册时携带的 join permit", Secret: true, Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyParentReconnectSec, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "父链重连间隔（秒）；运行期只能调大", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyParentEndpoints, Type: mgmtproto.ConfigTypeList, Description: "有序父端点列表，可带 #priority=N", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: configKeyParentRegisterAttempts, Type: mgmtproto.ConfigTypeInt, Default: "3", Min: int64Ptr(0), Description: "无 nodeID 缓存时启动前自注册的最多尝试次数，0 表示一直重试", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: configKeyParentRegisterTimeoutMs, Type: mgmtproto.ConfigTypeInt, Default: "8000", Min: int64Ptr(100), Description: "启动前自注册单次尝试的超时（毫秒）", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: configKeyParentRegisterBackoffMs, Type: mgmtproto.ConfigTypeInt, Default: "1000", Min: int64Ptr(0), Description: "启动前自注册首次重试的等待（毫秒），之后逐次翻倍，封顶 30s", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: configKeyParentTransportPrefer, Type: mgmtproto.ConfigTypeList, Description: "同优先级端点的传输偏好，如 quic,tcp", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: coreconfig.KeyProcChannelCount, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(1), Description: "dispatcher 通道数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyProcWorkersPerChan, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(1), Description: "每个 dispatcher 通道的 worker 数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyProcChannelBuffer, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "dispatcher 通道缓冲", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyProcQueueStrategy, Type: mgmtproto.ConfigTypeEnum, Allowed: []string{"conn", "subproto", "source_target", "roundrobin"}, Description: "dispatcher 选择通道的策略", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: coreconfig.KeySendChannelCount, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(1), Description: "send dispatcher 通道数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeySendWorkersPerChan, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(1), Description: "每个 send 通道的 worker 数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeySendChannelBuffer, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "send 通道缓冲", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeySendConnBuffer, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "每连接发送缓冲", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeySendEnqueueTimeoutMS, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "发送入队超时（毫秒）", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: coreconfig.KeyRoutingForwardRemote, Type: mgmtproto.ConfigTypeBool, Description: "是否转发非本地目标的帧", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyDefaultForwardEnable, Type: mgmtproto.ConfigTypeBool, Description: "是否启用缺省转发", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyDefaultForwardTarget, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "缺省转发目标节点", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: coreconfig.KeyDefaultForwardMap, Type: mgmtproto.ConfigTypeString, Description: "按子协议的缺省转发映射", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: coreconfig.KeyAuthDefaultRole, Type: mgmtproto.ConfigTypeString, Description: "新注册节点的默认角色", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthDefaultPerms, Type: mgmtproto.ConfigTypeList, Description: "新注册节点的默认权限", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthNodeRoles, Type: mgmtproto.ConfigTypeString, Description: "节点角色映射，如 1:superadmin;2:admin", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthRolePerms, Type: mgmtproto.ConfigTypeString, Description: "角色权限映射，如 admin:p1,p2;node:p3", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthRegisterRequireApproval, Type: mgmtproto.ConfigTypeBool, Description: "注册是否需要审批", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthRegisterPendingTTLSec, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "待审批注册的保留时间（秒）", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthRegisterPermitTTLSec, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "注册 permit 的有效期（秒）", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthBootstrapFirstRegisterEnable, Type: mgmtproto.ConfigTypeBool, Description: "是否启用首个注册者引导", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthBootstrapFirstRegisterRole, Type: mgmtproto.ConfigTypeString, Description: "首个注册者获得的角色", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthBootstrapFirstRegisterDeviceID, Type: mgmtproto.ConfigTypeString, Description: "限定首个注册者的 device_id", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthBootstrapFirstRegisterPubKey, Type: mgmtproto.ConfigTypeString, Description: "限定首个注册者的公钥", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthBootstrapFirstRegisterEpoch, Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "首个注册者引导的轮次", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthNodePrivKey, Type: mgmtproto.ConfigTypeString, Description: "节点私钥（base64 DER），由 auth 启动时填充", Secret: true, Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthNodePubKey, Type: mgmtproto.ConfigTypeString, Description: "节点公钥（base64 DER），由 auth 启动时填充", Reload: mgmtproto.ConfigReloadLive},
+	{Key: coreconfig.KeyAuthTrustedNodes, Type: mgmtproto.ConfigTypeJSON, Description: "受信节点表，由 auth 从 trusted_nodes.json 填充", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "auth.disable_persist", Type: mgmtproto.ConfigTypeBool, Default: "false", Description: "为 true 时 auth 不读写 trusted_nodes.json", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "auth.authority_mode", Type: mgmtproto.ConfigTypeEnum, Default: "legacy", Allowed: []string{"legacy", "semi-central"}, Description: "authority 选择模式", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "auth.authority_policy_ttl_sec", Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "semi-central 模式下 authority lease 的有效期（秒）", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "authority.node_id", Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "显式指定的 authority 节点", Reload: mgmtproto.ConfigReloadLive},
+
+	{Key: "file.base_dir", Type: mgmtproto.ConfigTypeString, Default: "./file", Description: "file 子协议根目录", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "file.max_size_bytes", Type: mgmtproto.ConfigTypeInt, Default: "0", Min: int64Ptr(0), Description: "单文件大小上限（字节），0 表示不限", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "file.max_concurrent", Type: mgmtproto.ConfigTypeInt, Default: "4", Min: int64Ptr(1), Description: "并发传输上限", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "file.chunk_bytes", Type: mgmtproto.ConfigTypeInt, Default: "262144", Min: int64Ptr(1), Description: "传输分块大小（字节）", Reload: mgmtproto.ConfigReloadLive},
+	{Key: "file.incomplete_ttl_sec", Type: mgmtproto.ConfigTypeInt, Default: "3600", Min: int64Ptr(0), Description: "未完成传输的保留时间（秒）", Reload: mgmtproto.ConfigReloadLive},
+
+	{Key: "flow.base_dir", Type: mgmtproto.ConfigTypeString, Default: "./flows", Description: "flow 定义与 run 归档目录", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "flow.backend", Type: mgmtproto.ConfigTypeEnum, Default: "json", Allowed: []string{"json", "pg", "sqlite"}, Description: "flow 定义持久化后端", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "flow.max_retained_runs", Type: mgmtproto.ConfigTypeInt, Min: int64Ptr(0), Description: "保留的历史 run 数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "flow.run_archive.backend", Type: mgmtproto.ConfigTypeEnum, Default: "off", Allowed: []string{"off", "file", "pg", "sqlite"}, Description: "run 归档后端", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "flow.run_archive_enabled", Type: mgmtproto.ConfigTypeBool, Description: "等价于 flow.run_archive.backend=file", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "varstore.backend", Type: mgmtproto.ConfigTypeEnum, Default: "memory", Allowed: []string{"memory", "pg", "sqlite", "journal"}, Description: "varstore 持久化后端", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "varstore.journal.dir", Type: mgmtproto.ConfigTypeString, Default: "./state/varstore", Description: "varstore journal 后端的日志与快照目录，相对路径基于 workdir", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "varstore.journal.fsync", Type: mgmtproto.ConfigTypeEnum, Default: "always", Allowed: []string{"always", "interval", "off"}, Description: "varstore journal 的刷盘策略：每条记录、按间隔或交给操作系统", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "varstore.journal.fsync_interval_ms", Type: mgmtproto.ConfigTypeInt, Default: "1000", Min: int64Ptr(1), Description: "varstore.journal.fsync=interval 时的刷盘间隔（毫秒）", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "varstore.journal.compact_records", Type: mgmtproto.ConfigTypeInt, Default: "1000", Min: int64Ptr(1), Description: "varstore journal 日志累计多少条记录后压缩为快照", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: "state.pg.dsn", Type: mgmtproto.ConfigTypeString, Description: "PG 状态后端连接串", Secret: true, Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.flow_table", Type: mgmtproto.ConfigTypeString, Default: "myflowhub_flow_definitions", Description: "flow 定义表名", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.varstore_table", Type: mgmtproto.ConfigTypeString, Default: "myflowhub_varstore_records", Description: "varstore 记录表名", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.flow_run_archive_table", Type: mgmtproto.ConfigTypeString, Default: "myflowhub_flow_run_archives", Description: "run 归档表名", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.migrations_table", Type: mgmtproto.ConfigTypeString, Default: "myflowhub_schema_migrations", Description: "PG schema 版本表名", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.max_conns", Type: mgmtproto.ConfigTypeInt, Default: "8", Min: int64Ptr(1), Description: "PG 状态后端共享连接池的最大连接数", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.min_conns", Type: mgmtproto.ConfigTypeInt, Default: "0", Min: int64Ptr(0), Description: "PG 共享连接池保持的最少连接数，不得大于 state.pg.max_conns", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.max_conn_idle_ms", Type: mgmtproto.ConfigTypeInt, Default: "300000", Min: int64Ptr(0), Description: "PG 连接空闲多久后关闭（毫秒）", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.pg.max_conn_lifetime_ms", Type: mgmtproto.ConfigTypeInt, Default: "3600000", Min: int64Ptr(0), Description: "PG 连接的最长存活时间（毫秒），到期后重建", Reload: mgmtproto.ConfigReloadRestart},
+	{Key: "state.sqlite.path", Type: mgmtproto.ConfigTypeString, Default: "./state/hub.db", Description: "sqlite 状态后端文件，相对路径基于 workdir", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: "exec.cap.permission.self_bypass", Type: mgmtproto.ConfigTypeBool, Default: "true", Description: "本节点发起的 capability 调用是否跳过权限检查", Reload: mgmtproto.ConfigReloadRestart},
+
+	{Key: configKeyLogLevel, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "全局日志级别", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentRuntime, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "hubruntime 自身（父链、配置、drain 等）的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentCore, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "Core 的 server / dispatcher / 路由与 listener的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentManagement, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "management 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentAuth, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "auth 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentVarStore, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "varstore 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentTopicBus, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "topicbus 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentExec, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "exec 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentFlow, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "flow 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentFile, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "file 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentStream, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "stream 子协议的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyLogLevelPrefix + LogComponentForward, Type: mgmtproto.ConfigTypeEnum, Allowed: logLevelNames, Description: "缺省转发 handler的日志级别，为空时沿用 log.level", Reload: mgmtproto.ConfigReloadLive},
+
+	{Key: configKeyTraceExporter, Type: mgmtproto.ConfigTypeEnum, Default: TraceExporterNone, Allowed: traceExporterNames, Description: "链路追踪 span 的导出目标：none 关闭，stdout 或 file 按行写出 OTLP/JSON", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyTraceFile, Type: mgmtproto.ConfigTypeString, Default: defaultTraceFile, Description: "trace.exporter=file 时的输出文件，相对路径基于 workdir", Reload: mgmtproto.ConfigReloadLive},
+	{Key: configKeyTraceSamplePermille, Type: mgmtproto.ConfigTypeInt, Default: "1000", Min: int64Ptr(0), Max: int64Ptr(1000), Description: "按帧 TraceID 采样的千分比；各 hub 取值相同时同一条链路要么全部记录、要么全部跳过", Reload: mgmtproto.ConfigReloadLive},
+}
+
+// configSchemaIndex 按键索引 configSchema。
+var configSchemaIndex = func() map[string]int {
+	idx := make(map[string]int, len(configSchema))
+	for i, spec := range configSchema {
+		idx[spec.Key] = i
+	}
+	return idx
+}()
+
+func int64Ptr(v int64) *int64 { return &v }
+
+// lookupConfigKey 返回键的 schema。
+func lookupConfigKey(key string) (mgmtproto.ConfigKeySchema, bool) {
+	i, ok := configSchemaIndex[strings.TrimSpace(key)]
+	if !ok {
+		return mgmtproto.ConfigKeySchema{}, false
+	}
+	return configSchema[i], true
+}
+
+// ConfigSchema 返回按键排序的配置 schema 副本；prefix 非空时只保留以其开头的键。
+// 未显式声明默认值的键取 DefaultOptions 与 Core 配置补齐后的值。
+func ConfigSchema(prefix string) []mgmtproto.ConfigKeySchema {
+	prefix = strings.TrimSpace(prefix)
+	defaults := snapshotConfig(coreconfig.NewMap(configDataFromOptions(DefaultOptions())))
+	out := make([]mgmtproto.ConfigKeySchema, 0, len(configSchema))
+	for _, spec := range configSchema {
+		if !strings.HasPrefix(spec.Key, prefix) {
+			continue
+		}
+		if spec.Default == "" {
+			spec.Default = defaults[spec.Key]
+		}
+		spec.Allowed = append([]string(nil), spec.Allowed...)
+		out = append(out, spec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// ValidateConfigValue 按 schema 校验一次写入。空值总是合法（表示回落到默认值）；
+// 未登记的键返回包装了 errUnknownConfigKey 的错误。
+func ValidateConfigValue(key, val string) error {
+	key = strings.TrimSpace(key)
+	spec, ok := lookupConfigKey(key)
+	if !ok {
+		return fmt.Errorf("%w %q", errUnknownConfigKey, key)
+	}
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return nil
+	}
+	switch spec.Type {
+	case mgmtproto.ConfigTypeInt:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config %s: %q is not an integer", key, val)
+		}
+		if spec.Min != nil && n < *spec.Min {
+			return fmt.Errorf("config %s: %d is below minimum %d", key, n, *spec.Min)
+		}
+		if spec.Max != nil && n > *spec.Max {
+			return fmt.Errorf("config %s: %d is above maximum %d", key, n, *spec.Max)
+		}
+	case mgmtproto.ConfigTypeBool:
+		// 与 parseBoolValue 同口径：两种默认值下结果不同，说明取值不在可识别的真 / 假写法中。
+		if parseBoolValue(val, true) != parseBoolValue(val, false) {
+			return fmt.Errorf("config %s: %q is not a boolean", key, val)
+		}
+	case mgmtproto.ConfigTypeEnum:
+		for _, allowed := range spec.Allowed {
+			if strings.EqualFold(val, allowed) {
+				return nil
+			}
+		}
+		return fmt.Errorf("config %s: %q is not one of %s", key, val, strings.Join(spec.Allowed, "|"))
+	case mgmtproto.ConfigTypeJSON:
+		if !json.Valid([]byte(val)) {
+			return fmt.Errorf("config %s: value is not valid JSON", key)
+		}
+	}
+	return nil
+}
+
+// validateKnownConfigValue 只校验已登记的键；未登记的键（如子协议运行期写入的内部状态）放行。
+func validateKnownConfigValue(key, val string) error {
+	if err := ValidateConfigValue(key, val); err != nil && !errors.Is(err, errUnknownConfigKey) {
+		return err
+	}
+	return nil
+}
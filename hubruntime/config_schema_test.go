@@ -0,0 +1,124 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_schema` 相关的行为。
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestValidateConfigValue(t *testing.T) {
+	cases := []struct {
+		key, val string
+		wantErr  string
+	}{
+		{key: coreconfig.KeyProcChannelCount, val: "4"},
+		{key: coreconfig.KeyProcChannelCount, val: "", wantErr: ""},
+		{key: coreconfig.KeyProcChannelCount, val: "four", wantErr: "not an integer"},
+		{key: coreconfig.KeyProcChannelCount, val: "0", wantErr: "below minimum 1"},
+		{key: coreconfig.KeyParentEnable, val: "yes"},
+		{key: coreconfig.KeyParentEnable, val: "On"},
+		{key: coreconfig.KeyParentEnable, val: "off"},
+		{key: coreconfig.KeyParentEnable, val: "maybe", wantErr: "not a boolean"},
+		{key: "flow.backend", val: "PG"},
+		{key: "flow.backend", val: "mysql", wantErr: "not one of json|pg|sqlite"},
+		{key: coreconfig.KeyAuthTrustedNodes, val: "{", wantErr: "not valid JSON"},
+		{key: "node.display_name", val: "anything"},
+		{key: "node.dispaly_name", val: "typo", wantErr: "unknown config key"},
+	}
+	for _, tc := range cases {
+		err := ValidateConfigValue(tc.key, tc.val)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s=%q: unexpected error %v", tc.key, tc.val, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("%s=%q: err=%v want %q", tc.key, tc.val, err, tc.wantErr)
+		}
+	}
+	if err := ValidateConfigValue("x.y", "1"); !errors.Is(err, errUnknownConfigKey) {
+		t.Fatalf("unknown key err=%v", err)
+	}
+}
+
+func TestConfigSchemaCoversDefaults(t *testing.T) {
+	defaults := snapshotConfig(coreconfig.NewMap(configDataFromOptions(DefaultOptions())))
+	for key := range defaults {
+		if _, ok := lookupConfigKey(key); !ok {
+			t.Fatalf("default key %q missing from schema", key)
+		}
+	}
+	for _, spec := range configSchema {
+		if spec.Reload != mgmtproto.ConfigReloadLive && spec.Reload != mgmtproto.ConfigReloadRestart {
+			t.Fatalf("%s: reload=%q", spec.Key, spec.Reload)
+		}
+		if spec.Type == mgmtproto.ConfigTypeEnum && len(spec.Allowed) == 0 {
+			t.Fatalf("%s: enum without allowed values", spec.Key)
+		}
+		if def := ConfigSchema(spec.Key)[0].Default; def != "" {
+			if err := ValidateConfigValue(spec.Key, def); err != nil {
+				t.Fatalf("%s: default %q invalid: %v", spec.Key, def, err)
+			}
+		}
+	}
+
+	files := ConfigSchema("file.")
+	if len(files) == 0 || files[0].Key != "file.base_dir" || files[0].Default != "./file" {
+		t.Fatalf("unexpected file schema: %+v", files)
+	}
+	for _, spec := range files {
+		if !strings.HasPrefix(spec.Key, "file.") {
+			t.Fatalf("prefix filter leaked %q", spec.Key)
+		}
+	}
+	if got := ConfigSchema(coreconfig.KeySendEnqueueTimeoutMS); len(got) != 1 || got[0].Default != "100" {
+		t.Fatalf("core default not filled: %+v", got)
+	}
+}
+
+func TestLayeredConfigRejectsInvalidWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime_config.json")
+	cfg, err := newLayeredConfig(path, configDataFromOptions(DefaultOptions()), nil)
+	if err != nil {
+		t.Fatalf("newLayeredConfig: %v", err)
+	}
+	var rejected []string
+	cfg.SetRejectHook(func(key string, err error) { rejected = append(rejected, key) })
+
+	if err := cfg.SetPersistent(coreconfig.KeyProcChannelCount, "-1"); err == nil {
+		t.Fatalf("expected invalid value to be rejected")
+	}
+	if err := cfg.SetPersistent("no.such_key", "1"); !errors.Is(err, errUnknownConfigKey) {
+		t.Fatalf("unknown key err=%v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("rejected writes must not touch the file, stat err=%v", err)
+	}
+
+	cfg.Set(coreconfig.KeyParentEnable, "maybe")
+	assertConfigValue(t, cfg, coreconfig.KeyParentEnable, "false")
+	cfg.Set("auth.some_internal_state", "kept")
+	assertConfigValue(t, cfg, "auth.some_internal_state", "kept")
+	if len(rejected) != 1 || rejected[0] != coreconfig.KeyParentEnable {
+		t.Fatalf("rejected=%v", rejected)
+	}
+
+	if err := cfg.SetPersistent("node.display_name", "Hub"); err != nil {
+		t.Fatalf("SetPersistent: %v", err)
+	}
+	if err := saveConfigMap(path, map[string]string{"node.display_name": "Edited", "flow.backend": "mongo"}); err != nil {
+		t.Fatalf("edit file: %v", err)
+	}
+	if _, err := cfg.ReloadPersistent(); err == nil || !strings.Contains(err.Error(), "flow.backend") {
+		t.Fatalf("reload err=%v", err)
+	}
+	assertConfigValue(t, cfg, "node.display_name", "Hub")
+}
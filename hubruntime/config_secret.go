@@ -0,0 +1,110 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `config_secret` 相关的逻辑。
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// isSecretConfigKey 报告 key 是否在 schema 中标记为 Secret。
+func isSecretConfigKey(key string) bool {
+	spec, ok := lookupConfigKey(strings.TrimSpace(key))
+	return ok && spec.Secret
+}
+
+// redactConfigValue 把 Secret 键的非空取值替换为 mgmtproto.ConfigRedactedValue。
+func redactConfigValue(key, val string) string {
+	if val == "" || !isSecretConfigKey(key) {
+		return val
+	}
+	return mgmtproto.ConfigRedactedValue
+}
+
+// resolveSecretRef 解析 Secret 键的整值引用：
+//   - `file:path`：文件内容（相对路径基于 baseDir），去掉末尾换行；
+//   - `env:NAME`：环境变量，未设置时报错。
+//
+// 其余取值原样返回；非 Secret 键不做解析，避免误伤恰好以 `file:` 开头的普通值。
+func resolveSecretRef(key, val, baseDir string) (string, error) {
+	if !isSecretConfigKey(key) {
+		return val, nil
+	}
+	ref := strings.TrimSpace(val)
+	switch {
+	case strings.HasPrefix(ref, "file:"):
+		return resolveConfigRef(ref, baseDir)
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimSpace(strings.TrimPrefix(ref, "env:"))
+		if name == "" {
+			return "", errors.New("empty env: reference")
+		}
+		return resolveConfigRef(name, baseDir)
+	default:
+		return val, nil
+	}
+}
+
+// expandConfigEntry 展开单个配置项：先做 `${...}` 插值，再解析 Secret 键的 `file:` / `env:` 引用。
+func expandConfigEntry(key, val, baseDir string) (string, error) {
+	expanded, err := expandConfigValue(val, baseDir)
+	if err != nil {
+		return "", err
+	}
+	return resolveSecretRef(key, expanded, baseDir)
+}
+
+// resolveSecretConfigRefs 只解析 Secret 键的引用，用于显式层（env / flag）：
+// 这些值历来不做 `${...}` 插值，这里不改变其余键的语义。
+func resolveSecretConfigRefs(data map[string]string, baseDir string) (map[string]string, error) {
+	out := cloneStringMap(data)
+	for key, val := range data {
+		resolved, err := resolveSecretRef(key, val, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", key, err)
+		}
+		out[key] = resolved
+	}
+	return out, nil
+}
+
+// explicitConfigData 生成显式层并解析其中 Secret 键的引用；相对路径基于 WorkDir。
+func explicitConfigData(opts Options) (map[string]string, error) {
+	return resolveSecretConfigRefs(explicitConfigDataFromOptions(opts), string(workDir(strings.TrimSpace(opts.WorkDir))))
+}
+
+// checkSecretPersist 拒绝把 Secret 键的明文写入持久层：只接受引用（`${...}` / `file:` / `env:`）或空值。
+// raw 与展开结果相同即视为明文。
+func checkSecretPersist(key, raw, expanded string) error {
+	if !isSecretConfigKey(key) || strings.TrimSpace(raw) == "" || raw != expanded {
+		return nil
+	}
+	return fmt.Errorf("config %s is secret: persist a reference (file:<path> or env:<NAME>) instead of the plain value", key)
+}
+
+// isConfigReference 粗略判断原文是否为引用（`${...}` / `file:` / `env:`），不解析引用目标。
+func isConfigReference(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	return strings.Contains(raw, "${") || strings.HasPrefix(raw, "file:") || strings.HasPrefix(raw, "env:")
+}
+
+// PlaintextSecretKeys 返回持久层中以明文保存的 Secret 键（历史文件遗留），供启动时告警。
+func (c *layeredConfig) PlaintextSecretKeys() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	for key, raw := range c.stored {
+		if checkSecretPersist(key, raw, c.persistent[key]) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
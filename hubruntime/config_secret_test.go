@@ -0,0 +1,85 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `config_secret` 相关的行为。
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestSecretConfigReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config", "pg_dsn"), []byte("postgres://u:pw@db/hub\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "permit"), []byte("permit-from-file\n"), 0o600); err != nil {
+		t.Fatalf("write permit: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, runtimeConfigFile), []byte(`{"state.pg.dsn":"file:pg_dsn","node.display_name":"file:not-a-ref"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("HUBTEST_JOIN_PERMIT", "permit-from-env")
+
+	// 持久层引用相对于配置文件目录，显式层引用相对于 WorkDir；非 Secret 键不解析。
+	opts := DefaultOptions()
+	opts.WorkDir = dir
+	opts.ParentJoinPermit = "file:permit"
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	assertConfigValue(t, cfg, "state.pg.dsn", "postgres://u:pw@db/hub")
+	assertConfigValue(t, cfg, coreconfig.KeyParentJoinPermit, "permit-from-file")
+	assertConfigValue(t, cfg, "node.display_name", "file:not-a-ref")
+
+	// 明文不落盘，引用写入原文。
+	if err := cfg.SetPersistent(coreconfig.KeyParentJoinPermit, "plain-permit"); err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Fatalf("plain secret persisted: err=%v", err)
+	}
+	if err := cfg.SetPersistent("state.pg.dsn", "env:HUBTEST_JOIN_PERMIT"); err != nil {
+		t.Fatalf("SetPersistent reference: %v", err)
+	}
+	assertConfigValue(t, cfg, "state.pg.dsn", "permit-from-env")
+	assertStoredValue(t, filepath.Join(dir, runtimeConfigFile), "state.pg.dsn", "env:HUBTEST_JOIN_PERMIT")
+	if err := cfg.SetPersistent("state.pg.dsn", "env:HUBTEST_UNSET_SECRET"); err == nil {
+		t.Fatalf("unresolvable reference should be rejected")
+	}
+
+	item := cfg.Explain("state.pg.dsn")
+	if item.Value != mgmtproto.ConfigRedactedValue {
+		t.Fatalf("explain leaked secret: %+v", item)
+	}
+	for _, layer := range item.Layers {
+		if layer.Value != "" && layer.Value != mgmtproto.ConfigRedactedValue {
+			t.Fatalf("explain layer leaked secret: %+v", item.Layers)
+		}
+	}
+	if keys := cfg.PlaintextSecretKeys(); len(keys) != 0 {
+		t.Fatalf("unexpected plaintext secrets: %v", keys)
+	}
+
+	// 历史文件中的明文仍然生效，但会被识别出来。
+	if err := os.WriteFile(filepath.Join(dir, runtimeConfigFile), []byte(`{"state.pg.dsn":"postgres://legacy"}`), 0o600); err != nil {
+		t.Fatalf("write legacy config: %v", err)
+	}
+	if _, err := cfg.ReloadPersistent(); err != nil {
+		t.Fatalf("ReloadPersistent: %v", err)
+	}
+	assertConfigValue(t, cfg, "state.pg.dsn", "postgres://legacy")
+	if keys := cfg.PlaintextSecretKeys(); len(keys) != 1 || keys[0] != "state.pg.dsn" {
+		t.Fatalf("plaintext secrets=%v", keys)
+	}
+
+	opts.ParentJoinPermit = "env:HUBTEST_UNSET_SECRET"
+	if _, err := buildConfig(opts); err == nil {
+		t.Fatalf("unresolvable explicit reference should fail")
+	}
+}
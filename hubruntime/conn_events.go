@@ -0,0 +1,118 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `conn_events` 相关的逻辑。
+
+import (
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+)
+
+// eventConnManager 包装连接管理器，把连接增删与登录索引更新转换为 runtime 事件。
+// Core server 在 Start 时通过 SetHooks 安装自己的回调，这里在其后追加事件发布，
+// 因此事件中的角色（Core 为未标记的接入连接补 role=child）与 server 看到的一致。
+// parent 非空时，父连接的增删同时推送给 bootstrap 循环。
+type eventConnManager struct {
+	core.IConnectionManager
+	emit   func(Event)
+	parent *parentBootstrap
+}
+
+func (m *eventConnManager) SetHooks(h core.ConnectionHooks) {
+	onAdd, onRemove := h.OnAdd, h.OnRemove
+	m.IConnectionManager.SetHooks(core.ConnectionHooks{
+		OnAdd: func(c core.IConnection) {
+			if onAdd != nil {
+				onAdd(c)
+			}
+			m.connAdded(c)
+		},
+		OnRemove: func(c core.IConnection) {
+			if onRemove != nil {
+				onRemove(c)
+			}
+			m.connRemoved(c)
+		},
+	})
+}
+
+// UpdateNodeIndex 是 auth 在 register / login 成功后绑定节点号的入口，子连接上的调用视为子节点登录。
+func (m *eventConnManager) UpdateNodeIndex(nodeID uint32, conn core.IConnection) {
+	m.IConnectionManager.UpdateNodeIndex(nodeID, conn)
+	if conn == nil || nodeID == 0 || isParentConn(conn) {
+		return
+	}
+	m.emit(Event{Type: EventChildLoggedIn, ConnID: conn.ID(), NodeID: nodeID})
+}
+
+func (m *eventConnManager) connAdded(c core.IConnection) {
+	if c == nil {
+		return
+	}
+	if isParentConn(c) {
+		m.parent.connUp(c)
+		m.emit(Event{Type: EventParentConnected, ConnID: c.ID()})
+		return
+	}
+	m.emit(Event{Type: EventChildConnected, ConnID: c.ID(), Listener: connListenerName(c)})
+}
+
+func (m *eventConnManager) connRemoved(c core.IConnection) {
+	if c == nil {
+		return
+	}
+	if isParentConn(c) {
+		m.parent.connDown(c)
+		m.emit(Event{Type: EventParentDisconnected, ConnID: c.ID()})
+		return
+	}
+	m.emit(Event{Type: EventChildOffline, ConnID: c.ID(), NodeID: connNodeID(c), Listener: connListenerName(c)})
+}
+
+// connNodeID 读取连接 meta 中的 nodeID，兼容 auth 写入的多种整数类型。
+func connNodeID(c core.IConnection) uint32 {
+	v, ok := c.GetMeta("nodeID")
+	if !ok {
+		return 0
+	}
+	switch n := v.(type) {
+	case uint32:
+		return n
+	case uint64:
+		return uint32(n)
+	case int:
+		if n > 0 {
+			return uint32(n)
+		}
+	case int64:
+		if n > 0 {
+			return uint32(n)
+		}
+	}
+	return 0
+}
+
+// registerEventObserver 把本地 auth handler 发出的待审批 register_resp（code=202）转换为 register.pending 事件。
+type registerEventObserver struct {
+	emit func(Event)
+}
+
+func (registerEventObserver) observeInbound(core.IConnection, core.IHeader, []byte) {}
+
+func (o registerEventObserver) observeOutbound(conn core.IConnection, hdr core.IHeader, payload []byte) {
+	if conn == nil || hdr == nil || hdr.SubProto() != authproto.SubProtoAuth {
+		return
+	}
+	if frameAction(hdr.SubProto(), payload) != authproto.ActionRegisterResp {
+		return
+	}
+	if code, ok := responseCode(hdr.SubProto(), payload); !ok || code != authCodePending {
+		return
+	}
+	ev := Event{Type: EventRegisterPending, ConnID: conn.ID()}
+	if raw, ok := scanJSONField(payload, "device_id"); ok {
+		ev.DeviceID = strings.Trim(string(raw), `"`)
+	}
+	o.emit(ev)
+}
@@ -0,0 +1,284 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `drain` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/header"
+	"github.com/yttydcs/myflowhub-core/subproto/kit"
+	"github.com/yttydcs/myflowhub-server/modules"
+	fileproto "github.com/yttydcs/myflowhub-server/protocol/file"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+	streamproto "github.com/yttydcs/myflowhub-server/protocol/stream"
+	varstoreproto "github.com/yttydcs/myflowhub-server/protocol/varstore"
+)
+
+const (
+	// defaultDrainTimeout 是 Stop 的 ctx 没有截止时间时 drain 阶段的上限。
+	defaultDrainTimeout = 10 * time.Second
+	// drainHardStopShare 是 ctx 剩余时间中留给硬停止（关闭连接、回收 worker）的比例。
+	drainHardStopShare = 5
+	// drainQuietPeriod 是判定在途会话结束所需的静默时长：此期间没有会话类子协议的入站帧。
+	drainQuietPeriod  = 300 * time.Millisecond
+	drainPollInterval = 20 * time.Millisecond
+
+	drainRejectMsg = "hub draining"
+)
+
+// drainState 记录 drain 阶段的状态，并作为帧观察者记录会话类子协议（file / flow / stream / varstore）最近一次入站活动。
+type drainState struct {
+	draining atomic.Bool
+	// lastActivity 为最近一次会话帧的 UnixNano。
+	lastActivity atomic.Int64
+}
+
+func newDrainState() *drainState {
+	return &drainState{}
+}
+
+func (d *drainState) observeInbound(_ core.IConnection, hdr core.IHeader, _ []byte) {
+	if d == nil || hdr == nil {
+		return
+	}
+	switch hdr.SubProto() {
+	case fileproto.SubProtoFile, flowproto.SubProtoFlow, streamproto.SubProtoStream, varstoreproto.SubProtoVarStore:
+		d.lastActivity.Store(time.Now().UnixNano())
+	}
+}
+
+func (d *drainState) observeOutbound(core.IConnection, core.IHeader, []byte) {}
+
+func (d *drainState) isDraining() bool {
+	return d != nil && d.draining.Load()
+}
+
+// quietFor 返回自最近一次会话帧以来的时长；从未有会话帧时视为已足够安静。
+func (d *drainState) quietFor(now time.Time) time.Duration {
+	last := d.lastActivity.Load()
+	if last == 0 {
+		return drainQuietPeriod
+	}
+	return now.Sub(time.Unix(0, last))
+}
+
+// drainDispatcher 在注册 flow / file / management handler 时套上 drainHandler。
+type drainDispatcher struct {
+	inner           modules.Dispatcher
+	state           *drainState
+	onParentDrained func(core.IConnection, mgmtproto.NodeDrainingReq)
+}
+
+func (d drainDispatcher) RegisterHandler(h core.ISubProcess) error {
+	if h != nil {
+		switch h.SubProto() {
+		case flowproto.SubProtoFlow, fileproto.SubProtoFile, mgmtproto.SubProtoManagement:
+			h = &drainHandler{ISubProcess: h, state: d.state, onParentDrained: d.onParentDrained}
+		}
+	}
+	return d.inner.RegisterHandler(h)
+}
+
+func (d drainDispatcher) RegisterDefaultHandler(h core.ISubProcess) {
+	d.inner.RegisterDefaultHandler(h)
+}
+
+// drainHandler 负责 drain 相关的两类帧：
+//   - 父链上的 management node_draining：交给 runtime 切换父端点，不再进入 management handler；
+//   - drain 期间新建会话的请求（flow run、file pull / offer）：直接以 4504（mgmtproto.DrainingCode）拒绝，已建立会话的后续帧照常处理。
+type drainHandler struct {
+	core.ISubProcess
+	state           *drainState
+	onParentDrained func(core.IConnection, mgmtproto.NodeDrainingReq)
+}
+
+func (h *drainHandler) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	sub := h.ISubProcess.SubProto()
+	if sub == mgmtproto.SubProtoManagement {
+		if frameAction(sub, payload) == mgmtproto.ActionNodeDraining {
+			if conn != nil && isParentConn(conn) && h.onParentDrained != nil {
+				var req mgmtproto.NodeDrainingReq
+				decodeActionData(payload, &req)
+				h.onParentDrained(conn, req)
+			}
+			return
+		}
+		h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+		return
+	}
+	if h.state.isDraining() && rejectNewSession(ctx, conn, hdr, sub, payload, mgmtproto.DrainingCode, drainRejectMsg) {
+		return
+	}
+	h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+}
+
+// rejectNewSession 以 code / msg 拒绝新建会话的请求（flow run、file pull / offer）并返回 true；其余帧返回 false。
+// drain 与维护模式共用。
+func rejectNewSession(ctx context.Context, conn core.IConnection, hdr core.IHeader, sub uint8, payload []byte, code int, msg string) bool {
+	switch sub {
+	case flowproto.SubProtoFlow:
+		if frameAction(sub, payload) != flowproto.ActionRun {
+			return false
+		}
+		var req flowproto.RunReq
+		decodeActionData(payload, &req)
+		raw, _ := json.Marshal(flowproto.RunResp{ReqID: req.ReqID, Code: code, Msg: msg, FlowID: req.FlowID})
+		body, _ := json.Marshal(flowproto.Message{Action: flowproto.ActionRunResp, Data: raw})
+		kit.SendResponse(ctx, nil, conn, hdr, body, sub)
+		return true
+	case fileproto.SubProtoFile:
+		if len(payload) == 0 || payload[0] != fileproto.KindCtrl {
+			return false
+		}
+		ctrl := payload[1:]
+		var resp any
+		var action string
+		switch frameAction(sub, payload) {
+		case fileproto.ActionRead:
+			var req fileproto.ReadReq
+			decodeActionData(ctrl, &req)
+			if req.Op != fileproto.OpPull {
+				return false
+			}
+			action, resp = fileproto.ActionReadResp, fileproto.ReadResp{Code: code, Msg: msg, Op: req.Op}
+		case fileproto.ActionWrite:
+			var req fileproto.WriteReq
+			decodeActionData(ctrl, &req)
+			if req.Op != fileproto.OpOffer {
+				return false
+			}
+			action, resp = fileproto.ActionWriteResp, fileproto.WriteResp{Code: code, Msg: msg, Op: req.Op, SessionID: req.SessionID}
+		default:
+			return false
+		}
+		raw, _ := json.Marshal(resp)
+		body, _ := json.Marshal(fileproto.Message{Action: action, Data: raw})
+		kit.SendResponse(ctx, nil, conn, hdr, append([]byte{fileproto.KindCtrl}, body...), sub)
+		return true
+	default:
+		return false
+	}
+}
+
+// drainTimeout 计算 drain 阶段的时长：ctx 有截止时间时留出 1/drainHardStopShare 给硬停止，否则取 defaultDrainTimeout。
+func drainTimeout(ctx context.Context, now time.Time) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return defaultDrainTimeout
+	}
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining - remaining/drainHardStopShare
+}
+
+// runDrain 是 Stop 的第一阶段，依次：
+//  1. 标记 draining（readiness 转为未就绪，新建会话的请求被拒绝）并关闭 listener，不再接受新连接；
+//  2. 向直连子节点发送 node_draining，使其切换到其他父端点；
+//  3. 等待在途 handler 调用归零且会话类子协议静默 drainQuietPeriod；
+//  4. 调用模块的 Drain hook 结束或 checkpoint 会话并落盘（含 file 的 `.part` 文件）。
+//
+// 等待超过 drain 时长时，Drain hook 改用 Stop 的 ctx，借用硬停止的剩余时间完成落盘；
+// 已有连接（包括父链）在硬停止前保持可用。
+func (r *Runtime) runDrain(ctx context.Context) {
+	r.mu.Lock()
+	srv := r.srv
+	state := r.drain
+	group := r.listeners
+	metrics := r.metrics
+	set := r.set
+	r.mu.Unlock()
+	if srv == nil || state == nil || !state.draining.CompareAndSwap(false, true) {
+		return
+	}
+
+	start := time.Now()
+	timeout := drainTimeout(ctx, start)
+	dctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline := start.Add(timeout)
+	r.log.Info("hub draining", "timeout", timeout)
+	r.emit(Event{Type: EventDraining, NodeID: srv.NodeID(), Message: deadline.Format(time.RFC3339Nano)})
+
+	if group != nil {
+		_ = group.Close()
+	}
+	notified := r.notifyChildrenDraining(srv, deadline)
+	// 通知经异步发送队列发出，硬停止会丢弃未写出的帧；把通知计为一次会话活动，至少等待一个静默期。
+	state.lastActivity.Store(time.Now().UnixNano())
+	idle := waitDrainIdle(dctx, metrics, state)
+	hookCtx := dctx
+	if !idle {
+		hookCtx = ctx
+	}
+	if err := modules.DrainHooks(hookCtx, set); err != nil {
+		r.log.Warn("module drain failed", "err", err)
+	}
+	r.log.Info("hub drained", "children_notified", notified, "idle", idle, "elapsed", time.Since(start))
+}
+
+// notifyChildrenDraining 向全部非父链连接发送 node_draining，返回成功发送的连接数。
+func (r *Runtime) notifyChildrenDraining(srv core.IServer, deadline time.Time) int {
+	raw, _ := json.Marshal(mgmtproto.NodeDrainingReq{NodeID: srv.NodeID(), DeadlineMs: deadline.UnixMilli()})
+	payload, _ := json.Marshal(mgmtproto.Message{Action: mgmtproto.ActionNodeDraining, Data: raw})
+	var conns []core.IConnection
+	srv.ConnManager().Range(func(conn core.IConnection) bool {
+		if !isParentConn(conn) {
+			conns = append(conns, conn)
+		}
+		return true
+	})
+	sent := 0
+	for _, conn := range conns {
+		// MajorCmd 逐跳处理，不会被子节点继续转发。
+		hdr := (&header.HeaderTcp{}).
+			WithMajor(header.MajorCmd).
+			WithSubProto(mgmtproto.SubProtoManagement).
+			WithSourceID(srv.NodeID()).
+			WithTargetID(0).
+			WithMsgID(r.msgSeq.Add(1)).
+			WithTimestamp(uint32(time.Now().Unix()))
+		if err := srv.Send(context.Background(), conn.ID(), hdr, payload); err != nil {
+			r.log.Debug("node_draining send failed", "conn", conn.ID(), "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// waitDrainIdle 等待在途 handler 调用归零且会话帧静默；ctx 结束时返回 false。
+func waitDrainIdle(ctx context.Context, metrics *runtimeMetrics, state *drainState) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if metrics.inflight.Load() == 0 && state.quietFor(time.Now()) >= drainQuietPeriod {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// onParentDraining 处理父节点的 drain 通知：记录当前端点正在 drain，
+// 有其他候选端点时立即断开父链，由 Core 重连到下一个端点；单端点时保持连接直到父节点停止。
+func (r *Runtime) onParentDraining(conn core.IConnection, req mgmtproto.NodeDrainingReq) {
+	r.mu.Lock()
+	endpoints := r.endpoints
+	r.mu.Unlock()
+	r.log.Warn("parent hub draining", "conn", conn.ID(), "parent_node", req.NodeID, "deadline_ms", req.DeadlineMs)
+	r.emit(Event{Type: EventParentDraining, ConnID: conn.ID(), NodeID: req.NodeID})
+	if endpoints.markDraining(conn.ID()) {
+		r.log.Info("switching away from draining parent endpoint", "conn", conn.ID())
+		_ = conn.Close()
+	}
+}
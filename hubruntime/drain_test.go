@@ -0,0 +1,185 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `drain` 相关的行为。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/header"
+	fileproto "github.com/yttydcs/myflowhub-server/protocol/file"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+type drainTestInner struct {
+	stubSubProcess
+	sub      uint8
+	received int
+}
+
+func (h *drainTestInner) SubProto() uint8 { return h.sub }
+func (h *drainTestInner) OnReceive(context.Context, core.IConnection, core.IHeader, []byte) {
+	h.received++
+}
+
+func drainTestPayload(t *testing.T, action string, data any) []byte {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	out, err := json.Marshal(mgmtproto.Message{Action: action, Data: raw})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return out
+}
+
+func TestDrainHandlerRejectsNewSessions(t *testing.T) {
+	state := newDrainState()
+	hdr := (&header.HeaderTcp{}).WithMajor(header.MajorCmd).WithSourceID(7).WithTargetID(1).WithMsgID(3)
+
+	flow := &drainTestInner{sub: flowproto.SubProtoFlow}
+	file := &drainTestInner{sub: fileproto.SubProtoFile}
+	flowH := &drainHandler{ISubProcess: flow, state: state}
+	fileH := &drainHandler{ISubProcess: file, state: state}
+
+	run := drainTestPayload(t, flowproto.ActionRun, flowproto.RunReq{ReqID: "r1", FlowID: "f1"})
+	pull := append([]byte{fileproto.KindCtrl}, drainTestPayload(t, fileproto.ActionRead, fileproto.ReadReq{Op: fileproto.OpPull, Name: "a.txt"})...)
+	offer := append([]byte{fileproto.KindCtrl}, drainTestPayload(t, fileproto.ActionWrite, fileproto.WriteReq{Op: fileproto.OpOffer, SessionID: "s1", Name: "a.txt"})...)
+	list := append([]byte{fileproto.KindCtrl}, drainTestPayload(t, fileproto.ActionRead, fileproto.ReadReq{Op: fileproto.OpList})...)
+	data := []byte{fileproto.KindData, 0, 1, 2}
+
+	conn := &registerTestConn{id: "c1"}
+	flowH.OnReceive(context.Background(), conn, hdr, run)
+	fileH.OnReceive(context.Background(), conn, hdr, pull)
+	if flow.received != 1 || file.received != 1 || len(conn.sent) != 0 {
+		t.Fatalf("before drain: flow=%d file=%d sent=%d", flow.received, file.received, len(conn.sent))
+	}
+
+	state.draining.Store(true)
+	flowH.OnReceive(context.Background(), conn, hdr, run)
+	fileH.OnReceive(context.Background(), conn, hdr, pull)
+	fileH.OnReceive(context.Background(), conn, hdr, offer)
+	fileH.OnReceive(context.Background(), conn, hdr, list)
+	fileH.OnReceive(context.Background(), conn, hdr, data)
+	flowH.OnReceive(context.Background(), conn, hdr, drainTestPayload(t, flowproto.ActionStatus, flowproto.StatusReq{}))
+	if flow.received != 2 || file.received != 3 {
+		t.Fatalf("during drain: flow=%d file=%d, want status / list / data passed through", flow.received, file.received)
+	}
+	if len(conn.sent) != 3 {
+		t.Fatalf("sent=%d, want 3 rejections", len(conn.sent))
+	}
+
+	var runResp flowproto.RunResp
+	decodeActionData(conn.sent[0].payload, &runResp)
+	if frameAction(flowproto.SubProtoFlow, conn.sent[0].payload) != flowproto.ActionRunResp ||
+		runResp.Code != mgmtproto.DrainingCode || runResp.ReqID != "r1" || runResp.FlowID != "f1" {
+		t.Fatalf("run rejection=%s", conn.sent[0].payload)
+	}
+	if conn.sent[0].hdr.TargetID() != 7 || conn.sent[0].hdr.GetMsgID() != 3 {
+		t.Fatalf("run rejection header target=%d msg=%d", conn.sent[0].hdr.TargetID(), conn.sent[0].hdr.GetMsgID())
+	}
+	for i, want := range []string{fileproto.ActionReadResp, fileproto.ActionWriteResp} {
+		p := conn.sent[i+1].payload
+		if len(p) == 0 || p[0] != fileproto.KindCtrl || frameAction(fileproto.SubProtoFile, p) != want {
+			t.Fatalf("file rejection %d=%q", i, p)
+		}
+		var resp fileproto.WriteResp
+		decodeActionData(p[1:], &resp)
+		if resp.Code != mgmtproto.DrainingCode {
+			t.Fatalf("file rejection %d code=%d", i, resp.Code)
+		}
+	}
+	if !bytes.Contains(conn.sent[2].payload, []byte(`"session_id":"s1"`)) {
+		t.Fatalf("offer rejection should echo session id: %s", conn.sent[2].payload)
+	}
+}
+
+func TestDrainHandlerNodeDraining(t *testing.T) {
+	mgmt := &drainTestInner{sub: mgmtproto.SubProtoManagement}
+	var got []mgmtproto.NodeDrainingReq
+	h := &drainHandler{ISubProcess: mgmt, state: newDrainState(), onParentDrained: func(_ core.IConnection, req mgmtproto.NodeDrainingReq) {
+		got = append(got, req)
+	}}
+	hdr := (&header.HeaderTcp{}).WithMajor(header.MajorCmd).WithSourceID(1)
+	payload := drainTestPayload(t, mgmtproto.ActionNodeDraining, mgmtproto.NodeDrainingReq{NodeID: 1, DeadlineMs: 42})
+
+	child := &registerTestConn{id: "child"}
+	h.OnReceive(context.Background(), child, hdr, payload)
+	parent := &registerTestConn{id: "parent"}
+	parent.SetMeta(core.MetaRoleKey, core.RoleParent)
+	h.OnReceive(context.Background(), parent, hdr, payload)
+	h.OnReceive(context.Background(), parent, hdr, drainTestPayload(t, mgmtproto.ActionNodeEcho, mgmtproto.NodeEchoReq{}))
+
+	if len(got) != 1 || got[0].NodeID != 1 || got[0].DeadlineMs != 42 {
+		t.Fatalf("parent drained callbacks=%+v, want one from parent conn", got)
+	}
+	if mgmt.received != 1 {
+		t.Fatalf("management received=%d, want only node_echo", mgmt.received)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	now := time.Now()
+	if got := drainTimeout(context.Background(), now); got != defaultDrainTimeout {
+		t.Fatalf("no deadline: %v", got)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(10*time.Second))
+	defer cancel()
+	if got := drainTimeout(ctx, now); got != 8*time.Second {
+		t.Fatalf("10s deadline: %v, want 8s", got)
+	}
+	if got := drainTimeout(ctx, now.Add(time.Minute)); got != 0 {
+		t.Fatalf("expired deadline: %v", got)
+	}
+}
+
+func TestParentEndpointSetAvoidsDrainingEndpoint(t *testing.T) {
+	eps, err := parseParentEndpointList("tcp://a:9000, tcp://b:9000", "")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	dialer := &endpointTestDialer{down: map[string]bool{}}
+	set := newParentEndpointSet(eps, dialer.dial, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	conn, err := set.Dial(context.Background(), "ignored")
+	if err != nil || conn.(*closeTrackConn).target != "tcp://a:9000" {
+		t.Fatalf("dial=%v err=%v", conn, err)
+	}
+	if set.markDraining("stale") {
+		t.Fatalf("stale conn id should be ignored")
+	}
+	if !set.markDraining(conn.ID()) {
+		t.Fatalf("markDraining should report an alternative endpoint")
+	}
+	next, err := set.Dial(context.Background(), "ignored")
+	if err != nil || next.(*closeTrackConn).target != "tcp://b:9000" {
+		t.Fatalf("redial=%v err=%v, want non-draining endpoint", next, err)
+	}
+	if st := set.Snapshot(next.ID()); st[0].DrainingUntil.IsZero() || !st[1].Current {
+		t.Fatalf("snapshot=%+v", st)
+	}
+
+	// 其他端点不可用时仍回退到正在 drain 的端点。
+	dialer.setDown("tcp://b:9000", true)
+	last, err := set.Dial(context.Background(), "ignored")
+	if err != nil || last.(*closeTrackConn).target != "tcp://a:9000" {
+		t.Fatalf("fallback dial=%v err=%v", last, err)
+	}
+
+	single := newParentEndpointSet(eps[:1], dialer.dial, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	only, err := single.Dial(context.Background(), "ignored")
+	if err != nil {
+		t.Fatalf("single dial: %v", err)
+	}
+	if single.markDraining(only.ID()) {
+		t.Fatalf("single endpoint has no alternative")
+	}
+}
@@ -0,0 +1,176 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `events` 相关的逻辑。
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventSubscriberBuffer 是每个订阅者的事件缓冲上限；缓冲满时新事件被丢弃并计入 Dropped。
+const eventSubscriberBuffer = 256
+
+// 事件类型。取值为稳定字符串，宿主可直接按字符串匹配（gomobile / JSON 回调同样适用）。
+const (
+	EventParentConnected      = "parent.connected"
+	EventParentDisconnected   = "parent.disconnected"
+	EventParentRegisterSent   = "parent.register_sent"
+	EventParentRegisterFailed = "parent.register_failed"
+	EventParentDraining       = "parent.draining"
+	EventParentNodeIDMismatch = "parent.node_id_mismatch"
+	EventChildConnected       = "child.connected"
+	EventChildLoggedIn        = "child.logged_in"
+	EventChildOffline         = "child.offline"
+	EventRegisterPending      = "register.pending"
+	EventListenerError        = "listener.error"
+	EventConfigChanged        = "config.changed"
+	EventDraining             = "runtime.draining"
+	EventMaintenanceEnabled   = "runtime.maintenance_enabled"
+	EventMaintenanceDisabled  = "runtime.maintenance_disabled"
+	EventError                = "runtime.error"
+	EventFailed               = "runtime.failed"
+)
+
+// Event 是 runtime 推送给宿主的类型化事件；未使用的字段保持零值。
+//   - ConnID / NodeID / DeviceID：事件关联的连接、节点号与设备号；
+//   - Listener：child.connected 的接入 listener，或 listener.error 的出错 listener；
+//   - Keys / RestartRequired：config.changed 中变化的键与其中需要重启的键；
+//   - Message：错误文本或补充说明（runtime.draining 为 drain 截止时间，RFC 3339）；
+//   - parent.node_id_mismatch 的 NodeID 为父节点新分配的 nodeID；runtime.maintenance_enabled 的 Message 为原因；
+//   - Dropped：本订阅者在此事件之前因缓冲满而丢弃的事件数。
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	ConnID   string `json:"conn_id,omitempty"`
+	NodeID   uint32 `json:"node_id,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+	Listener string `json:"listener,omitempty"`
+
+	Keys            []string `json:"keys,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+
+	Message string `json:"message,omitempty"`
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// EventListener 是 gomobile 友好的事件回调：每个事件编码为一个 JSON 字符串。
+type EventListener interface {
+	OnEvent(eventJSON string)
+}
+
+// Subscription 表示一次 SubscribeJSON 订阅。
+type Subscription struct {
+	cancel func()
+}
+
+// Cancel 取消订阅；可重复调用。取消后已缓冲但尚未交付的事件被丢弃。
+func (s *Subscription) Cancel() {
+	if s != nil && s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Subscribe 注册事件回调并返回取消函数。
+// 每个订阅者拥有独立的有界缓冲与交付 goroutine：回调执行缓慢只会让该订阅者丢事件，
+// 不会阻塞 runtime 或其他订阅者。订阅跨越 Start / Stop 持续有效。
+func (r *Runtime) Subscribe(fn func(Event)) (cancel func()) {
+	if fn == nil {
+		return func() {}
+	}
+	return r.bus().subscribe(fn, r.log)
+}
+
+// SubscribeJSON 是 Subscribe 的 gomobile 变体，事件以 JSON 字符串交付。
+func (r *Runtime) SubscribeJSON(listener EventListener) *Subscription {
+	if listener == nil {
+		return &Subscription{}
+	}
+	cancel := r.Subscribe(func(ev Event) {
+		raw, err := json.Marshal(ev)
+		if err != nil {
+			return
+		}
+		listener.OnEvent(string(raw))
+	})
+	return &Subscription{cancel: cancel}
+}
+
+// bus 延迟初始化事件分发器，兼容测试中直接构造的 Runtime。
+func (r *Runtime) bus() *eventBus {
+	r.eventsOnce.Do(func() {
+		r.events = &eventBus{subs: make(map[uint64]*eventSub)}
+	})
+	return r.events
+}
+
+// emit 发布一个事件；没有订阅者时几乎无开销。
+func (r *Runtime) emit(ev Event) {
+	r.bus().publish(ev)
+}
+
+type eventBus struct {
+	mu   sync.RWMutex
+	next uint64
+	subs map[uint64]*eventSub
+}
+
+type eventSub struct {
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+func (b *eventBus) subscribe(fn func(Event), log *slog.Logger) func() {
+	sub := &eventSub{ch: make(chan Event, eventSubscriberBuffer)}
+	b.mu.Lock()
+	b.next++
+	id := b.next
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		for ev := range sub.ch {
+			deliverEvent(fn, ev, log)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			close(sub.ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *eventBus) publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		out := ev
+		out.Dropped = sub.dropped.Swap(0)
+		select {
+		case sub.ch <- out:
+		default:
+			sub.dropped.Add(out.Dropped + 1)
+		}
+	}
+}
+
+// deliverEvent 隔离宿主回调的 panic，避免一个异常回调终止交付 goroutine。
+func deliverEvent(fn func(Event), ev Event, log *slog.Logger) {
+	defer func() {
+		if rec := recover(); rec != nil && log != nil {
+			log.Error("event subscriber panic", "type", ev.Type, "recover", rec)
+		}
+	}()
+	fn(ev)
+}
@@ -0,0 +1,178 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `events` 相关的行为。
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/connmgr"
+)
+
+func recvEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestSubscribeSlowSubscriberDropsWithoutBlocking(t *testing.T) {
+	rt := &Runtime{}
+	release := make(chan struct{})
+	got := make(chan Event, eventSubscriberBuffer*2)
+	cancel := rt.Subscribe(func(ev Event) {
+		<-release
+		got <- ev
+	})
+	defer cancel()
+
+	// 交付 goroutine 阻塞在回调里，缓冲填满后其余事件被丢弃。
+	total := eventSubscriberBuffer + 11
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < total; i++ {
+			rt.emit(Event{Type: EventChildConnected})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("emit blocked on slow subscriber")
+	}
+	close(release)
+
+	// 先排空已缓冲的事件；丢弃数由下一个成功入队的事件携带。
+	delivered := 0
+	for drained := false; !drained; {
+		select {
+		case <-got:
+			delivered++
+		case <-time.After(100 * time.Millisecond):
+			drained = true
+		}
+	}
+	rt.emit(Event{Type: EventError, Message: "after"})
+	var last Event
+	for last.Type != EventError {
+		last = recvEvent(t, got)
+		delivered++
+	}
+	if last.Dropped == 0 || uint64(delivered)+last.Dropped != uint64(total+1) {
+		t.Fatalf("delivered=%d dropped=%d, want sum %d", delivered, last.Dropped, total+1)
+	}
+}
+
+func TestSubscribeCancelAndJSONListener(t *testing.T) {
+	rt := &Runtime{}
+	listener := &jsonTestListener{ch: make(chan string, 4)}
+	sub := rt.SubscribeJSON(listener)
+
+	rt.emit(Event{Type: EventConfigChanged, Keys: []string{"addr"}, RestartRequired: []string{"addr"}})
+	var decoded map[string]any
+	select {
+	case raw := <-listener.ch:
+		if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+			t.Fatalf("decode %q: %v", raw, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for json event")
+	}
+	if decoded["type"] != EventConfigChanged || decoded["time"] == nil {
+		t.Fatalf("unexpected json event: %v", decoded)
+	}
+	if _, ok := decoded["conn_id"]; ok {
+		t.Fatalf("empty fields should be omitted: %v", decoded)
+	}
+
+	sub.Cancel()
+	sub.Cancel()
+	rt.emit(Event{Type: EventError})
+	select {
+	case raw := <-listener.ch:
+		t.Fatalf("cancelled listener received %q", raw)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type jsonTestListener struct {
+	ch chan string
+}
+
+func (l *jsonTestListener) OnEvent(eventJSON string) { l.ch <- eventJSON }
+
+func TestEventConnManagerLifecycle(t *testing.T) {
+	rt := &Runtime{}
+	got := make(chan Event, 16)
+	defer rt.Subscribe(func(ev Event) { got <- ev })()
+
+	cm := &eventConnManager{IConnectionManager: connmgr.New(), emit: rt.emit}
+	var serverAdds int
+	cm.SetHooks(core.ConnectionHooks{OnAdd: func(c core.IConnection) {
+		serverAdds++
+		if _, ok := c.GetMeta(core.MetaRoleKey); !ok {
+			c.SetMeta(core.MetaRoleKey, core.RoleChild)
+		}
+	}})
+
+	child := &registerTestConn{id: "child-1"}
+	child.SetMeta(metaListenerKey, "tcp")
+	if err := cm.Add(child); err != nil {
+		t.Fatalf("add child: %v", err)
+	}
+	if ev := recvEvent(t, got); ev.Type != EventChildConnected || ev.ConnID != "child-1" || ev.Listener != "tcp" {
+		t.Fatalf("child connect event=%+v", ev)
+	}
+	child.SetMeta("nodeID", uint32(42))
+	cm.UpdateNodeIndex(42, child)
+	if ev := recvEvent(t, got); ev.Type != EventChildLoggedIn || ev.NodeID != 42 {
+		t.Fatalf("login event=%+v", ev)
+	}
+	_ = cm.Remove(child.ID())
+	if ev := recvEvent(t, got); ev.Type != EventChildOffline || ev.NodeID != 42 || ev.Listener != "tcp" {
+		t.Fatalf("offline event=%+v", ev)
+	}
+
+	parent := &registerTestConn{id: "parent-1"}
+	parent.SetMeta(core.MetaRoleKey, core.RoleParent)
+	if err := cm.Add(parent); err != nil {
+		t.Fatalf("add parent: %v", err)
+	}
+	if ev := recvEvent(t, got); ev.Type != EventParentConnected || ev.ConnID != "parent-1" {
+		t.Fatalf("parent connect event=%+v", ev)
+	}
+	cm.UpdateNodeIndex(1, parent)
+	_ = cm.Remove(parent.ID())
+	if ev := recvEvent(t, got); ev.Type != EventParentDisconnected {
+		t.Fatalf("parent link should not emit login, got %+v", ev)
+	}
+	if serverAdds != 2 {
+		t.Fatalf("server OnAdd calls=%d, want 2", serverAdds)
+	}
+}
+
+func TestRuntimeEventsFromFramesAndErrors(t *testing.T) {
+	rt := &Runtime{}
+	got := make(chan Event, 8)
+	defer rt.Subscribe(func(ev Event) { got <- ev })()
+
+	obs := registerEventObserver{emit: rt.emit}
+	child := &registerTestConn{id: "child-1"}
+	obs.observeOutbound(child, authHeader(), []byte(`{"action":"register_resp","data":{"code":1,"device_id":"dev-a"}}`))
+	obs.observeOutbound(child, authHeader(), []byte(`{"action":"register_resp","data":{"code":202,"device_id":"dev-b","msg":"pending"}}`))
+	if ev := recvEvent(t, got); ev.Type != EventRegisterPending || ev.DeviceID != "dev-b" || ev.ConnID != "child-1" {
+		t.Fatalf("pending event=%+v", ev)
+	}
+
+	rt.storeErr(errors.New("listen tcp: address in use"))
+	if ev := recvEvent(t, got); ev.Type != EventError || ev.Message != "listen tcp: address in use" {
+		t.Fatalf("error event=%+v", ev)
+	}
+}
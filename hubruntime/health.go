@@ -0,0 +1,210 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `health` 相关的逻辑。
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+)
+
+const (
+	// stateProbeInterval / stateProbeTimeout 控制 pg 状态后端探活的周期与单次超时。
+	stateProbeInterval = 5 * time.Second
+	stateProbeTimeout  = 3 * time.Second
+
+	// authorityUnavailableTTL 是最近一次 code=4500 的有效期：之后没有新的 4500 即视为已恢复，
+	// 避免 hub 不再发出 authority 请求时 /readyz 永久停留在 authority_unavailable。
+	authorityUnavailableTTL = 60 * time.Second
+
+	authCodeOK                   = 1
+	authCodePending              = 202
+	authCodeAuthorityUnavailable = 4500
+)
+
+// Readiness 未就绪原因。
+const (
+	ReadyReasonNotStarted              = "not_started"
+	ReadyReasonDraining                = "draining"
+	ReadyReasonMaintenance             = "maintenance"
+	ReadyReasonParentNotConnected      = "parent_not_connected"
+	ReadyReasonParentRegisterPending   = "parent_register_pending"
+	ReadyReasonParentRegisterFailed    = "parent_register_failed"
+	ReadyReasonNodeIDMismatch          = "node_id_mismatch"
+	ReadyReasonStateBackendUnreachable = "state_backend_unreachable"
+	ReadyReasonAuthorityUnavailable    = "authority_unavailable"
+)
+
+// Readiness 描述 hub 当前是否可以接收子节点；Ready=false 时 Reasons 列出全部未满足的条件。
+type Readiness struct {
+	Ready   bool
+	Reasons []string
+}
+
+// healthState 记录就绪判定所需、只能从帧流中观察到的状态。
+type healthState struct {
+	mu sync.Mutex
+
+	// authorityUnavailableAt 是最近一次观察到 code=4500 的时间；authority 相关请求再次成功时清零，
+	// 超过 authorityUnavailableTTL 未再观察到 4500 时同样视为恢复。
+	authorityUnavailableAt time.Time
+	now                    func() time.Time
+
+	// stateProbe 为 false 表示未使用 pg 后端，无需探活。
+	stateProbe   bool
+	stateChecked bool
+	stateErr     error
+}
+
+func newHealthState() *healthState {
+	return &healthState{now: time.Now}
+}
+
+// observeInbound 观察父链上的 register_resp 携带的 authority 状态；注册结果本身由 parentBootstrap 跟踪。
+func (h *healthState) observeInbound(conn core.IConnection, hdr core.IHeader, payload []byte) {
+	if h == nil || conn == nil || hdr == nil || hdr.SubProto() != authproto.SubProtoAuth {
+		return
+	}
+	if !isParentConn(conn) || frameAction(hdr.SubProto(), payload) != authproto.ActionRegisterResp {
+		return
+	}
+	code, ok := responseCode(hdr.SubProto(), payload)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	h.noteAuthorityCodeLocked(code, true)
+	h.mu.Unlock()
+}
+
+// observeOutbound 观察本地 auth handler 发出的响应：code=4500 说明 authority 不可达，
+// register_resp 成功说明 authority 已恢复（半中心退化期 register 必须经过上游 authority）。
+func (h *healthState) observeOutbound(_ core.IConnection, hdr core.IHeader, payload []byte) {
+	if h == nil || hdr == nil || hdr.SubProto() != authproto.SubProtoAuth {
+		return
+	}
+	code, ok := responseCode(hdr.SubProto(), payload)
+	if !ok {
+		return
+	}
+	authoritative := frameAction(hdr.SubProto(), payload) == authproto.ActionRegisterResp
+	h.mu.Lock()
+	h.noteAuthorityCodeLocked(code, authoritative)
+	h.mu.Unlock()
+}
+
+func (h *healthState) noteAuthorityCodeLocked(code int, authoritative bool) {
+	switch {
+	case code == authCodeAuthorityUnavailable:
+		h.authorityUnavailableAt = h.now()
+	case code == authCodeOK && authoritative:
+		h.authorityUnavailableAt = time.Time{}
+	}
+}
+
+// authorityUnavailableLocked 判断最近一次 code=4500 是否仍在有效期内。
+func (h *healthState) authorityUnavailableLocked() bool {
+	return !h.authorityUnavailableAt.IsZero() && h.now().Sub(h.authorityUnavailableAt) < authorityUnavailableTTL
+}
+
+func (h *healthState) setStateProbe(err error) {
+	h.mu.Lock()
+	h.stateChecked = true
+	h.stateErr = err
+	h.mu.Unlock()
+}
+
+// Readiness 汇总就绪条件：
+//   - runtime 已启动（srv.Start 成功）且未进入 drain；
+//   - 未开启维护模式；
+//   - 启用父链时父链已连接；配置了 SelfID 时还需当前父连接上的 bootstrap register 已被确认（code=1），
+//     且父节点分配的 nodeID 与运行中的一致；
+//   - 使用 pg 状态后端时最近一次探活成功；
+//   - 最近 authorityUnavailableTTL 内未观察到 authority 不可达（code=4500）。
+func (r *Runtime) Readiness() Readiness {
+	r.mu.Lock()
+	srv := r.srv
+	opts := r.opts
+	health := r.health
+	drain := r.drain
+	boot := r.parent
+	r.mu.Unlock()
+	if srv == nil || health == nil {
+		return Readiness{Reasons: []string{ReadyReasonNotStarted}}
+	}
+	if drain.isDraining() {
+		return Readiness{Reasons: []string{ReadyReasonDraining}}
+	}
+
+	var reasons []string
+	if r.maintenance.enabled.Load() {
+		reasons = append(reasons, ReadyReasonMaintenance)
+	}
+	health.mu.Lock()
+	if health.stateProbe && (!health.stateChecked || health.stateErr != nil) {
+		reasons = append(reasons, ReadyReasonStateBackendUnreachable)
+	}
+	if health.authorityUnavailableLocked() {
+		reasons = append(reasons, ReadyReasonAuthorityUnavailable)
+	}
+	health.mu.Unlock()
+
+	if opts.ParentEnable && effectiveParentTarget(opts) != "" {
+		_, ok := boot.current()
+		reg := boot.snapshot()
+		switch {
+		case !ok:
+			reasons = append([]string{ReadyReasonParentNotConnected}, reasons...)
+		case opts.SelfID == "":
+			// 未配置 SelfID 时不会发送 bootstrap register，父链连通即视为就绪。
+		case reg.State == "" || reg.State == ParentRegisterSent || reg.State == ParentRegisterPending:
+			reasons = append([]string{ReadyReasonParentRegisterPending}, reasons...)
+		case reg.State != ParentRegisterOK:
+			reasons = append([]string{ReadyReasonParentRegisterFailed}, reasons...)
+		case boot.nodeIDMismatch():
+			reasons = append([]string{ReadyReasonNodeIDMismatch}, reasons...)
+		}
+	}
+	return Readiness{Ready: len(reasons) == 0, Reasons: reasons}
+}
+
+// probeStateBackend 周期性探测 pg 状态后端，直到 ctx 结束。
+func (r *Runtime) probeStateBackend(ctx context.Context, pools *defaultset.PGPools, health *healthState) {
+	ticker := time.NewTicker(stateProbeInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		pctx, cancel := context.WithTimeout(ctx, stateProbeTimeout)
+		err := pools.Probe(pctx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		health.setStateProbe(err)
+		if err != nil && lastErr == nil {
+			r.log.Warn("state backend unreachable", "err", err)
+		} else if err == nil && lastErr != nil {
+			r.log.Info("state backend reachable again")
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isParentConn 判断连接是否为父链连接。
+func isParentConn(conn core.IConnection) bool {
+	role, ok := conn.GetMeta(core.MetaRoleKey)
+	if !ok {
+		return false
+	}
+	s, _ := role.(string)
+	return s == core.RoleParent
+}
@@ -0,0 +1,180 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `health` 相关的行为。
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/connmgr"
+	"github.com/yttydcs/myflowhub-core/header"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+)
+
+// healthTestServer 只提供 Readiness 需要的 ConnManager。
+type healthTestServer struct {
+	core.IServer
+	cm core.IConnectionManager
+}
+
+func (s healthTestServer) ConnManager() core.IConnectionManager { return s.cm }
+
+func authHeader() core.IHeader {
+	return (&header.HeaderTcp{}).WithSubProto(authproto.SubProtoAuth)
+}
+
+func TestReadinessParentRegisterAck(t *testing.T) {
+	health := newHealthState()
+	opts := DefaultOptions()
+	opts.ParentEnable = true
+	opts.ParentAddr = "127.0.0.1:9000"
+	opts.SelfID = "hub-a"
+	rt := &Runtime{opts: opts, log: slog.New(slog.NewTextHandler(io.Discard, nil)), health: health}
+	boot := newParentBootstrap(rt, nil, opts.SelfID, rt.log)
+	cm := &eventConnManager{IConnectionManager: connmgr.New(), emit: rt.emit, parent: boot}
+	cm.SetHooks(core.ConnectionHooks{})
+	rt.srv = healthTestServer{cm: cm}
+	rt.parent = boot
+	observers := frameObserversFor(health, newDrainState(), boot, rt.emit)
+	inbound := func(conn core.IConnection, payload string) {
+		for _, o := range observers {
+			o.observeInbound(conn, authHeader(), []byte(payload))
+		}
+	}
+
+	assertReasons := func(step string, want ...string) {
+		t.Helper()
+		got := rt.Readiness()
+		if got.Ready != (len(want) == 0) || !reflect.DeepEqual(got.Reasons, want) {
+			t.Fatalf("%s: readiness=%+v, want reasons %v", step, got, want)
+		}
+	}
+
+	assertReasons("no parent", ReadyReasonParentNotConnected)
+
+	parent := &registerTestConn{id: "parent-1"}
+	parent.SetMeta(core.MetaRoleKey, core.RoleParent)
+	if err := cm.Add(parent); err != nil {
+		t.Fatalf("add parent: %v", err)
+	}
+	assertReasons("register not acked", ReadyReasonParentRegisterPending)
+
+	inbound(parent, `{"action":"register_resp","data":{"code":202,"msg":"pending approval"}}`)
+	assertReasons("pending approval", ReadyReasonParentRegisterPending)
+
+	inbound(parent, `{"action":"register_resp","data":{"code":1,"node_id":7}}`)
+	assertReasons("acked")
+
+	// 父链重连后需要在新连接上重新确认。
+	cm.Remove(parent.ID())
+	parent2 := &registerTestConn{id: "parent-2"}
+	parent2.SetMeta(core.MetaRoleKey, core.RoleParent)
+	if err := cm.Add(parent2); err != nil {
+		t.Fatalf("add parent2: %v", err)
+	}
+	assertReasons("reconnected", ReadyReasonParentRegisterPending)
+
+	inbound(parent2, `{"action":"register_resp","data":{"code":4500,"msg":"authority unavailable"}}`)
+	assertReasons("authority down", ReadyReasonParentRegisterFailed, ReadyReasonAuthorityUnavailable)
+}
+
+func TestReadinessAuthorityAndStateBackend(t *testing.T) {
+	health := newHealthState()
+	health.stateProbe = true
+	rt := &Runtime{opts: DefaultOptions(), srv: healthTestServer{cm: connmgr.New()}, health: health}
+
+	if got := rt.Readiness(); got.Ready || !reflect.DeepEqual(got.Reasons, []string{ReadyReasonStateBackendUnreachable}) {
+		t.Fatalf("before first probe: %+v", got)
+	}
+	health.setStateProbe(errors.New("connection refused"))
+	if got := rt.Readiness(); got.Ready {
+		t.Fatalf("failed probe should not be ready: %+v", got)
+	}
+	health.setStateProbe(nil)
+	if got := rt.Readiness(); !got.Ready {
+		t.Fatalf("healthy probe should be ready: %+v", got)
+	}
+
+	child := &registerTestConn{id: "child-1"}
+	health.observeOutbound(child, authHeader(), []byte(`{"action":"login_resp","data":{"code":4500,"msg":"authority unavailable"}}`))
+	if got := rt.Readiness(); got.Ready || !reflect.DeepEqual(got.Reasons, []string{ReadyReasonAuthorityUnavailable}) {
+		t.Fatalf("authority unavailable: %+v", got)
+	}
+	// 本地已知身份登录成功不代表 authority 恢复。
+	health.observeOutbound(child, authHeader(), []byte(`{"action":"login_resp","data":{"code":1}}`))
+	if got := rt.Readiness(); got.Ready {
+		t.Fatalf("local login success should not clear authority state: %+v", got)
+	}
+	health.observeOutbound(child, authHeader(), []byte(`{"action":"register_resp","data":{"code":1,"node_id":9}}`))
+	if got := rt.Readiness(); !got.Ready {
+		t.Fatalf("register success should clear authority state: %+v", got)
+	}
+}
+
+func TestReadinessAuthorityUnavailableExpires(t *testing.T) {
+	health := newHealthState()
+	now := time.Unix(1000, 0)
+	health.now = func() time.Time { return now }
+	rt := &Runtime{opts: DefaultOptions(), srv: healthTestServer{cm: connmgr.New()}, health: health}
+
+	child := &registerTestConn{id: "child-1"}
+	health.observeOutbound(child, authHeader(), []byte(`{"action":"register_resp","data":{"code":4500,"msg":"authority unavailable"}}`))
+	now = now.Add(authorityUnavailableTTL - time.Second)
+	if got := rt.Readiness(); got.Ready {
+		t.Fatalf("within ttl: %+v", got)
+	}
+	// 没有后续 authority 请求时，过期后自动恢复就绪。
+	now = now.Add(2 * time.Second)
+	if got := rt.Readiness(); !got.Ready {
+		t.Fatalf("after ttl: %+v", got)
+	}
+	// 新的 4500 重新计时。
+	health.observeOutbound(child, authHeader(), []byte(`{"action":"login_resp","data":{"code":4500}}`))
+	if got := rt.Readiness(); got.Ready {
+		t.Fatalf("fresh 4500: %+v", got)
+	}
+}
+
+func TestReadinessNotStarted(t *testing.T) {
+	rt := &Runtime{opts: DefaultOptions()}
+	if got := rt.Readiness(); got.Ready || !reflect.DeepEqual(got.Reasons, []string{ReadyReasonNotStarted}) {
+		t.Fatalf("readiness=%+v", got)
+	}
+}
+
+func TestAdminProbeEndpoints(t *testing.T) {
+	probe := func(rt *Runtime, path string) (int, map[string]any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		rt.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s body %q: %v", path, rec.Body.String(), err)
+		}
+		return rec.Code, body
+	}
+
+	stopped := &Runtime{opts: DefaultOptions()}
+	if code, _ := probe(stopped, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("stopped /healthz=%d", code)
+	}
+	if code, body := probe(stopped, "/readyz"); code != http.StatusServiceUnavailable || body["ready"] != false {
+		t.Fatalf("stopped /readyz=%d %v", code, body)
+	}
+
+	running := &Runtime{opts: DefaultOptions(), srv: healthTestServer{cm: connmgr.New()}, health: newHealthState()}
+	if code, _ := probe(running, "/healthz"); code != http.StatusOK {
+		t.Fatalf("running /healthz=%d", code)
+	}
+	if code, body := probe(running, "/readyz"); code != http.StatusOK || body["ready"] != true {
+		t.Fatalf("running /readyz=%d %v", code, body)
+	}
+}
@@ -0,0 +1,182 @@
+package hubtest
+
+// 本文件承载 `hubtest` 中与 `client` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/header"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+)
+
+// Message 是子协议通用的 `{"action","data"}` 报文。
+type Message struct {
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Frame 是客户端收到的一帧。
+type Frame struct {
+	Header  core.IHeader
+	Message Message
+}
+
+// Client 是接入某个 hub 的终端：经 auth register 取得节点号后，以该节点号为 SourceID 发送子协议请求。
+// 同一 Client 上的 Call 串行执行。
+type Client struct {
+	DeviceID string
+	NodeID   uint32
+	Hub      *Hub
+
+	conn  net.Conn
+	codec header.HeaderTcpCodec
+	msgID atomic.Uint32
+	mu    sync.Mutex
+}
+
+// Dial 经内存网络连接 hub，并以 deviceID 完成 register。
+func Dial(ctx context.Context, n *Network, hub *Hub, deviceID string) (*Client, error) {
+	conn, err := n.DialConn(ctx, hub.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{DeviceID: deviceID, Hub: hub, conn: conn}
+	msg, err := c.call(ctx, 0, authproto.SubProtoAuth, authproto.ActionRegister, map[string]any{"device_id": deviceID}, authproto.ActionRegisterResp)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("register: %w", err)
+	}
+	var resp struct {
+		Code   int    `json:"code"`
+		NodeID uint32 `json:"node_id"`
+		Msg    string `json:"msg"`
+	}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("register resp: %w", err)
+	}
+	if resp.Code != 1 || resp.NodeID == 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("register failed: code=%d node_id=%d msg=%s", resp.Code, resp.NodeID, resp.Msg)
+	}
+	c.NodeID = resp.NodeID
+	return c, nil
+}
+
+// Close 断开客户端连接。
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call 向节点 target 发送一条 MajorCmd 请求，并等待 action 为 respAction 的响应；期间收到的其他帧被丢弃。
+// 响应 Major 不是 OKResp 时返回错误（Message 仍然返回，便于断言错误码）。
+func (c *Client) Call(ctx context.Context, target uint32, subProto uint8, action string, data any, respAction string) (Message, error) {
+	if c.NodeID == 0 {
+		return Message{}, errors.New("hubtest: client not registered")
+	}
+	return c.call(ctx, target, subProto, action, data, respAction)
+}
+
+// Send 向节点 target 发送一条 MajorCmd 请求，不等待响应。
+func (c *Client) Send(target uint32, subProto uint8, action string, data any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.send(target, subProto, action, data)
+}
+
+// Recv 读取下一帧，直到 ctx 结束。
+func (c *Client) Recv(ctx context.Context) (Frame, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recv(ctx)
+}
+
+func (c *Client) call(ctx context.Context, target uint32, subProto uint8, action string, data any, respAction string) (Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.send(target, subProto, action, data); err != nil {
+		return Message{}, err
+	}
+	for {
+		f, err := c.recv(ctx)
+		if err != nil {
+			return Message{}, fmt.Errorf("wait %s: %w", respAction, err)
+		}
+		if f.Header.SubProto() != subProto || f.Message.Action != respAction {
+			continue
+		}
+		if f.Header.Major() != header.MajorOKResp {
+			return f.Message, fmt.Errorf("%s: response major=%d", respAction, f.Header.Major())
+		}
+		return f.Message, nil
+	}
+}
+
+func (c *Client) send(target uint32, subProto uint8, action string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", action, err)
+	}
+	payload, err := json.Marshal(Message{Action: action, Data: raw})
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", action, err)
+	}
+	hdr := (&header.HeaderTcp{}).
+		WithMajor(header.MajorCmd).
+		WithSubProto(subProto).
+		WithSourceID(c.NodeID).
+		WithTargetID(target).
+		WithMsgID(c.msgID.Add(1)).
+		WithPayloadLength(uint32(len(payload)))
+	frame, err := c.codec.Encode(hdr, payload)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", action, err)
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("write %s: %w", action, err)
+	}
+	return nil
+}
+
+func (c *Client) recv(ctx context.Context) (Frame, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	_ = c.conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	hdr, payload, err := c.codec.Decode(c.conn)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Frame{}, ctx.Err()
+		}
+		return Frame{}, err
+	}
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return Frame{Header: hdr}, fmt.Errorf("decode payload: %w", err)
+	}
+	return Frame{Header: hdr, Message: msg}, nil
+}
+
+// Request 向 hub 发送 action 请求，等待 `<action>_resp` 并把 data 解码为 Resp。
+func Request[Resp any](ctx context.Context, c *Client, to *Hub, subProto uint8, action string, req any) (Resp, error) {
+	var resp Resp
+	msg, err := c.Call(ctx, to.NodeID, subProto, action, req, action+"_resp")
+	if len(msg.Data) > 0 {
+		if uerr := json.Unmarshal(msg.Data, &resp); uerr != nil && err == nil {
+			err = fmt.Errorf("decode %s_resp: %w", action, uerr)
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,271 @@
+// Package hubtest 在单个进程内按声明的树形拓扑启动多个 hubruntime.Runtime，
+// 节点之间经内存网络（Network）互连，供多跳集成测试与仿真使用。
+//
+//	c := hubtest.Start(t, hubtest.N("root",
+//		hubtest.N("A", hubtest.N("C")),
+//		hubtest.N("B"),
+//	))
+//	cli := c.Client(t, "B", "dev-1")
+//	resp, err := hubtest.Request[NodeEchoResp](ctx, cli, c.Hub("C"), management.SubProtoManagement, "node_echo", req)
+package hubtest
+
+// 本文件承载 `hubtest` 中与 `cluster` 相关的逻辑。
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+)
+
+const (
+	// rootNodeID 是根 hub 的固定节点号；其余 hub 的节点号由父链 self-register 分配。
+	rootNodeID = 1
+	// readyTimeout 是单个 hub 启动并完成父链 register 的等待上限。
+	readyTimeout = 10 * time.Second
+	stopTimeout  = 3 * time.Second
+)
+
+// Node 声明拓扑中的一个 hub 及其子 hub。
+type Node struct {
+	Name     string
+	Children []Node
+	// Options 可选，在 harness 生成的 Options 上做额外修改（例如权限、模块配置）。
+	// 不应修改 Transport / Addr / Parent* / SelfID，这些由 harness 负责。
+	Options func(*hubruntime.Options)
+}
+
+// N 是声明 Node 的简写。
+func N(name string, children ...Node) Node {
+	return Node{Name: name, Children: children}
+}
+
+// Hub 是拓扑中一个已启动的 hub。
+type Hub struct {
+	Name    string
+	Addr    string
+	NodeID  uint32
+	Parent  *Hub
+	Runtime *hubruntime.Runtime
+
+	mu       sync.Mutex
+	loggedIn map[uint32]struct{}
+	changed  chan struct{}
+	cancel   func()
+}
+
+// Cluster 是按拓扑启动的一组 hub。
+type Cluster struct {
+	Net *Network
+
+	hubs  map[string]*Hub
+	order []*Hub // 启动顺序（父在子前）
+}
+
+// Start 按广度优先顺序启动拓扑中的每个 hub，并等待每个子 hub 完成父链 register、
+// 且父 hub 确认其登录后才返回。任一步失败都会 t.Fatal；测试结束时按逆序停止全部 hub。
+func Start(t testing.TB, root Node) *Cluster {
+	t.Helper()
+	c := &Cluster{Net: NewNetwork(), hubs: make(map[string]*Hub)}
+	t.Cleanup(c.stop)
+
+	type pending struct {
+		node   Node
+		parent *Hub
+	}
+	queue := []pending{{node: root}}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		h, err := c.startHub(t, p.node, p.parent)
+		if err != nil {
+			t.Fatalf("hubtest: start %s: %v", p.node.Name, err)
+		}
+		for _, child := range p.node.Children {
+			queue = append(queue, pending{node: child, parent: h})
+		}
+	}
+	return c
+}
+
+// Hub 返回名为 name 的 hub；不存在时 panic，便于在测试中直接链式调用。
+func (c *Cluster) Hub(name string) *Hub {
+	h, ok := c.hubs[name]
+	if !ok {
+		panic(fmt.Sprintf("hubtest: unknown hub %q", name))
+	}
+	return h
+}
+
+// Hubs 按启动顺序返回全部 hub。
+func (c *Cluster) Hubs() []*Hub {
+	return append([]*Hub(nil), c.order...)
+}
+
+// Client 在名为 hubName 的 hub 上接入一个以 deviceID 注册的客户端。
+func (c *Cluster) Client(t testing.TB, hubName, deviceID string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	cli, err := Dial(ctx, c.Net, c.Hub(hubName), deviceID)
+	if err != nil {
+		t.Fatalf("hubtest: client %s@%s: %v", deviceID, hubName, err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func (c *Cluster) startHub(t testing.TB, node Node, parent *Hub) (*Hub, error) {
+	if node.Name == "" {
+		return nil, fmt.Errorf("hub name required")
+	}
+	if _, dup := c.hubs[node.Name]; dup {
+		return nil, fmt.Errorf("duplicate hub name")
+	}
+	h := &Hub{
+		Name:     node.Name,
+		Addr:     node.Name + ".hubtest:7000",
+		Parent:   parent,
+		loggedIn: make(map[uint32]struct{}),
+		changed:  make(chan struct{}),
+	}
+
+	opts := hubruntime.DefaultOptions()
+	opts.TCPEnable = true
+	opts.Addr = h.Addr
+	opts.Transport = c.Net
+	opts.WorkDir = t.TempDir()
+	// auth 的 trusted_nodes.json 仍相对进程 cwd 读写，多个 hub 与多次测试会共用同一文件；
+	// 默认经 WorkDir 下的持久配置关闭 auth 持久化，节点密钥则随 WorkDir 隔离。
+	if err := seedAuthDisablePersist(opts.WorkDir); err != nil {
+		return nil, err
+	}
+	// 默认丢弃日志；需要排查时在 Node.Options 中替换 Logger。
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	// 默认放开权限，测试聚焦路由与子协议行为；需要验证权限时由 Node.Options 收紧。
+	opts.AuthDefaultRole = "superadmin"
+	opts.AuthDefaultPerms = "*"
+	opts.AuthRolePerms = "superadmin:*"
+	if parent == nil {
+		opts.NodeID = rootNodeID
+	} else {
+		opts.NodeID = 0
+		opts.ParentEnable = true
+		opts.ParentAddr = parent.Addr
+		opts.SelfID = node.Name
+	}
+	if node.Options != nil {
+		node.Options(&opts)
+	}
+
+	rt, err := hubruntime.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	h.Runtime = rt
+	h.cancel = rt.Subscribe(h.observe)
+
+	// Start 的 ctx 决定 runtime 的生命周期，不能带超时；超时只用于下面的等待。
+	if err := rt.Start(context.Background()); err != nil {
+		h.cancel()
+		return nil, err
+	}
+	c.hubs[h.Name] = h
+	c.order = append(c.order, h)
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	if err := c.Net.WaitListen(ctx, h.Addr); err != nil {
+		return nil, err
+	}
+	h.NodeID = rt.Status().NodeID
+	if h.NodeID == 0 {
+		return nil, fmt.Errorf("node id not assigned")
+	}
+	if parent == nil {
+		return h, nil
+	}
+	if err := h.waitRegistered(ctx); err != nil {
+		return nil, err
+	}
+	if err := parent.waitLoggedIn(ctx, h.NodeID); err != nil {
+		return nil, fmt.Errorf("parent %s: %w", parent.Name, err)
+	}
+	return h, nil
+}
+
+// seedAuthDisablePersist 在 dir 的持久配置中写入 auth.disable_persist=true。
+func seedAuthDisablePersist(dir string) error {
+	path := filepath.Join(dir, "config", "runtime_config.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(`{"auth.disable_persist":"true"}`), 0o600)
+}
+
+// stop 先停子 hub 再停父 hub，避免子 hub 在关闭过程中反复重连。
+func (c *Cluster) stop() {
+	for i := len(c.order) - 1; i >= 0; i-- {
+		h := c.order[i]
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		_ = h.Runtime.Stop(ctx)
+		cancel()
+		h.cancel()
+	}
+}
+
+// observe 记录在本 hub 登录的节点，并唤醒等待者。
+func (h *Hub) observe(ev hubruntime.Event) {
+	if ev.Type != hubruntime.EventChildLoggedIn {
+		return
+	}
+	h.mu.Lock()
+	h.loggedIn[ev.NodeID] = struct{}{}
+	close(h.changed)
+	h.changed = make(chan struct{})
+	h.mu.Unlock()
+}
+
+// waitLoggedIn 等待 nodeID 在本 hub 上完成登录（连接管理器绑定节点号）。
+func (h *Hub) waitLoggedIn(ctx context.Context, nodeID uint32) error {
+	for {
+		h.mu.Lock()
+		_, ok := h.loggedIn[nodeID]
+		changed := h.changed
+		h.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("node %d not logged in: %w", nodeID, ctx.Err())
+		}
+	}
+}
+
+// waitRegistered 等待本 hub 的父链 bootstrap register 被父 hub 接受。
+func (h *Hub) waitRegistered(ctx context.Context) error {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		st := h.Runtime.Status()
+		switch st.ParentRegisterState {
+		case hubruntime.ParentRegisterOK:
+			return nil
+		case hubruntime.ParentRegisterPending, hubruntime.ParentRegisterRejected:
+			return fmt.Errorf("parent register %s: %s", st.ParentRegisterState, st.ParentRegisterMessage)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("parent register not acknowledged (state=%q, last_error=%q): %w", st.ParentRegisterState, st.LastError, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package hubtest
+
+// 本文件覆盖 `hubtest` 中与 `cluster` 相关的行为。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yttydcs/myflowhub-core/header"
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestClusterMultiHopEcho(t *testing.T) {
+	c := Start(t, N("root",
+		N("A", N("C")),
+		N("B"),
+	))
+	if got := len(c.Hubs()); got != 4 {
+		t.Fatalf("hubs = %d, want 4", got)
+	}
+	if c.Hub("C").Parent != c.Hub("A") || c.Hub("A").Parent != c.Hub("root") {
+		t.Fatalf("unexpected topology")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cli := c.Client(t, "B", "dev-b")
+	// B -> root -> A -> C，再原路返回。
+	resp, err := Request[mgmtproto.NodeEchoResp](ctx, cli, c.Hub("C"), mgmtproto.SubProtoManagement, mgmtproto.ActionNodeEcho, mgmtproto.NodeEchoReq{Message: "ping"})
+	if err != nil {
+		t.Fatalf("node_echo: %v", err)
+	}
+	if resp.Code != 1 || resp.Echo != "ping" {
+		t.Fatalf("unexpected echo resp: %+v", resp)
+	}
+}
+
+func TestClusterIsolatesAuthState(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	for _, h := range c.Hubs() {
+		items, err := h.Runtime.ExplainConfig([]string{"auth.disable_persist"}, "")
+		if err != nil || len(items) != 1 || items[0].Value != "true" {
+			t.Fatalf("%s auth.disable_persist = %+v, err=%v", h.Name, items, err)
+		}
+		if _, err := os.Stat(filepath.Join(h.Runtime.Status().WorkDir, "config", "node_keys.json")); err != nil {
+			t.Fatalf("%s node keys should live under its workdir: %v", h.Name, err)
+		}
+	}
+	// 两个 hub 的节点身份互不相同。
+	keys := make(map[string]bool)
+	for _, h := range c.Hubs() {
+		items, _ := h.Runtime.ExplainConfig([]string{"auth.node_pubkey"}, "")
+		if len(items) != 1 || items[0].Value == "" || keys[items[0].Value] {
+			t.Fatalf("%s node pubkey missing or shared: %+v", h.Name, items)
+		}
+		keys[items[0].Value] = true
+	}
+}
+
+func TestClusterConfigActions(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cli := c.Client(t, "root", "dev-root")
+
+	schema, err := Request[mgmtproto.ConfigSchemaResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigSchema, mgmtproto.ConfigSchemaReq{Prefix: "flow."})
+	if err != nil || schema.Code != 1 || len(schema.Keys) == 0 {
+		t.Fatalf("config_schema: resp=%+v err=%v", schema, err)
+	}
+	for _, k := range schema.Keys {
+		if k.Key == "flow.backend" && (k.Type != mgmtproto.ConfigTypeEnum || k.Reload != mgmtproto.ConfigReloadRestart) {
+			t.Fatalf("unexpected flow.backend schema: %+v", k)
+		}
+	}
+
+	bad, err := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigSet, mgmtproto.ConfigSetReq{Key: "file.max_concurrent", Value: "0"})
+	if err != nil || bad.Code == 1 || bad.Msg == "" {
+		t.Fatalf("invalid config_set should fail with a message: resp=%+v err=%v", bad, err)
+	}
+	got, _ := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigGet, mgmtproto.ConfigGetReq{Key: "file.max_concurrent"})
+	if got.Value == "0" {
+		t.Fatalf("invalid value applied: %+v", got)
+	}
+
+	if _, err := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigSet, mgmtproto.ConfigSetReq{Key: "file.max_concurrent", Value: "8"}); err != nil {
+		t.Fatalf("config_set: %v", err)
+	}
+	explain, err := Request[mgmtproto.ConfigExplainResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigExplain, mgmtproto.ConfigExplainReq{Keys: []string{"file.max_concurrent"}})
+	if err != nil || explain.Code != 1 || len(explain.Items) != 1 {
+		t.Fatalf("config_explain: resp=%+v err=%v", explain, err)
+	}
+	if item := explain.Items[0]; item.Value != "8" || item.Source != mgmtproto.ConfigLayerPersistent {
+		t.Fatalf("unexpected explain item: %+v", item)
+	}
+
+	history, err := Request[mgmtproto.ConfigHistoryResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigHistory, mgmtproto.ConfigHistoryReq{Key: "file.max_concurrent"})
+	if err != nil || history.Code != 1 || len(history.Revisions) != 1 || history.Revisions[0].Actor == 0 {
+		t.Fatalf("config_history: resp=%+v err=%v", history, err)
+	}
+	rollback, err := Request[mgmtproto.ConfigRollbackResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigRollback, mgmtproto.ConfigRollbackReq{Rev: history.Revisions[0].Rev - 1})
+	if err != nil || rollback.Code != 1 || len(rollback.Keys) != 1 {
+		t.Fatalf("config_rollback: resp=%+v err=%v", rollback, err)
+	}
+	restored, _ := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigGet, mgmtproto.ConfigGetReq{Key: "file.max_concurrent"})
+	if restored.Value == "8" {
+		t.Fatalf("rollback not applied: %+v", restored)
+	}
+
+	// Secret 键：明文拒绝落盘，读取结果脱敏。
+	t.Setenv("HUBTEST_CLUSTER_PERMIT", "s3cret")
+	plain, err := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigSet, mgmtproto.ConfigSetReq{Key: "parent.join_permit", Value: "s3cret"})
+	if err != nil || plain.Code == 1 {
+		t.Fatalf("plain secret config_set should fail: resp=%+v err=%v", plain, err)
+	}
+	ref, err := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigSet, mgmtproto.ConfigSetReq{Key: "parent.join_permit", Value: "env:HUBTEST_CLUSTER_PERMIT"})
+	if err != nil || ref.Code != 1 {
+		t.Fatalf("secret reference config_set: resp=%+v err=%v", ref, err)
+	}
+	secret, err := Request[mgmtproto.ConfigResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionConfigGet, mgmtproto.ConfigGetReq{Key: "parent.join_permit"})
+	if err != nil || secret.Code != 1 || secret.Value != mgmtproto.ConfigRedactedValue {
+		t.Fatalf("secret config_get not redacted: resp=%+v err=%v", secret, err)
+	}
+}
+
+func TestClusterGracefulDrain(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	root := c.Hub("root")
+	cli := c.Client(t, "root", "dev-root")
+	parentDraining := make(chan hubruntime.Event, 1)
+	unsubscribe := c.Hub("A").Runtime.Subscribe(func(ev hubruntime.Event) {
+		if ev.Type == hubruntime.EventParentDraining {
+			parentDraining <- ev
+		}
+	})
+	defer unsubscribe()
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		stopped <- root.Runtime.Stop(ctx)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	frame, err := cli.Recv(ctx)
+	if err != nil {
+		t.Fatalf("recv node_draining: %v", err)
+	}
+	if frame.Header.Major() != header.MajorCmd || frame.Message.Action != mgmtproto.ActionNodeDraining {
+		t.Fatalf("unexpected frame major=%d action=%q", frame.Header.Major(), frame.Message.Action)
+	}
+	select {
+	case ev := <-parentDraining:
+		if ev.NodeID != root.NodeID {
+			t.Fatalf("parent.draining node=%d, want %d", ev.NodeID, root.NodeID)
+		}
+	case <-ctx.Done():
+		t.Fatalf("child hub did not observe parent drain")
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("stop did not finish")
+	}
+	if r := root.Runtime.Readiness(); r.Ready || r.Reasons[0] != hubruntime.ReadyReasonNotStarted {
+		t.Fatalf("readiness after stop=%+v", r)
+	}
+}
+
+func TestClusterRestartWithCachedNodeID(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	root, a := c.Hub("root"), c.Hub("A")
+	st := a.Runtime.Status()
+	if st.NodeIDSource != hubruntime.NodeIDSourceParent {
+		t.Fatalf("first start source=%q", st.NodeIDSource)
+	}
+	for _, h := range []*Hub{a, root} {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		if err := h.Runtime.Stop(ctx); err != nil {
+			t.Fatalf("stop %s: %v", h.Name, err)
+		}
+		cancel()
+	}
+
+	// 父节点不可达时，子 hub 以缓存的 nodeID 启动，不等待启动前注册。
+	opts := hubruntime.DefaultOptions()
+	opts.TCPEnable = true
+	opts.Addr = a.Addr
+	opts.Transport = c.Net
+	opts.WorkDir = st.WorkDir
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.ParentEnable = true
+	opts.ParentAddr = root.Addr
+	opts.SelfID = a.Name
+	rt, err := hubruntime.New(opts)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	begin := time.Now()
+	if err := rt.Start(context.Background()); err != nil {
+		t.Fatalf("offline start: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		_ = rt.Stop(ctx)
+	}()
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("offline start took %v", elapsed)
+	}
+	got := rt.Status()
+	if got.NodeID != st.NodeID || got.NodeIDSource != hubruntime.NodeIDSourceCache {
+		t.Fatalf("restart node=%d source=%q, want %d from cache", got.NodeID, got.NodeIDSource, st.NodeID)
+	}
+	if r := rt.Readiness(); r.Ready || r.Reasons[0] != hubruntime.ReadyReasonParentNotConnected {
+		t.Fatalf("readiness=%+v", r)
+	}
+}
+
+func TestClusterCachedNodeIDOverridesConfiguredNodeID(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	root, a := c.Hub("root"), c.Hub("A")
+	st := a.Runtime.Status()
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	if err := a.Runtime.Stop(ctx); err != nil {
+		t.Fatalf("stop A: %v", err)
+	}
+	cancel()
+
+	// 显式配置的 nodeID 与缓存的分配值不同：以分配值为准（记录 warn），上线后正常就绪。
+	opts := hubruntime.DefaultOptions()
+	opts.TCPEnable = true
+	opts.Addr = a.Addr
+	opts.Transport = c.Net
+	opts.WorkDir = st.WorkDir
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.ParentEnable = true
+	opts.ParentAddr = root.Addr
+	opts.SelfID = a.Name
+	opts.NodeID = st.NodeID + 100
+	rt, err := hubruntime.New(opts)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := rt.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		_ = rt.Stop(ctx)
+	}()
+	if got := rt.Status(); got.NodeID != st.NodeID || got.NodeIDSource != hubruntime.NodeIDSourceCache {
+		t.Fatalf("node=%d source=%q, want %d from cache", got.NodeID, got.NodeIDSource, st.NodeID)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !rt.Readiness().Ready {
+		if time.Now().After(deadline) {
+			t.Fatalf("readiness=%+v", rt.Readiness())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClusterMaintenanceMode(t *testing.T) {
+	c := Start(t, N("root", N("A")))
+	root := c.Hub("root")
+	cli := c.Client(t, "root", "dev-1")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set, err := Request[mgmtproto.MaintenanceResp](ctx, cli, root, mgmtproto.SubProtoManagement, mgmtproto.ActionMaintenanceSet, mgmtproto.MaintenanceSetReq{Enabled: true, Reason: "migrating"})
+	if err != nil || set.Code != 1 || !set.Enabled || set.Reason != "migrating" || set.SinceMs == 0 {
+		t.Fatalf("maintenance_set: resp=%+v err=%v", set, err)
+	}
+	if _, err := Dial(ctx, c.Net, root, "dev-2"); err == nil || !strings.Contains(err.Error(), "code=4503") || !strings.Contains(err.Error(), "migrating") {
+		t.Fatalf("new register during maintenance: err=%v", err)
+	}
+
+	// 已登录的客户端与子 hub 照常工作。
+	echo, err := Request[mgmtproto.NodeEchoResp](ctx, cli, c.Hub("A"), mgmtproto.SubProtoManagement, mgmtproto.ActionNodeEcho, mgmtproto.NodeEchoReq{Message: "ping"})
+	if err != nil || echo.Echo != "ping" {
+		t.Fatalf("echo during maintenance: resp=%+v err=%v", echo, err)
+	}
+	if r := root.Runtime.Readiness(); r.Ready || r.Reasons[0] != hubruntime.ReadyReasonMaintenance {
+		t.Fatalf("readiness=%+v", r)
+	}
+
+	root.Runtime.SetMaintenance(false, "")
+	get, err := Request[mgmtproto.MaintenanceResp](ctx, cli, root, mgmtproto.SubProtoManagement, mgmtproto.ActionMaintenanceGet, struct{}{})
+	if err != nil || get.Enabled || get.SinceMs != 0 {
+		t.Fatalf("maintenance_get: resp=%+v err=%v", get, err)
+	}
+	c.Client(t, "root", "dev-2")
+	if r := root.Runtime.Readiness(); !r.Ready {
+		t.Fatalf("readiness after maintenance=%+v", r)
+	}
+}
+
+func TestClusterMaintenanceSetRequiresPermission(t *testing.T) {
+	restricted := func(o *hubruntime.Options) {
+		o.AuthDefaultRole = "node"
+		o.AuthDefaultPerms = "flow.run"
+		o.AuthRolePerms = "node:flow.run"
+	}
+	c := Start(t, Node{Name: "root", Options: restricted})
+	root := c.Hub("root")
+	cli := c.Client(t, "root", "dev-1")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set, err := Request[mgmtproto.MaintenanceResp](ctx, cli, root, mgmtproto.SubProtoManagement, mgmtproto.ActionMaintenanceSet, mgmtproto.MaintenanceSetReq{Enabled: true, Reason: "migrating"})
+	if err != nil || set.Code != 403 || set.Enabled {
+		t.Fatalf("maintenance_set without permission: resp=%+v err=%v", set, err)
+	}
+	if root.Runtime.Maintenance().Enabled {
+		t.Fatalf("maintenance enabled by an unauthorized node")
+	}
+	// maintenance_get 只读，不要求权限。
+	get, err := Request[mgmtproto.MaintenanceResp](ctx, cli, root, mgmtproto.SubProtoManagement, mgmtproto.ActionMaintenanceGet, struct{}{})
+	if err != nil || get.Code != 1 || get.Enabled {
+		t.Fatalf("maintenance_get: resp=%+v err=%v", get, err)
+	}
+}
+
+func TestClusterTraceAcrossHops(t *testing.T) {
+	traced := func(o *hubruntime.Options) {
+		if err := os.WriteFile(filepath.Join(o.WorkDir, "hub.yaml"), []byte("trace:\n  exporter: file\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		o.ConfigFile = "hub.yaml"
+		o.ConfigFileReadOnly = true
+	}
+	tn := func(name string, children ...Node) Node {
+		return Node{Name: name, Children: children, Options: traced}
+	}
+	c := Start(t, tn("root", tn("A", tn("C")), tn("B")))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cli := c.Client(t, "B", "dev-b")
+	if _, err := Request[mgmtproto.NodeEchoResp](ctx, cli, c.Hub("C"), mgmtproto.SubProtoManagement, mgmtproto.ActionNodeEcho, mgmtproto.NodeEchoReq{Message: "ping"}); err != nil {
+		t.Fatalf("node_echo: %v", err)
+	}
+
+	type span struct {
+		TraceID string `json:"traceId"`
+		Name    string `json:"name"`
+	}
+	// spansOf 读取 hub 的 span 文件，按 trace id 返回 span 名。
+	spansOf := func(h *Hub) map[string][]string {
+		raw, _ := os.ReadFile(filepath.Join(h.Runtime.Status().WorkDir, "logs", "trace.jsonl"))
+		out := map[string][]string{}
+		for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+			var req struct {
+				ResourceSpans []struct {
+					ScopeSpans []struct {
+						Spans []span `json:"spans"`
+					} `json:"scopeSpans"`
+				} `json:"resourceSpans"`
+			}
+			if json.Unmarshal([]byte(line), &req) != nil {
+				continue
+			}
+			for _, rs := range req.ResourceSpans {
+				for _, ss := range rs.ScopeSpans {
+					for _, s := range ss.Spans {
+						out[s.TraceID] = append(out[s.TraceID], s.Name)
+					}
+				}
+			}
+		}
+		return out
+	}
+	// B -> root -> A -> C：node_echo 由沿途的 management handler 逐跳转发，响应在 pre-route 阶段原路转回。
+	// B 首次发送时分配 TraceID，之后每一跳都记在同一个 trace 下。
+	relay := []string{"management.node_echo", "send management.node_echo", "forward management.node_echo_resp"}
+	want := map[string][]string{
+		"B":    relay,
+		"root": relay,
+		"A":    relay,
+		"C":    {"management.node_echo", "send management.node_echo_resp"},
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var traceID, missing string
+		for _, name := range []string{"B", "root", "A", "C"} {
+			spans := spansOf(c.Hub(name))
+			if traceID == "" {
+				for id, names := range spans {
+					if containsAll(names, want[name]) {
+						traceID = id
+					}
+				}
+			}
+			if traceID == "" || !containsAll(spans[traceID], want[name]) {
+				missing = name
+				break
+			}
+		}
+		if missing == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hub %s has no spans %v under trace %q", missing, want[missing], traceID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNetworkPipe(t *testing.T) {
+	n := NewNetwork()
+	if _, err := n.DialConn(context.Background(), "nowhere:1"); err == nil {
+		t.Fatalf("expected refused dial")
+	}
+
+	client, server := newMemPipe("c", "s")
+	// 写入不等待对端读取。
+	for i := 0; i < 64; i++ {
+		if _, err := client.Write(make([]byte, 1024)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	buf := make([]byte, 64*1024)
+	total := 0
+	for total < 64*1024 {
+		n, err := server.Read(buf[total:])
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		total += n
+	}
+
+	_ = server.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := server.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	_ = server.SetReadDeadline(time.Time{})
+	_ = client.Close()
+	if _, err := server.Read(buf); err != io.EOF {
+		t.Fatalf("expected EOF after close, got %v", err)
+	}
+	if _, err := server.Write([]byte("x")); err == nil {
+		t.Fatalf("expected write error after peer close")
+	}
+}
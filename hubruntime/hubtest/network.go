@@ -0,0 +1,285 @@
+package hubtest
+
+// 本文件承载 `hubtest` 中与 `network` 相关的逻辑。
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/listener/tcp_listener"
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+)
+
+// Network 是进程内的“TCP”网络：地址只是名字，连接是带缓冲的内存管道。
+// 它实现 hubruntime.Transport，交给 Options.Transport 后 runtime 的 TCP listener 与父链拨号都走内存。
+type Network struct {
+	mu        sync.Mutex
+	listeners map[string]*memListener
+	// changed 在每次 bind / unbind 时被替换，用于唤醒 WaitListen。
+	changed chan struct{}
+	seq     atomic.Uint64
+}
+
+// NewNetwork 创建一个空的内存网络。
+func NewNetwork() *Network {
+	return &Network{listeners: make(map[string]*memListener), changed: make(chan struct{})}
+}
+
+// WaitListen 等待 addr 上出现 listener。runtime 的 Start 返回时 listener 可能尚未开始监听，
+// 与真实 TCP 一样需要等待。
+func (n *Network) WaitListen(ctx context.Context, addr string) error {
+	for {
+		n.mu.Lock()
+		_, ok := n.listeners[addr]
+		changed := n.changed
+		n.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("hubtest: %s not listening: %w", addr, ctx.Err())
+		}
+	}
+}
+
+// Listener 实现 hubruntime.Transport；地址在 Listen 时才被占用。
+func (n *Network) Listener(addr string) core.IListener {
+	return &memListener{net: n, addr: addr, accept: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// Dial 实现 hubruntime.Transport，返回包装成 Core 连接的客户端一端。
+func (n *Network) Dial(ctx context.Context, addr string) (core.IConnection, error) {
+	raw, err := n.DialConn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return tcp_listener.NewTCPConnection(raw), nil
+}
+
+// DialConn 连接到 addr 上的内存 listener，返回客户端一端的 net.Conn；地址无人监听时立即失败。
+func (n *Network) DialConn(ctx context.Context, addr string) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	n.mu.Lock()
+	l := n.listeners[addr]
+	n.mu.Unlock()
+	if l == nil {
+		return nil, fmt.Errorf("hubtest: dial %s: connection refused", addr)
+	}
+	id := n.seq.Add(1)
+	local := memAddr(fmt.Sprintf("client-%d", id))
+	client, server := newMemPipe(local, memAddr(addr))
+	select {
+	case l.accept <- server:
+		return client, nil
+	case <-l.done:
+		return nil, fmt.Errorf("hubtest: dial %s: connection refused", addr)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (n *Network) bind(l *memListener) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, taken := n.listeners[l.addr]; taken {
+		return fmt.Errorf("hubtest: listen %s: address already in use", l.addr)
+	}
+	n.listeners[l.addr] = l
+	n.signalLocked()
+	return nil
+}
+
+func (n *Network) unbind(l *memListener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.listeners[l.addr] == l {
+		delete(n.listeners, l.addr)
+		n.signalLocked()
+	}
+}
+
+func (n *Network) signalLocked() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+var _ hubruntime.Transport = (*Network)(nil)
+
+// memListener 把 Dial 投递来的服务端管道包装为 Core 连接并加入连接管理器，与 TCP listener 行为一致。
+type memListener struct {
+	net    *Network
+	addr   string
+	accept chan net.Conn
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (l *memListener) Protocol() string { return "tcp" }
+
+func (l *memListener) Addr() net.Addr { return memAddr(l.addr) }
+
+func (l *memListener) Listen(ctx context.Context, cm core.IConnectionManager) error {
+	if err := l.net.bind(l); err != nil {
+		return err
+	}
+	defer l.net.unbind(l)
+	for {
+		select {
+		case <-ctx.Done():
+			_ = l.Close()
+			return nil
+		case <-l.done:
+			return nil
+		case raw := <-l.accept:
+			if err := cm.Add(tcp_listener.NewTCPConnection(raw)); err != nil {
+				_ = raw.Close()
+			}
+		}
+	}
+}
+
+func (l *memListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// memAddr 是内存网络中的地址；连接 ID 由两端地址拼成，因此每条连接的客户端地址唯一。
+type memAddr string
+
+func (a memAddr) Network() string { return "hubtest" }
+func (a memAddr) String() string  { return string(a) }
+
+// newMemPipe 创建一对全双工内存连接。与 net.Pipe 不同，写入不等待对端读取（无界缓冲），
+// 避免 hub 向暂未读取的客户端推送时阻塞发送 worker。
+func newMemPipe(clientAddr, serverAddr memAddr) (client, server *memConn) {
+	up, down := newPipeBuffer(), newPipeBuffer()
+	client = &memConn{rd: down, wr: up, local: clientAddr, remote: serverAddr}
+	server = &memConn{rd: up, wr: down, local: serverAddr, remote: clientAddr}
+	return client, server
+}
+
+type memConn struct {
+	rd, wr        *pipeBuffer
+	local, remote memAddr
+	readDeadline  atomic.Value // time.Time
+}
+
+func (c *memConn) Read(p []byte) (int, error) {
+	var deadline time.Time
+	if v, ok := c.readDeadline.Load().(time.Time); ok {
+		deadline = v
+	}
+	return c.rd.read(p, deadline)
+}
+
+func (c *memConn) Write(p []byte) (int, error) { return c.wr.write(p) }
+
+func (c *memConn) Close() error {
+	c.rd.close()
+	c.wr.close()
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr  { return c.local }
+func (c *memConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(t)
+	c.rd.wake()
+	return nil
+}
+
+// SetWriteDeadline 是 no-op：写入从不阻塞。
+func (c *memConn) SetWriteDeadline(time.Time) error { return nil }
+
+// pipeBuffer 是单向字节缓冲；changed 在每次写入 / 关闭 / 截止时间变化时被替换，用于唤醒读者。
+type pipeBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	closed  bool
+	changed chan struct{}
+}
+
+func newPipeBuffer() *pipeBuffer {
+	return &pipeBuffer{changed: make(chan struct{})}
+}
+
+func (b *pipeBuffer) read(p []byte, deadline time.Time) (int, error) {
+	for {
+		b.mu.Lock()
+		if b.buf.Len() > 0 {
+			n, _ := b.buf.Read(p)
+			b.mu.Unlock()
+			return n, nil
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		if deadline.IsZero() {
+			<-changed
+			continue
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (b *pipeBuffer) write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, _ := b.buf.Write(p)
+	b.signalLocked()
+	return n, nil
+}
+
+func (b *pipeBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.signalLocked()
+	}
+}
+
+func (b *pipeBuffer) wake() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.signalLocked()
+}
+
+func (b *pipeBuffer) signalLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
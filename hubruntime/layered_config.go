@@ -3,8 +3,8 @@ package hubruntime
 // 本文件承载 `hubruntime` 中与 `layered_config` 相关的逻辑。
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,28 +14,61 @@ import (
 
 	core "github.com/yttydcs/myflowhub-core"
 	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
 )
 
 const runtimeConfigFile = "config/runtime_config.json"
 
 type layeredConfig struct {
 	mu sync.RWMutex
-
-	path       string
-	defaults   map[string]string
-	explicit   map[string]string
-	persistent map[string]string
-	runtime    map[string]string
-	effective  map[string]string
+	// persistMu 串行化持久层的读-改-写（写盘与历史记录），mu 只保护内存视图。
+	persistMu sync.Mutex
+
+	path        string
+	filePath    string
+	historyPath string // 持久配置变更历史；为空时不记录
+	defaults    map[string]string
+	file        map[string]string // 只读文件层（Options.ConfigFileReadOnly），已插值
+	explicit    map[string]string
+	stored      map[string]string // 持久文件原文，写回时使用
+	persistent  map[string]string // stored 插值后的结果
+	runtime     map[string]string
+	effective   map[string]string
+
+	onChange func(keys []string)
+	onReject func(key string, err error)
 }
 
 // buildConfig 以默认值、持久配置和显式传参三层叠加构造 runtime 配置。
-func buildConfig(opts Options) (core.IConfig, error) {
-	return newLayeredConfig(
-		runtimeConfigFile,
+// 持久配置文件缺省为 WorkDir 下的 config/runtime_config.json；设置 opts.ConfigFile 时：
+//   - ConfigFileReadOnly=false：该文件取代 runtime_config.json 作为持久层，config_set 按其格式写回；
+//   - ConfigFileReadOnly=true：该文件作为位于持久层之下的只读层，runtime_config.json 仍是持久层。
+func buildConfig(opts Options) (*layeredConfig, error) {
+	w := workDir(opts.WorkDir)
+	persistentPath := w.Resolve(runtimeConfigFile)
+	configFile := w.Resolve(opts.ConfigFile)
+	if configFile != "" && !opts.ConfigFileReadOnly {
+		persistentPath = configFile
+	}
+	explicit, err := explicitConfigData(opts)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := newLayeredConfig(
+		persistentPath,
 		configDataFromOptions(DefaultOptions()),
-		explicitConfigDataFromOptions(opts),
+		explicit,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if configFile != "" && opts.ConfigFileReadOnly {
+		if err := cfg.attachFileLayer(configFile); err != nil {
+			return nil, err
+		}
+	}
+	cfg.historyPath = w.Resolve(configHistoryFile)
+	return cfg, nil
 }
 
 // newLayeredConfig 加载持久配置文件，并生成一份线程安全的层叠配置视图。
@@ -44,14 +77,16 @@ func newLayeredConfig(path string, defaults, explicit map[string]string) (*layer
 	if path == "" {
 		return nil, errors.New("config path is required")
 	}
-	persistent, err := loadConfigMap(path)
+	stored, persistent, err := loadInterpolatedConfig(path)
 	if err != nil {
 		return nil, err
 	}
 	cfg := &layeredConfig{
 		path:       path,
 		defaults:   cloneStringMap(defaults),
+		file:       make(map[string]string),
 		explicit:   cloneStringMap(explicit),
+		stored:     stored,
 		persistent: persistent,
 		runtime:    make(map[string]string),
 		effective:  make(map[string]string),
@@ -60,6 +95,37 @@ func newLayeredConfig(path string, defaults, explicit map[string]string) (*layer
 	return cfg, nil
 }
 
+// attachFileLayer 挂载只读文件层；文件必须存在。只在启动期调用，不触发变更回调。
+func (c *layeredConfig) attachFileLayer(path string) error {
+	path = filepath.Clean(strings.TrimSpace(path))
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	_, data, err := loadInterpolatedConfig(path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.filePath = path
+	c.file = data
+	c.recomputeLocked()
+	c.mu.Unlock()
+	return nil
+}
+
+// loadInterpolatedConfig 读取配置文件，返回原文与插值后的两份表。
+func loadInterpolatedConfig(path string) (map[string]string, map[string]string, error) {
+	stored, err := loadConfigMap(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := interpolateConfigMap(stored, filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	return stored, data, nil
+}
+
 // Get 从当前生效配置快照中读取键值。
 func (c *layeredConfig) Get(key string) (string, bool) {
 	if c == nil {
@@ -92,6 +158,7 @@ func (c *layeredConfig) Keys() []string {
 
 // Set keeps current behavior: runtime-only overlay without touching persisted storage.
 // Set 只写运行期 overlay，不落盘，适合临时热更新。
+// 已登记键的非法取值不会写入，而是交给拒绝回调；未登记的键原样写入。
 func (c *layeredConfig) Set(key, val string) {
 	if c == nil {
 		return
@@ -100,66 +167,330 @@ func (c *layeredConfig) Set(key, val string) {
 	if key == "" {
 		return
 	}
+	if err := validateKnownConfigValue(key, val); err != nil {
+		c.reject(key, err)
+		return
+	}
 	c.mu.Lock()
 	c.runtime[key] = val
-	c.recomputeLocked()
+	changed, hook := c.recomputeAndDiffLocked()
 	c.mu.Unlock()
+	notifyConfigChange(hook, changed)
 }
 
-// SetPersistent 先写磁盘，再切换内存视图，确保重启后仍然生效。
+// SetPersistent 先写磁盘，再切换内存视图，确保重启后仍然生效；每次写入追加一条变更历史。
+// 写入前按 schema 校验：未登记的键与非法取值都会被拒绝，错误原样返回给调用方（management config_set）。
+// 取值中的 `${...}` 一律拒绝（见 checkWrittenConfigValue）；Secret 键只接受 `file:` / `env:` 引用，明文不落盘。
 func (c *layeredConfig) SetPersistent(key, val string) error {
+	return c.writePersistent(key, &val, configChange{source: mgmtproto.ConfigChangeSet})
+}
+
+// SetPersistentBy 同 SetPersistent，并在变更历史中记录发起方节点（management config_set 的来源）。
+func (c *layeredConfig) SetPersistentBy(key, val string, actor uint32) error {
+	return c.writePersistent(key, &val, configChange{source: mgmtproto.ConfigChangeSet, actor: actor})
+}
+
+// writePersistent 写入（val 为 nil 时删除）一个持久键并记录历史。
+func (c *layeredConfig) writePersistent(key string, val *string, change configChange) error {
+	return c.writePersistentBatch(map[string]*string{key: val}, change)
+}
+
+// writePersistentBatch 把一组键（值为 nil 时删除）一次写入持久层：全部校验通过后只落盘一次、
+// 只切换一次内存视图，任一键不合法时整体不生效。每个键追加一条变更历史。
+// 配置先落盘、再记历史：历史写入失败时变更已生效，错误中注明这一点。
+func (c *layeredConfig) writePersistentBatch(values map[string]*string, change configChange) error {
 	if c == nil {
 		return errors.New("config not initialized")
 	}
-	key = strings.TrimSpace(key)
-	if key == "" {
-		return errors.New("key is required")
+	keys := make([]string, 0, len(values))
+	trimmed := make(map[string]*string, len(values))
+	for key, val := range values {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return errors.New("key is required")
+		}
+		keys = append(keys, key)
+		trimmed[key] = val
 	}
+	sort.Strings(keys)
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
 
 	c.mu.RLock()
+	nextStored := cloneStringMap(c.stored)
 	nextPersistent := cloneStringMap(c.persistent)
 	path := c.path
+	historyPath := c.historyPath
 	c.mu.RUnlock()
 
-	nextPersistent[key] = val
-	if err := saveConfigMap(path, nextPersistent); err != nil {
+	revisions := make([]mgmtproto.ConfigRevision, 0, len(keys))
+	for _, key := range keys {
+		val := trimmed[key]
+		old := lookupStored(nextStored, key)
+		if val != nil && change.source == mgmtproto.ConfigChangeSet {
+			if err := checkWrittenConfigValue(key, *val); err != nil {
+				return err
+			}
+		}
+		if val == nil {
+			delete(nextStored, key)
+			delete(nextPersistent, key)
+		} else {
+			expanded, err := preparePersistentValue(key, *val, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			nextStored[key] = *val
+			nextPersistent[key] = expanded
+		}
+		revisions = append(revisions, newConfigRevision(key, old, val, change))
+	}
+	if err := saveConfigMap(path, nextStored); err != nil {
 		return err
 	}
 
 	c.mu.Lock()
+	c.stored = nextStored
 	c.persistent = nextPersistent
-	c.recomputeLocked()
+	changed, hook := c.recomputeAndDiffLocked()
 	c.mu.Unlock()
+	notifyConfigChange(hook, changed)
+
+	if err := appendConfigHistory(historyPath, revisions); err != nil {
+		return fmt.Errorf("config %s applied but history not recorded: %w", strings.Join(keys, ","), err)
+	}
 	return nil
 }
 
-// Merge 把另一份配置叠加到 runtime overlay，常用于动态注入。
+// checkWrittenConfigValue 拒绝经 management / runtime 写入的 `${...}`（包括转义写法 `$${`）。
+// 插值只在加载运营方编写的配置文件时进行；否则任一 management 对端都能借 config_set 让 hub 读取任意文件或环境变量，
+// 再经 config_get / config_explain 读回。
+func checkWrittenConfigValue(key, val string) error {
+	if strings.Contains(val, "${") {
+		return fmt.Errorf("config %s: ${...} references are only expanded in operator config files and cannot be written at runtime", key)
+	}
+	return nil
+}
+
+// preparePersistentValue 展开、校验将要落盘的原文，返回展开后的值。
+func preparePersistentValue(key, val, baseDir string) (string, error) {
+	expanded, err := expandConfigEntry(key, val, baseDir)
+	if err != nil {
+		return "", fmt.Errorf("config %s: %w", key, err)
+	}
+	if err := ValidateConfigValue(key, expanded); err != nil {
+		return "", err
+	}
+	if err := checkSecretPersist(key, val, expanded); err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// SetExplicit 整体替换显式覆盖层（env / flags / caller），供 runtime 重新配置时使用。
+func (c *layeredConfig) SetExplicit(explicit map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.explicit = cloneStringMap(explicit)
+	changed, hook := c.recomputeAndDiffLocked()
+	c.mu.Unlock()
+	notifyConfigChange(hook, changed)
+}
+
+// previewExplicit 返回把显式层替换为 explicit 后的 effective 视图，不修改当前配置、也不触发回调。
+// Reconfigure 用它先校验、切换 listener，成功后才提交 SetExplicit。
+func (c *layeredConfig) previewExplicit(explicit map[string]string) core.IConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	merged := cloneStringMap(c.defaults)
+	mergeStringMap(merged, c.file)
+	mergeStringMap(merged, c.persistent)
+	mergeStringMap(merged, explicit)
+	mergeStringMap(merged, c.runtime)
+	return coreconfig.NewMap(merged)
+}
+
+// ReloadPersistent 重新读取持久配置文件（以及只读文件层）并重算 effective，返回生效值发生变化的键。
+// 文件读取、解析、插值或校验失败时保留当前视图，避免半写入或手误的文件把运行中的 hub 打回默认值。
+// 持久文件被外部改动的键记入变更历史（来源 reload）。
+func (c *layeredConfig) ReloadPersistent() ([]string, error) {
+	if c == nil {
+		return nil, errors.New("config not initialized")
+	}
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	c.mu.RLock()
+	path := c.path
+	historyPath := c.historyPath
+	prevStored := c.stored
+	filePath := c.filePath
+	file := c.file
+	c.mu.RUnlock()
+
+	stored, persistent, err := loadInterpolatedConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfigMap(persistent); err != nil {
+		return nil, err
+	}
+	if filePath != "" {
+		if _, file, err = loadInterpolatedConfig(filePath); err != nil {
+			return nil, err
+		}
+		if err := validateConfigMap(file); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	c.stored = stored
+	c.persistent = persistent
+	c.file = file
+	changed, hook := c.recomputeAndDiffLocked()
+	c.mu.Unlock()
+	notifyConfigChange(hook, changed)
+
+	revisions := diffStoredRevisions(prevStored, stored, configChange{source: mgmtproto.ConfigChangeReload})
+	if err := appendConfigHistory(historyPath, revisions); err != nil {
+		return changed, fmt.Errorf("config reloaded but history not recorded: %w", err)
+	}
+	return changed, nil
+}
+
+// Path 返回持久配置文件路径。
+func (c *layeredConfig) Path() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.path
+}
+
+// FileLayerPath 返回只读文件层路径；未启用时为空。
+func (c *layeredConfig) FileLayerPath() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filePath
+}
+
+// SetChangeHook 注册 effective 变化回调；回调在锁外执行，可安全回读配置。
+func (c *layeredConfig) SetChangeHook(fn func(keys []string)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.onChange = fn
+	c.mu.Unlock()
+}
+
+// SetRejectHook 注册运行期写入被拒绝时的回调（Set / Merge 没有错误返回值）。
+func (c *layeredConfig) SetRejectHook(fn func(key string, err error)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.onReject = fn
+	c.mu.Unlock()
+}
+
+func (c *layeredConfig) reject(key string, err error) {
+	c.mu.RLock()
+	hook := c.onReject
+	c.mu.RUnlock()
+	if hook != nil {
+		hook(key, err)
+	}
+}
+
+// Merge 把另一份配置叠加到 runtime overlay，常用于动态注入；已登记键的非法取值被跳过。
 func (c *layeredConfig) Merge(other core.IConfig) core.IConfig {
 	if c == nil || other == nil {
 		return c
 	}
 	overlay := make(map[string]string)
 	for _, key := range other.Keys() {
-		if val, ok := other.Get(key); ok {
-			overlay[key] = val
+		val, ok := other.Get(key)
+		if !ok {
+			continue
+		}
+		if err := validateKnownConfigValue(key, val); err != nil {
+			c.reject(key, err)
+			continue
 		}
+		overlay[key] = val
 	}
 	c.mu.Lock()
 	mergeStringMap(c.runtime, overlay)
-	c.recomputeLocked()
+	changed, hook := c.recomputeAndDiffLocked()
 	c.mu.Unlock()
+	notifyConfigChange(hook, changed)
 	return c
 }
 
-// recomputeLocked 按 defaults -> persistent -> explicit -> runtime 的优先级重建快照。
+// recomputeLocked 按 defaults -> file -> persistent -> explicit -> runtime 的优先级重建快照。
 func (c *layeredConfig) recomputeLocked() {
 	merged := cloneStringMap(c.defaults)
+	mergeStringMap(merged, c.file)
 	mergeStringMap(merged, c.persistent)
 	mergeStringMap(merged, c.explicit)
 	mergeStringMap(merged, c.runtime)
 	c.effective = snapshotConfig(coreconfig.NewMap(merged))
 }
 
+// recomputeAndDiffLocked 重算 effective，并返回发生变化的键及当前回调。
+func (c *layeredConfig) recomputeAndDiffLocked() ([]string, func([]string)) {
+	prev := c.effective
+	c.recomputeLocked()
+	return diffConfigKeys(prev, c.effective), c.onChange
+}
+
+// notifyConfigChange 在有变化时调用回调。
+func notifyConfigChange(hook func([]string), changed []string) {
+	if hook == nil || len(changed) == 0 {
+		return
+	}
+	hook(changed)
+}
+
+// diffConfigKeys 返回两份快照之间新增、删除或取值变化的键，结果稳定排序。
+func diffConfigKeys(prev, next map[string]string) []string {
+	var changed []string
+	for key, val := range next {
+		if old, ok := prev[key]; !ok || old != val {
+			changed = append(changed, key)
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// validateConfigMap 按键序校验一份配置中已登记键的取值，返回首个错误。
+func validateConfigMap(data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validateKnownConfigValue(key, data[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // configDataFromOptions 把 Options 投影成 Core runtime 所需的扁平键值。
 func configDataFromOptions(opts Options) map[string]string {
 	return map[string]string{
@@ -168,6 +499,8 @@ func configDataFromOptions(opts Options) map[string]string {
 		coreconfig.KeyParentEnable:       boolString(opts.ParentEnable),
 		coreconfig.KeyParentJoinPermit:   strings.TrimSpace(opts.ParentJoinPermit),
 		coreconfig.KeyParentReconnectSec: strconv.Itoa(opts.ParentReconnectSec),
+		configKeyParentEndpoints:         strings.TrimSpace(opts.ParentEndpoints),
+		configKeyParentTransportPrefer:   strings.TrimSpace(opts.ParentTransportPrefer),
 
 		coreconfig.KeyProcChannelCount:   strconv.Itoa(opts.ProcChannels),
 		coreconfig.KeyProcWorkersPerChan: strconv.Itoa(opts.ProcWorkers),
@@ -184,6 +517,8 @@ func configDataFromOptions(opts Options) map[string]string {
 		coreconfig.KeyAuthDefaultPerms: strings.TrimSpace(opts.AuthDefaultPerms),
 		coreconfig.KeyAuthNodeRoles:    strings.TrimSpace(opts.AuthNodeRoles),
 		coreconfig.KeyAuthRolePerms:    strings.TrimSpace(opts.AuthRolePerms),
+
+		configKeyLogLevel: strings.TrimSpace(opts.LogLevel),
 	}
 }
 
@@ -219,6 +554,12 @@ func applyConfigToOptions(opts Options, cfg core.IConfig) Options {
 			opts.ParentAddr = target
 		}
 	}
+	if val, ok := cfg.Get(configKeyParentEndpoints); ok {
+		opts.ParentEndpoints = strings.TrimSpace(val)
+	}
+	if val, ok := cfg.Get(configKeyParentTransportPrefer); ok {
+		opts.ParentTransportPrefer = strings.TrimSpace(val)
+	}
 	if val, ok := cfg.Get(coreconfig.KeyParentEnable); ok {
 		opts.ParentEnable = parseBoolValue(val, opts.ParentEnable)
 	}
@@ -289,41 +630,6 @@ func parseIntValue(raw string, def int) int {
 	return n
 }
 
-func loadConfigMap(path string) (map[string]string, error) {
-	raw, err := os.ReadFile(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return make(map[string]string), nil
-		}
-		return nil, err
-	}
-	if len(strings.TrimSpace(string(raw))) == 0 {
-		return make(map[string]string), nil
-	}
-	var data map[string]string
-	if err := json.Unmarshal(raw, &data); err != nil {
-		return nil, err
-	}
-	if data == nil {
-		data = make(map[string]string)
-	}
-	return data, nil
-}
-
-func saveConfigMap(path string, data map[string]string) error {
-	dir := filepath.Dir(path)
-	if dir != "" {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return err
-		}
-	}
-	raw, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
-	}
-	return writeFileAtomic(path, raw, 0o600)
-}
-
 func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
 	tmp := path + ".tmp"
 	if err := os.WriteFile(tmp, data, perm); err != nil {
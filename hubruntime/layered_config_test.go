@@ -92,6 +92,66 @@ func TestLayeredConfigAllowsExplicitEmptyAuthRolePermsOverride(t *testing.T) {
 	assertConfigValue(t, cfg, coreconfig.KeyAuthRolePerms, "")
 }
 
+func TestLayeredConfigReloadPersistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime_config.json")
+	if err := saveConfigMap(path, map[string]string{
+		coreconfig.KeyAuthRolePerms: "admin:*",
+		"node.display_name":         "Edge A",
+	}); err != nil {
+		t.Fatalf("seed persistent config: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.Addr = "127.0.0.1:7000"
+	opts.AddConfigOverrideKeys("addr")
+	cfg, err := newLayeredConfig(path, configDataFromOptions(DefaultOptions()), explicitConfigDataFromOptions(opts))
+	if err != nil {
+		t.Fatalf("newLayeredConfig: %v", err)
+	}
+	var notified []string
+	cfg.SetChangeHook(func(keys []string) { notified = append(notified, keys...) })
+
+	if err := saveConfigMap(path, map[string]string{
+		coreconfig.KeyAuthRolePerms: "admin:*;node:file.read",
+		"node.display_name":         "Edge A",
+		"addr":                      "127.0.0.1:7100",
+	}); err != nil {
+		t.Fatalf("rewrite persistent config: %v", err)
+	}
+	changed, err := cfg.ReloadPersistent()
+	if err != nil {
+		t.Fatalf("ReloadPersistent: %v", err)
+	}
+
+	// addr is shadowed by the explicit layer, so only role perms changes effectively.
+	if len(changed) != 1 || changed[0] != coreconfig.KeyAuthRolePerms {
+		t.Fatalf("unexpected changed keys: %v", changed)
+	}
+	if len(notified) != 1 || notified[0] != coreconfig.KeyAuthRolePerms {
+		t.Fatalf("unexpected notified keys: %v", notified)
+	}
+	assertConfigValue(t, cfg, coreconfig.KeyAuthRolePerms, "admin:*;node:file.read")
+	assertConfigValue(t, cfg, "addr", "127.0.0.1:7000")
+}
+
+func TestLayeredConfigReloadKeepsViewOnBrokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime_config.json")
+	if err := saveConfigMap(path, map[string]string{"node.display_name": "Edge A"}); err != nil {
+		t.Fatalf("seed persistent config: %v", err)
+	}
+	cfg, err := newLayeredConfig(path, configDataFromOptions(DefaultOptions()), nil)
+	if err != nil {
+		t.Fatalf("newLayeredConfig: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{broken"), 0o600); err != nil {
+		t.Fatalf("write broken config: %v", err)
+	}
+	if _, err := cfg.ReloadPersistent(); err == nil {
+		t.Fatalf("expected parse error")
+	}
+	assertConfigValue(t, cfg, "node.display_name", "Edge A")
+}
+
 func TestApplyConfigToOptions(t *testing.T) {
 	opts := DefaultOptions()
 	opts.Addr = ":1234"
@@ -0,0 +1,509 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `listener_group` 相关的逻辑。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/listener/quic_listener"
+	"github.com/yttydcs/myflowhub-core/listener/rfcomm_listener"
+	"github.com/yttydcs/myflowhub-core/listener/tcp_listener"
+)
+
+const (
+	listenerNameTCP    = "tcp"
+	listenerNameQUIC   = "quic"
+	listenerNameRFCOMM = "rfcomm"
+
+	// metaListenerKey 记录连接由哪个 listener 接入，用于按 listener 关闭连接与统计。
+	metaListenerKey = "listener"
+
+	// listenerSettleDelay 是替换 listener 后判定“启动成功”的观察窗口；
+	// 绑定端口失败等错误会在窗口内从 Listen 直接返回。
+	listenerSettleDelay = 200 * time.Millisecond
+	listenerStopTimeout = 2 * time.Second
+)
+
+// listenerSpec 是单个 listener 的可比较描述；两次 spec 相等即视为无需重建。
+type listenerSpec struct {
+	Name string
+
+	Addr string
+
+	QUICALPN              string
+	QUICCertFile          string
+	QUICKeyFile           string
+	QUICClientCAFile      string
+	QUICRequireClientCert bool
+
+	RFCOMMUUID     string
+	RFCOMMChannel  int
+	RFCOMMAdapter  string
+	RFCOMMInsecure bool
+}
+
+// listenerSpecsFromOptions 把 Options 中启用的 listener 投影成按名称索引的 spec。
+func listenerSpecsFromOptions(opts Options) map[string]listenerSpec {
+	specs := make(map[string]listenerSpec, 3)
+	if opts.TCPEnable {
+		specs[listenerNameTCP] = listenerSpec{Name: listenerNameTCP, Addr: opts.Addr}
+	}
+	if opts.QUICEnable {
+		specs[listenerNameQUIC] = listenerSpec{
+			Name:                  listenerNameQUIC,
+			Addr:                  opts.QUICAddr,
+			QUICALPN:              opts.QUICALPN,
+			QUICCertFile:          opts.QUICCertFile,
+			QUICKeyFile:           opts.QUICKeyFile,
+			QUICClientCAFile:      opts.QUICClientCAFile,
+			QUICRequireClientCert: opts.QUICRequireClientCert,
+		}
+	}
+	if opts.RFCOMMEnable {
+		specs[listenerNameRFCOMM] = listenerSpec{
+			Name:           listenerNameRFCOMM,
+			RFCOMMUUID:     opts.RFCOMMUUID,
+			RFCOMMChannel:  opts.RFCOMMChannel,
+			RFCOMMAdapter:  opts.RFCOMMAdapter,
+			RFCOMMInsecure: opts.RFCOMMInsecure,
+		}
+	}
+	return specs
+}
+
+// newListenerFromSpec 按 spec 构造对应 transport 的 Core listener。
+func newListenerFromSpec(spec listenerSpec, log *slog.Logger) core.IListener {
+	switch spec.Name {
+	case listenerNameTCP:
+		return tcp_listener.New(spec.Addr, tcp_listener.Options{
+			KeepAlive:       true,
+			KeepAlivePeriod: 30 * time.Second,
+			Logger:          log,
+		})
+	case listenerNameQUIC:
+		return quic_listener.New(quic_listener.Options{
+			Addr:              spec.Addr,
+			ALPN:              spec.QUICALPN,
+			CertFile:          spec.QUICCertFile,
+			KeyFile:           spec.QUICKeyFile,
+			ClientCAFile:      spec.QUICClientCAFile,
+			RequireClientCert: spec.QUICRequireClientCert,
+			Logger:            log,
+		})
+	case listenerNameRFCOMM:
+		return rfcomm_listener.New(rfcomm_listener.Options{
+			UUID:     spec.RFCOMMUUID,
+			Channel:  spec.RFCOMMChannel,
+			Adapter:  spec.RFCOMMAdapter,
+			Insecure: spec.RFCOMMInsecure,
+			Logger:   log,
+		})
+	default:
+		return nil
+	}
+}
+
+// listenerFactory 允许测试注入内存 listener。
+type listenerFactory func(spec listenerSpec, log *slog.Logger) core.IListener
+
+type listenerSlot struct {
+	spec    listenerSpec
+	lst     core.IListener
+	done    chan struct{}
+	err     error
+	closing bool
+}
+
+type slotExit struct {
+	slot *listenerSlot
+	err  error
+}
+
+// listenerGroup 是一组可按名称独立替换的 listener，对 Core server 表现为单个 IListener。
+//
+// 与 multi_listener 的区别：
+//   - 单个 listener 出错不会连带关闭其他 listener；
+//   - 运行期可通过 Swap 只重建变化的 listener，未变化的 listener 与其上的连接保持不动；
+//   - 只有在非替换期间所有 listener 都退出、或一次 Swap 结束后没有任何 listener 在运行时，
+//     Listen 才返回错误（交由 server 自行停止）。
+type listenerGroup struct {
+	log     *slog.Logger
+	factory listenerFactory
+	// onError 在 listener 非预期退出且带错误时调用；须在 Listen 之前设置。
+	onError func(listener string, err error)
+	// onFail 在 Listen 因没有任何 listener 在运行而带错误返回时调用；须在 Listen 之前设置。
+	onFail func(err error)
+
+	swapMu sync.Mutex
+
+	mu        sync.Mutex
+	initial   map[string]listenerSpec
+	ctx       context.Context
+	cm        core.IConnectionManager
+	slots     map[string]*listenerSlot
+	swapping  bool
+	closed    bool
+	exitCh    chan slotExit
+	failCh    chan error // Swap 结束后没有 listener 在运行时投递，使 Listen 返回
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newListenerGroup(specs map[string]listenerSpec, factory listenerFactory, log *slog.Logger) *listenerGroup {
+	if factory == nil {
+		factory = newListenerFromSpec
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	initial := make(map[string]listenerSpec, len(specs))
+	for name, spec := range specs {
+		initial[name] = spec
+	}
+	return &listenerGroup{
+		log:     log,
+		factory: factory,
+		initial: initial,
+		slots:   make(map[string]*listenerSlot),
+		exitCh:  make(chan slotExit, 4),
+		failCh:  make(chan error, 1),
+		closeCh: make(chan struct{}),
+	}
+}
+
+func (g *listenerGroup) Protocol() string { return "group" }
+
+// Addr 优先返回 TCP listener 的实际监听地址。
+func (g *listenerGroup) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, name := range []string{listenerNameTCP, listenerNameQUIC, listenerNameRFCOMM} {
+		if slot, ok := g.slots[name]; ok && slot.lst != nil {
+			if addr := slot.lst.Addr(); addr != nil {
+				return addr
+			}
+		}
+	}
+	return nil
+}
+
+// Listen 启动初始 listener，并阻塞到 ctx 取消、Close 或所有 listener 异常退出。
+func (g *listenerGroup) Listen(ctx context.Context, cm core.IConnectionManager) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return errors.New("listener group already closed")
+	}
+	if len(g.initial) == 0 {
+		g.mu.Unlock()
+		return errors.New("no listener enabled")
+	}
+	g.ctx = ctx
+	g.cm = cm
+	for _, name := range sortedSpecNames(g.initial) {
+		g.startSlotLocked(g.initial[name])
+	}
+	g.mu.Unlock()
+
+	var firstErr error
+	for {
+		select {
+		case <-ctx.Done():
+			_ = g.Close()
+			return nil
+		case <-g.closeCh:
+			return nil
+		case err := <-g.failCh:
+			g.reportFail(err)
+			return err
+		case ex := <-g.exitCh:
+			if ex.err != nil && firstErr == nil {
+				firstErr = ex.err
+			}
+			g.mu.Lock()
+			running := len(g.slots)
+			swapping := g.swapping
+			g.mu.Unlock()
+			if running == 0 && !swapping {
+				if firstErr == nil {
+					firstErr = errors.New("all listeners exited")
+				}
+				g.reportFail(firstErr)
+				return firstErr
+			}
+		}
+	}
+}
+
+func (g *listenerGroup) reportFail(err error) {
+	if g.onFail != nil {
+		g.onFail(err)
+	}
+}
+
+// Close 关闭全部 listener；已接入的连接由 server 负责关闭。
+func (g *listenerGroup) Close() error {
+	g.closeOnce.Do(func() { close(g.closeCh) })
+	g.mu.Lock()
+	g.closed = true
+	slots := make([]*listenerSlot, 0, len(g.slots))
+	for _, slot := range g.slots {
+		slot.closing = true
+		slots = append(slots, slot)
+	}
+	g.slots = make(map[string]*listenerSlot)
+	g.mu.Unlock()
+
+	var firstErr error
+	for _, slot := range slots {
+		if err := slot.lst.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Specs 返回当前正在运行的 listener spec。
+func (g *listenerGroup) Specs() map[string]listenerSpec {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]listenerSpec, len(g.slots))
+	for name, slot := range g.slots {
+		out[name] = slot.spec
+	}
+	return out
+}
+
+// Swap 把运行中的 listener 调整为目标 spec 集合，返回实际重建或关闭的 listener 名称。
+//   - spec 未变化的 listener 不动；
+//   - spec 变化的 listener 先关闭再按新 spec 启动，已接入连接保留；
+//   - 被禁用的 listener 关闭，并断开经它接入的连接；
+//   - 新 listener 启动失败时尝试恢复旧 spec，并返回错误。
+func (g *listenerGroup) Swap(specs map[string]listenerSpec) ([]string, error) {
+	g.swapMu.Lock()
+	defer g.swapMu.Unlock()
+
+	g.mu.Lock()
+	if g.ctx == nil || g.closed {
+		g.mu.Unlock()
+		return nil, errors.New("listener group not running")
+	}
+	current := make(map[string]listenerSpec, len(g.slots))
+	for name, slot := range g.slots {
+		current[name] = slot.spec
+	}
+	g.swapping = true
+	g.mu.Unlock()
+	var swapErr error
+	defer func() {
+		g.mu.Lock()
+		g.swapping = false
+		empty := len(g.slots) == 0 && !g.closed
+		g.mu.Unlock()
+		// 替换期间的退出不会让 Listen 返回；新旧 listener 都起不来时由这里让 Listen 带错误返回，
+		// 否则 group 没有任何 listener 却一直阻塞。
+		if empty {
+			err := swapErr
+			if err == nil {
+				err = errors.New("all listeners exited")
+			}
+			select {
+			case g.failCh <- err:
+			default:
+			}
+		}
+	}()
+
+	names := make(map[string]listenerSpec, len(current)+len(specs))
+	for name, spec := range current {
+		names[name] = spec
+	}
+	for name, spec := range specs {
+		names[name] = spec
+	}
+
+	var changed []string
+	for _, name := range sortedSpecNames(names) {
+		oldSpec, hasOld := current[name]
+		newSpec, hasNew := specs[name]
+		if hasOld && hasNew && oldSpec == newSpec {
+			continue
+		}
+		if hasOld {
+			g.stopSlot(name)
+		}
+		if !hasNew {
+			g.closeConns(name)
+			changed = append(changed, name)
+			g.log.Info("listener disabled", "listener", name)
+			continue
+		}
+		if err := g.startAndSettle(newSpec); err != nil {
+			if hasOld {
+				if rerr := g.startAndSettle(oldSpec); rerr != nil {
+					g.log.Error("restore previous listener failed", "listener", name, "err", rerr)
+				}
+			}
+			swapErr = fmt.Errorf("start %s listener: %w", name, err)
+			return changed, swapErr
+		}
+		changed = append(changed, name)
+		g.log.Info("listener rebuilt", "listener", name)
+	}
+	return changed, nil
+}
+
+// startSlotLocked 启动一个 listener goroutine；调用方需持有 g.mu。
+func (g *listenerGroup) startSlotLocked(spec listenerSpec) *listenerSlot {
+	slot := &listenerSlot{
+		spec: spec,
+		lst:  g.factory(spec, g.log),
+		done: make(chan struct{}),
+	}
+	g.slots[spec.Name] = slot
+	if slot.lst == nil {
+		slot.err = fmt.Errorf("unsupported listener %q", spec.Name)
+		delete(g.slots, spec.Name)
+		close(slot.done)
+		return slot
+	}
+	ctx := g.ctx
+	cm := &taggedConnManager{IConnectionManager: g.cm, listener: spec.Name}
+	go func() {
+		err := slot.lst.Listen(ctx, cm)
+		g.mu.Lock()
+		slot.err = err
+		expected := slot.closing
+		if g.slots[spec.Name] == slot {
+			delete(g.slots, spec.Name)
+		}
+		g.mu.Unlock()
+		close(slot.done)
+		if expected {
+			return
+		}
+		if err != nil {
+			g.log.Error("listener exited", "listener", spec.Name, "err", err)
+			if g.onError != nil {
+				g.onError(spec.Name, err)
+			}
+		}
+		select {
+		case g.exitCh <- slotExit{slot: slot, err: err}:
+		case <-g.closeCh:
+		}
+	}()
+	return slot
+}
+
+// startAndSettle 启动 listener 并在观察窗口内确认其没有立即失败。
+func (g *listenerGroup) startAndSettle(spec listenerSpec) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return errors.New("listener group closed")
+	}
+	slot := g.startSlotLocked(spec)
+	g.mu.Unlock()
+
+	timer := time.NewTimer(listenerSettleDelay)
+	defer timer.Stop()
+	select {
+	case <-slot.done:
+		g.mu.Lock()
+		err := slot.err
+		g.mu.Unlock()
+		if err == nil {
+			err = errors.New("listener exited during startup")
+		}
+		return err
+	case <-timer.C:
+		return nil
+	}
+}
+
+// stopSlot 关闭指定 listener 并等待其 Listen 返回。
+func (g *listenerGroup) stopSlot(name string) {
+	g.mu.Lock()
+	slot, ok := g.slots[name]
+	if ok {
+		slot.closing = true
+		delete(g.slots, name)
+	}
+	g.mu.Unlock()
+	if !ok {
+		return
+	}
+	_ = slot.lst.Close()
+	select {
+	case <-slot.done:
+	case <-time.After(listenerStopTimeout):
+		g.log.Warn("listener stop timeout", "listener", name)
+	}
+}
+
+// closeConns 断开经指定 listener 接入的连接。
+func (g *listenerGroup) closeConns(name string) {
+	g.mu.Lock()
+	cm := g.cm
+	g.mu.Unlock()
+	if cm == nil {
+		return
+	}
+	var conns []core.IConnection
+	cm.Range(func(c core.IConnection) bool {
+		if connListenerName(c) == name {
+			conns = append(conns, c)
+		}
+		return true
+	})
+	for _, c := range conns {
+		_ = c.Close()
+	}
+}
+
+// connListenerName 返回连接的接入 listener 名称；父链等主动拨出的连接返回空串。
+func connListenerName(c core.IConnection) string {
+	if c == nil {
+		return ""
+	}
+	if v, ok := c.GetMeta(metaListenerKey); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// taggedConnManager 在连接加入管理器前打上接入 listener 标记。
+type taggedConnManager struct {
+	core.IConnectionManager
+	listener string
+}
+
+func (m *taggedConnManager) Add(conn core.IConnection) error {
+	if conn != nil {
+		conn.SetMeta(metaListenerKey, m.listener)
+	}
+	return m.IConnectionManager.Add(conn)
+}
+
+func sortedSpecNames(specs map[string]listenerSpec) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,224 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `listener_group` 相关的行为。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/connmgr"
+	"github.com/yttydcs/myflowhub-core/listener/tcp_listener"
+)
+
+// fakeListener 启动时向连接管理器加入一条 net.Pipe 连接，然后阻塞到 Close。
+type fakeListener struct {
+	spec   listenerSpec
+	fail   bool
+	peer   net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *fakeListener) Protocol() string { return l.spec.Name }
+func (l *fakeListener) Addr() net.Addr   { return nil }
+func (l *fakeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+func (l *fakeListener) Listen(ctx context.Context, cm core.IConnectionManager) error {
+	if l.fail {
+		return errors.New("bind failed")
+	}
+	local, peer := net.Pipe()
+	l.peer = peer
+	conn := &pipeTestConn{IConnection: tcp_listener.NewTCPConnection(local), id: fmt.Sprintf("pipe-%d", pipeConnSeq.Add(1))}
+	if err := cm.Add(conn); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-l.closed:
+	}
+	return nil
+}
+
+var pipeConnSeq atomic.Uint32
+
+// pipeTestConn 为 net.Pipe 连接提供唯一 ID（net.Pipe 的地址恒为 "pipe"）。
+type pipeTestConn struct {
+	core.IConnection
+	id string
+}
+
+func (c *pipeTestConn) ID() string { return c.id }
+
+type fakeListenerFactory struct {
+	mu      sync.Mutex
+	created []*fakeListener
+	failOn  string
+	failAll bool
+}
+
+func (f *fakeListenerFactory) build(spec listenerSpec, _ *slog.Logger) core.IListener {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := &fakeListener{spec: spec, fail: f.failAll || spec.Addr == f.failOn, closed: make(chan struct{})}
+	f.created = append(f.created, l)
+	return l
+}
+
+func (f *fakeListenerFactory) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.created)
+}
+
+func (f *fakeListenerFactory) get(i int) *fakeListener {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created[i]
+}
+
+func startTestGroup(t *testing.T, opts Options, factory *fakeListenerFactory) (*listenerGroup, core.IConnectionManager) {
+	t.Helper()
+	cm := connmgr.New()
+	group := newListenerGroup(listenerSpecsFromOptions(opts), factory.build, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = group.Listen(ctx, cm) }()
+	deadline := time.Now().Add(time.Second)
+	for len(group.Specs()) != len(listenerSpecsFromOptions(opts)) || cm.Count() != len(listenerSpecsFromOptions(opts)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("listener group did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return group, cm
+}
+
+func TestListenerGroupSwapRebuildsOnlyChanged(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Addr = "127.0.0.1:7001"
+	opts.QUICEnable = true
+	opts.QUICAddr = "127.0.0.1:7002"
+	factory := &fakeListenerFactory{}
+	group, cm := startTestGroup(t, opts, factory)
+	if factory.count() != 2 {
+		t.Fatalf("initial listeners=%d want=2", factory.count())
+	}
+
+	next := opts
+	next.Addr = "127.0.0.1:7101"
+	changed, err := group.Swap(listenerSpecsFromOptions(next))
+	if err != nil {
+		t.Fatalf("Swap: %v", err)
+	}
+	if !equalStrings(changed, []string{listenerNameTCP}) {
+		t.Fatalf("changed=%v want=[tcp]", changed)
+	}
+	if factory.count() != 3 {
+		t.Fatalf("listeners created=%d want=3", factory.count())
+	}
+	if got := group.Specs()[listenerNameTCP].Addr; got != "127.0.0.1:7101" {
+		t.Fatalf("tcp addr=%q", got)
+	}
+	// Connections accepted by the rebuilt listener stay connected.
+	if cm.Count() != 3 {
+		t.Fatalf("conn count=%d want=3", cm.Count())
+	}
+}
+
+func TestListenerGroupSwapDisableClosesConns(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Addr = "127.0.0.1:7001"
+	opts.QUICEnable = true
+	opts.QUICAddr = "127.0.0.1:7002"
+	factory := &fakeListenerFactory{}
+	group, _ := startTestGroup(t, opts, factory)
+
+	var quic *fakeListener
+	for i := 0; i < factory.count(); i++ {
+		if l := factory.get(i); l.spec.Name == listenerNameQUIC {
+			quic = l
+		}
+	}
+	next := opts
+	next.QUICEnable = false
+	changed, err := group.Swap(listenerSpecsFromOptions(next))
+	if err != nil {
+		t.Fatalf("Swap: %v", err)
+	}
+	if !equalStrings(changed, []string{listenerNameQUIC}) {
+		t.Fatalf("changed=%v want=[quic]", changed)
+	}
+	_ = quic.peer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := quic.peer.Read(make([]byte, 1)); !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("quic conn should be closed, read err=%v", err)
+	}
+}
+
+func TestListenerGroupSwapRestoresOnFailure(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Addr = "127.0.0.1:7001"
+	factory := &fakeListenerFactory{failOn: "127.0.0.1:7999"}
+	group, _ := startTestGroup(t, opts, factory)
+
+	next := opts
+	next.Addr = "127.0.0.1:7999"
+	if _, err := group.Swap(listenerSpecsFromOptions(next)); err == nil {
+		t.Fatalf("expected swap error")
+	}
+	if got := group.Specs()[listenerNameTCP].Addr; got != "127.0.0.1:7001" {
+		t.Fatalf("tcp addr after failed swap=%q want restored", got)
+	}
+}
+
+func TestListenerGroupListenFailsWhenSwapLeavesNoListener(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Addr = "127.0.0.1:7001"
+	factory := &fakeListenerFactory{}
+	cm := connmgr.New()
+	group := newListenerGroup(listenerSpecsFromOptions(opts), factory.build, nil)
+	failed := make(chan error, 1)
+	group.onFail = func(err error) { failed <- err }
+	done := make(chan error, 1)
+	go func() { done <- group.Listen(context.Background(), cm) }()
+	deadline := time.Now().Add(time.Second)
+	for len(group.Specs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("listener group did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 新地址与恢复旧地址都绑定失败：group 不再有 listener，Listen 必须带错误返回而不是一直阻塞。
+	factory.mu.Lock()
+	factory.failAll = true
+	factory.mu.Unlock()
+	next := opts
+	next.Addr = "127.0.0.1:7999"
+	if _, err := group.Swap(listenerSpecsFromOptions(next)); err == nil {
+		t.Fatalf("expected swap error")
+	}
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "start tcp listener") {
+			t.Fatalf("Listen err=%v", err)
+		}
+		if ferr := <-failed; ferr != err {
+			t.Fatalf("onFail err=%v want %v", ferr, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Listen kept blocking with no listener running")
+	}
+}
@@ -0,0 +1,209 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `logging` 相关的逻辑。
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+)
+
+// 日志级别配置键：log.level 为全局级别，log.level.<component> 覆盖单个组件；两者都可运行期修改。
+const (
+	configKeyLogLevel       = "log.level"
+	configKeyLogLevelPrefix = "log.level."
+
+	// logComponentKey 是标识日志组件的属性名，与 defaultset 派生组件 logger 时使用的属性名一致。
+	logComponentKey = defaultset.LogComponentKey
+)
+
+// 日志组件。runtime 为 hubruntime 自身，core 为 Core 的 server / dispatcher / 路由，其余为同名子协议模块（定义在 defaultset）。
+const (
+	LogComponentRuntime    = "runtime"
+	LogComponentCore       = "core"
+	LogComponentManagement = defaultset.LogComponentManagement
+	LogComponentAuth       = defaultset.LogComponentAuth
+	LogComponentVarStore   = defaultset.LogComponentVarStore
+	LogComponentTopicBus   = defaultset.LogComponentTopicBus
+	LogComponentExec       = defaultset.LogComponentExec
+	LogComponentFlow       = defaultset.LogComponentFlow
+	LogComponentFile       = defaultset.LogComponentFile
+	LogComponentStream     = defaultset.LogComponentStream
+	LogComponentForward    = defaultset.LogComponentForward
+)
+
+// logLevelNames 是日志级别键允许的取值。
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// parseLogLevel 解析 debug / info / warn(ing) / error（不区分大小写）。
+func parseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", raw)
+	}
+}
+
+// logLevels 保存全局级别与按组件的覆盖级别；读取路径只有原子操作。
+type logLevels struct {
+	def slog.LevelVar
+
+	mu         sync.Mutex
+	components map[string]*componentLevel
+}
+
+// componentLevel 是单个组件的级别；set 为 false 时沿用全局级别。
+type componentLevel struct {
+	set   atomic.Bool
+	level slog.LevelVar
+}
+
+func newLogLevels() *logLevels {
+	return &logLevels{components: make(map[string]*componentLevel)}
+}
+
+// component 返回组件的级别槽位，不存在时创建；logger 在 With 时解析一次并缓存。
+func (l *logLevels) component(name string) *componentLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.components[name]
+	if !ok {
+		c = &componentLevel{}
+		l.components[name] = c
+	}
+	return c
+}
+
+func (l *logLevels) enabled(c *componentLevel, level slog.Level) bool {
+	if c != nil && c.set.Load() {
+		return level >= c.level.Level()
+	}
+	return level >= l.def.Level()
+}
+
+// apply 按配置重设全部级别：未设置或为空的组件键恢复为沿用全局级别。
+// 非法取值（只可能来自文件层，写入路径已按 schema 校验）被忽略并返回，级别保持原值。
+func (l *logLevels) apply(cfg core.IConfig) []error {
+	var errs []error
+	level := slog.LevelInfo
+	if raw := trimmedConfigValue(cfg, configKeyLogLevel); raw != "" {
+		parsed, err := parseLogLevel(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", configKeyLogLevel, err))
+		} else {
+			level = parsed
+		}
+	}
+	l.def.Set(level)
+
+	l.mu.Lock()
+	for _, name := range logComponents {
+		if _, ok := l.components[name]; !ok {
+			l.components[name] = &componentLevel{}
+		}
+	}
+	components := make(map[string]*componentLevel, len(l.components))
+	for name, c := range l.components {
+		components[name] = c
+	}
+	l.mu.Unlock()
+
+	for name, c := range components {
+		key := configKeyLogLevelPrefix + name
+		raw := trimmedConfigValue(cfg, key)
+		if raw == "" {
+			c.set.Store(false)
+			continue
+		}
+		parsed, err := parseLogLevel(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		c.level.Set(parsed)
+		c.set.Store(true)
+	}
+	return errs
+}
+
+// logComponents 是 schema 中登记了 log.level.<component> 的组件。
+var logComponents = []string{
+	LogComponentRuntime, LogComponentCore, LogComponentManagement, LogComponentAuth, LogComponentVarStore,
+	LogComponentTopicBus, LogComponentExec, LogComponentFlow, LogComponentFile, LogComponentStream, LogComponentForward,
+}
+
+// levelRouter 包装宿主提供的 slog.Handler，按 logger 上的 component 属性决定级别。
+// 级别判定完全由 levelRouter 负责：通过判定的记录直接交给内层 Handle，不再经过内层的 Enabled，
+// 因此宿主 handler 固定在 Info 时，单个组件仍可以调到 debug。
+type levelRouter struct {
+	inner  slog.Handler
+	levels *logLevels
+	comp   *componentLevel
+	// grouped 为 true 时后续属性属于某个 group，其中的 component 不再视为组件标识。
+	grouped bool
+}
+
+func newLevelRouter(inner slog.Handler, levels *logLevels) *levelRouter {
+	return &levelRouter{inner: inner, levels: levels}
+}
+
+func (h *levelRouter) Enabled(_ context.Context, level slog.Level) bool {
+	return h.levels.enabled(h.comp, level)
+}
+
+func (h *levelRouter) Handle(ctx context.Context, rec slog.Record) error {
+	return h.inner.Handle(ctx, rec)
+}
+
+func (h *levelRouter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.inner = h.inner.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == logComponentKey {
+				out.comp = h.levels.component(a.Value.String())
+			}
+		}
+	}
+	return &out
+}
+
+func (h *levelRouter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.inner = h.inner.WithGroup(name)
+	out.grouped = true
+	return &out
+}
+
+// applyLogLevels 把当前配置中的日志级别应用到 runtime 的 logger。
+func (r *Runtime) applyLogLevels(cfg core.IConfig) {
+	for _, err := range r.logLevels.apply(cfg) {
+		r.log.Warn("invalid log level ignored", "err", err)
+	}
+}
+
+// hasLogLevelKey 判断变化的键中是否包含日志级别键。
+func hasLogLevelKey(keys []string) bool {
+	for _, key := range keys {
+		if key == configKeyLogLevel || strings.HasPrefix(key, configKeyLogLevelPrefix) {
+			return true
+		}
+	}
+	return false
+}
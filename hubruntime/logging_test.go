@@ -0,0 +1,95 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `logging` 相关的行为。
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+)
+
+func TestLevelRouterPerComponent(t *testing.T) {
+	var buf bytes.Buffer
+	levels := newLogLevels()
+	// 宿主 handler 固定在 Info，级别判定由 router 负责。
+	base := slog.New(newLevelRouter(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}), levels))
+	flow := defaultset.ComponentLogger(base, LogComponentFlow)
+	auth := defaultset.ComponentLogger(base, LogComponentAuth)
+
+	if errs := levels.apply(coreconfig.NewMap(map[string]string{"log.level.flow": "debug"})); len(errs) != 0 {
+		t.Fatalf("apply: %v", errs)
+	}
+	flow.Debug("flow debug")
+	auth.Debug("auth debug")
+	auth.Info("auth info")
+	out := buf.String()
+	if !strings.Contains(out, `"msg":"flow debug"`) || !strings.Contains(out, `"component":"flow"`) {
+		t.Fatalf("flow debug missing: %s", out)
+	}
+	if strings.Contains(out, "auth debug") || !strings.Contains(out, "auth info") {
+		t.Fatalf("auth should stay at info: %s", out)
+	}
+
+	// 组件键清空后回落到全局级别；group 内的 component 属性不视为组件标识。
+	buf.Reset()
+	if errs := levels.apply(coreconfig.NewMap(map[string]string{"log.level": "warn", "log.level.flow": ""})); len(errs) != 0 {
+		t.Fatalf("apply: %v", errs)
+	}
+	flow.Info("flow info")
+	base.WithGroup("req").With(logComponentKey, LogComponentFlow).Info("grouped info")
+	base.Warn("global warn")
+	if out := buf.String(); strings.Contains(out, "flow info") || strings.Contains(out, "grouped info") || !strings.Contains(out, "global warn") {
+		t.Fatalf("after reset: %s", out)
+	}
+
+	errs := levels.apply(coreconfig.NewMap(map[string]string{"log.level": "loud", "log.level.auth": "error"}))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "log.level") {
+		t.Fatalf("invalid level errs=%v", errs)
+	}
+	if !levels.enabled(nil, slog.LevelInfo) || levels.enabled(levels.component(LogComponentAuth), slog.LevelWarn) {
+		t.Fatalf("invalid global level should fall back to info, auth should be error")
+	}
+}
+
+func TestHasLogLevelKey(t *testing.T) {
+	if !hasLogLevelKey([]string{"file.base_dir", "log.level.stream"}) || !hasLogLevelKey([]string{"log.level"}) {
+		t.Fatalf("log level keys not detected")
+	}
+	if hasLogLevelKey([]string{"log.levels", "file.base_dir"}) {
+		t.Fatalf("unrelated keys detected")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "hub.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for name, want := range map[string]string{"hub.log": "dddddd\n", "hub.log.1": "cccccc\n", "hub.log.2": "bbbbbb\n"} {
+		raw, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil || string(raw) != want {
+			t.Fatalf("%s=%q err=%v, want %q", name, raw, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("backups beyond limit should be removed: %v", err)
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Fatalf("write after close should fail")
+	}
+}
@@ -0,0 +1,234 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `maintenance` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/subproto/kit"
+	"github.com/yttydcs/myflowhub-server/modules"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+	fileproto "github.com/yttydcs/myflowhub-server/protocol/file"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+const maintenanceRejectMsg = "hub in maintenance"
+
+// MaintenanceStatus 是维护模式的快照；Since 为开启时间，未开启时为零值。
+type MaintenanceStatus struct {
+	Enabled bool
+	Reason  string
+	Since   time.Time
+}
+
+// maintenanceState 保存维护模式；零值为关闭。读取路径（每个入站帧）只有一次原子读取。
+type maintenanceState struct {
+	enabled atomic.Bool
+
+	mu     sync.Mutex
+	reason string
+	since  time.Time
+}
+
+// set 切换维护模式并返回是否发生变化；已开启时再次开启只更新 reason。
+func (m *maintenanceState) set(enabled bool, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.enabled.Load() != enabled
+	switch {
+	case enabled && changed:
+		m.since = time.Now()
+		m.reason = reason
+	case enabled:
+		m.reason = reason
+	default:
+		m.since = time.Time{}
+		m.reason = ""
+	}
+	m.enabled.Store(enabled)
+	return changed
+}
+
+func (m *maintenanceState) snapshot() MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MaintenanceStatus{Enabled: m.enabled.Load(), Reason: m.reason, Since: m.since}
+}
+
+// rejectMsg 返回拒绝响应中的 msg：固定前缀加上可选的原因。
+func (m *maintenanceState) rejectMsg() string {
+	st := m.snapshot()
+	if st.Reason == "" {
+		return maintenanceRejectMsg
+	}
+	return maintenanceRejectMsg + ": " + st.Reason
+}
+
+// SetMaintenance 开启或关闭维护模式，可在 Start 之前调用，跨越 Stop / Start 保持；不持久化，进程重启后为关闭。
+// 维护模式下 hub 继续服务已登录的节点，但：
+//   - 拒绝新连接上的 register / login（code=mgmtproto.MaintenanceCode）；
+//   - 拒绝新的 flow run 与文件 pull / offer；
+//   - readiness 报告 maintenance。
+func (r *Runtime) SetMaintenance(enabled bool, reason string) {
+	if !r.maintenance.set(enabled, reason) {
+		return
+	}
+	typ := EventMaintenanceDisabled
+	if enabled {
+		typ = EventMaintenanceEnabled
+		r.log.Warn("maintenance mode enabled", "reason", reason)
+	} else {
+		r.log.Info("maintenance mode disabled")
+	}
+	r.emit(Event{Type: typ, Message: reason})
+}
+
+// Maintenance 返回维护模式的当前状态。
+func (r *Runtime) Maintenance() MaintenanceStatus {
+	return r.maintenance.snapshot()
+}
+
+// maintenanceDispatcher 在注册 auth / flow / file / management handler 时套上 maintenanceHandler。
+type maintenanceDispatcher struct {
+	inner modules.Dispatcher
+	r     *Runtime
+}
+
+func (d maintenanceDispatcher) RegisterHandler(h core.ISubProcess) error {
+	if h != nil {
+		switch h.SubProto() {
+		case authproto.SubProtoAuth, flowproto.SubProtoFlow, fileproto.SubProtoFile, mgmtproto.SubProtoManagement:
+			h = &maintenanceHandler{ISubProcess: h, r: d.r}
+		}
+	}
+	return d.inner.RegisterHandler(h)
+}
+
+func (d maintenanceDispatcher) RegisterDefaultHandler(h core.ISubProcess) {
+	d.inner.RegisterDefaultHandler(h)
+}
+
+// maintenanceHandler 在本地应答 maintenance_get / maintenance_set，并在维护模式下拒绝新的接入与会话。
+// 已登录连接（meta nodeID 非零）与父链上的帧不受影响。
+type maintenanceHandler struct {
+	core.ISubProcess
+	r *Runtime
+}
+
+func (h *maintenanceHandler) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	sub := h.ISubProcess.SubProto()
+	if sub == mgmtproto.SubProtoManagement {
+		if !h.handleAction(ctx, conn, hdr, payload) {
+			h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+		}
+		return
+	}
+	state := &h.r.maintenance
+	if !state.enabled.Load() {
+		h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+		return
+	}
+	switch sub {
+	case authproto.SubProtoAuth:
+		if rejectNewLogin(ctx, conn, hdr, payload, state.rejectMsg()) {
+			return
+		}
+	default:
+		if rejectNewSession(ctx, conn, hdr, sub, payload, mgmtproto.MaintenanceCode, state.rejectMsg()) {
+			return
+		}
+	}
+	h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+}
+
+// handleAction 应答发给本节点的 maintenance_get / maintenance_set；其他帧返回 false。
+func (h *maintenanceHandler) handleAction(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) bool {
+	srv := core.ServerFromContext(ctx)
+	if srv == nil || hdr == nil || (hdr.TargetID() != 0 && hdr.TargetID() != srv.NodeID()) {
+		return false
+	}
+	var action string
+	code, msg := 1, "ok"
+	switch frameAction(mgmtproto.SubProtoManagement, payload) {
+	case mgmtproto.ActionMaintenanceGet:
+		action = mgmtproto.ActionMaintenanceGetResp
+	case mgmtproto.ActionMaintenanceSet:
+		action = mgmtproto.ActionMaintenanceSetResp
+		var req mgmtproto.MaintenanceSetReq
+		decodeActionData(payload, &req)
+		if denied := h.r.maintenanceSetDenied(conn, hdr); denied != "" {
+			code, msg = 403, denied
+			h.r.log.Warn("maintenance_set denied", "source", hdr.SourceID(), "enabled", req.Enabled, "reason", denied)
+			break
+		}
+		h.r.log.Info("maintenance_set received", "source", hdr.SourceID(), "enabled", req.Enabled)
+		h.r.SetMaintenance(req.Enabled, req.Reason)
+	default:
+		return false
+	}
+	st := h.r.Maintenance()
+	resp := mgmtproto.MaintenanceResp{Code: code, Msg: msg, Enabled: st.Enabled, Reason: st.Reason}
+	if !st.Since.IsZero() {
+		resp.SinceMs = st.Since.UnixMilli()
+	}
+	sendConfigActionResp(ctx, conn, hdr, action, resp)
+	return true
+}
+
+// maintenanceSetDenied 判定 maintenance_set 的发起方，允许时返回空串，否则返回拒绝原因：
+//   - 父链上的帧是父节点下发的控制指令，直接放行（子节点信任父节点，见 permission.md）；
+//   - 其余帧须来自已登录连接（meta nodeID 非零），且 SourceID 对应节点拥有 mgmtproto.PermMaintenanceSet。
+//
+// 权限快照取自 handlers 共享的运行期依赖，随 auth.* 配置热更新。
+func (r *Runtime) maintenanceSetDenied(conn core.IConnection, hdr core.IHeader) string {
+	if conn == nil {
+		return "permission denied"
+	}
+	if isParentConn(conn) {
+		return ""
+	}
+	source := connNodeID(conn)
+	if source == 0 {
+		return "login required"
+	}
+	if id := hdr.SourceID(); id != 0 {
+		source = id
+	}
+	r.mu.Lock()
+	perms := r.set.Deps.PermConfig
+	r.mu.Unlock()
+	if perms == nil || !perms.Has(source, mgmtproto.PermMaintenanceSet) {
+		return "permission denied"
+	}
+	return ""
+}
+
+// rejectNewLogin 拒绝未登录连接上的 register / login 并返回 true；assist_* 与已登录连接的帧返回 false。
+func rejectNewLogin(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte, msg string) bool {
+	if conn == nil || isParentConn(conn) || connNodeID(conn) != 0 {
+		return false
+	}
+	var respAction, deviceID string
+	switch frameAction(authproto.SubProtoAuth, payload) {
+	case authproto.ActionRegister:
+		var req authproto.RegisterData
+		decodeActionData(payload, &req)
+		respAction, deviceID = authproto.ActionRegisterResp, req.DeviceID
+	case authproto.ActionLogin:
+		var req authproto.LoginData
+		decodeActionData(payload, &req)
+		respAction, deviceID = authproto.ActionLoginResp, req.DeviceID
+	default:
+		return false
+	}
+	raw, _ := json.Marshal(authproto.RespData{Code: mgmtproto.MaintenanceCode, Msg: msg, DeviceID: deviceID})
+	body, _ := json.Marshal(authproto.Message{Action: respAction, Data: raw})
+	kit.SendResponse(ctx, nil, conn, hdr, body, authproto.SubProtoAuth)
+	return true
+}
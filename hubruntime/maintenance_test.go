@@ -0,0 +1,74 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `maintenance` 相关的行为。
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/header"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestMaintenanceHandlerRejectsNewLogins(t *testing.T) {
+	rt := &Runtime{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	auth := &drainTestInner{sub: authproto.SubProtoAuth}
+	flow := &drainTestInner{sub: flowproto.SubProtoFlow}
+	authH := &maintenanceHandler{ISubProcess: auth, r: rt}
+	flowH := &maintenanceHandler{ISubProcess: flow, r: rt}
+	hdr := (&header.HeaderTcp{}).WithMajor(header.MajorCmd).WithSubProto(authproto.SubProtoAuth).WithMsgID(9)
+
+	register := drainTestPayload(t, authproto.ActionRegister, authproto.RegisterData{DeviceID: "dev-new"})
+	login := drainTestPayload(t, authproto.ActionLogin, authproto.LoginData{DeviceID: "dev-new"})
+	assist := drainTestPayload(t, authproto.ActionAssistRegister, authproto.RegisterData{DeviceID: "dev-deep"})
+	run := drainTestPayload(t, flowproto.ActionRun, flowproto.RunReq{ReqID: "r1", FlowID: "f1"})
+
+	fresh := &registerTestConn{id: "fresh"}
+	authH.OnReceive(context.Background(), fresh, hdr, register)
+	if auth.received != 1 || len(fresh.sent) != 0 {
+		t.Fatalf("maintenance off: received=%d sent=%d", auth.received, len(fresh.sent))
+	}
+
+	rt.SetMaintenance(true, "migrating")
+	authH.OnReceive(context.Background(), fresh, hdr, register)
+	authH.OnReceive(context.Background(), fresh, hdr, login)
+	flowH.OnReceive(context.Background(), fresh, hdr, run)
+	if auth.received != 1 || flow.received != 0 || len(fresh.sent) != 3 {
+		t.Fatalf("maintenance on: auth=%d flow=%d sent=%d", auth.received, flow.received, len(fresh.sent))
+	}
+	for i, want := range []string{authproto.ActionRegisterResp, authproto.ActionLoginResp} {
+		var resp authproto.RespData
+		decodeActionData(fresh.sent[i].payload, &resp)
+		if frameAction(authproto.SubProtoAuth, fresh.sent[i].payload) != want || resp.Code != mgmtproto.MaintenanceCode ||
+			resp.Msg != "hub in maintenance: migrating" || resp.DeviceID != "dev-new" {
+			t.Fatalf("auth rejection %d=%s", i, fresh.sent[i].payload)
+		}
+	}
+	var runResp flowproto.RunResp
+	decodeActionData(fresh.sent[2].payload, &runResp)
+	if runResp.Code != mgmtproto.MaintenanceCode || runResp.ReqID != "r1" {
+		t.Fatalf("run rejection=%s", fresh.sent[2].payload)
+	}
+
+	// 已登录连接、父链与 assist_* 不受影响。
+	loggedIn := &registerTestConn{id: "logged-in"}
+	loggedIn.SetMeta("nodeID", uint32(5))
+	authH.OnReceive(context.Background(), loggedIn, hdr, login)
+	authH.OnReceive(context.Background(), fresh, hdr, assist)
+	if auth.received != 3 || len(loggedIn.sent) != 0 || len(fresh.sent) != 3 {
+		t.Fatalf("pass-through: auth=%d", auth.received)
+	}
+	if st := rt.Maintenance(); !st.Enabled || st.Reason != "migrating" || st.Since.IsZero() {
+		t.Fatalf("status=%+v", st)
+	}
+
+	rt.SetMaintenance(false, "")
+	authH.OnReceive(context.Background(), fresh, hdr, register)
+	if auth.received != 4 || rt.Maintenance().Enabled {
+		t.Fatalf("maintenance off again: auth=%d", auth.received)
+	}
+}
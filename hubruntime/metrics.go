@@ -0,0 +1,572 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `metrics` 相关的逻辑。
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+	execproto "github.com/yttydcs/myflowhub-server/protocol/exec"
+	fileproto "github.com/yttydcs/myflowhub-server/protocol/file"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+	managementproto "github.com/yttydcs/myflowhub-server/protocol/management"
+	streamproto "github.com/yttydcs/myflowhub-server/protocol/stream"
+	topicbusproto "github.com/yttydcs/myflowhub-server/protocol/topicbus"
+	varstoreproto "github.com/yttydcs/myflowhub-server/protocol/varstore"
+)
+
+const (
+	// FrameDirectionIn / FrameDirectionOut 是帧计数的方向标签。
+	FrameDirectionIn  = "in"
+	FrameDirectionOut = "out"
+
+	// metricsActionOther 是超出基数上限或无法识别的 action 标签。
+	metricsActionOther = "other"
+	// metricsMaxActionsPerSubProto 限制单个子协议下 action 标签的数量，防止异常 payload 撑爆指标基数。
+	metricsMaxActionsPerSubProto = 64
+	// metricsActionScanLimit 限制 action / code 字段的扫描窗口，避免大 payload 上的线性开销。
+	metricsActionScanLimit = 4096
+	// metricsMaxActionLen 是 action 标签允许的最大长度。
+	metricsMaxActionLen = 64
+)
+
+// handlerLatencyBuckets / stateOpLatencyBuckets 是延迟直方图的上界（秒）。
+var (
+	handlerLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	stateOpLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// Metrics 是 runtime 指标的一次性快照，切片均按标签稳定排序。
+type Metrics struct {
+	Connections []ConnectionMetric
+
+	DispatchQueue QueueMetric
+	SendQueue     QueueMetric
+
+	// HandlerInFlight 是正在执行的子协议 handler 调用数。
+	HandlerInFlight int64
+
+	Frames         []FrameMetric
+	HandlerLatency []LatencyMetric
+	StateOps       []StateOpMetric
+}
+
+// ConnectionMetric 按接入 listener 与连接角色统计当前连接数。
+// Listener 为空表示主动拨出的连接（例如父链）。
+type ConnectionMetric struct {
+	Listener string
+	Role     string
+	Count    int
+}
+
+// QueueMetric 描述 dispatcher / send dispatcher 的队列配置。
+// 队列积压深度尚未提供：Core 的队列为私有字段且满队列丢帧不可观测，
+// 在 runtime 侧计数入队 / 出队会随丢帧漂移，需等 Core 导出积压接口后补齐。
+type QueueMetric struct {
+	Channels int
+	Workers  int
+	Capacity int
+}
+
+// FrameMetric 按方向、子协议与 action 统计帧数、字节数与错误数。
+//   - in 方向的错误是 handler panic；
+//   - out 方向的错误是发送失败或响应 code >= 400。
+type FrameMetric struct {
+	Direction string
+	SubProto  uint8
+	Action    string
+	Frames    uint64
+	Bytes     uint64
+	Errors    uint64
+}
+
+// Histogram 是累积直方图：Counts[i] 为耗时 <= Bounds[i] 的样本数，Count 为全部样本数。
+type Histogram struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// LatencyMetric 是单个子协议 action 的 handler 耗时分布。
+type LatencyMetric struct {
+	SubProto uint8
+	Action   string
+	Histogram
+}
+
+// StateOpMetric 是单个状态后端操作的耗时分布与错误数。
+type StateOpMetric struct {
+	Kind    string
+	Backend string
+	Op      string
+	Errors  uint64
+	Histogram
+}
+
+// SubProtoName 返回子协议编号对应的可读名称，未知编号返回十进制字符串。
+func SubProtoName(sub uint8) string {
+	switch sub {
+	case managementproto.SubProtoManagement:
+		return "management"
+	case authproto.SubProtoAuth:
+		return "auth"
+	case varstoreproto.SubProtoVarStore:
+		return "varstore"
+	case topicbusproto.SubProtoTopicBus:
+		return "topicbus"
+	case fileproto.SubProtoFile:
+		return "file"
+	case flowproto.SubProtoFlow:
+		return "flow"
+	case execproto.SubProtoExec:
+		return "exec"
+	case streamproto.SubProtoStream:
+		return "stream"
+	default:
+		return strconv.Itoa(int(sub))
+	}
+}
+
+type frameKey struct {
+	direction string
+	sub       uint8
+	action    string
+}
+
+type frameCounter struct {
+	frames atomic.Uint64
+	bytes  atomic.Uint64
+	errors atomic.Uint64
+}
+
+type latencyKey struct {
+	sub    uint8
+	action string
+}
+
+type stateOpKey struct {
+	kind    string
+	backend string
+	op      string
+}
+
+type stateOpStats struct {
+	errors atomic.Uint64
+	hist   *histogram
+}
+
+// histogram 是并发安全的固定桶直方图；counts 按桶非累积存储，快照时再累加。
+type histogram struct {
+	bounds []float64
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	sec := d.Seconds()
+	idx := sort.SearchFloat64s(h.bounds, sec)
+	h.mu.Lock()
+	if idx < len(h.counts) {
+		h.counts[idx]++
+	}
+	h.count++
+	h.sum += sec
+	h.mu.Unlock()
+}
+
+func (h *histogram) snapshot() Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := Histogram{
+		Bounds: append([]float64(nil), h.bounds...),
+		Counts: make([]uint64, len(h.counts)),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+	var acc uint64
+	for i, n := range h.counts {
+		acc += n
+		out.Counts[i] = acc
+	}
+	return out
+}
+
+// runtimeMetrics 汇总帧、handler 与状态后端的计数器；连接与队列在快照时现场读取。
+type runtimeMetrics struct {
+	inflight atomic.Int64
+
+	mu       sync.RWMutex
+	frames   map[frameKey]*frameCounter
+	latency  map[latencyKey]*histogram
+	stateOps map[stateOpKey]*stateOpStats
+	actions  map[uint8]map[string]struct{}
+}
+
+func newRuntimeMetrics() *runtimeMetrics {
+	return &runtimeMetrics{
+		frames:   make(map[frameKey]*frameCounter),
+		latency:  make(map[latencyKey]*histogram),
+		stateOps: make(map[stateOpKey]*stateOpStats),
+		actions:  make(map[uint8]map[string]struct{}),
+	}
+}
+
+// actionLabel 返回受基数上限约束的 action 标签。
+func (m *runtimeMetrics) actionLabel(sub uint8, action string) string {
+	if action == "" || action == metricsActionOther {
+		return action
+	}
+	m.mu.RLock()
+	_, ok := m.actions[sub][action]
+	m.mu.RUnlock()
+	if ok {
+		return action
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	known := m.actions[sub]
+	if known == nil {
+		known = make(map[string]struct{})
+		m.actions[sub] = known
+	}
+	if _, ok := known[action]; ok {
+		return action
+	}
+	if len(known) >= metricsMaxActionsPerSubProto {
+		return metricsActionOther
+	}
+	known[action] = struct{}{}
+	return action
+}
+
+// observeFrame 记录一帧收发；action 由 payload 廉价扫描得出。
+func (m *runtimeMetrics) observeFrame(direction string, hdr core.IHeader, payload []byte, failed bool) {
+	if m == nil || hdr == nil {
+		return
+	}
+	sub := hdr.SubProto()
+	c := m.frameCounter(direction, sub, frameAction(sub, payload))
+	c.frames.Add(1)
+	c.bytes.Add(uint64(len(payload)))
+	if failed {
+		c.errors.Add(1)
+	}
+}
+
+// observeHandlerPanic 把一次 handler panic 记为对应入站帧的错误。
+func (m *runtimeMetrics) observeHandlerPanic(sub uint8, action string) {
+	if m == nil {
+		return
+	}
+	m.frameCounter(FrameDirectionIn, sub, action).errors.Add(1)
+}
+
+func (m *runtimeMetrics) frameCounter(direction string, sub uint8, action string) *frameCounter {
+	key := frameKey{direction: direction, sub: sub, action: m.actionLabel(sub, action)}
+	m.mu.RLock()
+	c := m.frames[key]
+	m.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c = m.frames[key]; c == nil {
+		c = &frameCounter{}
+		m.frames[key] = c
+	}
+	return c
+}
+
+// observeHandler 记录一次 handler 调用耗时。
+func (m *runtimeMetrics) observeHandler(sub uint8, action string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	key := latencyKey{sub: sub, action: m.actionLabel(sub, action)}
+	m.mu.RLock()
+	h := m.latency[key]
+	m.mu.RUnlock()
+	if h == nil {
+		m.mu.Lock()
+		if h = m.latency[key]; h == nil {
+			h = newHistogram(handlerLatencyBuckets)
+			m.latency[key] = h
+		}
+		m.mu.Unlock()
+	}
+	h.observe(elapsed)
+}
+
+// ObserveStateOp 实现 defaultset.StateObserver，记录状态后端操作耗时与错误。
+func (m *runtimeMetrics) ObserveStateOp(kind, backend, op string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	key := stateOpKey{kind: kind, backend: backend, op: op}
+	m.mu.RLock()
+	s := m.stateOps[key]
+	m.mu.RUnlock()
+	if s == nil {
+		m.mu.Lock()
+		if s = m.stateOps[key]; s == nil {
+			s = &stateOpStats{hist: newHistogram(stateOpLatencyBuckets)}
+			m.stateOps[key] = s
+		}
+		m.mu.Unlock()
+	}
+	s.hist.observe(elapsed)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// snapshot 导出计数器部分；连接与队列由 Runtime.Metrics 补齐。
+func (m *runtimeMetrics) snapshot() Metrics {
+	out := Metrics{HandlerInFlight: m.inflight.Load()}
+	m.mu.RLock()
+	for key, c := range m.frames {
+		out.Frames = append(out.Frames, FrameMetric{
+			Direction: key.direction,
+			SubProto:  key.sub,
+			Action:    key.action,
+			Frames:    c.frames.Load(),
+			Bytes:     c.bytes.Load(),
+			Errors:    c.errors.Load(),
+		})
+	}
+	for key, h := range m.latency {
+		out.HandlerLatency = append(out.HandlerLatency, LatencyMetric{SubProto: key.sub, Action: key.action, Histogram: h.snapshot()})
+	}
+	for key, s := range m.stateOps {
+		out.StateOps = append(out.StateOps, StateOpMetric{
+			Kind:      key.kind,
+			Backend:   key.backend,
+			Op:        key.op,
+			Errors:    s.errors.Load(),
+			Histogram: s.hist.snapshot(),
+		})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(out.Frames, func(i, j int) bool {
+		a, b := out.Frames[i], out.Frames[j]
+		if a.Direction != b.Direction {
+			return a.Direction < b.Direction
+		}
+		if a.SubProto != b.SubProto {
+			return a.SubProto < b.SubProto
+		}
+		return a.Action < b.Action
+	})
+	sort.Slice(out.HandlerLatency, func(i, j int) bool {
+		a, b := out.HandlerLatency[i], out.HandlerLatency[j]
+		if a.SubProto != b.SubProto {
+			return a.SubProto < b.SubProto
+		}
+		return a.Action < b.Action
+	})
+	sort.Slice(out.StateOps, func(i, j int) bool {
+		a, b := out.StateOps[i], out.StateOps[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Backend != b.Backend {
+			return a.Backend < b.Backend
+		}
+		return a.Op < b.Op
+	})
+	return out
+}
+
+// Metrics 返回当前 runtime 的指标快照。计数器在每次 Start 时归零；
+// Stop 后仍可读取最后一次运行的累计计数器，但不再包含连接与队列。
+func (r *Runtime) Metrics() Metrics {
+	r.mu.Lock()
+	srv := r.srv
+	metrics := r.metrics
+	dispatcher := r.dispatcher
+	startConfig := r.startConfig
+	r.mu.Unlock()
+	if metrics == nil {
+		return Metrics{}
+	}
+	out := metrics.snapshot()
+	if srv == nil {
+		return out
+	}
+	out.Connections = connectionMetrics(srv.ConnManager())
+	if dispatcher != nil {
+		channels, workers, buffer := dispatcher.ConfigSnapshot()
+		out.DispatchQueue = QueueMetric{Channels: channels, Workers: workers, Capacity: channels * buffer}
+	}
+	out.SendQueue = sendQueueMetric(startConfig)
+	return out
+}
+
+// connectionMetrics 按 listener 与角色聚合当前连接。
+func connectionMetrics(cm core.IConnectionManager) []ConnectionMetric {
+	if cm == nil {
+		return nil
+	}
+	counts := make(map[[2]string]int)
+	cm.Range(func(c core.IConnection) bool {
+		if c == nil {
+			return true
+		}
+		role := ""
+		if v, ok := c.GetMeta(core.MetaRoleKey); ok {
+			role, _ = v.(string)
+		}
+		counts[[2]string{connListenerName(c), role}]++
+		return true
+	})
+	out := make([]ConnectionMetric, 0, len(counts))
+	for key, n := range counts {
+		out = append(out, ConnectionMetric{Listener: key[0], Role: key[1], Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Listener != out[j].Listener {
+			return out[i].Listener < out[j].Listener
+		}
+		return out[i].Role < out[j].Role
+	})
+	return out
+}
+
+// sendQueueMetric 按启动时的配置快照还原 Core send dispatcher 的分片参数，取值口径与
+// Core 的 NewSendDispatcherFromConfig 相同（非正数回落到默认值）。send.* 键只在启动期读取，快照即实际生效值。
+func sendQueueMetric(startConfig map[string]string) QueueMetric {
+	channels := positiveConfigInt(startConfig, coreconfig.KeySendChannelCount, 1)
+	return QueueMetric{
+		Channels: channels,
+		Workers:  positiveConfigInt(startConfig, coreconfig.KeySendWorkersPerChan, 1),
+		Capacity: channels * positiveConfigInt(startConfig, coreconfig.KeySendChannelBuffer, 64),
+	}
+}
+
+func positiveConfigInt(data map[string]string, key string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(data[key])); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// frameAction 从 payload 中廉价提取 action 标签。
+// file / stream 的 payload 带 1 字节 kind 前缀：ctrl 帧继续解析 JSON，data / ack 帧直接以 kind 作标签。
+func frameAction(sub uint8, payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	if sub == fileproto.SubProtoFile || sub == streamproto.SubProtoStream {
+		switch payload[0] {
+		case fileproto.KindCtrl:
+			payload = payload[1:]
+		case fileproto.KindData:
+			return "data"
+		case fileproto.KindAck:
+			return "ack"
+		default:
+			return metricsActionOther
+		}
+	}
+	raw, ok := scanJSONField(payload, "action")
+	if !ok || len(raw) < 2 || raw[0] != '"' {
+		return ""
+	}
+	return sanitizeAction(raw[1 : len(raw)-1])
+}
+
+// responseFailed 判断出站 payload 是否携带 code >= 400 的响应码。
+func responseFailed(sub uint8, payload []byte) bool {
+	code, ok := responseCode(sub, payload)
+	return ok && code >= 400
+}
+
+// responseCode 廉价提取 payload 中首个 `"code"` 数值；file / stream 只解析 ctrl 帧。
+func responseCode(sub uint8, payload []byte) (int, bool) {
+	if (sub == fileproto.SubProtoFile || sub == streamproto.SubProtoStream) && len(payload) > 0 {
+		if payload[0] != fileproto.KindCtrl {
+			return 0, false
+		}
+		payload = payload[1:]
+	}
+	raw, ok := scanJSONField(payload, "code")
+	if !ok {
+		return 0, false
+	}
+	code, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// scanJSONField 在扫描窗口内查找第一个 `"name":` 并返回其后的字符串或数字字面量（字符串含引号）。
+// 这是指标专用的近似解析：不处理转义，也不区分嵌套层级。
+func scanJSONField(payload []byte, name string) ([]byte, bool) {
+	if len(payload) > metricsActionScanLimit {
+		payload = payload[:metricsActionScanLimit]
+	}
+	needle := []byte(`"` + name + `"`)
+	idx := bytes.Index(payload, needle)
+	if idx < 0 {
+		return nil, false
+	}
+	rest := bytes.TrimLeft(payload[idx+len(needle):], " \t\r\n")
+	if len(rest) == 0 || rest[0] != ':' {
+		return nil, false
+	}
+	rest = bytes.TrimLeft(rest[1:], " \t\r\n")
+	if len(rest) == 0 {
+		return nil, false
+	}
+	if rest[0] == '"' {
+		end := bytes.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return nil, false
+		}
+		return rest[:end+2], true
+	}
+	end := 0
+	for end < len(rest) && (rest[end] == '-' || (rest[end] >= '0' && rest[end] <= '9')) {
+		end++
+	}
+	if end == 0 {
+		return nil, false
+	}
+	return rest[:end], true
+}
+
+// sanitizeAction 只接受 [A-Za-z0-9_.-] 且不超过上限长度的 action，其余归为 other。
+func sanitizeAction(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	if len(raw) > metricsMaxActionLen {
+		return metricsActionOther
+	}
+	for _, b := range raw {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '_', b == '.', b == '-':
+		default:
+			return metricsActionOther
+		}
+	}
+	return string(raw)
+}
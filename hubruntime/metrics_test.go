@@ -0,0 +1,185 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `metrics` 相关的行为。
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	"github.com/yttydcs/myflowhub-core/header"
+	fileproto "github.com/yttydcs/myflowhub-server/protocol/file"
+	flowproto "github.com/yttydcs/myflowhub-server/protocol/flow"
+)
+
+func TestFrameActionAndResponseFailed(t *testing.T) {
+	cases := []struct {
+		sub     uint8
+		payload []byte
+		want    string
+	}{
+		{flowproto.SubProtoFlow, []byte(`{"action": "run","data":{}}`), "run"},
+		{flowproto.SubProtoFlow, []byte(`{"data":{}}`), ""},
+		{flowproto.SubProtoFlow, []byte(`{"action":"bad action"}`), metricsActionOther},
+		{fileproto.SubProtoFile, append([]byte{fileproto.KindCtrl}, `{"action":"read"}`...), "read"},
+		{fileproto.SubProtoFile, []byte{fileproto.KindData, 1, 2, 3}, "data"},
+		{fileproto.SubProtoFile, []byte{fileproto.KindAck}, "ack"},
+	}
+	for _, tc := range cases {
+		if got := frameAction(tc.sub, tc.payload); got != tc.want {
+			t.Fatalf("frameAction(%d, %q)=%q, want %q", tc.sub, tc.payload, got, tc.want)
+		}
+	}
+	if !responseFailed(flowproto.SubProtoFlow, []byte(`{"action":"run_resp","data":{"code":4001}}`)) {
+		t.Fatalf("code 4001 should count as failed")
+	}
+	if responseFailed(flowproto.SubProtoFlow, []byte(`{"action":"run_resp","data":{"code":1}}`)) {
+		t.Fatalf("code 1 should not count as failed")
+	}
+	if responseFailed(fileproto.SubProtoFile, []byte{fileproto.KindData, '4', '0', '0'}) {
+		t.Fatalf("file data frame should not be parsed for code")
+	}
+}
+
+func TestRuntimeMetricsActionCardinalityCapped(t *testing.T) {
+	m := newRuntimeMetrics()
+	for i := 0; i < metricsMaxActionsPerSubProto+10; i++ {
+		m.observeHandler(flowproto.SubProtoFlow, "a"+strings.Repeat("x", i%60)+string(rune('a'+i/60)), time.Millisecond)
+	}
+	snap := m.snapshot()
+	if len(snap.HandlerLatency) != metricsMaxActionsPerSubProto+1 {
+		t.Fatalf("latency series=%d, want %d", len(snap.HandlerLatency), metricsMaxActionsPerSubProto+1)
+	}
+	var other *LatencyMetric
+	for i := range snap.HandlerLatency {
+		if snap.HandlerLatency[i].Action == metricsActionOther {
+			other = &snap.HandlerLatency[i]
+		}
+	}
+	if other == nil || other.Count != 10 {
+		t.Fatalf("overflow series=%+v, want count 10 under %q", other, metricsActionOther)
+	}
+}
+
+type panicHandler struct {
+	stubSubProcess
+}
+
+func (panicHandler) OnReceive(context.Context, core.IConnection, core.IHeader, []byte) {
+	panic("boom")
+}
+
+type stubSubProcess struct{}
+
+func (stubSubProcess) SubProto() uint8                                                   { return flowproto.SubProtoFlow }
+func (stubSubProcess) OnReceive(context.Context, core.IConnection, core.IHeader, []byte) {}
+func (stubSubProcess) Init() bool                                                        { return true }
+func (stubSubProcess) AcceptCmd() bool                                                   { return false }
+func (stubSubProcess) AllowSourceMismatch() bool                                         { return false }
+
+func TestObservedHandlerCountsPanicAndRepanics(t *testing.T) {
+	m := newRuntimeMetrics()
+	h := &observedHandler{ISubProcess: panicHandler{}, metrics: m}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("panic should propagate to dispatcher")
+			}
+		}()
+		h.OnReceive(context.Background(), nil, nil, []byte(`{"action":"run"}`))
+	}()
+	snap := m.snapshot()
+	if snap.HandlerInFlight != 0 {
+		t.Fatalf("inflight=%d, want 0", snap.HandlerInFlight)
+	}
+	if len(snap.Frames) != 1 || snap.Frames[0].Action != "run" || snap.Frames[0].Errors != 1 {
+		t.Fatalf("frames=%+v, want one run error", snap.Frames)
+	}
+	if len(snap.HandlerLatency) != 1 || snap.HandlerLatency[0].Count != 1 {
+		t.Fatalf("latency=%+v, want one sample", snap.HandlerLatency)
+	}
+}
+
+func TestSendQueueMetricMatchesCoreDefaults(t *testing.T) {
+	got := sendQueueMetric(map[string]string{coreconfig.KeySendChannelCount: "4", coreconfig.KeySendWorkersPerChan: "0", coreconfig.KeySendChannelBuffer: "x"})
+	if want := (QueueMetric{Channels: 4, Workers: 1, Capacity: 256}); got != want {
+		t.Fatalf("send queue=%+v want %+v", got, want)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	m := newRuntimeMetrics()
+	hdr := (&header.HeaderTcp{}).WithSubProto(flowproto.SubProtoFlow)
+	m.observeFrame(FrameDirectionOut, hdr, []byte(`{"action":"run_resp","data":{"code":500}}`), true)
+	m.ObserveStateOp("flow", "pg", "save", 3*time.Millisecond, errors.New("down"))
+	snap := m.snapshot()
+	snap.Connections = []ConnectionMetric{{Listener: "tcp", Role: core.RoleChild, Count: 2}}
+	snap.DispatchQueue = QueueMetric{Channels: 2, Workers: 4, Capacity: 512}
+
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, snap); err != nil {
+		t.Fatalf("WriteOpenMetrics: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`myflowhub_connections{listener="tcp",role="child"} 2`,
+		`myflowhub_queue_capacity{queue="dispatch"} 512`,
+		`myflowhub_queue_workers{queue="dispatch"} 8`,
+		`myflowhub_frames_total{direction="out",subproto="flow",action="run_resp"} 1`,
+		`myflowhub_frame_errors_total{direction="out",subproto="flow",action="run_resp"} 1`,
+		`myflowhub_state_op_duration_seconds_bucket{kind="flow",backend="pg",op="save",le="0.005"} 1`,
+		`myflowhub_state_op_duration_seconds_bucket{kind="flow",backend="pg",op="save",le="+Inf"} 1`,
+		`myflowhub_state_op_errors_total{kind="flow",backend="pg",op="save"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "myflowhub_queue_depth") {
+		t.Fatalf("queue depth is not exported by Core and must not be reported:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Fatalf("output must end with # EOF:\n%s", out)
+	}
+}
+
+func TestAdminServerServesMetrics(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rt := &Runtime{log: log, metrics: newRuntimeMetrics()}
+	rt.metrics.inflight.Add(2)
+	admin, err := startAdminServer("127.0.0.1:0", rt.adminHandler(), log)
+	if err != nil {
+		t.Fatalf("startAdminServer: %v", err)
+	}
+	defer func() { _ = admin.Close(context.Background()) }()
+
+	resp, err := http.Get("http://" + admin.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != OpenMetricsContentType {
+		t.Fatalf("status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !bytes.Contains(body, []byte("myflowhub_handler_inflight 2\n")) || !bytes.HasSuffix(body, []byte("# EOF\n")) {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+
+	post, err := http.Post("http://"+admin.Addr()+"/metrics", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("POST /metrics: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status=%d, want 405", post.StatusCode)
+	}
+}
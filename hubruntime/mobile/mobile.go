@@ -0,0 +1,238 @@
+// Package mobile 是 hubruntime 面向 gomobile 的句柄式封装：只使用 string / int / bool / error
+// 与回调接口，Android / iOS 宿主可直接 `gomobile bind` 本包，不再需要手写 shim。
+//
+//	hub, err := mobile.NewFromJSON(`{"Addr":":9000","WorkDir":"/data/hub","NodeID":1}`)
+//	hub.SetLogSink(sink)
+//	hub.SetEventListener(listener)
+//	err = hub.Start()
+//	status := hub.StatusJSON()
+//	err = hub.Stop(5000)
+//
+// Options 的 JSON 字段名与 hubruntime.Options 的字段名一致（不区分大小写），缺省字段取 DefaultOptionsJSON 中的值；
+// 未知字段会被拒绝，Options 增删字段后宿主无需改动绑定代码。
+package mobile
+
+// 本文件承载 `mobile` 中与 `mobile` 相关的逻辑。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+)
+
+// LogSink 接收 runtime 的日志。level 为 DEBUG / INFO / WARN / ERROR；component 为日志组件（见 hubruntime.LogComponent*），
+// 未带组件的日志为空；attrsJSON 是其余属性的 JSON 对象。回调在产生日志的 goroutine 上同步执行，应尽快返回。
+type LogSink interface {
+	OnLog(level, component, message, attrsJSON string)
+}
+
+// EventListener 接收 runtime 事件，每个事件编码为一个 JSON 字符串（见 hubruntime.Event）。
+type EventListener interface {
+	OnEvent(eventJSON string)
+}
+
+// Hub 是一个 hubruntime.Runtime 的句柄。
+type Hub struct {
+	rt   *hubruntime.Runtime
+	sink *sinkHolder
+
+	mu  sync.Mutex
+	sub *hubruntime.Subscription
+}
+
+// DefaultOptionsJSON 返回缺省 Options 的 JSON 编码，宿主可在其上修改后传给 NewFromJSON。
+func DefaultOptionsJSON() string {
+	raw, _ := json.Marshal(hubruntime.DefaultOptions())
+	return string(raw)
+}
+
+// NewFromJSON 以缺省 Options 为基础叠加 optionsJSON 中的字段并创建 Hub；空字符串等同于全部取缺省值。
+func NewFromJSON(optionsJSON string) (*Hub, error) {
+	opts := hubruntime.DefaultOptions()
+	if len(bytes.TrimSpace([]byte(optionsJSON))) > 0 {
+		dec := json.NewDecoder(bytes.NewReader([]byte(optionsJSON)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&opts); err != nil {
+			return nil, fmt.Errorf("decode options: %w", err)
+		}
+	}
+	sink := &sinkHolder{}
+	opts.Logger = slog.New(&sinkHandler{holder: sink})
+	rt, err := hubruntime.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Hub{rt: rt, sink: sink}, nil
+}
+
+// SetLogSink 设置日志回调；nil 表示丢弃日志。可在运行期随时替换。
+func (h *Hub) SetLogSink(sink LogSink) {
+	h.sink.set(sink)
+}
+
+// SetEventListener 设置事件回调并取消之前的订阅；nil 只取消。订阅跨越 Start / Stop 保留。
+func (h *Hub) SetEventListener(listener EventListener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sub.Cancel()
+	h.sub = nil
+	if listener != nil {
+		h.sub = h.rt.SubscribeJSON(listener)
+	}
+}
+
+// Start 启动 runtime；runtime 的生命周期持续到 Stop。
+func (h *Hub) Start() error {
+	return h.rt.Start(context.Background())
+}
+
+// Stop 先 drain 再停止 runtime。timeoutMs > 0 时作为整个停止过程的上限，否则使用 runtime 的缺省 drain 时长。
+func (h *Hub) Stop(timeoutMs int) error {
+	ctx := context.Background()
+	if timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	return h.rt.Stop(ctx)
+}
+
+// StatusJSON 返回 hubruntime.Status 的 JSON 编码。
+func (h *Hub) StatusJSON() string {
+	raw, _ := json.Marshal(h.rt.Status())
+	return string(raw)
+}
+
+// ReadinessJSON 返回 hubruntime.Readiness 的 JSON 编码。
+func (h *Hub) ReadinessJSON() string {
+	raw, _ := json.Marshal(h.rt.Readiness())
+	return string(raw)
+}
+
+// SetMaintenance 开关维护模式，见 hubruntime.Runtime.SetMaintenance。
+func (h *Hub) SetMaintenance(enabled bool, reason string) {
+	h.rt.SetMaintenance(enabled, reason)
+}
+
+// sinkHolder 保存当前的 LogSink，供全部派生 handler 共享。
+type sinkHolder struct {
+	mu   sync.RWMutex
+	sink LogSink
+}
+
+func (s *sinkHolder) set(sink LogSink) {
+	s.mu.Lock()
+	s.sink = sink
+	s.mu.Unlock()
+}
+
+func (s *sinkHolder) get() LogSink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sink
+}
+
+// sinkHandler 把 slog 记录转交给 LogSink。级别由 hubruntime 的 log.level* 配置判定，这里不再过滤。
+type sinkHandler struct {
+	holder    *sinkHolder
+	component string
+	attrs     []slog.Attr
+	group     string
+}
+
+func (h *sinkHandler) Enabled(context.Context, slog.Level) bool {
+	return h.holder.get() != nil
+}
+
+func (h *sinkHandler) Handle(_ context.Context, rec slog.Record) error {
+	sink := h.holder.get()
+	if sink == nil {
+		return nil
+	}
+	fields := make(map[string]any, len(h.attrs)+rec.NumAttrs())
+	for _, a := range h.attrs {
+		addLogAttr(fields, "", a)
+	}
+	rec.Attrs(func(a slog.Attr) bool {
+		addLogAttr(fields, h.group, a)
+		return true
+	})
+	attrsJSON := "{}"
+	if len(fields) > 0 {
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		attrsJSON = string(raw)
+	}
+	sink.OnLog(rec.Level.String(), h.component, rec.Message, attrsJSON)
+	return nil
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		if h.group == "" && a.Key == defaultset.LogComponentKey {
+			out.component = a.Value.String()
+			continue
+		}
+		if h.group != "" {
+			a.Key = h.group + a.Key
+		}
+		out.attrs = append(out.attrs, a)
+	}
+	return &out
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.group = h.group + name + "."
+	return &out
+}
+
+// addLogAttr 把属性展平到 fields，group 以 "a.b." 形式作为键前缀。
+func addLogAttr(fields map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addLogAttr(fields, p, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	switch v.Kind() {
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			fields[prefix+a.Key] = err.Error()
+			return
+		}
+		if _, err := json.Marshal(v.Any()); err != nil {
+			fields[prefix+a.Key] = fmt.Sprint(v.Any())
+			return
+		}
+		fields[prefix+a.Key] = v.Any()
+	case slog.KindDuration:
+		fields[prefix+a.Key] = v.Duration().String()
+	case slog.KindTime:
+		fields[prefix+a.Key] = v.Time().Format(time.RFC3339Nano)
+	default:
+		fields[prefix+a.Key] = v.Any()
+	}
+}
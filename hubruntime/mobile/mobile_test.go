@@ -0,0 +1,101 @@
+package mobile
+
+// 本文件覆盖 `mobile` 中与 `mobile` 相关的行为。
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yttydcs/myflowhub-server/hubruntime"
+)
+
+type testSink struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (s *testSink) OnLog(level, component, message, attrsJSON string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, level+"|"+component+"|"+message+"|"+attrsJSON)
+}
+
+func (s *testSink) find(substr string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.lines {
+		if strings.Contains(l, substr) {
+			return l
+		}
+	}
+	return ""
+}
+
+type testListener struct {
+	ch chan string
+}
+
+func (l *testListener) OnEvent(eventJSON string) { l.ch <- eventJSON }
+
+func TestNewFromJSONOptions(t *testing.T) {
+	var defaults hubruntime.Options
+	if err := json.Unmarshal([]byte(DefaultOptionsJSON()), &defaults); err != nil || defaults.Addr != ":9000" || !defaults.TCPEnable {
+		t.Fatalf("default options json: %+v err=%v", defaults, err)
+	}
+	if _, err := NewFromJSON(`{"Adr":":9001"}`); err == nil || !strings.Contains(err.Error(), "Adr") {
+		t.Fatalf("unknown field should be rejected: %v", err)
+	}
+	if _, err := NewFromJSON(`{"Transport":{}}`); err == nil {
+		t.Fatalf("Go-only field should be rejected")
+	}
+	if _, err := NewFromJSON(`{"TCPEnable":false}`); err == nil {
+		t.Fatalf("no listener should be rejected")
+	}
+	if _, err := NewFromJSON(""); err != nil {
+		t.Fatalf("empty json: %v", err)
+	}
+}
+
+func TestHubLifecycle(t *testing.T) {
+	raw, _ := json.Marshal(map[string]any{"Addr": "127.0.0.1:0", "WorkDir": t.TempDir(), "NodeID": 5})
+	hub, err := NewFromJSON(string(raw))
+	if err != nil {
+		t.Fatalf("NewFromJSON: %v", err)
+	}
+	sink := &testSink{}
+	hub.SetLogSink(sink)
+	events := &testListener{ch: make(chan string, 16)}
+	hub.SetEventListener(events)
+
+	if err := hub.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var st hubruntime.Status
+	if err := json.Unmarshal([]byte(hub.StatusJSON()), &st); err != nil || !st.Running || st.NodeID != 5 {
+		t.Fatalf("status=%s err=%v", hub.StatusJSON(), err)
+	}
+	if line := sink.find("hub runtime started"); !strings.HasPrefix(line, "INFO|runtime|") || !strings.Contains(line, `"node_id":5`) {
+		t.Fatalf("start log=%q", line)
+	}
+
+	hub.SetMaintenance(true, "upgrade")
+	select {
+	case ev := <-events.ch:
+		if !strings.Contains(ev, hubruntime.EventMaintenanceEnabled) {
+			t.Fatalf("event=%s", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no maintenance event")
+	}
+	hub.SetEventListener(nil)
+
+	if err := hub.Stop(2000); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := json.Unmarshal([]byte(hub.StatusJSON()), &st); err != nil || st.Running {
+		t.Fatalf("status after stop=%s", hub.StatusJSON())
+	}
+}
@@ -0,0 +1,206 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `node_id_cache` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+const (
+	// nodeIDCacheFile 记录父节点分配的 nodeID，按 (SelfID, 首选父端点) 区分。
+	nodeIDCacheFile = "config/node_id_cache.json"
+
+	configKeyParentRegisterAttempts  = "parent.register.attempts"
+	configKeyParentRegisterTimeoutMs = "parent.register.timeout_ms"
+	configKeyParentRegisterBackoffMs = "parent.register.backoff_ms"
+
+	defaultParentRegisterAttempts = 3
+	defaultParentRegisterTimeout  = 8 * time.Second
+	defaultParentRegisterBackoff  = time.Second
+)
+
+// Status.NodeIDSource 的取值。
+const (
+	NodeIDSourceConfig = "config"
+	NodeIDSourceParent = "parent"
+	NodeIDSourceCache  = "cache"
+)
+
+// nodeIDCacheEntry 是缓存文件中的一条记录。
+type nodeIDCacheEntry struct {
+	SelfID    string `json:"self_id"`
+	Parent    string `json:"parent"`
+	NodeID    uint32 `json:"node_id"`
+	UpdatedMs int64  `json:"updated_ms"`
+}
+
+// loadNodeIDCache 读取缓存文件；文件不存在时返回空。
+func loadNodeIDCache(path string) ([]nodeIDCacheEntry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []nodeIDCacheEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// lookupCachedNodeID 返回 (selfID, parent) 对应的缓存 nodeID；没有记录时返回 0。
+func lookupCachedNodeID(path, selfID, parent string) (uint32, error) {
+	entries, err := loadNodeIDCache(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e.SelfID == selfID && e.Parent == parent {
+			return e.NodeID, nil
+		}
+	}
+	return 0, nil
+}
+
+// storeCachedNodeID 写入或更新 (selfID, parent) 的记录；整个文件原子重写。
+func storeCachedNodeID(path, selfID, parent string, nodeID uint32) error {
+	if path == "" || nodeID == 0 {
+		return nil
+	}
+	entries, err := loadNodeIDCache(path)
+	if err != nil {
+		// 损坏的缓存不阻止写入新的分配结果。
+		entries = nil
+	}
+	entry := nodeIDCacheEntry{SelfID: selfID, Parent: parent, NodeID: nodeID, UpdatedMs: time.Now().UnixMilli()}
+	replaced := false
+	for i := range entries {
+		if entries[i].SelfID == selfID && entries[i].Parent == parent {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	raw, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(raw, '\n'), 0o600)
+}
+
+// parentRegisterPolicy 是启动前自注册的重试策略。
+//   - attempts：最多尝试次数，0 表示一直重试直到 Start 的 ctx 结束；
+//   - timeout：单次尝试（拨号 + register_resp）的上限；
+//   - backoff：首次重试前的等待，之后逐次翻倍，封顶 parentRegisterBackoffMax。
+type parentRegisterPolicy struct {
+	attempts int
+	timeout  time.Duration
+	backoff  time.Duration
+}
+
+func parentRegisterPolicyFromConfig(cfg core.IConfig) parentRegisterPolicy {
+	p := parentRegisterPolicy{
+		attempts: parseIntValue(trimmedConfigValue(cfg, configKeyParentRegisterAttempts), defaultParentRegisterAttempts),
+		timeout:  time.Duration(parseIntValue(trimmedConfigValue(cfg, configKeyParentRegisterTimeoutMs), 0)) * time.Millisecond,
+		backoff:  time.Duration(parseIntValue(trimmedConfigValue(cfg, configKeyParentRegisterBackoffMs), -1)) * time.Millisecond,
+	}
+	if p.attempts < 0 {
+		p.attempts = defaultParentRegisterAttempts
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultParentRegisterTimeout
+	}
+	if p.backoff < 0 {
+		p.backoff = defaultParentRegisterBackoff
+	}
+	return p
+}
+
+// delay 返回第 attempt 次失败（从 0 开始）之后的等待时长。
+func (p parentRegisterPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 0; i < attempt && d < parentRegisterBackoffMax; i++ {
+		d *= 2
+	}
+	if d > parentRegisterBackoffMax {
+		d = parentRegisterBackoffMax
+	}
+	return d
+}
+
+// selfRegisterWithRetry 按 policy 重复调用 register，直到成功、次数用完或 ctx 结束；返回最后一次的错误。
+func selfRegisterWithRetry(ctx context.Context, policy parentRegisterPolicy, register func(context.Context, time.Duration) (uint32, error), log *slog.Logger) (uint32, error) {
+	var lastErr error
+	for attempt := 0; policy.attempts == 0 || attempt < policy.attempts; attempt++ {
+		nodeID, err := register(ctx, policy.timeout)
+		if err == nil {
+			return nodeID, nil
+		}
+		lastErr = err
+		if policy.attempts != 0 && attempt+1 >= policy.attempts {
+			break
+		}
+		delay := policy.delay(attempt)
+		if log != nil {
+			log.Warn("self register failed, retrying", "attempt", attempt+1, "retry_in", delay, "err", err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, fmt.Errorf("self register: %w (last error: %v)", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+	return 0, fmt.Errorf("self register failed after %d attempts: %w", policy.attempts, lastErr)
+}
+
+// resolveSelfNodeID 决定启用 SelfID 时本节点使用的 nodeID：
+//   - 缓存中有 (SelfID, 首选父端点) 的记录时直接使用，不等待父节点，由父链上的 bootstrap register 在后台核对；
+//   - 否则按 parent.register.* 重试启动前自注册，成功后写入缓存。
+func resolveSelfNodeID(
+	ctx context.Context,
+	opts Options,
+	cfg core.IConfig,
+	cachePath, parentTarget string,
+	dialer func(context.Context, string) (core.IConnection, error),
+	log *slog.Logger,
+) (uint32, string, error) {
+	selfID := strings.TrimSpace(opts.SelfID)
+	cached, err := lookupCachedNodeID(cachePath, selfID, parentTarget)
+	if err != nil {
+		log.Warn("node id cache unreadable, registering with parent", "path", cachePath, "err", err)
+	}
+	if cached != 0 {
+		log.Info("using cached node id, parent registration will be reconciled in background", "node_id", cached, "self_id", selfID, "parent", parentTarget)
+		return cached, NodeIDSourceCache, nil
+	}
+
+	policy := parentRegisterPolicyFromConfig(cfg)
+	nodeID, err := selfRegisterWithRetry(ctx, policy, func(ctx context.Context, timeout time.Duration) (uint32, error) {
+		return selfRegisterNodeID(ctx, parentTarget, selfID, opts.ParentJoinPermit, timeout, dialer, log)
+	}, log)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := storeCachedNodeID(cachePath, selfID, parentTarget, nodeID); err != nil {
+		log.Warn("persist node id cache failed", "path", cachePath, "err", err)
+	}
+	return nodeID, NodeIDSourceParent, nil
+}
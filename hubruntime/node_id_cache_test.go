@@ -0,0 +1,148 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `node_id_cache` 相关的行为。
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestNodeIDCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), nodeIDCacheFile)
+	if id, err := lookupCachedNodeID(path, "hub-a", "tcp://p:9000"); err != nil || id != 0 {
+		t.Fatalf("missing cache: id=%d err=%v", id, err)
+	}
+	for _, e := range []struct {
+		self, parent string
+		id           uint32
+	}{{"hub-a", "tcp://p:9000", 11}, {"hub-a", "tcp://q:9000", 12}, {"hub-a", "tcp://p:9000", 13}} {
+		if err := storeCachedNodeID(path, e.self, e.parent, e.id); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+	entries, err := loadNodeIDCache(path)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("entries=%+v err=%v", entries, err)
+	}
+	if id, _ := lookupCachedNodeID(path, "hub-a", "tcp://p:9000"); id != 13 {
+		t.Fatalf("updated entry=%d, want 13", id)
+	}
+	if id, _ := lookupCachedNodeID(path, "hub-b", "tcp://p:9000"); id != 0 {
+		t.Fatalf("other self id matched: %d", id)
+	}
+}
+
+func TestSelfRegisterWithRetry(t *testing.T) {
+	policy := parentRegisterPolicyFromConfig(coreconfig.NewMap(map[string]string{configKeyParentRegisterBackoffMs: "0", configKeyParentRegisterTimeoutMs: "250"}))
+	if policy.attempts != defaultParentRegisterAttempts || policy.timeout != 250*time.Millisecond || policy.backoff != 0 {
+		t.Fatalf("policy=%+v", policy)
+	}
+	if d := (parentRegisterPolicy{backoff: time.Second}).delay(10); d != parentRegisterBackoffMax {
+		t.Fatalf("delay cap=%v", d)
+	}
+
+	calls := 0
+	register := func(_ context.Context, timeout time.Duration) (uint32, error) {
+		calls++
+		if timeout != policy.timeout {
+			t.Fatalf("timeout=%v", timeout)
+		}
+		if calls < 3 {
+			return 0, errors.New("dial refused")
+		}
+		return 21, nil
+	}
+	if id, err := selfRegisterWithRetry(context.Background(), policy, register, nil); err != nil || id != 21 || calls != 3 {
+		t.Fatalf("id=%d err=%v calls=%d", id, err, calls)
+	}
+
+	calls = 0
+	policy.attempts = 2
+	if _, err := selfRegisterWithRetry(context.Background(), policy, register, nil); err == nil || !strings.Contains(err.Error(), "dial refused") || calls != 2 {
+		t.Fatalf("exhausted: err=%v calls=%d", err, calls)
+	}
+
+	// attempts=0 一直重试，直到 ctx 结束。
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	unlimited := parentRegisterPolicy{timeout: time.Second, backoff: 5 * time.Millisecond}
+	_, err := selfRegisterWithRetry(ctx, unlimited, func(context.Context, time.Duration) (uint32, error) {
+		return 0, errors.New("offline")
+	}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unlimited retry err=%v", err)
+	}
+}
+
+func TestResolveSelfNodeIDUsesCache(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), nodeIDCacheFile)
+	opts := Options{SelfID: "hub-a"}
+	cfg := coreconfig.NewMap(map[string]string{configKeyParentRegisterAttempts: "1", configKeyParentRegisterBackoffMs: "0"})
+	offline := func(context.Context, string) (core.IConnection, error) {
+		return nil, errors.New("uplink down")
+	}
+
+	if _, _, err := resolveSelfNodeID(context.Background(), opts, cfg, path, "tcp://p:9000", offline, log); err == nil {
+		t.Fatalf("no cache and parent offline should fail")
+	}
+	if err := storeCachedNodeID(path, "hub-a", "tcp://p:9000", 31); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	id, source, err := resolveSelfNodeID(context.Background(), opts, cfg, path, "tcp://p:9000", offline, log)
+	if err != nil || id != 31 || source != NodeIDSourceCache {
+		t.Fatalf("cached start: id=%d source=%q err=%v", id, source, err)
+	}
+}
+
+func TestParentBootstrapReconcileNodeID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), nodeIDCacheFile)
+	rt := &Runtime{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	var mu sync.Mutex
+	var mismatches []Event
+	rt.Subscribe(func(ev Event) {
+		if ev.Type == EventParentNodeIDMismatch {
+			mu.Lock()
+			mismatches = append(mismatches, ev)
+			mu.Unlock()
+		}
+	})
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(mismatches)
+	}
+	boot := newParentBootstrap(rt, nil, "hub-a", rt.log)
+	boot.trackNodeID(31, NodeIDSourceCache, path, "tcp://p:9000")
+
+	boot.reconcileNodeID("parent-1", 31)
+	if boot.nodeIDMismatch() || boot.assignedNodeID() != 31 || rt.loadErr() != "" {
+		t.Fatalf("matching assignment: mismatch=%v assigned=%d err=%q", boot.nodeIDMismatch(), boot.assignedNodeID(), rt.loadErr())
+	}
+
+	boot.reconcileNodeID("parent-1", 32)
+	boot.reconcileNodeID("parent-2", 32)
+	if !boot.nodeIDMismatch() || !strings.Contains(rt.loadErr(), "restart to adopt") {
+		t.Fatalf("mismatch not reported: err=%q", rt.loadErr())
+	}
+	if id, _ := lookupCachedNodeID(path, "hub-a", "tcp://p:9000"); id != 32 {
+		t.Fatalf("cache=%d, want new assignment 32", id)
+	}
+	waitFor(t, "mismatch event", func() bool { return count() > 0 })
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(mismatches) != 1 || mismatches[0].NodeID != 32 || mismatches[0].ConnID != "parent-1" {
+		t.Fatalf("events=%+v, want a single mismatch event", mismatches)
+	}
+}
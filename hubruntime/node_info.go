@@ -0,0 +1,115 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `node_info` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-server/modules"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+// nodeInfoDispatcher 在注册 management handler 时套上 nodeInfoHandler，
+// 让本地 node_info 响应携带 runtime 才知道的信息（父端点状态等）。
+type nodeInfoDispatcher struct {
+	inner modules.Dispatcher
+	items func() map[string]string
+}
+
+func (d nodeInfoDispatcher) RegisterHandler(h core.ISubProcess) error {
+	if h != nil && h.SubProto() == mgmtproto.SubProtoManagement && d.items != nil {
+		h = &nodeInfoHandler{ISubProcess: h, items: d.items}
+	}
+	return d.inner.RegisterHandler(h)
+}
+
+func (d nodeInfoDispatcher) RegisterDefaultHandler(h core.ISubProcess) {
+	d.inner.RegisterDefaultHandler(h)
+}
+
+// nodeInfoHandler 只拦截 node_info 请求：把上下文中的 server 换成 nodeInfoServer，
+// 由其在发送本地 node_info_resp 时合并额外条目；其余 action 原样交给 management handler。
+type nodeInfoHandler struct {
+	core.ISubProcess
+	items func() map[string]string
+}
+
+func (h *nodeInfoHandler) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	if frameAction(mgmtproto.SubProtoManagement, payload) == mgmtproto.ActionNodeInfo {
+		if srv := core.ServerFromContext(ctx); srv != nil {
+			ctx = core.WithServerContext(ctx, nodeInfoServer{IServer: srv, items: h.items})
+		}
+	}
+	h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+}
+
+type nodeInfoServer struct {
+	core.IServer
+	items func() map[string]string
+}
+
+func (s nodeInfoServer) Send(ctx context.Context, connID string, hdr core.IHeader, payload []byte) error {
+	if hdr != nil && hdr.SubProto() == mgmtproto.SubProtoManagement {
+		payload = mergeNodeInfoItems(payload, s.items())
+	}
+	return s.IServer.Send(ctx, connID, hdr, payload)
+}
+
+// mergeNodeInfoItems 把 extra 合并进成功的 node_info_resp；解析失败或非目标帧时原样返回。
+// 同名条目以 management handler 的值为准。
+func mergeNodeInfoItems(payload []byte, extra map[string]string) []byte {
+	if len(extra) == 0 || frameAction(mgmtproto.SubProtoManagement, payload) != mgmtproto.ActionNodeInfoResp {
+		return payload
+	}
+	var msg mgmtproto.Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return payload
+	}
+	var resp mgmtproto.NodeInfoResp
+	if err := json.Unmarshal(msg.Data, &resp); err != nil || resp.Code != 1 {
+		return payload
+	}
+	if resp.Items == nil {
+		resp.Items = make(map[string]string, len(extra))
+	}
+	for k, v := range extra {
+		if _, exists := resp.Items[k]; !exists {
+			resp.Items[k] = v
+		}
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return payload
+	}
+	msg.Data = data
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// nodeInfoItems 返回附加到 node_info 的父链条目：
+//   - parent_endpoint：当前父连接所用端点（未连接时缺省）；
+//   - parent_endpoint_<i> / parent_endpoint_<i>_error：按拨号顺序（从 1 开始）的候选端点及其最近一次错误。
+func (r *Runtime) nodeInfoItems() map[string]string {
+	st := r.Status()
+	if len(st.ParentEndpoints) == 0 {
+		return nil
+	}
+	items := make(map[string]string, 1+2*len(st.ParentEndpoints))
+	for i, ep := range st.ParentEndpoints {
+		key := "parent_endpoint_" + strconv.Itoa(i+1)
+		items[key] = ep.Endpoint
+		if ep.LastError != "" {
+			items[key+"_error"] = ep.LastError
+		}
+		if ep.Current {
+			items["parent_endpoint"] = ep.Endpoint
+		}
+	}
+	return items
+}
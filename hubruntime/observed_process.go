@@ -0,0 +1,128 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `observed_process` 相关的逻辑。
+
+import (
+	"context"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-server/modules"
+)
+
+// observedProcess 包装 Core dispatcher，在进程边界统计收发帧。
+//   - OnReceive 统计每个入站帧（含随后被转发的帧）；
+//   - OnSend 统计经 IServer.Send 发出的帧，发送失败或响应 code >= 400 记为错误。
+//
+// 同一边界上的帧也交给 observers（就绪判定、runtime 事件）；出站帧只在发送成功后观察。
+// tracer 非空时，入站帧在 ctx 中记下到达时刻，出站帧交给 tracer 生成 span。
+type observedProcess struct {
+	core.IProcess
+	metrics   *runtimeMetrics
+	tracer    *tracer
+	observers []frameObserver
+}
+
+// frameObserver 观察进程边界上的帧；实现必须轻量且不得修改 payload。
+type frameObserver interface {
+	observeInbound(conn core.IConnection, hdr core.IHeader, payload []byte)
+	observeOutbound(conn core.IConnection, hdr core.IHeader, payload []byte)
+}
+
+func newObservedProcess(inner core.IProcess, metrics *runtimeMetrics, tracer *tracer, observers ...frameObserver) *observedProcess {
+	return &observedProcess{IProcess: inner, metrics: metrics, tracer: tracer, observers: observers}
+}
+
+// frameObserversFor 组装 runtime 使用的帧观察者；boot 为空（未启用父链）时省略。
+func frameObserversFor(health *healthState, drain *drainState, boot *parentBootstrap, emit func(Event)) []frameObserver {
+	observers := []frameObserver{health, drain, registerEventObserver{emit: emit}}
+	if boot != nil {
+		observers = append(observers, boot)
+	}
+	return observers
+}
+
+func (p *observedProcess) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	p.metrics.observeFrame(FrameDirectionIn, hdr, payload, false)
+	for _, o := range p.observers {
+		o.observeInbound(conn, hdr, payload)
+	}
+	p.IProcess.OnReceive(p.tracer.stampReceive(ctx), conn, hdr, payload)
+}
+
+func (p *observedProcess) OnSend(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) error {
+	start := time.Now()
+	err := p.IProcess.OnSend(ctx, conn, hdr, payload)
+	failed := err != nil
+	if !failed && hdr != nil {
+		failed = responseFailed(hdr.SubProto(), payload)
+	}
+	p.metrics.observeFrame(FrameDirectionOut, hdr, payload, failed)
+	p.tracer.observeSend(ctx, conn, hdr, payload, start, failed)
+	if err == nil {
+		for _, o := range p.observers {
+			o.observeOutbound(conn, hdr, payload)
+		}
+	}
+	return err
+}
+
+// Shutdown 转发给内层 dispatcher，保持 server.Stop 对 worker 的回收语义。
+func (p *observedProcess) Shutdown() {
+	if d, ok := p.IProcess.(interface{ Shutdown() }); ok {
+		d.Shutdown()
+	}
+}
+
+// observedHandler 包装子协议 handler，统计在途调用数与按 action 的耗时；tracer 非空时每次调用记一个 span。
+// 只用于注册到 dispatcher；BindServer / ReloadConfig 等扩展接口仍作用在 modules.Set 中的原始 handler 上。
+type observedHandler struct {
+	core.ISubProcess
+	metrics *runtimeMetrics
+	tracer  *tracer
+}
+
+func (h *observedHandler) OnReceive(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) {
+	sub := h.ISubProcess.SubProto()
+	action := frameAction(sub, payload)
+	h.metrics.inflight.Add(1)
+	start := time.Now()
+	defer func() {
+		h.metrics.inflight.Add(-1)
+		h.metrics.observeHandler(sub, action, time.Since(start))
+		if rec := recover(); rec != nil {
+			h.metrics.observeHandlerPanic(sub, action)
+			// 交还给 dispatcher 的 panic 防护，保持原有日志与 worker 存活语义。
+			panic(rec)
+		}
+	}()
+	if h.tracer.enabled() {
+		h.tracer.handle(ctx, conn, hdr, sub, action, func(ctx context.Context) {
+			h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+		})
+		return
+	}
+	h.ISubProcess.OnReceive(ctx, conn, hdr, payload)
+}
+
+// observedDispatcher 在注册时为每个 handler 套上 observedHandler。
+type observedDispatcher struct {
+	inner   modules.Dispatcher
+	metrics *runtimeMetrics
+	tracer  *tracer
+}
+
+func (d observedDispatcher) RegisterHandler(h core.ISubProcess) error {
+	if h == nil {
+		return d.inner.RegisterHandler(h)
+	}
+	return d.inner.RegisterHandler(&observedHandler{ISubProcess: h, metrics: d.metrics, tracer: d.tracer})
+}
+
+func (d observedDispatcher) RegisterDefaultHandler(h core.ISubProcess) {
+	if h == nil {
+		d.inner.RegisterDefaultHandler(h)
+		return
+	}
+	d.inner.RegisterDefaultHandler(&observedHandler{ISubProcess: h, metrics: d.metrics, tracer: d.tracer})
+}
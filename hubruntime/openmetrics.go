@@ -0,0 +1,141 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `openmetrics` 相关的逻辑。
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OpenMetricsContentType 是 /metrics 响应使用的内容类型。
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// WriteOpenMetrics 把指标快照编码为 OpenMetrics 文本格式（以 `# EOF` 结尾）。
+// 手写编码而不引入 Prometheus client，保持 runtime 依赖面与 gomobile 构建体积不变。
+func WriteOpenMetrics(w io.Writer, m Metrics) error {
+	bw := bufio.NewWriter(w)
+	om := openMetricsWriter{w: bw}
+
+	om.family("myflowhub_connections", "gauge", "Current connections by listener and role.")
+	for _, c := range m.Connections {
+		om.sample("myflowhub_connections", labels("listener", c.Listener, "role", c.Role), strconv.Itoa(c.Count))
+	}
+
+	om.family("myflowhub_queue_capacity", "gauge", "Total buffered capacity of dispatcher / send dispatcher queues.")
+	om.sample("myflowhub_queue_capacity", labels("queue", "dispatch"), strconv.Itoa(m.DispatchQueue.Capacity))
+	om.sample("myflowhub_queue_capacity", labels("queue", "send"), strconv.Itoa(m.SendQueue.Capacity))
+	om.family("myflowhub_queue_workers", "gauge", "Workers serving dispatcher / send dispatcher queues.")
+	om.sample("myflowhub_queue_workers", labels("queue", "dispatch"), strconv.Itoa(m.DispatchQueue.Channels*m.DispatchQueue.Workers))
+	om.sample("myflowhub_queue_workers", labels("queue", "send"), strconv.Itoa(m.SendQueue.Channels*m.SendQueue.Workers))
+
+	om.family("myflowhub_handler_inflight", "gauge", "Sub-protocol handler calls currently executing.")
+	om.sample("myflowhub_handler_inflight", "", strconv.FormatInt(m.HandlerInFlight, 10))
+
+	om.family("myflowhub_frames", "counter", "Frames received / sent by direction, sub-protocol and action.")
+	for _, f := range m.Frames {
+		om.sample("myflowhub_frames_total", frameLabels(f), strconv.FormatUint(f.Frames, 10))
+	}
+	om.family("myflowhub_frame_bytes", "counter", "Payload bytes received / sent by direction, sub-protocol and action.")
+	for _, f := range m.Frames {
+		om.sample("myflowhub_frame_bytes_total", frameLabels(f), strconv.FormatUint(f.Bytes, 10))
+	}
+	om.family("myflowhub_frame_errors", "counter", "Handler panics (in) and failed sends or error responses (out).")
+	for _, f := range m.Frames {
+		om.sample("myflowhub_frame_errors_total", frameLabels(f), strconv.FormatUint(f.Errors, 10))
+	}
+
+	om.family("myflowhub_handler_duration_seconds", "histogram", "Sub-protocol handler latency.")
+	for _, l := range m.HandlerLatency {
+		om.histogram("myflowhub_handler_duration_seconds", labels("subproto", SubProtoName(l.SubProto), "action", l.Action), l.Histogram)
+	}
+
+	om.family("myflowhub_state_op_duration_seconds", "histogram", "State backend operation latency.")
+	for _, s := range m.StateOps {
+		om.histogram("myflowhub_state_op_duration_seconds", labels("kind", s.Kind, "backend", s.Backend, "op", s.Op), s.Histogram)
+	}
+	om.family("myflowhub_state_op_errors", "counter", "Failed state backend operations.")
+	for _, s := range m.StateOps {
+		om.sample("myflowhub_state_op_errors_total", labels("kind", s.Kind, "backend", s.Backend, "op", s.Op), strconv.FormatUint(s.Errors, 10))
+	}
+
+	om.line("# EOF")
+	if om.err != nil {
+		return om.err
+	}
+	return bw.Flush()
+}
+
+type openMetricsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (o *openMetricsWriter) line(s string) {
+	if o.err != nil {
+		return
+	}
+	if _, err := o.w.WriteString(s + "\n"); err != nil {
+		o.err = err
+	}
+}
+
+func (o *openMetricsWriter) family(name, typ, help string) {
+	o.line("# TYPE " + name + " " + typ)
+	o.line("# HELP " + name + " " + escapeOpenMetrics(help))
+}
+
+func (o *openMetricsWriter) sample(name, lbls, value string) {
+	if lbls != "" {
+		o.line(name + "{" + lbls + "} " + value)
+		return
+	}
+	o.line(name + " " + value)
+}
+
+func (o *openMetricsWriter) histogram(name, lbls string, h Histogram) {
+	prefix := lbls
+	if prefix != "" {
+		prefix += ","
+	}
+	for i, bound := range h.Bounds {
+		o.sample(name+"_bucket", prefix+`le="`+formatFloat(bound)+`"`, strconv.FormatUint(h.Counts[i], 10))
+	}
+	o.sample(name+"_bucket", prefix+`le="+Inf"`, strconv.FormatUint(h.Count, 10))
+	o.sample(name+"_count", lbls, strconv.FormatUint(h.Count, 10))
+	o.sample(name+"_sum", lbls, formatFloat(h.Sum))
+}
+
+func frameLabels(f FrameMetric) string {
+	return labels("direction", f.Direction, "subproto", SubProtoName(f.SubProto), "action", f.Action)
+}
+
+// labels 把成对的 name/value 编码为 `a="x",b="y"`。
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeOpenMetrics(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// escapeOpenMetrics 按 OpenMetrics 规则转义 HELP 文本与标签值中的 `\`、换行与双引号。
+func escapeOpenMetrics(s string) string {
+	if !strings.ContainsAny(s, "\\\n\"") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -20,7 +20,7 @@ const defaultAuthRolePerms = coreconfig.DefaultAuthRolePerms
 // - Keep this struct gomobile-friendly (basic types only) to simplify future binding.
 // - Fields are intentionally aligned with cmd/hub_server flags/env to avoid drift.
 type Options struct {
-	// Listener toggles (restart required to take effect).
+	// Listener toggles (applied at Start, or live via Runtime.Reconfigure which rebuilds only changed listeners).
 	//
 	// TCP remains the default transport in v1.
 	TCPEnable bool
@@ -61,6 +61,13 @@ type Options struct {
 	ParentJoinPermit   string
 	ParentReconnectSec int
 
+	// ParentEndpoints is an optional ordered failover list (comma or newline separated) that takes
+	// precedence over ParentEndpoint/ParentAddr. Each entry may carry a "#priority=N" suffix
+	// (lower is preferred, default 0), e.g. "quic://a:9000?server_name=a, tcp://b:9000#priority=1".
+	// ParentTransportPrefer orders entries of equal priority by transport, e.g. "quic,tcp,bt+rfcomm".
+	ParentEndpoints       string
+	ParentTransportPrefer string
+
 	// Dispatcher/worker settings
 	ProcChannels int
 	ProcWorkers  int
@@ -78,21 +85,48 @@ type Options struct {
 	AuthNodeRoles    string
 	AuthRolePerms    string
 
-	// WorkDir changes process working directory during Start to make relative paths (e.g. config/*)
-	// resolve into an app-private directory on Android.
+	// WorkDir is the root for this runtime's relative paths (config/runtime_config.json, QUIC cert files,
+	// file.base_dir, flow.base_dir). The process working directory is never changed, so several runtimes
+	// with different WorkDirs can share one process. Empty keeps paths relative to the process cwd.
 	WorkDir string
 
+	// ConfigFile is an optional JSON / YAML / TOML config file (format by extension) with nested sections and
+	// ${ENV_VAR} / ${file:path} interpolation. Relative paths resolve against WorkDir. By default it replaces
+	// config/runtime_config.json as the persistent layer (config_set writes back to it); with ConfigFileReadOnly
+	// it becomes a read-only layer below the persistent one. Applied at Start only.
+	ConfigFile         string
+	ConfigFileReadOnly bool
+
 	// SelfID is used for parent self-register/bootstrap (auth register).
 	// When empty, runtime will not perform self-register and will not bind parent conn via register.
 	SelfID string
 
+	// AdminAddr is the optional HTTP admin listener address (e.g. 127.0.0.1:9100) serving /metrics, /healthz, /readyz and /config/*.
+	// Empty disables it. Applied at Start only.
+	AdminAddr string
+
+	// LogLevel is the global log level (debug|info|warn|error), projected to config key log.level.
+	// Per-component levels are config-only (log.level.<component>); both apply live.
+	LogLevel string
+
 	// ConfigOverrideKeys records config keys explicitly supplied by env/flags/caller.
 	// It is stored as a comma-separated list to keep Options gomobile-friendly.
 	ConfigOverrideKeys string
 
-	Logger *slog.Logger
+	// Logger and Transport are Go-only hooks; they are skipped in JSON (see hubruntime/mobile).
+	Logger *slog.Logger `json:"-"`
+
+	// Transport optionally replaces the TCP listener and TCP parent dialing (e.g. an in-process network for tests).
+	// Nil uses real sockets.
+	Transport Transport `json:"-"`
 }
 
+// 父链多端点配置键（Core 只认识单个 parent.addr）。
+const (
+	configKeyParentEndpoints       = "parent.endpoints"
+	configKeyParentTransportPrefer = "parent.transport_prefer"
+)
+
 // DefaultOptions 提供与 hub_server CLI 对齐的默认运行参数。
 func DefaultOptions() Options {
 	return Options{
@@ -136,6 +170,8 @@ func DefaultOptions() Options {
 
 		WorkDir:            "",
 		SelfID:             "",
+		AdminAddr:          "",
+		LogLevel:           "info",
 		ConfigOverrideKeys: "",
 	}
 }
@@ -186,6 +222,14 @@ func DefaultOptionsFromEnv() Options {
 		opts.ParentAddr = v
 		opts.AddConfigOverrideKeys(coreconfig.KeyParentAddr)
 	}
+	if v, ok := lookupEnvString("HUB_PARENT_ENDPOINTS"); ok {
+		opts.ParentEndpoints = v
+		opts.AddConfigOverrideKeys(configKeyParentEndpoints)
+	}
+	if v, ok := lookupEnvString("HUB_PARENT_TRANSPORT_PREFER"); ok {
+		opts.ParentTransportPrefer = v
+		opts.AddConfigOverrideKeys(configKeyParentTransportPrefer)
+	}
 	if v, ok := lookupEnvBool("HUB_PARENT_ENABLE"); ok {
 		opts.ParentEnable = v
 		opts.AddConfigOverrideKeys(coreconfig.KeyParentEnable)
@@ -268,6 +312,19 @@ func DefaultOptionsFromEnv() Options {
 	if v, ok := lookupEnvString("HUB_SELF_ID"); ok {
 		opts.SelfID = v
 	}
+	if v, ok := lookupEnvString("HUB_ADMIN_ADDR"); ok {
+		opts.AdminAddr = v
+	}
+	if v, ok := lookupEnvString("HUB_LOG_LEVEL"); ok {
+		opts.LogLevel = v
+		opts.AddConfigOverrideKeys(configKeyLogLevel)
+	}
+	if v, ok := lookupEnvString("HUB_CONFIG"); ok {
+		opts.ConfigFile = v
+	}
+	if v, ok := lookupEnvBool("HUB_CONFIG_READONLY"); ok {
+		opts.ConfigFileReadOnly = v
+	}
 
 	return opts
 }
@@ -279,7 +336,7 @@ func (o *Options) Normalize() {
 	}
 	defaults := DefaultOptions()
 	overrideKeys := o.configOverrideKeySet()
-	if strings.TrimSpace(o.ParentEndpoint) != "" || strings.TrimSpace(o.ParentAddr) != "" {
+	if strings.TrimSpace(o.ParentEndpoint) != "" || strings.TrimSpace(o.ParentAddr) != "" || strings.TrimSpace(o.ParentEndpoints) != "" {
 		o.ParentEnable = true
 	}
 	o.Addr = strings.TrimSpace(o.Addr)
@@ -291,6 +348,8 @@ func (o *Options) Normalize() {
 	o.ParentEndpoint = strings.TrimSpace(o.ParentEndpoint)
 	o.ParentAddr = strings.TrimSpace(o.ParentAddr)
 	o.ParentJoinPermit = strings.TrimSpace(o.ParentJoinPermit)
+	o.ParentEndpoints = strings.TrimSpace(o.ParentEndpoints)
+	o.ParentTransportPrefer = strings.TrimSpace(o.ParentTransportPrefer)
 	if o.TCPEnable && o.Addr == "" {
 		if _, ok := overrideKeys["addr"]; !ok {
 			o.Addr = defaults.Addr
@@ -370,7 +429,15 @@ func (o *Options) Normalize() {
 		}
 	}
 	o.WorkDir = strings.TrimSpace(o.WorkDir)
+	o.ConfigFile = strings.TrimSpace(o.ConfigFile)
 	o.SelfID = strings.TrimSpace(o.SelfID)
+	o.AdminAddr = strings.TrimSpace(o.AdminAddr)
+	o.LogLevel = strings.ToLower(strings.TrimSpace(o.LogLevel))
+	if o.LogLevel == "" {
+		if _, ok := overrideKeys[configKeyLogLevel]; !ok {
+			o.LogLevel = defaults.LogLevel
+		}
+	}
 	o.ConfigOverrideKeys = joinOverrideKeys(splitOverrideKeys(o.ConfigOverrideKeys))
 }
 
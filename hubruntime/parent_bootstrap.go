@@ -0,0 +1,414 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `parent_bootstrap` 相关的逻辑。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	authproto "github.com/yttydcs/myflowhub-server/protocol/auth"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+const (
+	// parentRegisterBackoffMin / parentRegisterBackoffMax 是 bootstrap register 重试的指数退避区间。
+	parentRegisterBackoffMin = 500 * time.Millisecond
+	parentRegisterBackoffMax = 30 * time.Second
+	// parentRegisterRespTimeout 是发送 register 后等待 register_resp 的上限，超时按失败重试。
+	parentRegisterRespTimeout = 10 * time.Second
+
+	authCodeRejected = 4001
+)
+
+// 父链 bootstrap register 状态（Status.ParentRegisterState）。
+const (
+	ParentRegisterSent                 = "sent"
+	ParentRegisterOK                   = "ok"
+	ParentRegisterPending              = "pending"
+	ParentRegisterRejected             = "rejected"
+	ParentRegisterAuthorityUnavailable = "authority_unavailable"
+	ParentRegisterMaintenance          = "maintenance"
+	ParentRegisterFailed               = "failed"
+)
+
+// parentRegisterStatus 是对外暴露的 bootstrap register 快照。
+type parentRegisterStatus struct {
+	State   string
+	Code    int
+	Message string
+}
+
+// parentBootstrap 跟踪持久父连接，并在其上发送 bootstrap register。
+//   - 父连接的建立 / 断开由连接管理器钩子推送（connUp / connDown），不再轮询连接表；
+//   - 发送失败、register_resp 为 4500 / 4503（父节点维护中）或等待响应超时时按指数退避 + 抖动重试；
+//   - pending（待审批）同样按退避重发 register，避免审批后父节点推送的 register_resp 丢失时一直停在 pending；
+//   - rejected / 其他失败码不重试，结果记录在 Status 中，直到父链重连。
+type parentBootstrap struct {
+	r   *Runtime
+	cfg core.IConfig
+	log *slog.Logger
+
+	selfID string
+	wake   chan struct{}
+
+	mu       sync.Mutex
+	conn     core.IConnection
+	status   parentRegisterStatus
+	attempts int
+	// sendAt 非零表示需要在该时间点（重新）发送；deadline 非零表示正在等待 register_resp。
+	sendAt   time.Time
+	deadline time.Time
+
+	// nodeID 为本节点运行中的 nodeID，assigned 为父节点 register_resp 最近给出的 nodeID；
+	// cachePath / cacheParent / cached 对应 workdir 中的 nodeID 缓存记录（见 trackNodeID）。
+	nodeID      uint32
+	nodeSource  string
+	assigned    uint32
+	cachePath   string
+	cacheParent string
+	cached      uint32
+}
+
+func newParentBootstrap(r *Runtime, cfg core.IConfig, selfID string, log *slog.Logger) *parentBootstrap {
+	return &parentBootstrap{
+		r:      r,
+		cfg:    cfg,
+		log:    log,
+		selfID: strings.TrimSpace(selfID),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// trackNodeID 开启 nodeID 核对：父链 register_resp 给出的 nodeID 写入缓存，与运行中的 nodeID 不一致时上报。
+// 调用时 nodeID 已在缓存中（取自缓存，或启动前注册后已写入）。
+func (b *parentBootstrap) trackNodeID(nodeID uint32, source, cachePath, parent string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodeID = nodeID
+	b.nodeSource = source
+	b.cachePath = cachePath
+	b.cacheParent = parent
+	b.cached = nodeID
+}
+
+// assignedNodeID 返回父节点最近一次分配的 nodeID；尚未收到时为 0。
+func (b *parentBootstrap) assignedNodeID() uint32 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.assigned
+}
+
+// nodeIDMismatch 判断父节点分配的 nodeID 是否与运行中的不一致。
+func (b *parentBootstrap) nodeIDMismatch() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.assigned != 0 && b.nodeID != 0 && b.assigned != b.nodeID
+}
+
+// reconcileNodeID 处理 register_resp（code=1）中的 nodeID：更新缓存；与运行中的 nodeID 不一致时不做覆盖，
+// 而是记录错误、发布 parent.node_id_mismatch，并使 readiness 报告 node_id_mismatch，直到重启采用新的分配。
+func (b *parentBootstrap) reconcileNodeID(connID string, assigned uint32) {
+	if assigned == 0 {
+		return
+	}
+	b.mu.Lock()
+	if b.cachePath == "" {
+		b.mu.Unlock()
+		return
+	}
+	running, source := b.nodeID, b.nodeSource
+	changed := b.assigned != assigned
+	b.assigned = assigned
+	persist := b.cached != assigned
+	if persist {
+		b.cached = assigned
+	}
+	path, selfID, parent := b.cachePath, b.selfID, b.cacheParent
+	b.mu.Unlock()
+
+	if persist {
+		if err := storeCachedNodeID(path, selfID, parent, assigned); err != nil {
+			b.log.Warn("persist node id cache failed", "path", path, "err", err)
+		}
+	}
+	if running == assigned || !changed {
+		return
+	}
+	err := fmt.Errorf("parent assigned node id %d, running as %d (source=%s); restart to adopt", assigned, running, source)
+	b.log.Error("node id mismatch with parent assignment", "conn", connID, "assigned", assigned, "running", running, "source", source)
+	b.r.storeErr(err)
+	b.r.emit(Event{Type: EventParentNodeIDMismatch, ConnID: connID, NodeID: assigned, Message: err.Error()})
+}
+
+// connUp 在父连接加入连接管理器时调用（Core 父链循环的 goroutine 内，须快速返回）。
+func (b *parentBootstrap) connUp(conn core.IConnection) {
+	if b == nil || conn == nil {
+		return
+	}
+	// Local side: mark parent conn as "logged-in" to pass sourceMismatch gating.
+	// We don't need exact parent node id here; any non-zero value is sufficient for the
+	// later "parent conn exemption" branch. Use 1 as a stable default.
+	if ensureConnNodeIDNonZero(conn, 1) {
+		b.log.Info("parent conn meta node_id initialized", "conn", conn.ID(), "node_id", uint32(1))
+	}
+	b.mu.Lock()
+	b.conn = conn
+	b.status = parentRegisterStatus{}
+	b.attempts = 0
+	b.deadline = time.Time{}
+	b.sendAt = time.Time{}
+	if b.selfID != "" {
+		b.sendAt = time.Now()
+	}
+	b.mu.Unlock()
+	b.poke()
+}
+
+// connDown 在父连接移出连接管理器时调用。
+func (b *parentBootstrap) connDown(conn core.IConnection) {
+	if b == nil || conn == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.conn != nil && b.conn.ID() == conn.ID() {
+		b.conn = nil
+		b.status = parentRegisterStatus{}
+		b.attempts = 0
+		b.sendAt = time.Time{}
+		b.deadline = time.Time{}
+	}
+	b.mu.Unlock()
+	b.poke()
+}
+
+// current 返回当前父连接。
+func (b *parentBootstrap) current() (core.IConnection, bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conn, b.conn != nil
+}
+
+func (b *parentBootstrap) snapshot() parentRegisterStatus {
+	if b == nil {
+		return parentRegisterStatus{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+func (b *parentBootstrap) observeInbound(conn core.IConnection, hdr core.IHeader, payload []byte) {
+	if b == nil || b.selfID == "" || conn == nil || hdr == nil || hdr.SubProto() != authproto.SubProtoAuth {
+		return
+	}
+	if !isParentConn(conn) || frameAction(hdr.SubProto(), payload) != authproto.ActionRegisterResp {
+		return
+	}
+	code, ok := responseCode(hdr.SubProto(), payload)
+	if !ok {
+		return
+	}
+	var msg string
+	if raw, ok := scanJSONField(payload, "msg"); ok {
+		msg = strings.Trim(string(raw), `"`)
+	}
+	b.registerResp(conn.ID(), code, msg)
+	if code == authCodeOK {
+		var resp authproto.RespData
+		decodeActionData(payload, &resp)
+		b.reconcileNodeID(conn.ID(), resp.NodeID)
+	}
+}
+
+func (b *parentBootstrap) observeOutbound(core.IConnection, core.IHeader, []byte) {}
+
+// registerResp 归类父链 register_resp；只处理当前父连接上的响应。
+func (b *parentBootstrap) registerResp(connID string, code int, msg string) {
+	b.mu.Lock()
+	if b.conn == nil || b.conn.ID() != connID {
+		b.mu.Unlock()
+		return
+	}
+	b.deadline = time.Time{}
+	b.status = parentRegisterStatus{State: classifyRegisterCode(code), Code: code, Message: msg}
+	retry := code == authCodeAuthorityUnavailable || code == mgmtproto.MaintenanceCode || code == authCodePending
+	var delay time.Duration
+	if retry {
+		delay = b.scheduleRetryLocked()
+	} else {
+		b.attempts = 0
+	}
+	st := b.status
+	b.mu.Unlock()
+
+	switch st.State {
+	case ParentRegisterOK:
+		b.log.Info("parent bootstrap register acknowledged", "conn", connID)
+	case ParentRegisterPending:
+		b.log.Warn("parent bootstrap register pending approval", "conn", connID, "msg", msg, "retry_in", delay)
+	default:
+		err := registerRespError(st)
+		b.log.Warn("parent bootstrap register not accepted", "conn", connID, "code", code, "msg", msg, "retry_in", delay)
+		b.r.storeErr(err)
+		b.r.emit(Event{Type: EventParentRegisterFailed, ConnID: connID, Message: err.Error()})
+	}
+	b.poke()
+}
+
+// run 处理父连接变化、发送 register 与超时重试，直到 ctx 结束。
+func (b *parentBootstrap) run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		next := b.step(ctx)
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// step 执行到期的动作，并返回下一个需要唤醒的时间点（零值表示只等待事件）。
+func (b *parentBootstrap) step(ctx context.Context) time.Time {
+	now := time.Now()
+	b.mu.Lock()
+	conn := b.conn
+	if conn == nil {
+		b.mu.Unlock()
+		return time.Time{}
+	}
+	if !b.deadline.IsZero() && !now.Before(b.deadline) {
+		b.deadline = time.Time{}
+		b.status = parentRegisterStatus{State: ParentRegisterFailed, Message: "register response timeout"}
+		delay := b.scheduleRetryLocked()
+		b.mu.Unlock()
+		err := errors.New("parent bootstrap register: response timeout")
+		b.log.Warn("parent bootstrap register unanswered", "conn", conn.ID(), "retry_in", delay)
+		b.r.storeErr(err)
+		b.r.emit(Event{Type: EventParentRegisterFailed, ConnID: conn.ID(), Message: err.Error()})
+		return b.nextWake()
+	}
+	if b.sendAt.IsZero() || now.Before(b.sendAt) {
+		b.mu.Unlock()
+		return b.nextWake()
+	}
+	// 先标记为已发送：register_resp 可能在 SendWithHeader 返回前就被读 goroutine 处理。
+	// 待审批期间的重发保留 pending 状态，readiness 与 Status 不因重发在 pending / sent 之间来回切换。
+	b.sendAt = time.Time{}
+	if b.status.State != ParentRegisterPending {
+		b.status = parentRegisterStatus{State: ParentRegisterSent}
+	}
+	b.deadline = now.Add(parentRegisterRespTimeout)
+	b.mu.Unlock()
+
+	displayName := trimmedConfigValue(b.cfg, "node.display_name")
+	joinPermit := trimmedConfigValue(b.cfg, coreconfig.KeyParentJoinPermit)
+	if err := sendRegisterOnConn(ctx, conn, b.selfID, displayName, joinPermit, &b.r.msgSeq); err != nil {
+		b.mu.Lock()
+		if b.conn == nil || b.conn.ID() != conn.ID() {
+			// 发送期间父链已切换，新连接的状态由 connUp 重置。
+			b.mu.Unlock()
+			return b.nextWake()
+		}
+		b.deadline = time.Time{}
+		b.status = parentRegisterStatus{State: ParentRegisterFailed, Message: err.Error()}
+		delay := b.scheduleRetryLocked()
+		b.mu.Unlock()
+		b.log.Warn("parent bootstrap register failed", "err", err, "conn", conn.ID(), "retry_in", delay)
+		b.r.storeErr(err)
+		b.r.emit(Event{Type: EventParentRegisterFailed, ConnID: conn.ID(), Message: err.Error()})
+		return b.nextWake()
+	}
+	b.log.Info("parent bootstrap register sent", "conn", conn.ID(), "self_id", b.selfID)
+	b.r.emit(Event{Type: EventParentRegisterSent, ConnID: conn.ID()})
+	return b.nextWake()
+}
+
+func (b *parentBootstrap) nextWake() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	next := b.sendAt
+	if !b.deadline.IsZero() && (next.IsZero() || b.deadline.Before(next)) {
+		next = b.deadline
+	}
+	return next
+}
+
+// scheduleRetryLocked 安排下一次发送并返回退避时长；调用方须持有 b.mu。
+func (b *parentBootstrap) scheduleRetryLocked() time.Duration {
+	delay := registerBackoff(b.attempts)
+	b.attempts++
+	b.sendAt = time.Now().Add(delay)
+	return delay
+}
+
+func (b *parentBootstrap) poke() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// registerBackoff 返回第 attempt 次重试（从 0 开始）的等待时长：指数增长、封顶，并在 [d/2, d) 内随机抖动。
+func registerBackoff(attempt int) time.Duration {
+	d := parentRegisterBackoffMin
+	for i := 0; i < attempt && d < parentRegisterBackoffMax; i++ {
+		d *= 2
+	}
+	if d > parentRegisterBackoffMax {
+		d = parentRegisterBackoffMax
+	}
+	half := d / 2
+	return half + rand.N(half)
+}
+
+func classifyRegisterCode(code int) string {
+	switch code {
+	case authCodeOK:
+		return ParentRegisterOK
+	case authCodePending:
+		return ParentRegisterPending
+	case authCodeRejected:
+		return ParentRegisterRejected
+	case authCodeAuthorityUnavailable:
+		return ParentRegisterAuthorityUnavailable
+	case mgmtproto.MaintenanceCode:
+		return ParentRegisterMaintenance
+	default:
+		return ParentRegisterFailed
+	}
+}
+
+// registerRespError 把未被接受的 register_resp 转换为 Status.LastError 中的错误文本。
+func registerRespError(st parentRegisterStatus) error {
+	if st.Message == "" {
+		return fmt.Errorf("parent bootstrap register %s (code=%d)", st.State, st.Code)
+	}
+	return fmt.Errorf("parent bootstrap register %s (code=%d): %s", st.State, st.Code, st.Message)
+}
+
+var _ frameObserver = (*parentBootstrap)(nil)
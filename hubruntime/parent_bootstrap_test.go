@@ -0,0 +1,144 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `parent_bootstrap` 相关的行为。
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-core/connmgr"
+)
+
+// flakyParentConn 在前 fail 次发送时返回错误，并记录成功发送的次数。
+type flakyParentConn struct {
+	registerTestConn
+	mu   sync.Mutex
+	fail int
+	sent int
+}
+
+func (c *flakyParentConn) SendWithHeader(core.IHeader, []byte, core.IHeaderCodec) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail > 0 {
+		c.fail--
+		return errors.New("broken pipe")
+	}
+	c.sent++
+	return nil
+}
+
+func (c *flakyParentConn) sentCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRegisterBackoffBounds(t *testing.T) {
+	for attempt := 0; attempt < 12; attempt++ {
+		want := parentRegisterBackoffMin << attempt
+		if want > parentRegisterBackoffMax || want <= 0 {
+			want = parentRegisterBackoffMax
+		}
+		for i := 0; i < 20; i++ {
+			d := registerBackoff(attempt)
+			if d < want/2 || d >= want {
+				t.Fatalf("attempt %d: backoff %v outside [%v, %v)", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestParentBootstrapRetriesAndClassifiesResponse(t *testing.T) {
+	rt := &Runtime{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	boot := newParentBootstrap(rt, nil, "hub-a", rt.log)
+	cm := &eventConnManager{IConnectionManager: connmgr.New(), emit: rt.emit, parent: boot}
+	cm.SetHooks(core.ConnectionHooks{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go boot.run(ctx)
+
+	parent := &flakyParentConn{registerTestConn: registerTestConn{id: "parent-1"}, fail: 1}
+	parent.SetMeta(core.MetaRoleKey, core.RoleParent)
+	if err := cm.Add(parent); err != nil {
+		t.Fatalf("add parent: %v", err)
+	}
+	if id, ok := parent.GetMeta("nodeID"); !ok || id != uint32(1) {
+		t.Fatalf("parent meta nodeID=%v, want 1", id)
+	}
+
+	// 第一次发送失败，退避后重发成功。
+	waitFor(t, "register resent after failure", func() bool { return parent.sentCount() == 1 })
+	if st := boot.snapshot(); st.State != ParentRegisterSent {
+		t.Fatalf("state after send=%+v", st)
+	}
+	if !strings.Contains(rt.loadErr(), "broken pipe") {
+		t.Fatalf("send failure not recorded: %q", rt.loadErr())
+	}
+
+	// 4500 视为暂时失败，按退避重发。
+	boot.registerResp(parent.ID(), authCodeAuthorityUnavailable, "authority unavailable")
+	if st := boot.snapshot(); st.State != ParentRegisterAuthorityUnavailable || st.Code != authCodeAuthorityUnavailable {
+		t.Fatalf("state after 4500=%+v", st)
+	}
+	waitFor(t, "register resent after 4500", func() bool { return parent.sentCount() == 2 })
+
+	// rejected 不再重试，结果保留到父链重连。
+	boot.registerResp(parent.ID(), authCodeRejected, "denied")
+	if st := boot.snapshot(); st.State != ParentRegisterRejected || st.Message != "denied" {
+		t.Fatalf("state after reject=%+v", st)
+	}
+	if !strings.Contains(rt.loadErr(), "rejected (code=4001): denied") {
+		t.Fatalf("reject not recorded: %q", rt.loadErr())
+	}
+	// 旧连接上的响应被忽略。
+	boot.registerResp("parent-0", authCodeOK, "")
+	if st := boot.snapshot(); st.State != ParentRegisterRejected {
+		t.Fatalf("stale response changed state: %+v", st)
+	}
+
+	_ = cm.Remove(parent.ID())
+	if _, ok := boot.current(); ok || boot.snapshot().State != "" {
+		t.Fatalf("state should reset on disconnect: %+v", boot.snapshot())
+	}
+
+	parent2 := &flakyParentConn{registerTestConn: registerTestConn{id: "parent-2"}}
+	parent2.SetMeta(core.MetaRoleKey, core.RoleParent)
+	if err := cm.Add(parent2); err != nil {
+		t.Fatalf("add parent2: %v", err)
+	}
+	waitFor(t, "register sent on reconnect", func() bool { return parent2.sentCount() == 1 })
+	// pending 按退避重发，重发期间状态保持 pending。
+	boot.registerResp(parent2.ID(), authCodePending, "pending approval")
+	waitFor(t, "register resent while pending", func() bool { return parent2.sentCount() == 2 })
+	if st := boot.snapshot(); st.State != ParentRegisterPending || st.Code != authCodePending {
+		t.Fatalf("state after pending resend=%+v", st)
+	}
+	boot.registerResp(parent2.ID(), authCodeOK, "")
+	if st := boot.snapshot(); st.State != ParentRegisterOK {
+		t.Fatalf("state after approval=%+v", st)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := parent2.sentCount(); n != 2 {
+		t.Fatalf("acknowledged register resent %d times", n)
+	}
+}
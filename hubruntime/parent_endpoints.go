@@ -0,0 +1,319 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `parent_endpoints` 相关的逻辑。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+const (
+	// parentEndpointDialTimeout 是多端点时单个端点的拨号上限，避免一个黑洞地址拖住整轮轮换。
+	parentEndpointDialTimeout = 10 * time.Second
+	// parentFailbackInterval 是连接在非首选端点上时探测更优端点的周期。
+	parentFailbackInterval = 30 * time.Second
+	// parentDrainAvoid 是父节点通告 drain 后回避该端点的时长：期间拨号先尝试其他端点，回切探测也跳过它。
+	parentDrainAvoid = 2 * time.Minute
+)
+
+// ParentEndpointStatus 描述一个候选父端点的当前状态（按实际拨号顺序排列）。
+type ParentEndpointStatus struct {
+	Endpoint  string
+	Transport string
+	Priority  int
+	// Current 表示当前父连接经由该端点建立。
+	Current bool
+
+	LastError       string
+	LastErrorAt     time.Time
+	LastConnectedAt time.Time
+	// DrainingUntil 非零表示父节点经该端点通告过 drain，在此之前拨号会优先尝试其他端点。
+	DrainingUntil time.Time
+}
+
+// parentEndpoint 是解析后的候选端点；index 为配置中的原始位置，用于稳定排序。
+type parentEndpoint struct {
+	target    string
+	transport string
+	priority  int
+	index     int
+}
+
+// parseParentEndpointList 解析逗号 / 换行分隔的端点列表。
+// 每项可带 `#priority=N` 后缀（N 越小越优先，缺省 0）；prefer 为逗号分隔的传输偏好（如 "quic,tcp,bt+rfcomm"）。
+// 排序键依次为：priority、传输偏好位置（未列出的传输排在最后）、配置顺序。
+func parseParentEndpointList(raw, prefer string) ([]parentEndpoint, error) {
+	rank := make(map[string]int)
+	for i, t := range splitListValue(prefer) {
+		t = strings.ToLower(t)
+		if _, ok := rank[t]; !ok {
+			rank[t] = i
+		}
+	}
+	seen := make(map[string]struct{})
+	var out []parentEndpoint
+	for i, item := range splitListValue(raw) {
+		target, priority := item, 0
+		if at := strings.LastIndex(item, "#"); at >= 0 {
+			target = strings.TrimSpace(item[:at])
+			frag := strings.TrimSpace(item[at+1:])
+			val, ok := strings.CutPrefix(frag, "priority=")
+			if !ok {
+				return nil, fmt.Errorf("parent endpoint %q: unsupported suffix %q", item, frag)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(val))
+			if err != nil {
+				return nil, fmt.Errorf("parent endpoint %q: invalid priority: %w", item, err)
+			}
+			priority = n
+		}
+		scheme, _, err := parseParentEndpoint(target)
+		if err != nil {
+			return nil, fmt.Errorf("parent endpoint %q: %w", target, err)
+		}
+		if _, dup := seen[target]; dup {
+			continue
+		}
+		seen[target] = struct{}{}
+		out = append(out, parentEndpoint{target: target, transport: scheme, priority: priority, index: i})
+	}
+	transportRank := func(t string) int {
+		if r, ok := rank[t]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	sort.SliceStable(out, func(a, b int) bool {
+		if out[a].priority != out[b].priority {
+			return out[a].priority < out[b].priority
+		}
+		return transportRank(out[a].transport) < transportRank(out[b].transport)
+	})
+	return out, nil
+}
+
+// splitListValue 按逗号与换行切分列表型配置值，并去掉空项。
+func splitListValue(raw string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parentEndpointsFromOptions 返回按优先级排序的候选父端点。
+// ParentEndpoints 非空时以其为准；否则退化为 ParentEndpoint / ParentAddr 单端点。
+func parentEndpointsFromOptions(opts Options) ([]parentEndpoint, error) {
+	if strings.TrimSpace(opts.ParentEndpoints) != "" {
+		return parseParentEndpointList(opts.ParentEndpoints, opts.ParentTransportPrefer)
+	}
+	target := effectiveParentTarget(opts)
+	if target == "" {
+		return nil, nil
+	}
+	scheme, _, err := parseParentEndpoint(target)
+	if err != nil {
+		return nil, err
+	}
+	return []parentEndpoint{{target: target, transport: scheme}}, nil
+}
+
+type parentEndpointState struct {
+	lastErr         string
+	lastErrAt       time.Time
+	lastConnectedAt time.Time
+	drainingUntil   time.Time
+}
+
+// parentEndpointSet 是父链拨号器：每次拨号按优先级依次尝试全部端点，返回第一个成功的连接；
+// 连接落在非首选端点上时，runFailback 周期探测更优端点，恢复后断开当前父链，由 Core 重连回首选端点。
+type parentEndpointSet struct {
+	endpoints []parentEndpoint
+	dial      func(context.Context, string) (core.IConnection, error)
+	log       *slog.Logger
+	// failbackEvery 默认为 parentFailbackInterval，测试中可调小。
+	failbackEvery time.Duration
+
+	mu      sync.Mutex
+	state   []parentEndpointState
+	current int
+	connID  string
+}
+
+func newParentEndpointSet(endpoints []parentEndpoint, dial func(context.Context, string) (core.IConnection, error), log *slog.Logger) *parentEndpointSet {
+	return &parentEndpointSet{
+		endpoints: endpoints,
+		dial:      dial,
+		log:       log,
+		state:     make([]parentEndpointState, len(endpoints)),
+		current:   -1,
+
+		failbackEvery: parentFailbackInterval,
+	}
+}
+
+// Dial 实现 Core ParentDialer；addr 参数被忽略，端点取自候选列表。
+func (s *parentEndpointSet) Dial(ctx context.Context, _ string) (core.IConnection, error) {
+	if len(s.endpoints) == 0 {
+		return nil, errors.New("no parent endpoint configured")
+	}
+	var errs []error
+	for _, i := range s.dialOrder() {
+		conn, err := s.dialOne(ctx, i)
+		if err == nil {
+			s.mu.Lock()
+			s.current = i
+			s.connID = conn.ID()
+			s.state[i].lastConnectedAt = time.Now()
+			s.mu.Unlock()
+			if i > 0 {
+				s.log.Warn("parent connected via fallback endpoint", "endpoint", s.endpoints[i].target, "preferred", s.endpoints[0].target)
+			}
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.endpoints[i].target, err))
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, fmt.Errorf("all parent endpoints failed: %w", errors.Join(errs...))
+}
+
+// dialOrder 返回本轮拨号顺序：按优先级排列，正在 drain 的端点整体后移，仅在其他端点都失败时才尝试。
+func (s *parentEndpointSet) dialOrder() []int {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order := make([]int, 0, len(s.endpoints))
+	var draining []int
+	for i := range s.endpoints {
+		if now.Before(s.state[i].drainingUntil) {
+			draining = append(draining, i)
+			continue
+		}
+		order = append(order, i)
+	}
+	return append(order, draining...)
+}
+
+// markDraining 记录 connID 所在的当前端点已通告 drain；返回是否还有其他端点可切换。
+// connID 不是当前父连接时忽略（通告来自已被替换的旧连接）。
+func (s *parentEndpointSet) markDraining(connID string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current < 0 || s.connID != connID {
+		return false
+	}
+	s.state[s.current].drainingUntil = time.Now().Add(parentDrainAvoid)
+	return len(s.endpoints) > 1
+}
+
+func (s *parentEndpointSet) isDraining(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.state[i].drainingUntil)
+}
+
+// dialOne 拨号单个端点并记录结果；多端点时附加单端点超时。
+func (s *parentEndpointSet) dialOne(ctx context.Context, i int) (core.IConnection, error) {
+	dctx := ctx
+	if len(s.endpoints) > 1 {
+		var cancel context.CancelFunc
+		dctx, cancel = context.WithTimeout(ctx, parentEndpointDialTimeout)
+		defer cancel()
+	}
+	conn, err := s.dial(dctx, s.endpoints[i].target)
+	if err == nil && conn == nil {
+		err = errors.New("dial returned nil conn")
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.state[i].lastErr = err.Error()
+		s.state[i].lastErrAt = time.Now()
+		s.mu.Unlock()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// runFailback 周期检查当前父连接是否落在非首选端点上；若更优端点可拨通，则关闭当前父连接触发重连。
+func (s *parentEndpointSet) runFailback(ctx context.Context, current func() (core.IConnection, bool)) {
+	if len(s.endpoints) < 2 {
+		return
+	}
+	ticker := time.NewTicker(s.failbackEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		conn, ok := current()
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		idx := s.current
+		live := s.connID == conn.ID()
+		s.mu.Unlock()
+		if !live || idx <= 0 {
+			continue
+		}
+		for i := 0; i < idx; i++ {
+			if s.isDraining(i) {
+				continue
+			}
+			probe, err := s.dialOne(ctx, i)
+			if err != nil {
+				continue
+			}
+			_ = probe.Close()
+			s.log.Info("preferred parent endpoint healthy again, failing back", "endpoint", s.endpoints[i].target, "from", s.endpoints[idx].target)
+			_ = conn.Close()
+			break
+		}
+	}
+}
+
+// Snapshot 返回各端点状态；liveConnID 为当前父连接 ID（未连接时为空）。
+func (s *parentEndpointSet) Snapshot(liveConnID string) []ParentEndpointStatus {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ParentEndpointStatus, len(s.endpoints))
+	for i, ep := range s.endpoints {
+		out[i] = ParentEndpointStatus{
+			Endpoint:        ep.target,
+			Transport:       ep.transport,
+			Priority:        ep.priority,
+			Current:         liveConnID != "" && i == s.current && s.connID == liveConnID,
+			LastError:       s.state[i].lastErr,
+			LastErrorAt:     s.state[i].lastErrAt,
+			LastConnectedAt: s.state[i].lastConnectedAt,
+			DrainingUntil:   s.state[i].drainingUntil,
+		}
+	}
+	return out
+}
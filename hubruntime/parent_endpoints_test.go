@@ -0,0 +1,169 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `parent_endpoints` 相关的行为。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestParseParentEndpointListOrdering(t *testing.T) {
+	eps, err := parseParentEndpointList(
+		"tcp://b:9000, quic://a:9000?server_name=a\n10.0.0.9:9000#priority=5, tcp://b:9000, tcp://c:9000#priority=-1",
+		"quic, tcp",
+	)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var got []string
+	for _, ep := range eps {
+		got = append(got, ep.target)
+	}
+	want := []string{"tcp://c:9000", "quic://a:9000?server_name=a", "tcp://b:9000", "10.0.0.9:9000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order=%v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"tcp://a:9000#prio=1", "tcp://a:9000#priority=x", "ftp://a"} {
+		if _, err := parseParentEndpointList(bad, ""); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+}
+
+func TestParentEndpointsOptionsRoundTrip(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ParentEndpoints = "tcp://b:9000, quic://a:9000?server_name=a"
+	opts.ParentTransportPrefer = "quic,tcp"
+	opts.Normalize()
+	if !opts.ParentEnable {
+		t.Fatalf("parent endpoints should enable parent link")
+	}
+	data := configDataFromOptions(opts)
+	if data[coreconfig.KeyParentAddr] != "quic://a:9000?server_name=a" {
+		t.Fatalf("parent.addr=%q, want preferred endpoint", data[coreconfig.KeyParentAddr])
+	}
+	back := applyConfigToOptions(DefaultOptions(), coreconfig.NewMap(data))
+	if back.ParentEndpoints != opts.ParentEndpoints || back.ParentTransportPrefer != "quic,tcp" {
+		t.Fatalf("round trip lost endpoints: %+v", back)
+	}
+	if !configKeyRequiresRestart(configKeyParentEndpoints) {
+		t.Fatalf("parent.endpoints should require restart")
+	}
+}
+
+// endpointTestDialer 按端点决定拨号成败，并记录拨出的连接。
+type endpointTestDialer struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	conns []*closeTrackConn
+	seq   atomic.Int32
+}
+
+type closeTrackConn struct {
+	registerTestConn
+	target string
+	closed atomic.Bool
+}
+
+func (c *closeTrackConn) Close() error { c.closed.Store(true); return nil }
+
+func (d *endpointTestDialer) setDown(target string, down bool) {
+	d.mu.Lock()
+	d.down[target] = down
+	d.mu.Unlock()
+}
+
+func (d *endpointTestDialer) dial(_ context.Context, target string) (core.IConnection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down[target] {
+		return nil, errors.New("connection refused")
+	}
+	c := &closeTrackConn{registerTestConn: registerTestConn{id: target + "#" + string(rune('0'+d.seq.Add(1)))}, target: target}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+func TestParentEndpointSetRotatesAndFailsBack(t *testing.T) {
+	eps, err := parseParentEndpointList("tcp://wired:9000, tcp://lte:9000", "")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	dialer := &endpointTestDialer{down: map[string]bool{"tcp://wired:9000": true}}
+	set := newParentEndpointSet(eps, dialer.dial, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	set.failbackEvery = 10 * time.Millisecond
+
+	conn, err := set.Dial(context.Background(), "ignored")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	st := set.Snapshot(conn.ID())
+	if st[0].Current || !st[1].Current || st[0].LastError != "connection refused" || st[1].LastError != "" {
+		t.Fatalf("snapshot after fallback=%+v", st)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go set.runFailback(ctx, func() (core.IConnection, bool) { return conn, true })
+
+	time.Sleep(50 * time.Millisecond)
+	if conn.(*closeTrackConn).closed.Load() {
+		t.Fatalf("fallback conn closed while preferred endpoint still down")
+	}
+	dialer.setDown("tcp://wired:9000", false)
+	waitFor(t, "failback closes fallback conn", func() bool { return conn.(*closeTrackConn).closed.Load() })
+
+	// Core 重连时重新拨号，首选端点优先。
+	next, err := set.Dial(context.Background(), "ignored")
+	if err != nil || next.(*closeTrackConn).target != "tcp://wired:9000" {
+		t.Fatalf("redial=%v err=%v, want preferred endpoint", next, err)
+	}
+	if st := set.Snapshot(next.ID()); !st[0].Current {
+		t.Fatalf("snapshot after failback=%+v", st)
+	}
+
+	dialer.setDown("tcp://wired:9000", true)
+	dialer.setDown("tcp://lte:9000", true)
+	if _, err := set.Dial(context.Background(), "ignored"); err == nil || !strings.Contains(err.Error(), "all parent endpoints failed") {
+		t.Fatalf("all-down dial err=%v", err)
+	}
+}
+
+func TestMergeNodeInfoItems(t *testing.T) {
+	payload := []byte(`{"action":"node_info_resp","data":{"code":1,"msg":"ok","items":{"node_id":"5","parent_endpoint":"local"}}}`)
+	out := mergeNodeInfoItems(payload, map[string]string{"parent_endpoint": "tcp://x", "parent_endpoint_1": "tcp://x"})
+	var msg mgmtproto.Message
+	if err := json.Unmarshal(out, &msg); err != nil || msg.Action != mgmtproto.ActionNodeInfoResp {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	var resp mgmtproto.NodeInfoResp
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+	if resp.Items["node_id"] != "5" || resp.Items["parent_endpoint"] != "local" || resp.Items["parent_endpoint_1"] != "tcp://x" {
+		t.Fatalf("items=%v", resp.Items)
+	}
+
+	failed := []byte(`{"action":"node_info_resp","data":{"code":500,"msg":"no server context"}}`)
+	if got := mergeNodeInfoItems(failed, map[string]string{"a": "b"}); string(got) != string(failed) {
+		t.Fatalf("failed response should be untouched: %s", got)
+	}
+	other := []byte(`{"action":"config_get_resp","data":{"code":1}}`)
+	if got := mergeNodeInfoItems(other, map[string]string{"a": "b"}); string(got) != string(other) {
+		t.Fatalf("other actions should be untouched: %s", got)
+	}
+}
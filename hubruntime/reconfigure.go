@@ -0,0 +1,184 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `reconfigure` 相关的逻辑。
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ReconfigureResult 描述一次 Reconfigure 的结果。
+//   - Listeners：实际被重建或关闭的 listener 名称（tcp / quic / rfcomm）；
+//   - RestartRequired：运行期无法切换、需要重启 runtime 才会生效的项，
+//     包括 Options 字段（workdir / config_file / self_id / node_id / admin_addr）与只在启动期读取的配置键。
+type ReconfigureResult struct {
+	Listeners       []string
+	RestartRequired []string
+}
+
+// Reconfigure 把新的 Options 应用到运行中的 hub：
+//   - 显式覆盖层按新 Options 重建，可热生效的配置键经 onConfigChanged 推送；
+//   - 只重建 spec 变化的 listener，dispatcher、handler Set 与未变化 listener 上的连接保持不动；
+//   - 被禁用的 listener 会断开经它接入的连接。
+//
+// 配置校验与 listener 切换都成功后才提交显式层；新 listener 启动失败时会尝试恢复旧 listener，
+// 并返回错误，此时显式层与 Status.Addr 都仍反映旧配置。
+func (r *Runtime) Reconfigure(ctx context.Context, opts Options) (ReconfigureResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return ReconfigureResult{}, err
+	}
+	r.reconfMu.Lock()
+	defer r.reconfMu.Unlock()
+
+	opts.Normalize()
+	if !opts.TCPEnable && !opts.RFCOMMEnable && !opts.QUICEnable {
+		return ReconfigureResult{}, errors.New("no listener enabled")
+	}
+
+	r.mu.Lock()
+	cur := r.opts
+	cfg := r.cfg
+	running := r.srv != nil && r.listeners != nil
+	r.mu.Unlock()
+	if !running || cfg == nil {
+		return ReconfigureResult{}, errors.New("runtime not started")
+	}
+
+	var restart []string
+	if strings.TrimSpace(opts.WorkDir) != "" && strings.TrimSpace(opts.WorkDir) != strings.TrimSpace(cur.WorkDir) {
+		restart = append(restart, "workdir")
+	}
+	if opts.ConfigFile != "" && (opts.ConfigFile != cur.ConfigFile || opts.ConfigFileReadOnly != cur.ConfigFileReadOnly) {
+		restart = append(restart, "config_file")
+	}
+	if strings.TrimSpace(opts.SelfID) != strings.TrimSpace(cur.SelfID) {
+		restart = append(restart, "self_id")
+	}
+	if opts.NodeID != 0 && opts.NodeID != cur.NodeID && strings.TrimSpace(cur.SelfID) == "" {
+		restart = append(restart, "node_id")
+	}
+	if opts.AdminAddr != cur.AdminAddr {
+		restart = append(restart, "admin_addr")
+	}
+
+	// 与 Start 相同的口径：显式层叠加后把 effective 配置投影回 Options。
+	// 先在预览视图上校验并切换 listener，全部成功后才提交显式层，失败时配置与运行状态保持不变。
+	// WorkDir 不可热切换，Secret 引用的相对路径按当前 WorkDir 解析。
+	explicitOpts := opts
+	explicitOpts.WorkDir = cur.WorkDir
+	explicit, err := explicitConfigData(explicitOpts)
+	if err != nil {
+		return ReconfigureResult{}, err
+	}
+	if err := validateConfigMap(explicit); err != nil {
+		r.storeErr(err)
+		return ReconfigureResult{RestartRequired: restart}, err
+	}
+	next := applyConfigToOptions(opts, cfg.previewExplicit(explicit))
+	next.WorkDir = cur.WorkDir
+	next.ConfigFile = cur.ConfigFile
+	next.ConfigFileReadOnly = cur.ConfigFileReadOnly
+	next.SelfID = cur.SelfID
+	next.NodeID = cur.NodeID
+	next.AdminAddr = cur.AdminAddr
+	next.Logger = cur.Logger
+	next.Transport = cur.Transport
+	next = resolveOptionPaths(next, workDir(cur.WorkDir))
+	if err := validateListenerOptions(next); err != nil {
+		r.storeErr(err)
+		return ReconfigureResult{Listeners: nil, RestartRequired: restart}, err
+	}
+	if err := ensureQUICDevCertIfNeeded(&next, r.log); err != nil {
+		r.storeErr(err)
+		return ReconfigureResult{RestartRequired: restart}, err
+	}
+
+	changed, err := r.applyListeners(next)
+	if err != nil {
+		// 部分 listener 可能已切到新参数，按切换前的参数恢复，使运行状态仍与未变的显式层一致。
+		if _, rerr := r.applyListeners(cur); rerr != nil {
+			r.log.Error("restore listeners after failed reconfigure failed", "err", rerr)
+		}
+		restart = append(restart, r.pendingRestartKeys()...)
+		r.storeErr(err)
+		return ReconfigureResult{RestartRequired: restart}, err
+	}
+	cfg.SetExplicit(explicit)
+	restart = append(restart, r.pendingRestartKeys()...)
+
+	r.mu.Lock()
+	r.opts = mergeListenerOptions(r.opts, next)
+	r.mu.Unlock()
+	r.log.Info("hub runtime reconfigured", "listeners", changed, "restart_required", restart)
+	return ReconfigureResult{Listeners: changed, RestartRequired: restart}, nil
+}
+
+// applyListeners 把运行中的 listener 调整为 opts 描述的集合，并同步 runtime 记录的监听参数。
+func (r *Runtime) applyListeners(opts Options) ([]string, error) {
+	r.mu.Lock()
+	group := r.listeners
+	r.mu.Unlock()
+	if group == nil {
+		return nil, errors.New("runtime not started")
+	}
+	changed, err := group.Swap(listenerSpecsFromOptions(opts))
+	if len(changed) > 0 {
+		r.mu.Lock()
+		r.opts = mergeListenerOptions(r.opts, opts)
+		r.mu.Unlock()
+	}
+	return changed, err
+}
+
+// applyListenerAddr 在 `addr` 配置热更新时只重建 TCP listener。
+func (r *Runtime) applyListenerAddr(addr string) {
+	r.mu.Lock()
+	next := r.opts
+	r.mu.Unlock()
+	addr = strings.TrimSpace(addr)
+	if addr == "" || addr == next.Addr {
+		return
+	}
+	next.Addr = addr
+	if _, err := r.applyListeners(next); err != nil {
+		r.log.Warn("apply tcp listener addr failed", "addr", addr, "err", err)
+		r.storeErr(err)
+	}
+}
+
+// validateListenerOptions 校验启用的 listener 是否具备必需的地址。
+func validateListenerOptions(opts Options) error {
+	if opts.TCPEnable && strings.TrimSpace(opts.Addr) == "" {
+		return errors.New("tcp addr required")
+	}
+	if opts.QUICEnable && strings.TrimSpace(opts.QUICAddr) == "" {
+		return errors.New("quic addr required")
+	}
+	return nil
+}
+
+// mergeListenerOptions 只把 listener 相关字段从 src 复制到 dst。
+func mergeListenerOptions(dst, src Options) Options {
+	dst.TCPEnable = src.TCPEnable
+	dst.Addr = src.Addr
+
+	dst.QUICEnable = src.QUICEnable
+	dst.QUICAddr = src.QUICAddr
+	dst.QUICALPN = src.QUICALPN
+	dst.QUICCertFile = src.QUICCertFile
+	dst.QUICKeyFile = src.QUICKeyFile
+	dst.QUICDevCertAuto = src.QUICDevCertAuto
+	dst.QUICClientCAFile = src.QUICClientCAFile
+	dst.QUICRequireClientCert = src.QUICRequireClientCert
+
+	dst.RFCOMMEnable = src.RFCOMMEnable
+	dst.RFCOMMUUID = src.RFCOMMUUID
+	dst.RFCOMMChannel = src.RFCOMMChannel
+	dst.RFCOMMAdapter = src.RFCOMMAdapter
+	dst.RFCOMMInsecure = src.RFCOMMInsecure
+	return dst
+}
@@ -0,0 +1,53 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `reconfigure` 相关的行为。
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconfigureKeepsExplicitLayerOnListenerFailure(t *testing.T) {
+	opts := DefaultOptions()
+	opts.WorkDir = t.TempDir()
+	opts.Addr = "127.0.0.1:7001"
+	cfg, err := newLayeredConfig(filepath.Join(opts.WorkDir, runtimeConfigFile), configDataFromOptions(DefaultOptions()), explicitConfigDataFromOptions(opts))
+	if err != nil {
+		t.Fatalf("newLayeredConfig: %v", err)
+	}
+	factory := &fakeListenerFactory{failOn: "127.0.0.1:7999"}
+	group, cm := startTestGroup(t, opts, factory)
+	rt := &Runtime{
+		opts:        opts,
+		cfg:         cfg,
+		srv:         healthTestServer{cm: cm},
+		listeners:   group,
+		log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		parentPacer: newParentDialPacer(3 * time.Second),
+	}
+
+	next := opts
+	next.Addr = "127.0.0.1:7999"
+	if _, err := rt.Reconfigure(context.Background(), next); err == nil {
+		t.Fatalf("expected reconfigure error")
+	}
+	if got, _ := cfg.Get("addr"); got != "127.0.0.1:7001" {
+		t.Fatalf("effective addr after failed reconfigure=%q want unchanged", got)
+	}
+	if got := group.Specs()[listenerNameTCP].Addr; got != "127.0.0.1:7001" {
+		t.Fatalf("tcp listener after failed reconfigure=%q", got)
+	}
+
+	next.Addr = "127.0.0.1:7101"
+	res, err := rt.Reconfigure(context.Background(), next)
+	if err != nil || !equalStrings(res.Listeners, []string{listenerNameTCP}) {
+		t.Fatalf("Reconfigure res=%+v err=%v", res, err)
+	}
+	if got, _ := cfg.Get("addr"); got != "127.0.0.1:7101" {
+		t.Fatalf("effective addr=%q", got)
+	}
+}
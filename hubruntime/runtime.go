@@ -10,8 +10,6 @@ import (
 	"log/slog"
 	"net"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,15 +19,18 @@ import (
 	"github.com/yttydcs/myflowhub-core/bootstrap"
 	"github.com/yttydcs/myflowhub-core/connmgr"
 	"github.com/yttydcs/myflowhub-core/header"
-	"github.com/yttydcs/myflowhub-core/listener/multi_listener"
 	"github.com/yttydcs/myflowhub-core/listener/quic_listener"
 	"github.com/yttydcs/myflowhub-core/listener/rfcomm_listener"
 	"github.com/yttydcs/myflowhub-core/listener/tcp_listener"
 	"github.com/yttydcs/myflowhub-core/process"
 	"github.com/yttydcs/myflowhub-core/server"
 	"github.com/yttydcs/myflowhub-server/modules"
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
 )
 
+// runtimeFailStopTimeout 是 runtime 因故障自行停止时 drain 与关闭 server 的总时限。
+const runtimeFailStopTimeout = 10 * time.Second
+
 type Status struct {
 	Running bool
 
@@ -41,24 +42,79 @@ type Status struct {
 	ParentConnected bool
 	ParentConnID    string
 
+	// ParentEndpoint 是当前父连接所用的端点；ParentEndpoints 按拨号顺序列出全部候选端点及其最近错误。
+	ParentEndpoint  string
+	ParentEndpoints []ParentEndpointStatus
+
+	// ParentRegisterState 是当前父连接上 bootstrap register 的结果（ParentRegister* 常量）；
+	// 未配置 SelfID 或父链未连接时为空。Code / Message 取自最近一次 register_resp 或发送错误。
+	ParentRegisterState   string
+	ParentRegisterCode    int
+	ParentRegisterMessage string
+
+	// NodeIDSource 说明 NodeID 的来源（NodeIDSource* 常量）：配置、启动前向父节点注册，或 workdir 中的缓存。
+	// ParentAssignedNodeID 是父链 register_resp 最近一次给出的 nodeID；与 NodeID 不同说明父节点已改派，需要重启才能采用。
+	NodeIDSource         string
+	ParentAssignedNodeID uint32
+
 	WorkDir string
 
+	// AdminAddr 是 admin HTTP 监听实际绑定的地址；未启用时为空。
+	AdminAddr string
+
+	// Maintenance 为维护模式是否开启，MaintenanceReason 为开启时给出的原因（见 SetMaintenance）。
+	Maintenance       bool
+	MaintenanceReason string
+
+	// ConfigRestartRequired 列出运行期已修改、但需要重启 runtime 才会生效的配置键。
+	ConfigRestartRequired []string
+
 	LastError string
 }
 
 type Runtime struct {
 	mu sync.Mutex
+	// reconfMu 串行化 Reconfigure，避免两次 listener 替换交错。
+	reconfMu sync.Mutex
 
 	opts Options
-	log  *slog.Logger
+	// maintenance 是维护模式状态，独立于 Start / Stop。
+	maintenance maintenanceState
+	// nodeIDSource 是 opts.NodeID 的来源（NodeIDSource* 常量），Start 时确定。
+	nodeIDSource string
+	// log 是带 component=runtime 的 logger；baseLog 未带组件，供 Core 与模块派生各自的组件 logger。
+	// 二者共享 logLevels，级别由 log.level / log.level.<component> 配置决定。
+	log       *slog.Logger
+	baseLog   *slog.Logger
+	logLevels *logLevels
 
 	srv core.IServer
+	cfg *layeredConfig
+	set modules.Set
+
+	listeners *listenerGroup
+
+	// metrics 在每次 Start 时重建；Stop 后保留，供宿主读取最后一次运行的累计值。
+	metrics    *runtimeMetrics
+	tracer     *tracer
+	health     *healthState
+	drain      *drainState
+	parent     *parentBootstrap
+	endpoints  *parentEndpointSet
+	dispatcher *process.DispatcherProcess
+	admin      *adminServer
+
+	// events 跨越 Start / Stop 保留，订阅者不需要在重启后重新订阅。
+	events     *eventBus
+	eventsOnce sync.Once
+
+	// startConfig 是启动时的 effective 配置快照，用于计算待重启生效的键。
+	startConfig map[string]string
+	parentPacer *parentDialPacer
 
 	startCtx    context.Context
 	startCancel context.CancelFunc
 
-	workdirPrev string
-
 	parentWatchCancel context.CancelFunc
 
 	lastErr atomic.Value // string
@@ -75,10 +131,12 @@ func New(opts Options) (*Runtime, error) {
 	if opts.Logger == nil {
 		opts.Logger = slog.Default()
 	}
-	return &Runtime{opts: opts, log: opts.Logger}, nil
+	levels := newLogLevels()
+	base := slog.New(newLevelRouter(opts.Logger.Handler(), levels))
+	return &Runtime{opts: opts, log: defaultset.ComponentLogger(base, LogComponentRuntime), baseLog: base, logLevels: levels}, nil
 }
 
-// Start 负责工作目录切换、层叠配置构建、默认模块装配以及父链 bootstrap。
+// Start 负责工作目录准备、层叠配置构建、默认模块装配以及父链 bootstrap。
 func (r *Runtime) Start(ctx context.Context) error {
 	if ctx == nil {
 		ctx = context.Background()
@@ -91,38 +149,28 @@ func (r *Runtime) Start(ctx context.Context) error {
 	}
 	opts := r.opts
 	log := r.log
+	coreLog := defaultset.ComponentLogger(r.baseLog, LogComponentCore)
 	r.mu.Unlock()
 
-	normalizedWorkDir, err := r.applyWorkDir(opts.WorkDir)
+	wd, err := prepareWorkDir(opts.WorkDir)
 	if err != nil {
 		r.storeErr(err)
 		return err
 	}
-	if normalizedWorkDir != "" {
-		opts.WorkDir = normalizedWorkDir
-	}
+	opts.WorkDir = string(wd)
 
 	cfg, err := buildConfig(opts)
 	if err != nil {
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
-	opts = applyConfigToOptions(opts, cfg)
-	if opts.TCPEnable && strings.TrimSpace(opts.Addr) == "" {
-		err := errors.New("tcp addr required")
-		_ = r.restoreWorkDir()
-		r.storeErr(err)
-		return err
-	}
-	if opts.QUICEnable && strings.TrimSpace(opts.QUICAddr) == "" {
-		err := errors.New("quic addr required")
-		_ = r.restoreWorkDir()
+	r.applyLogLevels(cfg)
+	opts = resolveOptionPaths(applyConfigToOptions(opts, cfg), wd)
+	if err := validateListenerOptions(opts); err != nil {
 		r.storeErr(err)
 		return err
 	}
 	if err := ensureQUICDevCertIfNeeded(&opts, log); err != nil {
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
@@ -130,163 +178,228 @@ func (r *Runtime) Start(ctx context.Context) error {
 	parentTarget := effectiveParentTarget(opts)
 
 	// Pre-start: if parent enabled and self id provided, self-register to obtain/confirm node id.
+	var endpoints *parentEndpointSet
 	if opts.ParentEnable && parentTarget != "" {
-		_, _, err := parseParentEndpoint(parentTarget)
+		eps, err := parentEndpointsFromOptions(opts)
 		if err != nil {
-			_ = r.restoreWorkDir()
 			r.storeErr(err)
 			return err
 		}
+		endpoints = newParentEndpointSet(eps, parentDialerFor(opts.Transport), log)
 	}
-	if opts.ParentEnable && parentTarget != "" && opts.SelfID != "" {
-		nodeID, err := selfRegisterNodeID(ctx, parentTarget, opts.SelfID, opts.ParentJoinPermit, dialParentEndpoint, log)
+	nodeIDSource := NodeIDSourceConfig
+	cachePath := wd.Resolve(nodeIDCacheFile)
+	if endpoints != nil && opts.SelfID != "" {
+		nodeID, source, err := resolveSelfNodeID(ctx, opts, cfg, cachePath, parentTarget, endpoints.Dial, log)
 		if err != nil {
-			_ = r.restoreWorkDir()
 			r.storeErr(err)
 			return err
 		}
-		if opts.NodeID == 0 {
-			opts.NodeID = nodeID
-		} else if opts.NodeID != nodeID {
-			log.Warn("node-id mismatch, override by parent assignment", "configured", opts.NodeID, "assigned", nodeID, "self_id", opts.SelfID)
-			opts.NodeID = nodeID
+		if opts.NodeID != 0 && opts.NodeID != nodeID {
+			log.Warn("node-id mismatch, override by parent assignment", "configured", opts.NodeID, "assigned", nodeID, "source", source, "self_id", opts.SelfID)
 		}
+		opts.NodeID = nodeID
+		nodeIDSource = source
 	}
 
-	cm := connmgr.New()
-	base := process.NewPreRoutingProcess(log).WithConfig(cfg)
-	dispatcher, err := process.NewDispatcherFromConfig(cfg, base, log)
+	var boot *parentBootstrap
+	if endpoints != nil {
+		boot = newParentBootstrap(r, cfg, opts.SelfID, log)
+		if opts.SelfID != "" {
+			boot.trackNodeID(opts.NodeID, nodeIDSource, cachePath, parentTarget)
+		}
+	}
+	cm := &eventConnManager{IConnectionManager: connmgr.New(), emit: r.emit, parent: boot}
+	tracer := newTracer(wd, log)
+	r.applyTracing(tracer, cfg)
+	started := false
+	defer func() {
+		if !started {
+			tracer.close()
+		}
+	}()
+	base := process.NewPreRoutingProcess(coreLog).WithConfig(cfg)
+	dispatcher, err := process.NewDispatcherFromConfig(cfg, &tracedPreRoute{IProcess: base, tracer: tracer}, coreLog)
 	if err != nil {
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
-	set, err := modules.DefaultHub(cfg, log)
+	metrics := newRuntimeMetrics()
+	health := newHealthState()
+	drain := newDrainState()
+	health.stateProbe = defaultset.UsesPGStateBackend(cfg)
+	set, err := modules.DefaultHubWithOptions(defaultset.BuildOptions{Config: cfg, Logger: r.baseLog, StateObserver: metrics, ResolvePath: wd.resolver()})
 	if err != nil {
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
-	if err := modules.RegisterAll(dispatcher, set); err != nil {
-		_ = r.restoreWorkDir()
+	defer func() {
+		if !started {
+			modules.CloseStateStores(set)
+		}
+	}()
+	// 注册到 dispatcher 的是带观测的包装；BindServer / ReloadConfig 仍作用在 set 中的原始 handler 上。
+	// management 的 node_info 额外附带父端点状态，config_schema / config_explain 由 runtime 本地应答；
+	// 维护模式下拒绝新的登录与会话；最外层在 drain 期间拒绝新建会话，并接管父节点发来的 node_draining。
+	registrar := drainDispatcher{
+		inner: maintenanceDispatcher{
+			inner: configActionsDispatcher{inner: nodeInfoDispatcher{inner: observedDispatcher{inner: dispatcher, metrics: metrics, tracer: tracer}, items: r.nodeInfoItems}, cfg: cfg},
+			r:     r,
+		},
+		state:           drain,
+		onParentDrained: r.onParentDraining,
+	}
+	if err := modules.RegisterAll(registrar, set); err != nil {
 		r.storeErr(err)
 		return err
 	}
 
-	listeners := make([]core.IListener, 0, 3)
-	if opts.TCPEnable {
-		listeners = append(listeners, tcp_listener.New(opts.Addr, tcp_listener.Options{
-			KeepAlive:       true,
-			KeepAlivePeriod: 30 * time.Second,
-			Logger:          log,
-		}))
-	}
-	if opts.QUICEnable {
-		listeners = append(listeners, quic_listener.New(quic_listener.Options{
-			Addr:              opts.QUICAddr,
-			ALPN:              opts.QUICALPN,
-			CertFile:          opts.QUICCertFile,
-			KeyFile:           opts.QUICKeyFile,
-			ClientCAFile:      opts.QUICClientCAFile,
-			RequireClientCert: opts.QUICRequireClientCert,
-			Logger:            log,
-		}))
-	}
-	if opts.RFCOMMEnable {
-		listeners = append(listeners, rfcomm_listener.New(rfcomm_listener.Options{
-			UUID:     opts.RFCOMMUUID,
-			Channel:  opts.RFCOMMChannel,
-			Adapter:  opts.RFCOMMAdapter,
-			Insecure: opts.RFCOMMInsecure,
-			Logger:   log,
-		}))
-	}
-	if len(listeners) == 0 {
+	specs := listenerSpecsFromOptions(opts)
+	if len(specs) == 0 {
 		err := errors.New("no listener enabled")
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
-
-	var lst core.IListener
-	if len(listeners) == 1 {
-		lst = listeners[0]
-	} else {
-		ml, err := multi_listener.New(listeners...)
-		if err != nil {
-			_ = r.restoreWorkDir()
-			r.storeErr(err)
-			return err
-		}
-		lst = ml
+	group := newListenerGroup(specs, listenerFactoryFor(opts.Transport), coreLog)
+	group.onError = func(name string, err error) {
+		r.emit(Event{Type: EventListenerError, Listener: name, Message: err.Error()})
+	}
+	group.onFail = func(err error) {
+		r.fail(fmt.Errorf("no listener running: %w", err))
 	}
 	codec := header.HeaderTcpCodec{}
+	parentDial := parentDialerFor(opts.Transport)
+	if endpoints != nil {
+		parentDial = endpoints.Dial
+	}
+	pacer := newParentDialPacer(reconnectIntervalFromConfig(cfg))
 
 	srv, err := server.New(server.Options{
 		Name:         "HubServer",
-		Logger:       log,
-		Process:      dispatcher,
+		Logger:       coreLog,
+		Process:      newObservedProcess(dispatcher, metrics, tracer, frameObserversFor(health, drain, boot, r.emit)...),
 		Codec:        codec,
-		Listener:     lst,
+		Listener:     group,
 		Config:       cfg,
 		Manager:      cm,
-		ParentDialer: dialParentEndpoint,
+		ParentDialer: pacer.Wrap(parentDial),
 		NodeID:       opts.NodeID,
 	})
 	if err != nil {
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
+	tracer.nodeID = srv.NodeID
 
 	startCtx, startCancel := context.WithCancel(ctx)
 
 	if err := srv.Start(startCtx); err != nil {
 		startCancel()
-		_ = r.restoreWorkDir()
 		r.storeErr(err)
 		return err
 	}
 	modules.BindServerHooks(srv, set)
 
+	var admin *adminServer
+	if opts.AdminAddr != "" {
+		admin, err = startAdminServer(opts.AdminAddr, r.adminHandler(), log)
+		if err != nil {
+			startCancel()
+			_ = srv.Stop(context.Background())
+			r.storeErr(err)
+			return err
+		}
+	}
+
 	r.mu.Lock()
 	// Re-check to avoid race with concurrent Stop (defensive).
 	if r.srv != nil {
 		r.mu.Unlock()
 		startCancel()
+		_ = admin.Close(context.Background())
 		_ = srv.Stop(context.Background())
-		_ = r.restoreWorkDir()
 		return errors.New("runtime already started")
 	}
 	r.opts = opts // keep possibly overridden NodeID
+	r.nodeIDSource = nodeIDSource
 	r.srv = srv
+	r.cfg = cfg
+	r.set = set
+	r.listeners = group
+	r.metrics = metrics
+	r.tracer = tracer
+	r.health = health
+	r.drain = drain
+	r.parent = boot
+	r.endpoints = endpoints
+	r.dispatcher = dispatcher
+	r.admin = admin
+	r.startConfig = snapshotConfig(cfg)
+	r.parentPacer = pacer
 	r.startCtx = startCtx
 	r.startCancel = startCancel
 	r.mu.Unlock()
+	started = true
 
-	// Post-start: bind parent connection (root side) by sending an auth register on the persistent parent link.
-	if opts.ParentEnable && parentTarget != "" {
-		r.startParentBootstrapWatcher(cfg)
+	// Config hot reload: management config_set / file edits / SIGHUP all funnel into onConfigChanged.
+	cfg.SetChangeHook(r.onConfigChanged)
+	cfg.SetRejectHook(func(key string, err error) {
+		log.Warn("runtime config write rejected", "key", key, "err", err)
+	})
+	if keys := cfg.PlaintextSecretKeys(); len(keys) > 0 {
+		log.Warn("secret config keys stored in plain text; replace them with file:/env: references", "path", cfg.Path(), "keys", keys)
+	}
+	go r.watchConfigFile(startCtx, cfg.Path())
+	if path := cfg.FileLayerPath(); path != "" {
+		go r.watchConfigFile(startCtx, path)
 	}
+	if health.stateProbe {
+		go r.probeStateBackend(startCtx, set.PGPools, health)
+	}
+
+	// Post-start: bind parent connection (root side) by sending an auth register on the persistent parent link.
+	r.startParentBootstrapWatcher(boot, endpoints)
 	log.Info("hub runtime started", "addr", opts.Addr, "node_id", opts.NodeID, "parent", parentTarget)
 	return nil
 }
 
-// Stop 终止 watcher、停止 server，并恢复启动前的工作目录。
+// Stop 先执行 drain（见 runDrain，时长取自 ctx 的剩余时间），再终止 watcher、停止 server。
 func (r *Runtime) Stop(ctx context.Context) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	r.runDrain(ctx)
+
 	r.mu.Lock()
 	srv := r.srv
 	cancel := r.startCancel
+	cfg := r.cfg
+	admin := r.admin
+	tracer := r.tracer
+	set := r.set
 	r.srv = nil
+	r.admin = nil
+	r.tracer = nil
+	r.health = nil
+	r.drain = nil
+	r.parent = nil
+	r.endpoints = nil
+	r.dispatcher = nil
+	r.cfg = nil
+	r.set = modules.Set{}
+	r.listeners = nil
+	r.startConfig = nil
+	r.parentPacer = nil
 	r.startCtx = nil
 	r.startCancel = nil
 	parentCancel := r.parentWatchCancel
 	r.parentWatchCancel = nil
 	r.mu.Unlock()
 
+	if cfg != nil {
+		cfg.SetChangeHook(nil)
+		cfg.SetRejectHook(nil)
+	}
 	if parentCancel != nil {
 		parentCancel()
 	}
@@ -294,12 +407,18 @@ func (r *Runtime) Stop(ctx context.Context) error {
 		cancel()
 	}
 	var stopErr error
-	if srv != nil {
-		stopErr = srv.Stop(ctx)
-	}
-	if err := r.restoreWorkDir(); err != nil && stopErr == nil {
+	if err := admin.Close(ctx); err != nil {
 		stopErr = err
 	}
+	if srv != nil {
+		if err := srv.Stop(ctx); err != nil {
+			stopErr = err
+		}
+	}
+	// server 停止后不再有新的 span，写出剩余 span 并关闭导出文件。
+	tracer.close()
+	// handler 已随 server 停止，不会再访问状态后端。
+	modules.CloseStateStores(set)
 	return stopErr
 }
 
@@ -307,130 +426,70 @@ func (r *Runtime) Stop(ctx context.Context) error {
 func (r *Runtime) Status() Status {
 	r.mu.Lock()
 	opts := r.opts
+	nodeIDSource := r.nodeIDSource
 	srv := r.srv
+	admin := r.admin
+	boot := r.parent
+	endpoints := r.endpoints
 	r.mu.Unlock()
 
 	st := Status{
 		Running:       srv != nil,
 		Addr:          opts.Addr,
 		NodeID:        opts.NodeID,
+		NodeIDSource:  nodeIDSource,
 		ParentEnabled: opts.ParentEnable,
 		ParentAddr:    effectiveParentTarget(opts),
 		WorkDir:       opts.WorkDir,
+		AdminAddr:     admin.Addr(),
 		LastError:     r.loadErr(),
 	}
+	if m := r.maintenance.snapshot(); m.Enabled {
+		st.Maintenance = true
+		st.MaintenanceReason = m.Reason
+	}
 	if srv == nil {
 		return st
 	}
-	if conn, ok := findParentConn(srv.ConnManager()); ok {
+	st.ConfigRestartRequired = r.pendingRestartKeys()
+	if boot == nil {
+		return st
+	}
+	var liveConnID string
+	if conn, ok := boot.current(); ok {
 		st.ParentConnected = true
 		st.ParentConnID = conn.ID()
+		liveConnID = conn.ID()
 	}
-	return st
-}
-
-// applyWorkDir 在启动前切到应用私有目录，使相对路径配置能稳定落盘。
-func (r *Runtime) applyWorkDir(dir string) (string, error) {
-	if strings.TrimSpace(dir) == "" {
-		return "", nil
-	}
-	abs := dir
-	if !filepath.IsAbs(abs) {
-		if wd, err := os.Getwd(); err == nil && strings.TrimSpace(wd) != "" {
-			abs = filepath.Join(wd, dir)
+	st.ParentEndpoints = endpoints.Snapshot(liveConnID)
+	for _, ep := range st.ParentEndpoints {
+		if ep.Current {
+			st.ParentEndpoint = ep.Endpoint
 		}
 	}
-	if err := os.MkdirAll(abs, 0o755); err != nil {
-		return "", fmt.Errorf("mkdir workdir: %w", err)
-	}
-	prev, err := os.Getwd()
-	if err != nil {
-		return "", fmt.Errorf("getwd: %w", err)
-	}
-	if err := os.Chdir(abs); err != nil {
-		return "", fmt.Errorf("chdir: %w", err)
-	}
-	r.mu.Lock()
-	r.workdirPrev = prev
-	r.mu.Unlock()
-	return abs, nil
-}
-
-// restoreWorkDir 把进程 cwd 恢复到启动前的目录。
-func (r *Runtime) restoreWorkDir() error {
-	r.mu.Lock()
-	prev := r.workdirPrev
-	r.workdirPrev = ""
-	r.mu.Unlock()
-	if strings.TrimSpace(prev) == "" {
-		return nil
-	}
-	return os.Chdir(prev)
+	reg := boot.snapshot()
+	st.ParentRegisterState = reg.State
+	st.ParentRegisterCode = reg.Code
+	st.ParentRegisterMessage = reg.Message
+	st.ParentAssignedNodeID = boot.assignedNodeID()
+	return st
 }
 
-// startParentBootstrapWatcher 监听持久父连接建立，并在其上补发 auth register。
-func (r *Runtime) startParentBootstrapWatcher(cfg core.IConfig) {
+// startParentBootstrapWatcher 启动父链 bootstrap 循环与多端点回切探测；父连接变化由连接管理器钩子推送。
+func (r *Runtime) startParentBootstrapWatcher(boot *parentBootstrap, endpoints *parentEndpointSet) {
 	r.mu.Lock()
-	if r.srv == nil || r.parentWatchCancel != nil {
+	if r.srv == nil || r.parentWatchCancel != nil || boot == nil {
 		r.mu.Unlock()
 		return
 	}
-	srv := r.srv
-	opts := r.opts
-	log := r.log
-	ctx := r.startCtx
-	watchCtx, cancel := context.WithCancel(ctx)
+	watchCtx, cancel := context.WithCancel(r.startCtx)
 	r.parentWatchCancel = cancel
 	r.mu.Unlock()
 
-	go func() {
-		ticker := time.NewTicker(300 * time.Millisecond)
-		defer ticker.Stop()
-		var lastConnID string
-		var lastMetaInitConnID string
-		var lastRegisterConnID string
-		for {
-			select {
-			case <-watchCtx.Done():
-				return
-			case <-ticker.C:
-			}
-			conn, ok := findParentConn(srv.ConnManager())
-			if !ok || conn == nil {
-				lastConnID = ""
-				lastMetaInitConnID = ""
-				lastRegisterConnID = ""
-				continue
-			}
-			lastConnID = conn.ID()
-
-			// Local side: mark parent conn as "logged-in" to pass sourceMismatch gating.
-			// We don't need exact parent node id here; any non-zero value is sufficient for the
-			// later "parent conn exemption" branch. Use 1 as a stable default.
-			if lastMetaInitConnID != lastConnID {
-				if ensured := ensureConnNodeIDNonZero(conn, 1); ensured {
-					log.Info("parent conn meta node_id initialized", "conn", conn.ID(), "node_id", uint32(1))
-				}
-				lastMetaInitConnID = lastConnID
-			}
-
-			// Root side: bind meta(nodeID) on the persistent parent link via auth register.
-			if strings.TrimSpace(opts.SelfID) == "" {
-				continue
-			}
-			if lastRegisterConnID == lastConnID {
-				continue
-			}
-			displayName := trimmedConfigValue(cfg, "node.display_name")
-			if err := sendRegisterOnConn(watchCtx, conn, opts.SelfID, displayName, opts.ParentJoinPermit, &r.msgSeq); err != nil {
-				log.Warn("parent bootstrap register failed", "err", err, "conn", conn.ID())
-				r.storeErr(err)
-				continue
-			}
-			lastRegisterConnID = lastConnID
-			log.Info("parent bootstrap register sent", "conn", conn.ID(), "self_id", opts.SelfID)
-		}
-	}()
+	go boot.run(watchCtx)
+	if endpoints != nil {
+		go endpoints.runFailback(watchCtx, boot.current)
+	}
 }
 
 // ensureConnNodeIDNonZero 给 parent 连接补一个非零 nodeID，以通过早期 source 校验。
@@ -462,15 +521,18 @@ func ensureConnNodeIDNonZero(conn core.IConnection, fallback uint32) (ensured bo
 	return true
 }
 
-// selfRegisterNodeID 在 runtime 启动前短路完成一次自注册，拿到父节点分配的 nodeID。
+// selfRegisterNodeID 在 runtime 启动前短路完成一次自注册，拿到父节点分配的 nodeID；timeout 为单次尝试的上限。
 func selfRegisterNodeID(
 	ctx context.Context,
 	parentTarget, selfID, joinPermit string,
+	timeout time.Duration,
 	dialer func(context.Context, string) (core.IConnection, error),
 	log *slog.Logger,
 ) (uint32, error) {
-	// Use a short timeout to avoid blocking embedded runtimes on flaky networks.
-	cctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	if timeout <= 0 {
+		timeout = defaultParentRegisterTimeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	nodeID, _, err := bootstrap.SelfRegister(cctx, bootstrap.SelfRegisterOptions{
 		ParentAddr: parentTarget,
@@ -482,7 +544,7 @@ func selfRegisterNodeID(
 		},
 		SelfID:     selfID,
 		JoinPermit: joinPermit,
-		Timeout:    8 * time.Second,
+		Timeout:    timeout,
 		DoLogin:    false,
 		Logger:     log,
 	})
@@ -543,27 +605,6 @@ func sendRegisterOnConn(ctx context.Context, conn core.IConnection, selfID, disp
 	return conn.SendWithHeader(hdr, payload, header.HeaderTcpCodec{})
 }
 
-// findParentConn 在连接管理器里查找当前父链连接。
-func findParentConn(cm core.IConnectionManager) (core.IConnection, bool) {
-	if cm == nil {
-		return nil, false
-	}
-	var parent core.IConnection
-	cm.Range(func(c core.IConnection) bool {
-		if c == nil {
-			return true
-		}
-		if role, ok := c.GetMeta(core.MetaRoleKey); ok {
-			if s, ok2 := role.(string); ok2 && s == core.RoleParent {
-				parent = c
-				return false
-			}
-		}
-		return true
-	})
-	return parent, parent != nil
-}
-
 // boolString 把布尔值映射成配置层使用的字符串字面量。
 func boolString(v bool) string {
 	if v {
@@ -572,8 +613,14 @@ func boolString(v bool) string {
 	return "false"
 }
 
-// effectiveParentTarget 优先使用带 scheme 的 ParentEndpoint，再退化到旧的 ParentAddr。
+// effectiveParentTarget 返回首选父端点：配置了 ParentEndpoints 时取排序后的第一项，
+// 否则优先使用带 scheme 的 ParentEndpoint，再退化到旧的 ParentAddr。
 func effectiveParentTarget(opts Options) string {
+	if strings.TrimSpace(opts.ParentEndpoints) != "" {
+		if eps, err := parseParentEndpointList(opts.ParentEndpoints, opts.ParentTransportPrefer); err == nil && len(eps) > 0 {
+			return eps[0].target
+		}
+	}
 	if strings.TrimSpace(opts.ParentEndpoint) != "" {
 		return strings.TrimSpace(opts.ParentEndpoint)
 	}
@@ -640,12 +687,28 @@ func dialParentEndpoint(ctx context.Context, target string) (core.IConnection, e
 	}
 }
 
-// storeErr 保存最近一次可见错误，供宿主通过 Status 读取。
+// storeErr 保存最近一次可见错误，供宿主通过 Status 读取，并以 runtime.error 事件推送。
 func (r *Runtime) storeErr(err error) {
 	if err == nil {
 		return
 	}
 	r.lastErr.Store(err.Error())
+	r.emit(Event{Type: EventError, Message: err.Error()})
+}
+
+// fail 在 runtime 无法继续提供服务时（如 listener 全部退出）记录错误并停止 runtime，
+// 停止完成后推送 runtime.failed，使 Status / 健康检查如实反映，宿主可据此退出或重启。
+func (r *Runtime) fail(err error) {
+	r.log.Error("hub runtime failed", "err", err)
+	r.storeErr(err)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), runtimeFailStopTimeout)
+		defer cancel()
+		if serr := r.Stop(ctx); serr != nil {
+			r.log.Warn("stop failed runtime", "err", serr)
+		}
+		r.emit(Event{Type: EventFailed, Message: err.Error()})
+	}()
 }
 
 // loadErr 读取最近一次记录的错误文本。
@@ -150,6 +150,7 @@ func TestSelfRegisterNodeIDUsesInjectedDialerForGenericTarget(t *testing.T) {
 		target,
 		"hub-quic",
 		"",
+		0,
 		func(_ context.Context, actualTarget string) (core.IConnection, error) {
 			gotTarget = actualTarget
 			return tcp_listener.NewTCPConnection(client), nil
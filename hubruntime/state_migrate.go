@@ -0,0 +1,38 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `state_migrate` 相关的逻辑。
+
+import (
+	"context"
+
+	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+)
+
+// MigrateState 按 Start 的口径离线构造配置，把 pg 状态后端的 schema 迁移到最新版本并返回每条迁移的状态；
+// dryRun 时只报告待执行的迁移与语句，不做改动。hub 启动后首次访问 pg 后端时会自动执行同样的迁移。
+func MigrateState(ctx context.Context, opts Options, dryRun bool) ([]defaultset.StateMigration, error) {
+	opts.Normalize()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return defaultset.MigratePGState(ctx, cfg, dryRun)
+}
+
+// MigrateStateData 按 Start 的口径离线构造配置与 workdir 路径解析，把 kind 类状态数据从 from 后端搬迁到 to 后端，
+// 并核对数量与校验和；中断后以相同参数重跑即可续传。见 defaultset.MigrateStateData。
+func MigrateStateData(ctx context.Context, opts Options, kind, from, to string, dryRun bool) (defaultset.StateDataMigration, error) {
+	opts.Normalize()
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		return defaultset.StateDataMigration{}, err
+	}
+	return defaultset.MigrateStateData(ctx, defaultset.StateDataMigrateOptions{
+		Config:      cfg,
+		ResolvePath: workDir(opts.WorkDir).resolver(),
+		Kind:        kind,
+		From:        from,
+		To:          to,
+		DryRun:      dryRun,
+	})
+}
@@ -0,0 +1,633 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `tracing` 相关的逻辑。
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+// 链路追踪配置键，均可运行期修改。
+const (
+	configKeyTraceExporter       = "trace.exporter"
+	configKeyTraceFile           = "trace.file"
+	configKeyTraceSamplePermille = "trace.sample_permille"
+	configKeyTracePrefix         = "trace."
+
+	defaultTraceFile           = "logs/trace.jsonl"
+	defaultTraceSamplePermille = 1000
+
+	// traceQueueSize 是待导出 span 的缓冲；写出跟不上时丢弃新 span 并计数，不阻塞帧处理。
+	traceQueueSize = 4096
+	// traceBatchMax 是单行 OTLP/JSON 导出请求中的最多 span 数。
+	traceBatchMax = 128
+	// traceFileMaxBytes / traceFileBackups 是文件导出的轮转参数。
+	traceFileMaxBytes = 64 << 20
+	traceFileBackups  = 3
+
+	traceServiceName = "myflowhub-hub"
+	traceScopeName   = "github.com/yttydcs/myflowhub-server/hubruntime"
+)
+
+// trace.exporter 的取值。
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+)
+
+var traceExporterNames = []string{TraceExporterNone, TraceExporterStdout, TraceExporterFile}
+
+// OTLP SpanKind / StatusCode。
+const (
+	traceSpanKindServer   = 2
+	traceSpanKindProducer = 4
+
+	traceStatusOK    = 1
+	traceStatusError = 2
+)
+
+// traceSampled 按帧 TraceID 决定是否采样。判定只依赖 TraceID 与千分比，
+// 同一条链路上的每个 hub 只要配置相同就做出相同的决定，不需要在帧里携带采样标记。
+func traceSampled(traceID uint32, permille int64) bool {
+	if traceID == 0 || permille <= 0 {
+		return false
+	}
+	if permille >= 1000 {
+		return true
+	}
+	// TraceID 多为顺序分配，先乘以奇数常量打散，再映射到 [0, 1000)。
+	h := traceID * 2654435761
+	return int64(uint64(h)*1000>>32) < permille
+}
+
+// otlpTraceID 把 32 位帧 TraceID 扩展为 OTLP 的 16 字节 trace id（高位补零），各 hub 得到相同的值。
+func otlpTraceID(traceID uint32) string {
+	var b [16]byte
+	binary.BigEndian.PutUint32(b[12:], traceID)
+	return hex.EncodeToString(b[:])
+}
+
+func newSpanID() uint64 {
+	for {
+		if id := rand.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
+
+func spanIDHex(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return hex.EncodeToString(b[:])
+}
+
+// traceScope 是一次转发判定或 handler 调用的 span 上下文，经 ctx 传给其中发生的 IServer.Send。
+//   - forward 为 true 时是 pre-route 阶段：其中的发送是转发本身，只记录到 scope，不另起 span；
+//   - 否则是 handler 调用：其中的每次发送各记一个子 span。
+//
+// traceID 取自入站帧；入站帧未带 TraceID（为 0）时采用第一次发送时 Core 补齐的值。
+type traceScope struct {
+	spanID  uint64
+	forward bool
+
+	mu      sync.Mutex
+	traceID uint32
+	sends   int
+	failed  int
+	conns   []string
+}
+
+func (s *traceScope) recordSend(conn core.IConnection, hdr core.IHeader, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.traceID == 0 && hdr != nil {
+		s.traceID = hdr.GetTraceID()
+	}
+	s.sends++
+	if failed {
+		s.failed++
+	}
+	if conn != nil && len(s.conns) < 8 {
+		s.conns = append(s.conns, conn.ID())
+	}
+}
+
+type traceScopeKey struct{}
+type traceReceivedKey struct{}
+
+func traceScopeFrom(ctx context.Context) *traceScope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(traceScopeKey{}).(*traceScope)
+	return s
+}
+
+// traceReceivedAt 返回帧在进程边界被接收的时刻；span 从这里开始，包含 dispatcher 排队时间。
+func traceReceivedAt(ctx context.Context) time.Time {
+	if ctx != nil {
+		if t, ok := ctx.Value(traceReceivedKey{}).(time.Time); ok {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// traceAttr 是 span 属性；num 有效时导出为 intValue。
+type traceAttr struct {
+	key   string
+	str   string
+	num   int64
+	isNum bool
+}
+
+func traceStr(key, v string) traceAttr { return traceAttr{key: key, str: v} }
+func traceInt(key string, v int64) traceAttr {
+	return traceAttr{key: key, num: v, isNum: true}
+}
+
+type traceSpan struct {
+	traceID  uint32
+	spanID   uint64
+	parentID uint64
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []traceAttr
+	errMsg   string
+}
+
+// frameTraceAttrs 是每个 span 共有的帧属性。
+func frameTraceAttrs(conn core.IConnection, hdr core.IHeader, action string) []traceAttr {
+	attrs := []traceAttr{
+		traceStr("hub.subproto", SubProtoName(hdr.SubProto())),
+		traceInt("hub.major", int64(hdr.Major())),
+		traceInt("hub.source", int64(hdr.SourceID())),
+		traceInt("hub.target", int64(hdr.TargetID())),
+		traceInt("hub.msg_id", int64(hdr.GetMsgID())),
+		traceInt("hub.hop_limit", int64(hdr.GetHopLimit())),
+	}
+	if action != "" {
+		attrs = append(attrs, traceStr("hub.action", action))
+	}
+	if conn != nil {
+		attrs = append(attrs, traceStr("hub.conn", conn.ID()))
+	}
+	return attrs
+}
+
+func traceSpanName(sub uint8, action string) string {
+	if action == "" {
+		return SubProtoName(sub)
+	}
+	return SubProtoName(sub) + "." + action
+}
+
+// tracer 为帧的每一跳生成 span 并异步导出为 OTLP/JSON。
+//   - pre-route 阶段被转发（或丢弃）的帧记一个 forward span；
+//   - 每次 handler 调用记一个 server span；
+//   - handler 中发出的帧（响应、向其他节点发起的请求）各记一个 producer span，父 span 为该 handler span。
+//
+// 跨 hub 的 span 通过相同的 trace id 关联；帧头没有 span id，不同 hub 的 span 之间没有父子关系。
+type tracer struct {
+	// nodeID 返回本节点当前的 nodeID，作为 resource 属性；Start 在 server 创建后设置。
+	nodeID func() uint32
+	wd     workDir
+	log    *slog.Logger
+
+	permille atomic.Int64 // exporter 为 none 时为 0，热路径只读这一个值
+	queue    chan traceSpan
+	dropped  atomic.Uint64
+
+	mu       sync.Mutex
+	exporter string
+	path     string
+	out      io.Writer
+	closer   io.Closer
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newTracer(wd workDir, log *slog.Logger) *tracer {
+	t := &tracer{
+		wd:    wd,
+		log:   log,
+		queue: make(chan traceSpan, traceQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// enabled 报告是否可能采样；nil tracer 总是返回 false。
+func (t *tracer) enabled() bool {
+	return t != nil && t.permille.Load() > 0
+}
+
+func (t *tracer) sampled(traceID uint32) bool {
+	return t != nil && traceSampled(traceID, t.permille.Load())
+}
+
+// apply 按配置切换导出目标与采样率；导出目标不可用时保持关闭并返回错误。
+func (t *tracer) apply(cfg core.IConfig) error {
+	exporter := strings.ToLower(trimmedConfigValue(cfg, configKeyTraceExporter))
+	if exporter == "" {
+		exporter = TraceExporterNone
+	}
+	path := trimmedConfigValue(cfg, configKeyTraceFile)
+	if path == "" {
+		path = defaultTraceFile
+	}
+	path = t.wd.Resolve(path)
+	permille := int64(parseIntValue(trimmedConfigValue(cfg, configKeyTraceSamplePermille), defaultTraceSamplePermille))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if exporter != t.exporter || (exporter == TraceExporterFile && path != t.path) {
+		t.permille.Store(0)
+		if t.closer != nil {
+			_ = t.closer.Close()
+		}
+		t.out, t.closer, t.exporter, t.path = nil, nil, TraceExporterNone, ""
+		switch exporter {
+		case TraceExporterNone:
+		case TraceExporterStdout:
+			t.out = os.Stdout
+		case TraceExporterFile:
+			f, err := OpenRotatingFile(path, traceFileMaxBytes, traceFileBackups)
+			if err != nil {
+				return fmt.Errorf("%s: %w", configKeyTraceFile, err)
+			}
+			t.out, t.closer, t.path = f, f, path
+		default:
+			return fmt.Errorf("%s: unknown exporter %q", configKeyTraceExporter, exporter)
+		}
+		t.exporter = exporter
+	}
+	if t.out == nil {
+		permille = 0
+	}
+	t.permille.Store(permille)
+	return nil
+}
+
+// record 把一个已结束的 span 放入导出队列；未采样或队列已满时直接返回。
+func (t *tracer) record(s traceSpan) {
+	if !t.sampled(s.traceID) {
+		return
+	}
+	select {
+	case t.queue <- s:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// stampReceive 在 ctx 中记下帧到达进程边界的时刻。
+func (t *tracer) stampReceive(ctx context.Context) context.Context {
+	if !t.enabled() || ctx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceReceivedKey{}, time.Now())
+}
+
+// observeSend 记录一次 IServer.Send：pre-route 中的转发并入 forward span，其余发送各记一个 producer span。
+func (t *tracer) observeSend(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte, start time.Time, failed bool) {
+	if !t.enabled() || hdr == nil {
+		return
+	}
+	scope := traceScopeFrom(ctx)
+	if scope != nil && scope.forward {
+		scope.recordSend(conn, hdr, failed)
+		return
+	}
+	var parent uint64
+	if scope != nil {
+		scope.recordSend(conn, hdr, failed)
+		parent = scope.spanID
+	}
+	traceID := hdr.GetTraceID()
+	if !t.sampled(traceID) {
+		return
+	}
+	sub := hdr.SubProto()
+	action := frameAction(sub, payload)
+	s := traceSpan{
+		traceID:  traceID,
+		spanID:   newSpanID(),
+		parentID: parent,
+		name:     "send " + traceSpanName(sub, action),
+		kind:     traceSpanKindProducer,
+		start:    start,
+		end:      time.Now(),
+		attrs:    frameTraceAttrs(conn, hdr, action),
+	}
+	if failed {
+		s.errMsg = "send failed or error response"
+	}
+	t.record(s)
+}
+
+// preRoute 包装一次转发判定；返回 false（帧已转发或被丢弃）时记一个 forward span。
+func (t *tracer) preRoute(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte, next func(context.Context) bool) bool {
+	if !t.enabled() || hdr == nil {
+		return next(ctx)
+	}
+	start := traceReceivedAt(ctx)
+	scope := &traceScope{spanID: newSpanID(), forward: true, traceID: hdr.GetTraceID()}
+	if next(context.WithValue(ctx, traceScopeKey{}, scope)) {
+		return true
+	}
+	scope.mu.Lock()
+	traceID, sends, failed, conns := scope.traceID, scope.sends, scope.failed, scope.conns
+	scope.mu.Unlock()
+
+	sub := hdr.SubProto()
+	action := frameAction(sub, payload)
+	s := traceSpan{
+		traceID: traceID,
+		spanID:  scope.spanID,
+		name:    "forward " + traceSpanName(sub, action),
+		kind:    traceSpanKindServer,
+		start:   start,
+		end:     time.Now(),
+		attrs:   frameTraceAttrs(conn, hdr, action),
+	}
+	s.attrs = append(s.attrs, traceInt("hub.forward.sends", int64(sends)))
+	if len(conns) > 0 {
+		s.attrs = append(s.attrs, traceStr("hub.forward.conns", strings.Join(conns, ",")))
+	}
+	switch {
+	case sends == 0:
+		s.errMsg = "not forwarded"
+	case failed > 0:
+		s.errMsg = "forward failed"
+	}
+	t.record(s)
+	return false
+}
+
+// handle 包装一次 handler 调用；panic 时 span 标记为错误后继续向上抛出。
+func (t *tracer) handle(ctx context.Context, conn core.IConnection, hdr core.IHeader, sub uint8, action string, next func(context.Context)) {
+	if !t.enabled() || hdr == nil {
+		next(ctx)
+		return
+	}
+	start := traceReceivedAt(ctx)
+	scope := &traceScope{spanID: newSpanID(), traceID: hdr.GetTraceID()}
+	panicked := true
+	defer func() {
+		scope.mu.Lock()
+		traceID := scope.traceID
+		scope.mu.Unlock()
+		s := traceSpan{
+			traceID: traceID,
+			spanID:  scope.spanID,
+			name:    traceSpanName(sub, action),
+			kind:    traceSpanKindServer,
+			start:   start,
+			end:     time.Now(),
+			attrs:   frameTraceAttrs(conn, hdr, action),
+		}
+		if panicked {
+			s.errMsg = "handler panic"
+		}
+		t.record(s)
+	}()
+	next(context.WithValue(ctx, traceScopeKey{}, scope))
+	panicked = false
+}
+
+// run 是导出 goroutine：按批写出 span，每批一行 OTLP/JSON ExportTraceServiceRequest。
+// stop 关闭后写出队列中剩余的 span 再退出；队列本身不关闭，close 之后的 record 不会 panic。
+func (t *tracer) run() {
+	defer close(t.done)
+	batch := make([]traceSpan, 0, traceBatchMax)
+	var reported uint64
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch[:0], s)
+		case <-t.stop:
+			for {
+				batch = t.collect(batch[:0])
+				if len(batch) == 0 {
+					return
+				}
+				t.export(batch)
+			}
+		}
+		batch = t.collect(batch)
+		t.export(batch)
+		if dropped := t.dropped.Load(); dropped != reported {
+			reported = dropped
+			t.log.Warn("trace spans dropped, exporter cannot keep up", "dropped_total", dropped)
+		}
+	}
+}
+
+// collect 不阻塞地从队列补齐一批。
+func (t *tracer) collect(batch []traceSpan) []traceSpan {
+	for len(batch) < traceBatchMax {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (t *tracer) export(batch []traceSpan) {
+	var nodeID uint32
+	if t.nodeID != nil {
+		nodeID = t.nodeID()
+	}
+	line, err := json.Marshal(otlpRequest(nodeID, batch))
+	if err != nil {
+		t.log.Warn("encode trace spans failed", "err", err)
+		return
+	}
+	line = append(line, '\n')
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out == nil {
+		return
+	}
+	if _, err := t.out.Write(line); err != nil {
+		t.log.Warn("write trace spans failed", "exporter", t.exporter, "err", err)
+	}
+}
+
+// close 停止接收 span，写出队列中剩余的 span 后关闭导出文件。
+func (t *tracer) close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() {
+		t.permille.Store(0)
+		close(t.stop)
+		<-t.done
+		t.mu.Lock()
+		if t.closer != nil {
+			_ = t.closer.Close()
+		}
+		t.out, t.closer = nil, nil
+		t.mu.Unlock()
+	})
+}
+
+// 以下为 OTLP/JSON（opentelemetry-proto 的 JSON 映射）中用到的部分。
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue 中 intValue 按 proto3 JSON 映射编码为字符串。
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func otlpAttr(a traceAttr) otlpKeyValue {
+	if a.isNum {
+		v := strconv.FormatInt(a.num, 10)
+		return otlpKeyValue{Key: a.key, Value: otlpAnyValue{IntValue: &v}}
+	}
+	v := a.str
+	return otlpKeyValue{Key: a.key, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpRequest(nodeID uint32, batch []traceSpan) otlpExportRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		out := otlpSpan{
+			TraceID:           otlpTraceID(s.traceID),
+			SpanID:            spanIDHex(s.spanID),
+			ParentSpanID:      spanIDHex(s.parentID),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: traceStatusOK},
+		}
+		for _, a := range s.attrs {
+			out.Attributes = append(out.Attributes, otlpAttr(a))
+		}
+		if s.errMsg != "" {
+			out.Status = otlpStatus{Code: traceStatusError, Message: s.errMsg}
+		}
+		spans = append(spans, out)
+	}
+	return otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			otlpAttr(traceStr("service.name", traceServiceName)),
+			otlpAttr(traceInt("hub.node_id", int64(nodeID))),
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: traceScopeName}, Spans: spans}},
+	}}}
+}
+
+// tracedPreRoute 包装 Core 的 PreRoutingProcess，使转发判定经过 tracer。
+type tracedPreRoute struct {
+	core.IProcess
+	tracer *tracer
+}
+
+type preRouteDecider interface {
+	PreRoute(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) bool
+}
+
+func (p *tracedPreRoute) PreRoute(ctx context.Context, conn core.IConnection, hdr core.IHeader, payload []byte) bool {
+	inner, ok := p.IProcess.(preRouteDecider)
+	if !ok {
+		p.IProcess.OnReceive(ctx, conn, hdr, payload)
+		return true
+	}
+	return p.tracer.preRoute(ctx, conn, hdr, payload, func(ctx context.Context) bool {
+		return inner.PreRoute(ctx, conn, hdr, payload)
+	})
+}
+
+// applyTracing 把当前配置中的 trace.* 应用到 tracer。
+func (r *Runtime) applyTracing(t *tracer, cfg core.IConfig) {
+	if t == nil {
+		return
+	}
+	if err := t.apply(cfg); err != nil {
+		r.log.Warn("trace exporter unavailable, tracing disabled", "err", err)
+	}
+}
+
+// hasTraceKey 判断变化的键中是否包含链路追踪键。
+func hasTraceKey(keys []string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, configKeyTracePrefix) {
+			return true
+		}
+	}
+	return false
+}
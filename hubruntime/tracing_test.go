@@ -0,0 +1,121 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `tracing` 相关的行为。
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	"github.com/yttydcs/myflowhub-core/header"
+	mgmtproto "github.com/yttydcs/myflowhub-server/protocol/management"
+)
+
+func TestTraceSampled(t *testing.T) {
+	if traceSampled(0, 1000) || traceSampled(42, 0) || !traceSampled(42, 1000) {
+		t.Fatalf("bounds: trace id 0 never sampled, 0 permille never, 1000 always")
+	}
+	sampled := 0
+	for id := uint32(1); id <= 20000; id++ {
+		if traceSampled(id, 100) {
+			sampled++
+		}
+		if traceSampled(id, 100) != traceSampled(id, 100) {
+			t.Fatalf("sampling must be deterministic for trace %d", id)
+		}
+		if traceSampled(id, 100) && !traceSampled(id, 500) {
+			t.Fatalf("trace %d sampled at 100 but not at 500", id)
+		}
+	}
+	// 顺序分配的 TraceID 也应接近配置的比例。
+	if sampled < 1700 || sampled > 2300 {
+		t.Fatalf("sampled %d of 20000 at 100 permille", sampled)
+	}
+	if got := otlpTraceID(0x01020304); got != "00000000000000000000000001020304" {
+		t.Fatalf("otlp trace id=%s", got)
+	}
+}
+
+func TestTracerExportsOTLPJSON(t *testing.T) {
+	dir := t.TempDir()
+	tr := newTracer(workDir(dir), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	tr.nodeID = func() uint32 { return 7 }
+	if tr.enabled() {
+		t.Fatalf("tracer should start disabled")
+	}
+	if err := tr.apply(coreconfig.NewMap(map[string]string{configKeyTraceExporter: TraceExporterFile, configKeyTraceFile: "out/spans.jsonl"})); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !tr.enabled() {
+		t.Fatalf("file exporter with default sampling should be enabled")
+	}
+
+	// 入站帧未带 TraceID：handler span 采用响应发送时补齐的 TraceID，响应 span 挂在 handler span 下。
+	req := (&header.HeaderTcp{}).WithMajor(header.MajorCmd).WithSubProto(mgmtproto.SubProtoManagement).WithSourceID(9).WithTargetID(7).WithMsgID(3)
+	payload := drainTestPayload(t, mgmtproto.ActionNodeEcho, mgmtproto.NodeEchoReq{Message: "hi"})
+	conn := &registerTestConn{id: "c1"}
+	tr.handle(tr.stampReceive(context.Background()), conn, req, mgmtproto.SubProtoManagement, mgmtproto.ActionNodeEcho, func(ctx context.Context) {
+		resp := (&header.HeaderTcp{}).WithMajor(header.MajorOKResp).WithSubProto(mgmtproto.SubProtoManagement).WithSourceID(7).WithTargetID(9).WithTraceID(0xabc)
+		tr.observeSend(ctx, conn, resp, drainTestPayload(t, mgmtproto.ActionNodeEchoResp, mgmtproto.NodeEchoResp{Code: 1}), time.Now(), false)
+	})
+	// 转发判定中的发送并入 forward span；未转发的帧记为错误。
+	fwd := (&header.HeaderTcp{}).WithMajor(header.MajorCmd).WithSubProto(mgmtproto.SubProtoManagement).WithSourceID(9).WithTargetID(11).WithTraceID(0xdef)
+	tr.preRoute(context.Background(), conn, fwd, payload, func(ctx context.Context) bool {
+		tr.observeSend(ctx, &registerTestConn{id: "up"}, fwd.Clone(), payload, time.Now(), false)
+		return false
+	})
+	tr.preRoute(context.Background(), conn, fwd, payload, func(context.Context) bool { return false })
+	tr.close()
+
+	f, err := os.Open(filepath.Join(dir, "out", "spans.jsonl"))
+	if err != nil {
+		t.Fatalf("open spans: %v", err)
+	}
+	defer f.Close()
+	spans := map[string]otlpSpan{}
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var req otlpExportRequest
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			t.Fatalf("decode line %q: %v", sc.Text(), err)
+		}
+		rs := req.ResourceSpans[0]
+		if v := rs.Resource.Attributes[1].Value.IntValue; rs.Resource.Attributes[1].Key != "hub.node_id" || v == nil || *v != "7" {
+			t.Fatalf("resource=%+v", rs.Resource)
+		}
+		for _, s := range rs.ScopeSpans[0].Spans {
+			spans[s.Name+"/"+s.Status.Message] = s
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) != 4 {
+		t.Fatalf("spans=%v, want handler, send, forward, dropped forward", names)
+	}
+	handler, send := spans["management.node_echo/"], spans["send management.node_echo_resp/"]
+	if handler.Kind != traceSpanKindServer || handler.TraceID != otlpTraceID(0xabc) || handler.ParentSpanID != "" {
+		t.Fatalf("handler span=%+v", handler)
+	}
+	if send.Kind != traceSpanKindProducer || send.TraceID != handler.TraceID || send.ParentSpanID != handler.SpanID {
+		t.Fatalf("send span=%+v, want child of handler %s", send, handler.SpanID)
+	}
+	forward := spans["forward management.node_echo/"]
+	if forward.TraceID != otlpTraceID(0xdef) || forward.Status.Code != traceStatusOK {
+		t.Fatalf("forward span=%+v", forward)
+	}
+	if dropped := spans["forward management.node_echo/not forwarded"]; dropped.Status.Code != traceStatusError {
+		t.Fatalf("dropped forward span=%+v", dropped)
+	}
+
+	// 关闭导出后热路径直接跳过。
+	if err := tr.apply(coreconfig.NewMap(map[string]string{configKeyTraceExporter: TraceExporterNone})); err != nil || tr.enabled() {
+		t.Fatalf("exporter none: enabled=%v err=%v", tr.enabled(), err)
+	}
+}
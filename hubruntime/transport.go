@@ -0,0 +1,47 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `transport` 相关的逻辑。
+
+import (
+	"context"
+	"log/slog"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+// Transport 替换 runtime 的 TCP 传输：TCP listener 由 Listener 构造，`tcp://` / 裸 host:port 父端点经 Dial 拨号。
+// QUIC / RFCOMM 不受影响。主要供进程内多 hub 测试（hubtest）使用，使拓扑不占用真实端口。
+type Transport interface {
+	Listener(addr string) core.IListener
+	Dial(ctx context.Context, addr string) (core.IConnection, error)
+}
+
+// listenerFactoryFor 返回按 Transport 构造 TCP listener 的工厂；t 为 nil 时返回 nil（使用默认工厂）。
+func listenerFactoryFor(t Transport) listenerFactory {
+	if t == nil {
+		return nil
+	}
+	return func(spec listenerSpec, log *slog.Logger) core.IListener {
+		if spec.Name == listenerNameTCP {
+			return t.Listener(spec.Addr)
+		}
+		return newListenerFromSpec(spec, log)
+	}
+}
+
+// parentDialerFor 返回父链拨号函数；TCP 端点在 t 非空时交给 t.Dial。
+func parentDialerFor(t Transport) func(context.Context, string) (core.IConnection, error) {
+	if t == nil {
+		return dialParentEndpoint
+	}
+	return func(ctx context.Context, target string) (core.IConnection, error) {
+		scheme, addr, err := parseParentEndpoint(target)
+		if err != nil {
+			return nil, err
+		}
+		if scheme == "tcp" {
+			return t.Dial(ctx, addr)
+		}
+		return dialParentEndpoint(ctx, target)
+	}
+}
@@ -0,0 +1,56 @@
+package hubruntime
+
+// 本文件承载 `hubruntime` 中与 `workdir` 相关的逻辑。
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workDir 是单个 runtime 的路径解析根。
+// runtime 不再切换进程 cwd，而是把自身用到的相对路径（持久配置、QUIC 证书、file / flow 目录）
+// 统一拼到该目录下，使同一进程可以承载多个互不干扰的 hub。零值表示沿用进程 cwd。
+type workDir string
+
+// prepareWorkDir 把 dir 规范为绝对路径并确保目录存在；dir 为空时返回零值。
+func prepareWorkDir(dir string) (workDir, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve workdir: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir workdir: %w", err)
+	}
+	return workDir(abs), nil
+}
+
+// Resolve 把相对路径解析到 workDir 下；空串、绝对路径或零值 workDir 时原样返回。
+func (w workDir) Resolve(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" || w == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(string(w), path)
+}
+
+// resolver 返回注入给默认模块集合的路径解析器；零值 workDir 返回 nil，保持子协议自身的默认解析。
+func (w workDir) resolver() func(string) string {
+	if w == "" {
+		return nil
+	}
+	return w.Resolve
+}
+
+// resolveOptionPaths 把 Options 中由 runtime 自己读取的文件路径解析到 workDir 下。
+func resolveOptionPaths(opts Options, w workDir) Options {
+	opts.QUICCertFile = w.Resolve(opts.QUICCertFile)
+	opts.QUICKeyFile = w.Resolve(opts.QUICKeyFile)
+	opts.QUICClientCAFile = w.Resolve(opts.QUICClientCAFile)
+	return opts
+}
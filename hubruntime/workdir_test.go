@@ -0,0 +1,92 @@
+package hubruntime
+
+// 本文件覆盖 `hubruntime` 中与 `workdir` 相关的行为。
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestWorkDirResolve(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "hub-a")
+	wd, err := prepareWorkDir(root)
+	if err != nil {
+		t.Fatalf("prepareWorkDir: %v", err)
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		t.Fatalf("workdir not created: %v", err)
+	}
+	abs := filepath.Join(t.TempDir(), "cert.pem")
+	cases := map[string]string{
+		"":              "",
+		"config/a.json": filepath.Join(root, "config", "a.json"),
+		" ./file ":      filepath.Join(root, "file"),
+		abs:             abs,
+	}
+	for in, want := range cases {
+		if got := wd.Resolve(in); got != want {
+			t.Fatalf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := workDir("").Resolve("config/a.json"); got != "config/a.json" {
+		t.Fatalf("zero workDir should keep relative path, got %q", got)
+	}
+	if workDir("").resolver() != nil {
+		t.Fatalf("zero workDir should not inject a resolver")
+	}
+
+	opts := resolveOptionPaths(Options{QUICCertFile: "tls/cert.pem", QUICKeyFile: abs}, wd)
+	if opts.QUICCertFile != filepath.Join(root, "tls", "cert.pem") || opts.QUICKeyFile != abs {
+		t.Fatalf("unexpected resolved quic paths: %+v", opts)
+	}
+}
+
+func TestBuildConfigPerWorkDirWithoutChdir(t *testing.T) {
+	before, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	roots := []string{filepath.Join(t.TempDir(), "hub-a"), filepath.Join(t.TempDir(), "hub-b")}
+	for i, root := range roots {
+		path := filepath.Join(root, runtimeConfigFile)
+		if err := saveConfigMap(path, map[string]string{"node.display_name": "hub-" + string(rune('a'+i))}); err != nil {
+			t.Fatalf("seed %s: %v", path, err)
+		}
+	}
+
+	for i, root := range roots {
+		opts := DefaultOptions()
+		wd, err := prepareWorkDir(root)
+		if err != nil {
+			t.Fatalf("prepareWorkDir: %v", err)
+		}
+		opts.WorkDir = string(wd)
+		cfg, err := buildConfig(opts)
+		if err != nil {
+			t.Fatalf("buildConfig: %v", err)
+		}
+		if got := cfg.Path(); got != filepath.Join(root, runtimeConfigFile) {
+			t.Fatalf("config path = %q", got)
+		}
+		assertConfigValue(t, cfg, "node.display_name", "hub-"+string(rune('a'+i)))
+		if err := cfg.SetPersistent(coreconfig.KeyParentAddr, "tcp://parent-"+string(rune('a'+i))+":9000"); err != nil {
+			t.Fatalf("SetPersistent: %v", err)
+		}
+	}
+
+	for i, root := range roots {
+		got, err := loadConfigMap(filepath.Join(root, runtimeConfigFile))
+		if err != nil {
+			t.Fatalf("loadConfigMap: %v", err)
+		}
+		if want := "tcp://parent-" + string(rune('a'+i)) + ":9000"; got[coreconfig.KeyParentAddr] != want {
+			t.Fatalf("hub %d persisted parent addr = %q, want %q", i, got[coreconfig.KeyParentAddr], want)
+		}
+	}
+	if after, _ := os.Getwd(); after != before {
+		t.Fatalf("process cwd changed: %q -> %q", before, after)
+	}
+}
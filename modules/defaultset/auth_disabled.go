@@ -12,6 +12,6 @@ import (
 )
 
 // newAuthHandler 在 noauth 变体下返回 nil，让上层集合自动跳过 auth。
-func newAuthHandler(cfg core.IConfig, log *slog.Logger) core.ISubProcess {
-	return nil
+func newAuthHandler(cfg core.IConfig, resolve PathResolver, log *slog.Logger) (core.ISubProcess, error) {
+	return nil, nil
 }
@@ -13,6 +13,10 @@ import (
 )
 
 // newAuthHandler 在启用 auth build tag 时构造默认 auth handler。
-func newAuthHandler(cfg core.IConfig, log *slog.Logger) core.ISubProcess {
-	return authhandler.NewLoginHandlerWithConfig(cfg, log)
+// auth 必须拿到原始配置（权限快照按配置实例区分），节点密钥文件改由 preloadAuthNodeKeys 按 resolve 解析。
+func newAuthHandler(cfg core.IConfig, resolve PathResolver, log *slog.Logger) (core.ISubProcess, error) {
+	if err := preloadAuthNodeKeys(cfg, resolve); err != nil {
+		return nil, err
+	}
+	return authhandler.NewLoginHandlerWithConfig(cfg, log), nil
 }
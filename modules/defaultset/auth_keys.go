@@ -0,0 +1,114 @@
+//go:build !noauth
+// +build !noauth
+
+package defaultset
+
+// 本文件承载默认模块集合中与 `auth_keys` 相关的装配逻辑。
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+)
+
+// authNodeKeysFile 是节点密钥文件的相对路径，与 auth 子协议内部使用的位置与格式一致。
+const authNodeKeysFile = "config/node_keys.json"
+
+// authNodeKeys 是节点密钥文件的内容（base64 DER）。
+type authNodeKeys struct {
+	PrivKey string `json:"privkey"`
+	PubKey  string `json:"pubkey"`
+}
+
+// preloadAuthNodeKeys 在构造 auth handler 之前，从 resolve 解析出的密钥文件加载（不存在时生成并写入）节点密钥，
+// 并写入 auth.node_privkey / auth.node_pubkey。auth 子协议在配置中已有密钥时不再读写进程 cwd 下的密钥文件，
+// 因此节点身份跟随所属 runtime 的 WorkDir。resolve 为空或配置中已有密钥时不做任何事。
+func preloadAuthNodeKeys(cfg core.IConfig, resolve PathResolver) error {
+	if cfg == nil || resolve == nil {
+		return nil
+	}
+	priv, _ := cfg.Get(coreconfig.KeyAuthNodePrivKey)
+	pub, _ := cfg.Get(coreconfig.KeyAuthNodePubKey)
+	if strings.TrimSpace(priv) != "" && strings.TrimSpace(pub) != "" {
+		return nil
+	}
+	path := resolve(authNodeKeysFile)
+	keys, err := readAuthNodeKeys(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load auth node keys %s: %w", path, err)
+		}
+		if keys, err = newAuthNodeKeys(); err != nil {
+			return err
+		}
+		if err := writeAuthNodeKeys(path, keys); err != nil {
+			return fmt.Errorf("write auth node keys %s: %w", path, err)
+		}
+	}
+	cfg.Set(coreconfig.KeyAuthNodePrivKey, keys.PrivKey)
+	cfg.Set(coreconfig.KeyAuthNodePubKey, keys.PubKey)
+	return nil
+}
+
+func readAuthNodeKeys(path string) (authNodeKeys, error) {
+	var keys authNodeKeys
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keys, err
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return keys, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keys.PrivKey))
+	if err != nil {
+		return keys, err
+	}
+	if _, err := x509.ParseECPrivateKey(raw); err != nil {
+		return keys, err
+	}
+	if strings.TrimSpace(keys.PubKey) == "" {
+		return keys, errors.New("missing pubkey")
+	}
+	return keys, nil
+}
+
+func newAuthNodeKeys() (authNodeKeys, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return authNodeKeys{}, fmt.Errorf("generate auth node keys: %w", err)
+	}
+	privDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return authNodeKeys{}, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		return authNodeKeys{}, err
+	}
+	return authNodeKeys{
+		PrivKey: base64.StdEncoding.EncodeToString(privDER),
+		PubKey:  base64.StdEncoding.EncodeToString(pubDER),
+	}, nil
+}
+
+func writeAuthNodeKeys(path string, keys authNodeKeys) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
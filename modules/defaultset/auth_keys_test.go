@@ -0,0 +1,61 @@
+//go:build !noauth
+// +build !noauth
+
+package defaultset
+
+// 本文件覆盖 `defaultset` 中与 `auth_keys` 相关的行为。
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestBuildKeepsAuthNodeKeysUnderWorkDir(t *testing.T) {
+	root := t.TempDir()
+	resolve := func(p string) string { return filepath.Join(root, p) }
+	cfg := config.NewMap(map[string]string{})
+	if _, err := Build(BuildOptions{Config: cfg, ResolvePath: resolve}); err != nil {
+		t.Fatalf("Build err=%v", err)
+	}
+	path := filepath.Join(root, authNodeKeysFile)
+	keys, err := readAuthNodeKeys(path)
+	if err != nil {
+		t.Fatalf("node keys should live under the workdir: %v", err)
+	}
+	if v, _ := cfg.Get(config.KeyAuthNodePrivKey); v != keys.PrivKey {
+		t.Fatalf("auth.node_privkey not loaded from %s", path)
+	}
+	if v, _ := cfg.Get(config.KeyAuthNodePubKey); v != keys.PubKey {
+		t.Fatalf("auth.node_pubkey not loaded from %s", path)
+	}
+
+	// 重启（新的配置实例）沿用同一身份。
+	again := config.NewMap(map[string]string{})
+	if err := preloadAuthNodeKeys(again, resolve); err != nil {
+		t.Fatalf("preload err=%v", err)
+	}
+	if v, _ := again.Get(config.KeyAuthNodePrivKey); v != keys.PrivKey {
+		t.Fatalf("restart generated a new node identity")
+	}
+}
+
+func TestPreloadAuthNodeKeysRejectsCorruptFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, authNodeKeysFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"privkey":"bm90LWEta2V5","pubkey":"x"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 损坏的密钥文件不能被静默替换成新身份。
+	if err := preloadAuthNodeKeys(config.NewMap(map[string]string{}), func(p string) string { return filepath.Join(root, p) }); err == nil {
+		t.Fatalf("expected error for corrupt key file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"privkey":"bm90LWEta2V5","pubkey":"x"}` {
+		t.Fatalf("corrupt key file overwritten: %s", data)
+	}
+}
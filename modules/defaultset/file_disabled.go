@@ -15,3 +15,7 @@ import (
 func newFileHandler(cfg core.IConfig, deps runtimedeps.Deps, log *slog.Logger) (core.ISubProcess, error) {
 	return nil, nil
 }
+
+func newFileParts(cfg core.IConfig, log *slog.Logger) *FileParts {
+	return nil
+}
@@ -17,3 +17,8 @@ func newFileHandler(cfg core.IConfig, deps runtimedeps.Deps, log *slog.Logger) (
 	// 默认集合只负责把共享依赖注入 File 子协议，具体传输状态机仍留在子模块内部。
 	return filehandler.NewHandlerWithDeps(cfg, deps, log), nil
 }
+
+// newFileParts 的 cfg 应带 ResolvePath 解析；未解析的相对路径与 file handler 一样基于可执行文件目录。
+func newFileParts(cfg core.IConfig, log *slog.Logger) *FileParts {
+	return &FileParts{baseDir: fileBaseDir(cfg), log: log}
+}
@@ -0,0 +1,57 @@
+//go:build !nofile
+// +build !nofile
+
+package defaultset
+
+// 本文件覆盖默认模块集合中与 `file_parts` 相关的装配逻辑。
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestFilePartsResolvesBaseDirUnderWorkDir(t *testing.T) {
+	root := t.TempDir()
+	cfg := withResolvedPaths(config.NewMap(map[string]string{"file.base_dir": "uploads"}), func(p string) string {
+		return filepath.Join(root, p)
+	})
+	if got := newFileParts(cfg, nil).baseDir; got != filepath.Join(root, "uploads") {
+		t.Fatalf("baseDir = %q", got)
+	}
+}
+
+func TestFilePartsDrainSyncsPartFiles(t *testing.T) {
+	root := t.TempDir()
+	part := filepath.Join(root, "inbox", "a.bin.part")
+	if err := os.MkdirAll(filepath.Dir(part), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(part, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &FileParts{baseDir: root}
+	if err := p.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() err=%v", err)
+	}
+	// checkpoint 不改动内容，续传偏移仍为已写入的长度。
+	if raw, err := os.ReadFile(part); err != nil || string(raw) != "partial" {
+		t.Fatalf("part content = %q, %v", raw, err)
+	}
+
+	if err := (&FileParts{baseDir: filepath.Join(root, "missing")}).Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() on missing base_dir err=%v", err)
+	}
+	if err := (*FileParts)(nil).Drain(context.Background()); err != nil {
+		t.Fatalf("nil Drain() err=%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Drain(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Drain() with cancelled ctx err=%v", err)
+	}
+}
@@ -13,19 +13,19 @@ import (
 	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
 )
 
-func newFlowHandler(cfg core.IConfig, deps runtimedeps.Deps, log *slog.Logger) (core.ISubProcess, error) {
+func newFlowHandler(cfg core.IConfig, deps runtimedeps.Deps, observer StateObserver, stores stateStores, log *slog.Logger) (core.ISubProcess, error) {
 	// Flow 需要先解析定义持久化与 run archive 后端，避免 handler 内部硬编码存储实现。
-	store, err := newFlowPersistence(cfg)
+	store, err := newFlowPersistence(cfg, stores)
 	if err != nil {
 		return nil, err
 	}
-	archiveStore, err := newFlowRunArchiveStore(cfg)
+	archiveStore, err := newFlowRunArchiveStore(cfg, stores)
 	if err != nil {
 		return nil, err
 	}
 	return flowhandler.NewHandlerWithOptions(cfg, flowhandler.HandlerOptions{
 		RuntimeDeps:     deps,
-		Persistence:     store,
-		RunArchiveStore: archiveStore,
+		Persistence:     observeFlowPersistence(store, backendValue(cfg, cfgFlowBackend, backendJSON), observer),
+		RunArchiveStore: observeFlowRunArchiveStore(archiveStore, flowRunArchiveBackendValue(cfg), observer),
 	}, log), nil
 }
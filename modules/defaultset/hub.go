@@ -6,53 +6,112 @@ import (
 	"log/slog"
 
 	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-subproto/exec/runtimedeps"
 	"github.com/yttydcs/myflowhub-subproto/forward"
 	"github.com/yttydcs/myflowhub-subproto/management"
 )
 
+// BuildOptions 描述构造默认模块集合所需的输入。
+// StateObserver 可选，非空时为可插拔状态后端（当前为 pg）的每次操作上报耗时。
+// ResolvePath 可选，非空时 file / flow 的目录型配置（file.base_dir、flow.base_dir）与 auth 的节点密钥文件经它解析，
+// 使同一进程内的多个 runtime 各自落盘，不依赖进程 cwd。
+type BuildOptions struct {
+	Config        core.IConfig
+	Logger        *slog.Logger
+	StateObserver StateObserver
+	ResolvePath   PathResolver
+}
+
+// Bundle 是默认模块集合的构造结果：handlers、default fallback 以及它们共享的运行期依赖。
+// Deps 会暴露给上层，便于在配置热更新时刷新共享的权限快照。
+// PGPools / SQLiteDBs / VarStoreJournals 是 pg / sqlite / journal 状态后端共享的连接池、数据库句柄与日志文件，
+// 调用方在停止 handlers 后负责 Close。
+// FileParts 在 drain 时 checkpoint 未完成的文件传输；构建时排除 file 子协议时为 nil。
+type Bundle struct {
+	Handlers         []core.ISubProcess
+	Default          core.ISubProcess
+	Deps             runtimedeps.Deps
+	PGPools          *PGPools
+	SQLiteDBs        *SQLiteDBs
+	VarStoreJournals *VarStoreJournals
+	FileParts        *FileParts
+}
+
 // DefaultHub 返回 hub_server 的默认启用模块集合（handlers + default fallback）。
 //
 // 注意：本包仅负责“默认集合的构造策略”，不做重复校验；校验由上层 `modules.DefaultHub` 统一完成。
 // DefaultHub 按默认产品口径拼出一套可直接用于 hub_server 的 handler 集合。
 func DefaultHub(cfg core.IConfig, log *slog.Logger) (handlers []core.ISubProcess, def core.ISubProcess, err error) {
+	bundle, err := Build(BuildOptions{Config: cfg, Logger: log})
+	if err != nil {
+		return nil, nil, err
+	}
+	return bundle.Handlers, bundle.Default, nil
+}
+
+// Build 构造默认模块集合，并把共享依赖一并返回给调用方。
+// 每个 handler 拿到带 `component=<模块名>` 属性的 logger，便于按模块过滤与调整级别。
+func Build(opts BuildOptions) (bundle Bundle, err error) {
+	cfg := opts.Config
+	log := opts.Logger
 	deps := newRuntimeDeps(cfg)
-	handlers = make([]core.ISubProcess, 0, 8)
-	handlers = append(handlers, management.NewHandlerWithDeps(deps, log))
+	pathCfg := withResolvedPaths(cfg, opts.ResolvePath)
+	stores := stateStores{
+		pg:      newPGPools(cfg),
+		sqlite:  newSQLiteDBs(pathCfg),
+		journal: newVarStoreJournals(pathCfg, ComponentLogger(log, LogComponentVarStore)),
+	}
+	defer func() {
+		if err != nil {
+			stores.close()
+		}
+	}()
+	handlers := make([]core.ISubProcess, 0, 8)
+	handlers = append(handlers, management.NewHandlerWithDeps(deps, ComponentLogger(log, LogComponentManagement)))
 
-	if h := newAuthHandler(cfg, log); h != nil {
+	if h, err := newAuthHandler(cfg, opts.ResolvePath, ComponentLogger(log, LogComponentAuth)); err != nil {
+		return Bundle{}, err
+	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newVarStoreHandler(cfg, deps, log); err != nil {
-		return nil, nil, err
+	if h, err := newVarStoreHandler(cfg, deps, opts.StateObserver, stores, ComponentLogger(log, LogComponentVarStore)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newTopicBusHandler(cfg, deps, log); err != nil {
-		return nil, nil, err
+	if h, err := newTopicBusHandler(cfg, deps, ComponentLogger(log, LogComponentTopicBus)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newExecHandler(cfg, deps, log); err != nil {
-		return nil, nil, err
+	if h, err := newExecHandler(cfg, deps, ComponentLogger(log, LogComponentExec)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newFlowHandler(cfg, deps, log); err != nil {
-		return nil, nil, err
+	if h, err := newFlowHandler(pathCfg, deps, opts.StateObserver, stores, ComponentLogger(log, LogComponentFlow)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newFileHandler(cfg, deps, log); err != nil {
-		return nil, nil, err
+	if h, err := newFileHandler(pathCfg, deps, ComponentLogger(log, LogComponentFile)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
-	if h, err := newStreamHandler(cfg, log); err != nil {
-		return nil, nil, err
+	if h, err := newStreamHandler(cfg, ComponentLogger(log, LogComponentStream)); err != nil {
+		return Bundle{}, err
 	} else if h != nil {
 		handlers = append(handlers, h)
 	}
 
-	def = forward.NewDefaultForwardHandler(cfg, log)
-	return handlers, def, nil
+	return Bundle{
+		Handlers:         handlers,
+		Default:          forward.NewDefaultForwardHandler(cfg, ComponentLogger(log, LogComponentForward)),
+		Deps:             deps,
+		PGPools:          stores.pg,
+		SQLiteDBs:        stores.sqlite,
+		VarStoreJournals: stores.journal,
+		FileParts:        newFileParts(pathCfg, ComponentLogger(log, LogComponentFile)),
+	}, nil
 }
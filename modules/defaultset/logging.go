@@ -0,0 +1,29 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `logging` 相关的装配逻辑。
+
+import "log/slog"
+
+// LogComponentKey 是标识日志组件的属性名；组件 logger 由 ComponentLogger 派生。
+const LogComponentKey = "component"
+
+// 默认模块集合中各 handler 的日志组件名，与子协议同名；hubruntime 按这些名称解析 log.level.<component>。
+const (
+	LogComponentManagement = "management"
+	LogComponentAuth       = "auth"
+	LogComponentVarStore   = "varstore"
+	LogComponentTopicBus   = "topicbus"
+	LogComponentExec       = "exec"
+	LogComponentFlow       = "flow"
+	LogComponentFile       = "file"
+	LogComponentStream     = "stream"
+	LogComponentForward    = "forward"
+)
+
+// ComponentLogger 为模块派生带 component 属性的 logger；log 为空时保持为空，由模块回落到默认 logger。
+func ComponentLogger(log *slog.Logger, component string) *slog.Logger {
+	if log == nil {
+		return nil
+	}
+	return log.With(LogComponentKey, component)
+}
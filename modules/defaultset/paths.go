@@ -0,0 +1,49 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `paths` 相关的装配逻辑。
+
+import (
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+// PathResolver 把配置中的相对路径解析为绝对路径（通常以所属 runtime 的 WorkDir 为根）。
+// 为 nil 时保持子协议自身的默认解析方式。
+type PathResolver func(path string) string
+
+// pathConfigKeys 列出默认 handler 与状态后端读取的路径型配置键及其缺省值（与子协议内的默认值保持一致）。
+var pathConfigKeys = map[string]string{
+	cfgFileBaseDir:        "./file",
+	cfgFlowBaseDir:        defaultFlowBaseDir,
+	cfgStateSQLitePath:    defaultSQLitePath,
+	cfgVarStoreJournalDir: defaultVarStoreJournalDir,
+}
+
+// pathConfig 只改写路径型配置键的读取结果，其余读写原样透传给底层配置。
+// 仅交给 file / flow handler、SQLiteDBs 与 VarStoreJournals 使用：permission.SharedConfig 按配置实例区分快照，
+// 其余 handler 必须继续拿到原始配置。
+type pathConfig struct {
+	core.IConfig
+	resolve PathResolver
+}
+
+// withResolvedPaths 在 resolve 非空时返回带路径解析的配置视图。
+func withResolvedPaths(cfg core.IConfig, resolve PathResolver) core.IConfig {
+	if cfg == nil || resolve == nil {
+		return cfg
+	}
+	return pathConfig{IConfig: cfg, resolve: resolve}
+}
+
+func (c pathConfig) Get(key string) (string, bool) {
+	def, ok := pathConfigKeys[strings.TrimSpace(key)]
+	if !ok {
+		return c.IConfig.Get(key)
+	}
+	val, found := c.IConfig.Get(key)
+	if val = strings.TrimSpace(val); !found || val == "" {
+		val = def
+	}
+	return c.resolve(val), true
+}
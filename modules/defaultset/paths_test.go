@@ -0,0 +1,38 @@
+package defaultset
+
+// 本文件覆盖默认模块集合中与 `paths` 相关的装配逻辑。
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestWithResolvedPathsRewritesOnlyDirKeys(t *testing.T) {
+	root := t.TempDir()
+	base := config.NewMap(map[string]string{
+		"file.base_dir": "uploads",
+		"flow.backend":  "json",
+	})
+	if got := withResolvedPaths(base, nil); got != base {
+		t.Fatalf("nil resolver should return the original config")
+	}
+	cfg := withResolvedPaths(base, func(p string) string { return filepath.Join(root, p) })
+
+	if v, _ := cfg.Get("file.base_dir"); v != filepath.Join(root, "uploads") {
+		t.Fatalf("file.base_dir = %q", v)
+	}
+	// 未配置时按子协议默认值解析，仍落在 resolver 根目录下。
+	if v, ok := cfg.Get("flow.base_dir"); !ok || v != filepath.Join(root, "flows") {
+		t.Fatalf("flow.base_dir = %q, %v", v, ok)
+	}
+	if v, _ := cfg.Get("flow.backend"); v != "json" {
+		t.Fatalf("non-path key rewritten: %q", v)
+	}
+	// 写入透传给底层配置。
+	cfg.Set("file.base_dir", "other")
+	if v, _ := base.Get("file.base_dir"); v != "other" {
+		t.Fatalf("Set not forwarded: %q", v)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build nosqlite
+// +build nosqlite
+
+package defaultset
+
+// 本文件承载默认模块集合中与 `sqlite_disabled` 相关的装配逻辑。
+
+const sqliteBuiltin = false
@@ -0,0 +1,14 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package defaultset
+
+// 本文件承载默认模块集合中与 `sqlite_enabled` 相关的装配逻辑。
+
+import (
+	// 纯 Go 实现的 sqlite 驱动，CGO_ENABLED=0 构建同样可用。
+	_ "modernc.org/sqlite"
+)
+
+// sqliteBuiltin 表示当前二进制带有 sqlite 驱动；以 nosqlite 构建时为 false，选择 sqlite 后端会报错。
+const sqliteBuiltin = true
@@ -8,9 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/jackc/pgx/v5"
 	core "github.com/yttydcs/myflowhub-core"
 	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
 	"github.com/yttydcs/myflowhub-subproto/varstore"
@@ -25,11 +25,13 @@ const (
 	cfgStatePGVarTable            = "state.pg.varstore_table"
 	cfgStatePGFlowRunArchiveTable = "state.pg.flow_run_archive_table"
 
-	backendJSON   = "json"
-	backendMemory = "memory"
-	backendPG     = "pg"
-	backendOff    = "off"
-	backendFile   = "file"
+	backendJSON    = "json"
+	backendMemory  = "memory"
+	backendPG      = "pg"
+	backendSQLite  = "sqlite"
+	backendJournal = "journal"
+	backendOff     = "off"
+	backendFile    = "file"
 
 	defaultFlowTable           = "myflowhub_flow_definitions"
 	defaultVarTable            = "myflowhub_varstore_records"
@@ -38,34 +40,55 @@ const (
 
 var pgIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-func newFlowPersistence(cfg core.IConfig) (flowhandler.Persistence, error) {
+// stateStores 汇集可插拔状态后端共享的连接池、数据库句柄与日志文件，由 Build 创建并随 Bundle 交给调用方关闭。
+type stateStores struct {
+	pg      *PGPools
+	sqlite  *SQLiteDBs
+	journal *VarStoreJournals
+}
+
+func (s stateStores) close() {
+	s.pg.Close()
+	s.sqlite.Close()
+	s.journal.Close()
+}
+
+func newFlowPersistence(cfg core.IConfig, stores stateStores) (flowhandler.Persistence, error) {
 	switch backendValue(cfg, cfgFlowBackend, backendJSON) {
 	case backendJSON:
 		return nil, nil
 	case backendPG:
-		return newPGFlowPersistence(cfg)
+		return newPGFlowPersistence(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteFlowPersistence(stores.sqlite)
 	default:
 		return nil, fmt.Errorf("unsupported %s", cfgFlowBackend)
 	}
 }
 
-func newVarStorePersistence(cfg core.IConfig) (varstore.Persistence, error) {
+func newVarStorePersistence(cfg core.IConfig, stores stateStores) (varstore.Persistence, error) {
 	switch backendValue(cfg, cfgVarStoreBackend, backendMemory) {
 	case backendMemory:
 		return nil, nil
 	case backendPG:
-		return newPGVarStorePersistence(cfg)
+		return newPGVarStorePersistence(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteVarStorePersistence(stores.sqlite)
+	case backendJournal:
+		return newJournalVarStorePersistence(stores.journal)
 	default:
 		return nil, fmt.Errorf("unsupported %s", cfgVarStoreBackend)
 	}
 }
 
-func newFlowRunArchiveStore(cfg core.IConfig) (flowhandler.RunArchiveStore, error) {
+func newFlowRunArchiveStore(cfg core.IConfig, stores stateStores) (flowhandler.RunArchiveStore, error) {
 	switch flowRunArchiveBackendValue(cfg) {
 	case backendOff, backendFile:
 		return nil, nil
 	case backendPG:
-		return newPGFlowRunArchiveStore(cfg)
+		return newPGFlowRunArchiveStore(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteFlowRunArchiveStore(stores.sqlite)
 	default:
 		return nil, fmt.Errorf("unsupported %s", cfgFlowRunArchiveBackend)
 	}
@@ -112,6 +135,22 @@ func requiredConfigValue(cfg core.IConfig, key string) (string, error) {
 	return strings.TrimSpace(raw), nil
 }
 
+// intConfigValue 读取整数配置，未配置或为空时取 def，低于 min 或无法解析时报错。
+func intConfigValue(cfg core.IConfig, key string, def, min int) (int, error) {
+	if cfg == nil {
+		return def, nil
+	}
+	raw, ok := cfg.Get(key)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || v < min || v > int(^uint32(0)>>1) {
+		return 0, fmt.Errorf("invalid %s", key)
+	}
+	return v, nil
+}
+
 func normalizedPGTableName(cfg core.IConfig, key, def string) (string, error) {
 	name := strings.TrimSpace(def)
 	if cfg != nil {
@@ -125,78 +164,58 @@ func normalizedPGTableName(cfg core.IConfig, key, def string) (string, error) {
 	return name, nil
 }
 
-func withPGConn(ctx context.Context, dsn string, fn func(context.Context, *pgx.Conn) error) error {
-	if ctx == nil {
-		ctx = context.Background()
+// newPGStateTable 校验 state.pg.dsn 与表名，并在 dsn 对应的共享连接池上构造 pick 选出的表的句柄。
+func newPGStateTable(cfg core.IConfig, pools *PGPools, pick func(pgStateTables) string) (*pgTable, error) {
+	dsn, err := requiredConfigValue(cfg, cfgStatePGDSN)
+	if err != nil {
+		return nil, err
 	}
-	conn, err := pgx.Connect(ctx, dsn)
+	tables, err := pgStateTablesFromConfig(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer conn.Close(ctx)
-	return fn(ctx, conn)
+	return newPGTable(pools, dsn, pick(tables))
 }
 
 type pgFlowPersistence struct {
-	dsn   string
-	table string
+	*pgTable
 }
 
-func newPGFlowPersistence(cfg core.IConfig) (flowhandler.Persistence, error) {
-	dsn, err := requiredConfigValue(cfg, cfgStatePGDSN)
+func newPGFlowPersistence(cfg core.IConfig, pools *PGPools) (flowhandler.Persistence, error) {
+	t, err := newPGStateTable(cfg, pools, func(t pgStateTables) string { return t.Flow })
 	if err != nil {
 		return nil, err
 	}
-	if _, err := pgx.ParseConfig(dsn); err != nil {
-		return nil, fmt.Errorf("invalid %s: %w", cfgStatePGDSN, err)
+	return &pgFlowPersistence{pgTable: t}, nil
+}
+
+func (p *pgFlowPersistence) LoadAll(ctx context.Context) ([]flowhandler.FlowDocument, error) {
+	ctx, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
 	}
-	table, err := normalizedPGTableName(cfg, cfgStatePGFlowTable, defaultFlowTable)
+	rows, err := p.pool.Query(ctx, fmt.Sprintf(`SELECT flow_id, doc FROM %s ORDER BY flow_id`, p.table))
 	if err != nil {
 		return nil, err
 	}
-	return &pgFlowPersistence{dsn: dsn, table: table}, nil
-}
-
-func (p *pgFlowPersistence) ensureSchema(ctx context.Context, conn *pgx.Conn) error {
-	query := fmt.Sprintf(`
-CREATE TABLE IF NOT EXISTS %s (
-	flow_id TEXT PRIMARY KEY,
-	doc JSONB NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-)`, p.table)
-	_, err := conn.Exec(ctx, query)
-	return err
-}
-
-func (p *pgFlowPersistence) LoadAll(ctx context.Context) ([]flowhandler.FlowDocument, error) {
+	defer rows.Close()
 	var docs []flowhandler.FlowDocument
-	err := withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
+	for rows.Next() {
+		var flowID string
+		var raw []byte
+		if err := rows.Scan(&flowID, &raw); err != nil {
+			return nil, err
 		}
-		rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT flow_id, doc FROM %s ORDER BY flow_id`, p.table))
-		if err != nil {
-			return err
+		var doc flowhandler.FlowDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
 		}
-		defer rows.Close()
-		for rows.Next() {
-			var flowID string
-			var raw []byte
-			if err := rows.Scan(&flowID, &raw); err != nil {
-				return err
-			}
-			var doc flowhandler.FlowDocument
-			if err := json.Unmarshal(raw, &doc); err != nil {
-				return err
-			}
-			if strings.TrimSpace(doc.FlowID) == "" {
-				doc.FlowID = flowID
-			}
-			docs = append(docs, doc)
+		if strings.TrimSpace(doc.FlowID) == "" {
+			doc.FlowID = flowID
 		}
-		return rows.Err()
-	})
-	if err != nil {
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return docs, nil
@@ -207,105 +226,78 @@ func (p *pgFlowPersistence) Save(ctx context.Context, doc flowhandler.FlowDocume
 	if err != nil {
 		return err
 	}
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`
+	ctx, err = p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`
 INSERT INTO %s (flow_id, doc, updated_at)
 VALUES ($1, $2::jsonb, NOW())
 ON CONFLICT (flow_id) DO UPDATE
 SET doc = EXCLUDED.doc,
 	updated_at = NOW()`, p.table), strings.TrimSpace(doc.FlowID), string(raw))
-		return err
-	})
+	return err
 }
 
 func (p *pgFlowPersistence) Delete(ctx context.Context, flowID string) error {
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE flow_id = $1`, p.table), strings.TrimSpace(flowID))
+	ctx, err := p.prepare(ctx)
+	if err != nil {
 		return err
-	})
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE flow_id = $1`, p.table), strings.TrimSpace(flowID))
+	return err
 }
 
 type pgVarStorePersistence struct {
-	dsn   string
-	table string
+	*pgTable
 }
 
-func newPGVarStorePersistence(cfg core.IConfig) (varstore.Persistence, error) {
-	dsn, err := requiredConfigValue(cfg, cfgStatePGDSN)
-	if err != nil {
-		return nil, err
-	}
-	if _, err := pgx.ParseConfig(dsn); err != nil {
-		return nil, fmt.Errorf("invalid %s: %w", cfgStatePGDSN, err)
-	}
-	table, err := normalizedPGTableName(cfg, cfgStatePGVarTable, defaultVarTable)
+func newPGVarStorePersistence(cfg core.IConfig, pools *PGPools) (varstore.Persistence, error) {
+	t, err := newPGStateTable(cfg, pools, func(t pgStateTables) string { return t.VarStore })
 	if err != nil {
 		return nil, err
 	}
-	return &pgVarStorePersistence{dsn: dsn, table: table}, nil
-}
-
-func (p *pgVarStorePersistence) ensureSchema(ctx context.Context, conn *pgx.Conn) error {
-	query := fmt.Sprintf(`
-CREATE TABLE IF NOT EXISTS %s (
-	owner BIGINT NOT NULL,
-	name TEXT NOT NULL,
-	value TEXT NOT NULL,
-	value_type TEXT NOT NULL,
-	visibility TEXT NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-	PRIMARY KEY (owner, name)
-)`, p.table)
-	_, err := conn.Exec(ctx, query)
-	return err
+	return &pgVarStorePersistence{pgTable: t}, nil
 }
 
 func (p *pgVarStorePersistence) LoadAll(ctx context.Context) ([]varstore.VarDocument, error) {
-	var docs []varstore.VarDocument
-	err := withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		rows, err := conn.Query(ctx, fmt.Sprintf(`
+	ctx, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.pool.Query(ctx, fmt.Sprintf(`
 SELECT owner, name, value, value_type, visibility
 FROM %s
 ORDER BY owner, name`, p.table))
-		if err != nil {
-			return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs []varstore.VarDocument
+	for rows.Next() {
+		var owner int64
+		var doc varstore.VarDocument
+		if err := rows.Scan(&owner, &doc.Name, &doc.Value, &doc.Type, &doc.Visibility); err != nil {
+			return nil, err
 		}
-		defer rows.Close()
-		for rows.Next() {
-			var owner int64
-			var doc varstore.VarDocument
-			if err := rows.Scan(&owner, &doc.Name, &doc.Value, &doc.Type, &doc.Visibility); err != nil {
-				return err
-			}
-			if owner < 0 || owner > int64(^uint32(0)) {
-				return errors.New("owner out of range")
-			}
-			doc.Owner = uint32(owner)
-			docs = append(docs, doc)
+		if owner < 0 || owner > int64(^uint32(0)) {
+			return nil, errors.New("owner out of range")
 		}
-		return rows.Err()
-	})
-	if err != nil {
+		doc.Owner = uint32(owner)
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return docs, nil
 }
 
 func (p *pgVarStorePersistence) Save(ctx context.Context, doc varstore.VarDocument) error {
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`
+	ctx, err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`
 INSERT INTO %s (owner, name, value, value_type, visibility, updated_at)
 VALUES ($1, $2, $3, $4, $5, NOW())
 ON CONFLICT (owner, name) DO UPDATE
@@ -313,89 +305,64 @@ SET value = EXCLUDED.value,
 	value_type = EXCLUDED.value_type,
 	visibility = EXCLUDED.visibility,
 	updated_at = NOW()`, p.table), int64(doc.Owner), strings.TrimSpace(doc.Name), doc.Value, doc.Type, strings.TrimSpace(doc.Visibility))
-		return err
-	})
+	return err
 }
 
 func (p *pgVarStorePersistence) Delete(ctx context.Context, owner uint32, name string) error {
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE owner = $1 AND name = $2`, p.table), int64(owner), strings.TrimSpace(name))
+	ctx, err := p.prepare(ctx)
+	if err != nil {
 		return err
-	})
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE owner = $1 AND name = $2`, p.table), int64(owner), strings.TrimSpace(name))
+	return err
 }
 
 type pgFlowRunArchiveStore struct {
-	dsn   string
-	table string
+	*pgTable
 }
 
-func newPGFlowRunArchiveStore(cfg core.IConfig) (flowhandler.RunArchiveStore, error) {
-	dsn, err := requiredConfigValue(cfg, cfgStatePGDSN)
+func newPGFlowRunArchiveStore(cfg core.IConfig, pools *PGPools) (flowhandler.RunArchiveStore, error) {
+	t, err := newPGStateTable(cfg, pools, func(t pgStateTables) string { return t.FlowRunArchive })
 	if err != nil {
 		return nil, err
 	}
-	if _, err := pgx.ParseConfig(dsn); err != nil {
-		return nil, fmt.Errorf("invalid %s: %w", cfgStatePGDSN, err)
-	}
-	table, err := normalizedPGTableName(cfg, cfgStatePGFlowRunArchiveTable, defaultFlowRunArchiveTable)
-	if err != nil {
-		return nil, err
-	}
-	return &pgFlowRunArchiveStore{dsn: dsn, table: table}, nil
-}
-
-func (p *pgFlowRunArchiveStore) ensureSchema(ctx context.Context, conn *pgx.Conn) error {
-	query := fmt.Sprintf(`
-CREATE TABLE IF NOT EXISTS %s (
-	flow_id TEXT NOT NULL,
-	run_id TEXT NOT NULL,
-	record JSONB NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-	PRIMARY KEY (flow_id, run_id)
-)`, p.table)
-	_, err := conn.Exec(ctx, query)
-	return err
+	return &pgFlowRunArchiveStore{pgTable: t}, nil
 }
 
 func (p *pgFlowRunArchiveStore) LoadAll(ctx context.Context) ([]flowhandler.ArchivedRunRecord, error) {
-	var records []flowhandler.ArchivedRunRecord
-	err := withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		rows, err := conn.Query(ctx, fmt.Sprintf(`
+	ctx, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.pool.Query(ctx, fmt.Sprintf(`
 SELECT flow_id, run_id, record
 FROM %s
 ORDER BY flow_id, run_id`, p.table))
-		if err != nil {
-			return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []flowhandler.ArchivedRunRecord
+	for rows.Next() {
+		var flowID string
+		var runID string
+		var raw []byte
+		if err := rows.Scan(&flowID, &runID, &raw); err != nil {
+			return nil, err
 		}
-		defer rows.Close()
-		for rows.Next() {
-			var flowID string
-			var runID string
-			var raw []byte
-			if err := rows.Scan(&flowID, &runID, &raw); err != nil {
-				return err
-			}
-			var record flowhandler.ArchivedRunRecord
-			if err := json.Unmarshal(raw, &record); err != nil {
-				return err
-			}
-			if strings.TrimSpace(record.FlowID) == "" {
-				record.FlowID = flowID
-			}
-			if strings.TrimSpace(record.RunID) == "" {
-				record.RunID = runID
-			}
-			records = append(records, record)
+		var record flowhandler.ArchivedRunRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
 		}
-		return rows.Err()
-	})
-	if err != nil {
+		if strings.TrimSpace(record.FlowID) == "" {
+			record.FlowID = flowID
+		}
+		if strings.TrimSpace(record.RunID) == "" {
+			record.RunID = runID
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return records, nil
@@ -406,26 +373,24 @@ func (p *pgFlowRunArchiveStore) Save(ctx context.Context, record flowhandler.Arc
 	if err != nil {
 		return err
 	}
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`
+	ctx, err = p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`
 INSERT INTO %s (flow_id, run_id, record, updated_at)
 VALUES ($1, $2, $3::jsonb, NOW())
 ON CONFLICT (flow_id, run_id) DO UPDATE
 SET record = EXCLUDED.record,
 	updated_at = NOW()`, p.table), strings.TrimSpace(record.FlowID), strings.TrimSpace(record.RunID), string(raw))
-		return err
-	})
+	return err
 }
 
 func (p *pgFlowRunArchiveStore) Delete(ctx context.Context, flowID, runID string) error {
-	return withPGConn(ctx, p.dsn, func(ctx context.Context, conn *pgx.Conn) error {
-		if err := p.ensureSchema(ctx, conn); err != nil {
-			return err
-		}
-		_, err := conn.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE flow_id = $1 AND run_id = $2`, p.table), strings.TrimSpace(flowID), strings.TrimSpace(runID))
+	ctx, err := p.prepare(ctx)
+	if err != nil {
 		return err
-	})
+	}
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE flow_id = $1 AND run_id = $2`, p.table), strings.TrimSpace(flowID), strings.TrimSpace(runID))
+	return err
 }
@@ -4,7 +4,9 @@ package defaultset
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/yttydcs/myflowhub-core/config"
 	flowproto "github.com/yttydcs/myflowhub-proto/protocol/flow"
@@ -12,7 +14,7 @@ import (
 
 func TestDefaultHubRejectsUnsupportedFlowBackend(t *testing.T) {
 	cfg := config.NewMap(map[string]string{
-		cfgFlowBackend: "sqlite",
+		cfgFlowBackend: "mysql",
 	})
 
 	if _, _, err := DefaultHub(cfg, nil); err == nil {
@@ -22,7 +24,7 @@ func TestDefaultHubRejectsUnsupportedFlowBackend(t *testing.T) {
 
 func TestDefaultHubRejectsUnsupportedVarStoreBackend(t *testing.T) {
 	cfg := config.NewMap(map[string]string{
-		cfgVarStoreBackend: "sqlite",
+		cfgVarStoreBackend: "mysql",
 	})
 
 	if _, _, err := DefaultHub(cfg, nil); err == nil {
@@ -42,7 +44,7 @@ func TestDefaultHubRequiresPGDSNWhenBackendIsPG(t *testing.T) {
 
 func TestDefaultHubRejectsUnsupportedFlowRunArchiveBackend(t *testing.T) {
 	cfg := config.NewMap(map[string]string{
-		cfgFlowRunArchiveBackend: "sqlite",
+		cfgFlowRunArchiveBackend: "mysql",
 	})
 
 	if _, _, err := DefaultHub(cfg, nil); err == nil {
@@ -65,7 +67,10 @@ func TestPGFlowPersistenceLoadAllReturnsConnectionError(t *testing.T) {
 		cfgStatePGDSN: "postgres://127.0.0.1:1/myflowhub?sslmode=disable&connect_timeout=1",
 	})
 
-	store, err := newPGFlowPersistence(cfg)
+	pools := newPGPools(cfg)
+	defer pools.Close()
+
+	store, err := newPGFlowPersistence(cfg, pools)
 	if err != nil {
 		t.Fatalf("newPGFlowPersistence err=%v", err)
 	}
@@ -79,7 +84,10 @@ func TestPGFlowRunArchiveStoreLoadAllReturnsConnectionError(t *testing.T) {
 		cfgStatePGDSN: "postgres://127.0.0.1:1/myflowhub?sslmode=disable&connect_timeout=1",
 	})
 
-	store, err := newPGFlowRunArchiveStore(cfg)
+	pools := newPGPools(cfg)
+	defer pools.Close()
+
+	store, err := newPGFlowRunArchiveStore(cfg, pools)
 	if err != nil {
 		t.Fatalf("newPGFlowRunArchiveStore err=%v", err)
 	}
@@ -93,7 +101,10 @@ func TestPGVarStorePersistenceLoadAllReturnsConnectionError(t *testing.T) {
 		cfgStatePGDSN: "postgres://127.0.0.1:1/myflowhub?sslmode=disable&connect_timeout=1",
 	})
 
-	store, err := newPGVarStorePersistence(cfg)
+	pools := newPGPools(cfg)
+	defer pools.Close()
+
+	store, err := newPGVarStorePersistence(cfg, pools)
 	if err != nil {
 		t.Fatalf("newPGVarStorePersistence err=%v", err)
 	}
@@ -139,3 +150,73 @@ func TestDefaultHubWithPGFlowRunArchiveBackendIncludesFlowHandler(t *testing.T)
 	}
 	t.Fatalf("DefaultHub() missing flow handler for subproto=%d", flowproto.SubProtoFlow)
 }
+
+func TestPGBackendsShareOnePoolPerDSN(t *testing.T) {
+	cfg := config.NewMap(map[string]string{
+		cfgStatePGDSN: "postgres://127.0.0.1:1/myflowhub?sslmode=disable&connect_timeout=1",
+	})
+	pools := newPGPools(cfg)
+
+	flowStore, err := newPGFlowPersistence(cfg, pools)
+	if err != nil {
+		t.Fatalf("newPGFlowPersistence err=%v", err)
+	}
+	varStore, err := newPGVarStorePersistence(cfg, pools)
+	if err != nil {
+		t.Fatalf("newPGVarStorePersistence err=%v", err)
+	}
+	archiveStore, err := newPGFlowRunArchiveStore(cfg, pools)
+	if err != nil {
+		t.Fatalf("newPGFlowRunArchiveStore err=%v", err)
+	}
+	pool := flowStore.(*pgFlowPersistence).pool
+	if varStore.(*pgVarStorePersistence).pool != pool || archiveStore.(*pgFlowRunArchiveStore).pool != pool {
+		t.Fatalf("pg backends with the same dsn should share one pool")
+	}
+	if len(pools.pools) != 1 {
+		t.Fatalf("pools=%d, want 1", len(pools.pools))
+	}
+	if err := pools.Probe(context.Background()); err == nil {
+		t.Fatalf("expected pg probe error")
+	}
+
+	pools.Close()
+	if err := pools.Probe(context.Background()); !errors.Is(err, errPGPoolsClosed) {
+		t.Fatalf("probe after close err=%v", err)
+	}
+	if _, err := newPGVarStorePersistence(cfg, pools); !errors.Is(err, errPGPoolsClosed) {
+		t.Fatalf("backend after close err=%v", err)
+	}
+}
+
+func TestPGPoolConfigAppliesStateSettings(t *testing.T) {
+	dsn := "postgres://127.0.0.1:5432/myflowhub?sslmode=disable"
+	poolCfg, err := pgPoolConfig(config.NewMap(map[string]string{
+		cfgStatePGMaxConns:          "16",
+		cfgStatePGMinConns:          "2",
+		cfgStatePGMaxConnIdleMs:     "1500",
+		cfgStatePGMaxConnLifetimeMs: "60000",
+	}), dsn)
+	if err != nil {
+		t.Fatalf("pgPoolConfig err=%v", err)
+	}
+	if poolCfg.MaxConns != 16 || poolCfg.MinConns != 2 || poolCfg.MaxConnIdleTime != 1500*time.Millisecond || poolCfg.MaxConnLifetime != time.Minute {
+		t.Fatalf("pool config=%+v", poolCfg)
+	}
+
+	poolCfg, err = pgPoolConfig(config.NewMap(nil), dsn)
+	if err != nil || poolCfg.MaxConns != defaultPGMaxConns || poolCfg.MinConns != defaultPGMinConns {
+		t.Fatalf("default pool config=%+v err=%v", poolCfg, err)
+	}
+
+	for _, kv := range [][2]string{
+		{cfgStatePGMaxConns, "0"},
+		{cfgStatePGMinConns, "-1"},
+		{cfgStatePGMinConns, "9"},
+		{cfgStatePGMaxConnIdleMs, "soon"},
+	} {
+		if _, err := pgPoolConfig(config.NewMap(map[string]string{kv[0]: kv[1]}), dsn); err == nil {
+			t.Fatalf("expected error for %s=%s", kv[0], kv[1])
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_data_migrate` 相关的装配逻辑。
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+// StateDataKindRunArchive 是 `state migrate -kind` 中 run 归档的写法，对应 StateKindFlowRunArchive。
+const StateDataKindRunArchive = "run_archive"
+
+// StateDataMigrateOptions 描述一次状态数据搬迁：把 Kind 类数据从 From 后端复制到 To 后端。
+// Config 与 ResolvePath 的口径与 BuildOptions 相同，两端后端的连接参数、表名与目录都取自 Config。
+type StateDataMigrateOptions struct {
+	Config      core.IConfig
+	ResolvePath PathResolver
+	Kind        string
+	From        string
+	To          string
+	DryRun      bool
+}
+
+// StateDataMigration 是一次搬迁的结果。Copied 为本次写入（dry-run 下为待写入）的记录数，
+// Skipped 为目标端已有相同内容而跳过的记录数（中断后重跑即靠此续传），Extra 为目标端有而源端没有、保留不动的记录数。
+// Checksum 是源端全部记录的 sha256；非 dry-run 时搬迁后会重新读取目标端，逐条核对后 Verified 等于 Source。
+type StateDataMigration struct {
+	Kind     string `json:"kind"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Source   int    `json:"source"`
+	Copied   int    `json:"copied"`
+	Skipped  int    `json:"skipped"`
+	Extra    int    `json:"extra"`
+	Verified int    `json:"verified"`
+	Checksum string `json:"checksum"`
+}
+
+// MigrateStateData 通过源端 LoadAll 读出全部记录，再经目标端 Save 逐条写入，最后回读目标端核对数量与校验和。
+// 目标端写入均为按主键覆盖，且跳过内容已相同的记录，因此中断后以相同参数重跑即可续传。源端不做任何修改，
+// 目标端多出的记录也不删除。应在 hub 停止、不再写入源端时执行。
+func MigrateStateData(ctx context.Context, opts StateDataMigrateOptions) (StateDataMigration, error) {
+	out := StateDataMigration{
+		Kind:   strings.ToLower(strings.TrimSpace(opts.Kind)),
+		From:   strings.ToLower(strings.TrimSpace(opts.From)),
+		To:     strings.ToLower(strings.TrimSpace(opts.To)),
+		DryRun: opts.DryRun,
+	}
+	if out.From == out.To {
+		return out, fmt.Errorf("source and destination backend are both %q", out.From)
+	}
+	pathCfg := withResolvedPaths(opts.Config, opts.ResolvePath)
+	stores := stateStores{
+		pg:      newPGPools(opts.Config),
+		sqlite:  newSQLiteDBs(pathCfg),
+		journal: newVarStoreJournals(pathCfg, nil),
+	}
+	defer stores.close()
+
+	var err error
+	switch out.Kind {
+	case StateKindFlow:
+		var src, dst flowhandler.Persistence
+		if src, err = migrationFlowStore(opts.Config, pathCfg, stores, out.From); err != nil {
+			return out, err
+		}
+		if dst, err = migrationFlowStore(opts.Config, pathCfg, stores, out.To); err != nil {
+			return out, err
+		}
+		err = migrateStateRecords(ctx, src, dst, func(doc flowhandler.FlowDocument) string {
+			return strings.TrimSpace(doc.FlowID)
+		}, &out)
+	case StateKindVarStore:
+		var src, dst varstore.Persistence
+		if src, err = migrationVarStoreStore(opts.Config, stores, out.From); err != nil {
+			return out, err
+		}
+		if dst, err = migrationVarStoreStore(opts.Config, stores, out.To); err != nil {
+			return out, err
+		}
+		err = migrateStateRecords(ctx, src, dst, func(doc varstore.VarDocument) string {
+			return fmt.Sprintf("%d/%s", doc.Owner, strings.TrimSpace(doc.Name))
+		}, &out)
+	case StateDataKindRunArchive, StateKindFlowRunArchive:
+		out.Kind = StateDataKindRunArchive
+		var src, dst flowhandler.RunArchiveStore
+		if src, err = migrationRunArchiveStore(opts.Config, pathCfg, stores, out.From); err != nil {
+			return out, err
+		}
+		if dst, err = migrationRunArchiveStore(opts.Config, pathCfg, stores, out.To); err != nil {
+			return out, err
+		}
+		err = migrateStateRecords(ctx, src, dst, func(record flowhandler.ArchivedRunRecord) string {
+			return strings.TrimSpace(record.FlowID) + "/" + strings.TrimSpace(record.RunID)
+		}, &out)
+	default:
+		return out, fmt.Errorf("unsupported kind %q (want %s|%s|%s)", opts.Kind, StateKindFlow, StateKindVarStore, StateDataKindRunArchive)
+	}
+	return out, err
+}
+
+// migrationFlowStore 与 newFlowPersistence 相同，但 json 后端返回可在 hub 之外读写的实现而不是 nil。
+func migrationFlowStore(cfg, pathCfg core.IConfig, stores stateStores, backend string) (flowhandler.Persistence, error) {
+	switch backend {
+	case backendJSON:
+		return newJSONFlowPersistence(pathCfg), nil
+	case backendPG:
+		return newPGFlowPersistence(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteFlowPersistence(stores.sqlite)
+	default:
+		return nil, fmt.Errorf("unsupported flow backend %q (want %s|%s|%s)", backend, backendJSON, backendPG, backendSQLite)
+	}
+}
+
+// migrationVarStoreStore 与 newVarStorePersistence 相同；memory 后端不落盘，没有可搬迁的数据。
+func migrationVarStoreStore(cfg core.IConfig, stores stateStores, backend string) (varstore.Persistence, error) {
+	switch backend {
+	case backendPG:
+		return newPGVarStorePersistence(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteVarStorePersistence(stores.sqlite)
+	case backendJournal:
+		return newJournalVarStorePersistence(stores.journal)
+	case backendMemory:
+		return nil, fmt.Errorf("varstore backend %q keeps nothing across restarts and cannot be migrated", backend)
+	default:
+		return nil, fmt.Errorf("unsupported varstore backend %q (want %s|%s|%s)", backend, backendPG, backendSQLite, backendJournal)
+	}
+}
+
+// migrationRunArchiveStore 与 newFlowRunArchiveStore 相同，但 file 后端返回可在 hub 之外读写的实现而不是 nil。
+func migrationRunArchiveStore(cfg, pathCfg core.IConfig, stores stateStores, backend string) (flowhandler.RunArchiveStore, error) {
+	switch backend {
+	case backendFile:
+		return newFileFlowRunArchiveStore(pathCfg), nil
+	case backendPG:
+		return newPGFlowRunArchiveStore(cfg, stores.pg)
+	case backendSQLite:
+		return newSQLiteFlowRunArchiveStore(stores.sqlite)
+	default:
+		return nil, fmt.Errorf("unsupported run_archive backend %q (want %s|%s|%s)", backend, backendFile, backendPG, backendSQLite)
+	}
+}
+
+// stateRecordStore 是搬迁需要的最小能力，flow / varstore / run 归档的持久化接口均满足。
+type stateRecordStore[T any] interface {
+	LoadAll(ctx context.Context) ([]T, error)
+	Save(ctx context.Context, record T) error
+}
+
+func migrateStateRecords[T any](ctx context.Context, src, dst stateRecordStore[T], key func(T) string, out *StateDataMigration) error {
+	records, err := src.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", out.From, err)
+	}
+	srcSums, err := stateRecordSums(records, key)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", out.From, err)
+	}
+	existing, err := dst.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", out.To, err)
+	}
+	dstSums, err := stateRecordSums(existing, key)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", out.To, err)
+	}
+	out.Source = len(srcSums)
+	out.Checksum = stateRecordsChecksum(srcSums)
+	for k := range dstSums {
+		if _, ok := srcSums[k]; !ok {
+			out.Extra++
+		}
+	}
+
+	sort.Slice(records, func(a, b int) bool { return key(records[a]) < key(records[b]) })
+	for _, record := range records {
+		k := key(record)
+		if sum, ok := dstSums[k]; ok && sum == srcSums[k] {
+			out.Skipped++
+			continue
+		}
+		if !out.DryRun {
+			if err := dst.Save(ctx, record); err != nil {
+				return fmt.Errorf("save %s %s: %w", out.To, k, err)
+			}
+		}
+		out.Copied++
+	}
+	if out.DryRun {
+		return nil
+	}
+
+	// 回读目标端逐条核对，源端每条记录都必须以相同内容出现在目标端。
+	written, err := dst.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", out.To, err)
+	}
+	if dstSums, err = stateRecordSums(written, key); err != nil {
+		return fmt.Errorf("verify %s: %w", out.To, err)
+	}
+	matched := make(map[string]string, len(srcSums))
+	var mismatched []string
+	for k, sum := range srcSums {
+		if dstSums[k] == sum {
+			matched[k] = sum
+			continue
+		}
+		mismatched = append(mismatched, k)
+	}
+	out.Verified = len(matched)
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("verify %s: %d of %d records differ (first %s)", out.To, len(mismatched), out.Source, mismatched[0])
+	}
+	if sum := stateRecordsChecksum(matched); sum != out.Checksum {
+		return fmt.Errorf("verify %s: checksum %s, want %s", out.To, sum, out.Checksum)
+	}
+	return nil
+}
+
+// stateRecordSums 计算每条记录 JSON 编码的 sha256，同一主键出现两次时报错。
+func stateRecordSums[T any](records []T, key func(T) string) (map[string]string, error) {
+	sums := make(map[string]string, len(records))
+	for _, record := range records {
+		k := key(record)
+		if _, ok := sums[k]; ok {
+			return nil, fmt.Errorf("duplicate record %s", k)
+		}
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", k, err)
+		}
+		sum := sha256.Sum256(raw)
+		sums[k] = hex.EncodeToString(sum[:])
+	}
+	return sums, nil
+}
+
+// stateRecordsChecksum 按主键排序后对每条记录的校验和再做一次 sha256，与记录的读取顺序无关。
+func stateRecordsChecksum(sums map[string]string) string {
+	keys := make([]string, 0, len(sums))
+	for k := range sums {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\t%s\n", k, sums[k])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,113 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package defaultset
+
+// 本文件覆盖 `defaultset` 中与 `state_data_migrate` 相关的行为。
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+func TestMigrateStateDataCopiesVerifiesAndResumes(t *testing.T) {
+	root := t.TempDir()
+	resolve := func(p string) string { return filepath.Join(root, p) }
+	opts := StateDataMigrateOptions{Config: config.NewMap(nil), ResolvePath: resolve}
+	ctx := context.Background()
+
+	flows := newJSONFlowPersistence(withResolvedPaths(opts.Config, resolve))
+	runs := newFileFlowRunArchiveStore(withResolvedPaths(opts.Config, resolve))
+	for _, id := range []string{"f1", "f2", "f3"} {
+		if err := flows.Save(ctx, flowhandler.FlowDocument{FlowID: id, Name: "flow " + id}); err != nil {
+			t.Fatalf("flow save err=%v", err)
+		}
+	}
+	if err := runs.Save(ctx, flowhandler.ArchivedRunRecord{FlowID: "f1", RunID: "r1", Status: "succeeded"}); err != nil {
+		t.Fatalf("run save err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "flows", "_runs", "f1", "r1.json")); err != nil {
+		t.Fatalf("file run archive layout: %v", err)
+	}
+
+	dry := opts
+	dry.Kind, dry.From, dry.To, dry.DryRun = StateKindFlow, backendJSON, backendSQLite, true
+	out, err := MigrateStateData(ctx, dry)
+	if err != nil || out.Source != 3 || out.Copied != 3 || out.Verified != 0 || !strings.HasPrefix(out.Checksum, "sha256:") {
+		t.Fatalf("dry run out=%+v err=%v", out, err)
+	}
+	checksum := out.Checksum
+
+	// 模拟中断：目标端已有一条相同记录、一条旧内容记录和一条源端没有的记录。
+	dbs := newSQLiteDBs(withResolvedPaths(config.NewMap(nil), resolve))
+	dst, _ := newSQLiteFlowPersistence(dbs)
+	for _, doc := range []flowhandler.FlowDocument{{FlowID: "f1", Name: "flow f1"}, {FlowID: "f2", Name: "stale"}, {FlowID: "f9"}} {
+		if err := dst.Save(ctx, doc); err != nil {
+			t.Fatalf("seed err=%v", err)
+		}
+	}
+	dbs.Close()
+
+	run := dry
+	run.DryRun = false
+	out, err = MigrateStateData(ctx, run)
+	if err != nil || out.Source != 3 || out.Copied != 2 || out.Skipped != 1 || out.Extra != 1 || out.Verified != 3 || out.Checksum != checksum {
+		t.Fatalf("flow migrate out=%+v err=%v", out, err)
+	}
+	out, err = MigrateStateData(ctx, run)
+	if err != nil || out.Copied != 0 || out.Skipped != 3 || out.Verified != 3 {
+		t.Fatalf("rerun should skip everything: out=%+v err=%v", out, err)
+	}
+
+	out, err = MigrateStateData(ctx, StateDataMigrateOptions{Config: opts.Config, ResolvePath: resolve, Kind: StateDataKindRunArchive, From: backendFile, To: backendSQLite})
+	if err != nil || out.Kind != StateDataKindRunArchive || out.Copied != 1 || out.Verified != 1 {
+		t.Fatalf("run archive migrate out=%+v err=%v", out, err)
+	}
+
+	// varstore：sqlite -> journal，再读回核对。
+	dbs = newSQLiteDBs(withResolvedPaths(config.NewMap(nil), resolve))
+	vars, _ := newSQLiteVarStorePersistence(dbs)
+	if err := vars.Save(ctx, varstore.VarDocument{Owner: 7, Name: "a", Value: "1", Type: "string", Visibility: "public"}); err != nil {
+		t.Fatalf("var save err=%v", err)
+	}
+	dbs.Close()
+	out, err = MigrateStateData(ctx, StateDataMigrateOptions{Config: opts.Config, ResolvePath: resolve, Kind: StateKindVarStore, From: backendSQLite, To: backendJournal})
+	if err != nil || out.Copied != 1 || out.Verified != 1 {
+		t.Fatalf("varstore migrate out=%+v err=%v", out, err)
+	}
+	journals := newVarStoreJournals(withResolvedPaths(config.NewMap(nil), resolve), nil)
+	defer journals.Close()
+	if docs, err := newTestJournalPersistence(t, journals).LoadAll(ctx); err != nil || len(docs) != 1 || docs[0].Value != "1" {
+		t.Fatalf("journal docs=%+v err=%v", docs, err)
+	}
+}
+
+func TestMigrateStateDataRejectsInvalidRequests(t *testing.T) {
+	cfg := config.NewMap(map[string]string{cfgStateSQLitePath: filepath.Join(t.TempDir(), "state.db")})
+	for _, tc := range []struct {
+		kind, from, to string
+		wantErr        string
+	}{
+		{kind: "topics", from: backendJSON, to: backendPG, wantErr: "unsupported kind"},
+		{kind: StateKindFlow, from: backendPG, to: backendPG, wantErr: "both"},
+		{kind: StateKindFlow, from: backendJSON, to: backendJournal, wantErr: "unsupported flow backend"},
+		{kind: StateKindVarStore, from: backendMemory, to: backendSQLite, wantErr: "cannot be migrated"},
+		{kind: StateDataKindRunArchive, from: backendOff, to: backendSQLite, wantErr: "unsupported run_archive backend"},
+		{kind: StateKindVarStore, from: backendSQLite, to: backendPG, wantErr: cfgStatePGDSN},
+	} {
+		_, err := MigrateStateData(context.Background(), StateDataMigrateOptions{Config: cfg, Kind: tc.kind, From: tc.from, To: tc.to})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("%s %s->%s err=%v want %q", tc.kind, tc.from, tc.to, err, tc.wantErr)
+		}
+	}
+	if err := newJSONFlowPersistence(config.NewMap(map[string]string{cfgFlowBaseDir: t.TempDir()})).Save(context.Background(), flowhandler.FlowDocument{FlowID: "../x"}); err == nil {
+		t.Fatalf("flow id with path separator should be rejected")
+	}
+}
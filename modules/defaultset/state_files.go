@@ -0,0 +1,214 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_files` 相关的装配逻辑。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+)
+
+const (
+	cfgFlowBaseDir = "flow.base_dir"
+
+	defaultFlowBaseDir = "./flows"
+
+	// flowRunArchiveDirName 是 file run 归档在 flow.base_dir 下的子目录。
+	flowRunArchiveDirName = "_runs"
+)
+
+// jsonFlowPersistence 按 flow handler 内置 json 后端的布局读写 flow 定义：flow.base_dir/<flow_id>.json。
+// hub 运行时 json 后端仍由 flow handler 自己实现，这里只供 `state migrate` 在 hub 之外搬迁数据。
+type jsonFlowPersistence struct {
+	dir string
+}
+
+// newJSONFlowPersistence 的 cfg 应带 ResolvePath 解析，使 flow.base_dir 相对 runtime 的 WorkDir。
+func newJSONFlowPersistence(cfg core.IConfig) flowhandler.Persistence {
+	return &jsonFlowPersistence{dir: flowBaseDir(cfg)}
+}
+
+func (p *jsonFlowPersistence) LoadAll(context.Context) ([]flowhandler.FlowDocument, error) {
+	entries, err := os.ReadDir(p.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var docs []flowhandler.FlowDocument
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		var doc flowhandler.FlowDocument
+		if err := readJSONFile(filepath.Join(p.dir, name), &doc); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(doc.FlowID) == "" {
+			doc.FlowID = strings.TrimSuffix(name, ".json")
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(a, b int) bool { return docs[a].FlowID < docs[b].FlowID })
+	return docs, nil
+}
+
+func (p *jsonFlowPersistence) Save(_ context.Context, doc flowhandler.FlowDocument) error {
+	flowID, err := stateFileName(doc.FlowID)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(p.dir, flowID+".json"), doc)
+}
+
+func (p *jsonFlowPersistence) Delete(_ context.Context, flowID string) error {
+	flowID, err := stateFileName(flowID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(p.dir, flowID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// fileFlowRunArchiveStore 按 flow handler 内置 file 归档的布局读写 run 归档：flow.base_dir/_runs/<flow_id>/<run_id>.json。
+// 与 jsonFlowPersistence 一样只供 `state migrate` 使用。
+type fileFlowRunArchiveStore struct {
+	dir string
+}
+
+// newFileFlowRunArchiveStore 的 cfg 应带 ResolvePath 解析，与 newJSONFlowPersistence 相同。
+func newFileFlowRunArchiveStore(cfg core.IConfig) flowhandler.RunArchiveStore {
+	return &fileFlowRunArchiveStore{dir: filepath.Join(flowBaseDir(cfg), flowRunArchiveDirName)}
+}
+
+func (p *fileFlowRunArchiveStore) LoadAll(context.Context) ([]flowhandler.ArchivedRunRecord, error) {
+	flows, err := os.ReadDir(p.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []flowhandler.ArchivedRunRecord
+	for _, flow := range flows {
+		if !flow.IsDir() {
+			continue
+		}
+		runs, err := os.ReadDir(filepath.Join(p.dir, flow.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			name := run.Name()
+			if run.IsDir() || filepath.Ext(name) != ".json" {
+				continue
+			}
+			var record flowhandler.ArchivedRunRecord
+			if err := readJSONFile(filepath.Join(p.dir, flow.Name(), name), &record); err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(record.FlowID) == "" {
+				record.FlowID = flow.Name()
+			}
+			if strings.TrimSpace(record.RunID) == "" {
+				record.RunID = strings.TrimSuffix(name, ".json")
+			}
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(a, b int) bool {
+		if records[a].FlowID != records[b].FlowID {
+			return records[a].FlowID < records[b].FlowID
+		}
+		return records[a].RunID < records[b].RunID
+	})
+	return records, nil
+}
+
+func (p *fileFlowRunArchiveStore) Save(_ context.Context, record flowhandler.ArchivedRunRecord) error {
+	flowID, err := stateFileName(record.FlowID)
+	if err != nil {
+		return err
+	}
+	runID, err := stateFileName(record.RunID)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(p.dir, flowID, runID+".json"), record)
+}
+
+func (p *fileFlowRunArchiveStore) Delete(_ context.Context, flowID, runID string) error {
+	flowID, err := stateFileName(flowID)
+	if err != nil {
+		return err
+	}
+	if runID, err = stateFileName(runID); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(p.dir, flowID, runID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func flowBaseDir(cfg core.IConfig) string {
+	dir := defaultFlowBaseDir
+	if cfg != nil {
+		if raw, ok := cfg.Get(cfgFlowBaseDir); ok && strings.TrimSpace(raw) != "" {
+			dir = strings.TrimSpace(raw)
+		}
+	}
+	return filepath.Clean(dir)
+}
+
+// stateFileName 校验用作文件名的 id，拒绝空值与路径分隔符，避免写出目录之外。
+func stateFileName(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid id %q for file backend", id)
+	}
+	return id, nil
+}
+
+func readJSONFile(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile 写临时文件后替换，避免中断时留下半个文件。
+func writeJSONFile(path string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
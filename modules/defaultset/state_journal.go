@@ -0,0 +1,438 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_journal` 相关的装配逻辑。
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/yttydcs/myflowhub-core"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+const (
+	cfgVarStoreJournalDir             = "varstore.journal.dir"
+	cfgVarStoreJournalFsync           = "varstore.journal.fsync"
+	cfgVarStoreJournalFsyncIntervalMs = "varstore.journal.fsync_interval_ms"
+	cfgVarStoreJournalCompactRecords  = "varstore.journal.compact_records"
+
+	defaultVarStoreJournalDir             = "./state/varstore"
+	defaultVarStoreJournalFsyncIntervalMs = 1000
+	defaultVarStoreJournalCompactRecords  = 1000
+
+	// journalFsyncAlways / journalFsyncInterval / journalFsyncOff 是 varstore.journal.fsync 的取值：
+	// 每条记录写入后立即 fsync、后台按间隔 fsync、只交给操作系统回写。
+	journalFsyncAlways   = "always"
+	journalFsyncInterval = "interval"
+	journalFsyncOff      = "off"
+
+	journalLogName      = "journal.log"
+	journalSnapshotName = "snapshot.json"
+
+	journalOpSet    = "set"
+	journalOpRevoke = "revoke"
+
+	journalSnapshotVersion = 1
+)
+
+var errVarStoreJournalsClosed = errors.New("varstore journals closed")
+
+// VarStoreJournals 按目录持有 journal 状态后端的日志文件：varstore 记录以追加日志写入，
+// 日志达到 varstore.journal.compact_records 条后压缩为快照。日志在首个 journal 后端构造时打开，
+// 由 Build 随 Bundle 返回，调用方在停止时 Close。
+type VarStoreJournals struct {
+	cfg core.IConfig
+	log *slog.Logger
+
+	mu       sync.Mutex
+	journals map[string]*varStoreJournal
+	closed   bool
+}
+
+// newVarStoreJournals 的 cfg 应带 ResolvePath 解析，使 varstore.journal.dir 相对 runtime 的 WorkDir。
+func newVarStoreJournals(cfg core.IConfig, log *slog.Logger) *VarStoreJournals {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &VarStoreJournals{cfg: cfg, log: log, journals: make(map[string]*varStoreJournal)}
+}
+
+// journal 返回 varstore.journal.dir 对应的日志，不存在时按 varstore.journal.* 的设置创建（此时尚未读取文件）。
+func (s *VarStoreJournals) journal() (*varStoreJournal, error) {
+	dir := defaultVarStoreJournalDir
+	if s.cfg != nil {
+		if raw, ok := s.cfg.Get(cfgVarStoreJournalDir); ok && strings.TrimSpace(raw) != "" {
+			dir = strings.TrimSpace(raw)
+		}
+	}
+	dir = filepath.Clean(dir)
+	fsync := backendValue(s.cfg, cfgVarStoreJournalFsync, journalFsyncAlways)
+	switch fsync {
+	case journalFsyncAlways, journalFsyncInterval, journalFsyncOff:
+	default:
+		return nil, fmt.Errorf("invalid %s", cfgVarStoreJournalFsync)
+	}
+	intervalMs, err := intConfigValue(s.cfg, cfgVarStoreJournalFsyncIntervalMs, defaultVarStoreJournalFsyncIntervalMs, 1)
+	if err != nil {
+		return nil, err
+	}
+	compactRecords, err := intConfigValue(s.cfg, cfgVarStoreJournalCompactRecords, defaultVarStoreJournalCompactRecords, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errVarStoreJournalsClosed
+	}
+	if j, ok := s.journals[dir]; ok {
+		return j, nil
+	}
+	j := &varStoreJournal{
+		dir:            dir,
+		fsync:          fsync,
+		fsyncInterval:  time.Duration(intervalMs) * time.Millisecond,
+		compactRecords: compactRecords,
+		log:            s.log,
+	}
+	s.journals[dir] = j
+	return j, nil
+}
+
+// Close 把全部日志刷盘并关闭；之后再构造或使用 journal 后端会失败。s 为 nil 时什么也不做。
+func (s *VarStoreJournals) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	journals := s.journals
+	s.journals = nil
+	s.closed = true
+	s.mu.Unlock()
+	for _, j := range journals {
+		if err := j.close(); err != nil {
+			s.log.Warn("varstore journal close failed", "dir", j.dir, "err", err)
+		}
+	}
+}
+
+type journalKey struct {
+	owner uint32
+	name  string
+}
+
+// journalRecord 是日志中的一行：op 为 set 时携带完整记录，为 revoke 时只有 owner / name。
+type journalRecord struct {
+	Op string `json:"op"`
+	varstore.VarDocument
+}
+
+type journalSnapshot struct {
+	Version int                    `json:"version"`
+	Records []varstore.VarDocument `json:"records"`
+}
+
+// varStoreJournal 是某个目录下的快照与追加日志，并在内存中保存回放后的全部记录，用于 LoadAll 与压缩。
+// 日志与快照都是幂等的最终状态操作，压缩中途崩溃时新快照加旧日志回放的结果不变。
+type varStoreJournal struct {
+	dir            string
+	fsync          string
+	fsyncInterval  time.Duration
+	compactRecords int
+	log            *slog.Logger
+
+	mu      sync.Mutex
+	opened  bool
+	closed  bool
+	records map[journalKey]varstore.VarDocument
+	file    *os.File
+	size    int64
+	entries int
+	dirty   bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newJournalVarStorePersistence(journals *VarStoreJournals) (varstore.Persistence, error) {
+	j, err := journals.journal()
+	if err != nil {
+		return nil, err
+	}
+	return &journalVarStorePersistence{journal: j}, nil
+}
+
+type journalVarStorePersistence struct {
+	journal *varStoreJournal
+}
+
+func (p *journalVarStorePersistence) LoadAll(context.Context) ([]varstore.VarDocument, error) {
+	return p.journal.loadAll()
+}
+
+func (p *journalVarStorePersistence) Save(_ context.Context, doc varstore.VarDocument) error {
+	doc.Name = strings.TrimSpace(doc.Name)
+	doc.Visibility = strings.TrimSpace(doc.Visibility)
+	return p.journal.apply(journalRecord{Op: journalOpSet, VarDocument: doc})
+}
+
+func (p *journalVarStorePersistence) Delete(_ context.Context, owner uint32, name string) error {
+	return p.journal.apply(journalRecord{Op: journalOpRevoke, VarDocument: varstore.VarDocument{Owner: owner, Name: strings.TrimSpace(name)}})
+}
+
+func (j *varStoreJournal) loadAll() ([]varstore.VarDocument, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j.sortedRecords(), nil
+}
+
+// apply 先把记录追加到日志（按 fsync 策略刷盘），成功后才更新内存状态；写入失败时截断掉残缺的尾部。
+func (j *varStoreJournal) apply(rec journalRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.open(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := j.file.Write(line)
+	if err == nil && j.fsync == journalFsyncAlways {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		_ = j.file.Truncate(j.size)
+		return err
+	}
+	j.size += int64(n)
+	j.entries++
+	j.dirty = true
+	j.replay(rec)
+	if j.entries >= j.compactRecords {
+		// 记录已写入日志，压缩失败不影响本次结果，下次写入时重试。
+		if err := j.compact(); err != nil {
+			j.log.Warn("varstore journal compaction failed", "dir", j.dir, "entries", j.entries, "err", err)
+		}
+	}
+	return nil
+}
+
+// open 在首次使用时读取快照、回放日志并打开日志文件；调用方持有 j.mu。
+// 日志末尾不以换行结束的残行视为写入中途崩溃，截断后继续；中间出现无法解析的行则报错，不猜测数据。
+func (j *varStoreJournal) open() error {
+	if j.closed {
+		return errVarStoreJournalsClosed
+	}
+	if j.opened {
+		return nil
+	}
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", cfgVarStoreJournalDir, err)
+	}
+	records, err := readJournalSnapshot(filepath.Join(j.dir, journalSnapshotName))
+	if err != nil {
+		return err
+	}
+	j.records = records
+
+	path := filepath.Join(j.dir, journalLogName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	size, entries, err := j.replayLog(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	j.file, j.size, j.entries, j.opened = file, size, entries, true
+	if j.fsync == journalFsyncInterval {
+		j.stop, j.done = make(chan struct{}), make(chan struct{})
+		go j.syncLoop(j.stop, j.done)
+	}
+	return nil
+}
+
+// replayLog 把日志逐行回放到 j.records，返回最后一个完整行之后的偏移与行数。
+func (j *varStoreJournal) replayLog(file *os.File) (int64, int, error) {
+	r := bufio.NewReader(file)
+	var offset int64
+	entries := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				j.log.Warn("varstore journal truncated torn tail", "dir", j.dir, "offset", offset, "bytes", len(line))
+			}
+			return offset, entries, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			return 0, 0, fmt.Errorf("corrupt record at offset %d: %w", offset, err)
+		}
+		if rec.Op != journalOpSet && rec.Op != journalOpRevoke {
+			return 0, 0, fmt.Errorf("corrupt record at offset %d: unknown op %q", offset, rec.Op)
+		}
+		j.replay(rec)
+		offset += int64(len(line))
+		entries++
+	}
+}
+
+func (j *varStoreJournal) replay(rec journalRecord) {
+	key := journalKey{owner: rec.Owner, name: rec.Name}
+	if rec.Op == journalOpRevoke {
+		delete(j.records, key)
+		return
+	}
+	j.records[key] = rec.VarDocument
+}
+
+func (j *varStoreJournal) sortedRecords() []varstore.VarDocument {
+	docs := make([]varstore.VarDocument, 0, len(j.records))
+	for _, doc := range j.records {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(a, b int) bool {
+		if docs[a].Owner != docs[b].Owner {
+			return docs[a].Owner < docs[b].Owner
+		}
+		return docs[a].Name < docs[b].Name
+	})
+	return docs
+}
+
+// compact 把当前记录写成新快照并清空日志；调用方持有 j.mu。
+// 快照总是先 fsync 再替换，之后才截断日志，与 fsync 策略无关，避免压缩本身丢失已落盘的数据。
+func (j *varStoreJournal) compact() error {
+	raw, err := json.Marshal(journalSnapshot{Version: journalSnapshotVersion, Records: j.sortedRecords()})
+	if err != nil {
+		return err
+	}
+	if err := writeJournalSnapshot(filepath.Join(j.dir, journalSnapshotName), raw); err != nil {
+		return err
+	}
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.size, j.entries, j.dirty = 0, 0, false
+	return nil
+}
+
+// syncLoop 是 fsync=interval 时的后台刷盘循环，只在有新写入时 fsync。
+func (j *varStoreJournal) syncLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(j.fsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		j.mu.Lock()
+		if j.file != nil && j.dirty {
+			if err := j.file.Sync(); err != nil {
+				j.log.Warn("varstore journal fsync failed", "dir", j.dir, "err", err)
+			} else {
+				j.dirty = false
+			}
+		}
+		j.mu.Unlock()
+	}
+}
+
+// close 停止后台刷盘并在关闭前 fsync 一次，使 fsync=interval / off 时正常停止也不丢数据。
+func (j *varStoreJournal) close() error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return nil
+	}
+	j.closed = true
+	file, stop, done := j.file, j.stop, j.done
+	j.file = nil
+	j.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if file == nil {
+		return nil
+	}
+	return errors.Join(file.Sync(), file.Close())
+}
+
+func readJournalSnapshot(path string) (map[journalKey]varstore.VarDocument, error) {
+	records := make(map[journalKey]varstore.VarDocument)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap journalSnapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if snap.Version != journalSnapshotVersion {
+		return nil, fmt.Errorf("%s: unsupported snapshot version %d", path, snap.Version)
+	}
+	for _, doc := range snap.Records {
+		records[journalKey{owner: doc.Owner, name: doc.Name}] = doc
+	}
+	return records, nil
+}
+
+// writeJournalSnapshot 先写临时文件并 fsync，再替换旧快照；目录 fsync 尽力而为（部分平台不支持）。
+func writeJournalSnapshot(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err = errors.Join(err, f.Close()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package defaultset
+
+// 本文件覆盖 `defaultset` 中与 `state_journal` 相关的行为。
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+func newTestJournalPersistence(t *testing.T, journals *VarStoreJournals) varstore.Persistence {
+	t.Helper()
+	store, err := newJournalVarStorePersistence(journals)
+	if err != nil {
+		t.Fatalf("newJournalVarStorePersistence err=%v", err)
+	}
+	return store
+}
+
+func TestJournalVarStoreReplaysAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.NewMap(map[string]string{
+		cfgVarStoreJournalDir:            dir,
+		cfgVarStoreJournalCompactRecords: "4",
+	})
+	ctx := context.Background()
+
+	journals := newVarStoreJournals(cfg, nil)
+	store := newTestJournalPersistence(t, journals)
+	if docs, err := store.LoadAll(ctx); err != nil || len(docs) != 0 {
+		t.Fatalf("empty journal docs=%+v err=%v", docs, err)
+	}
+	for _, doc := range []varstore.VarDocument{
+		{Owner: 7, Name: "a", Value: "1", Type: "string", Visibility: "public"},
+		{Owner: 9, Name: "b", Value: "x", Type: "string", Visibility: "public"},
+		{Owner: 7, Name: "a", Value: "2", Type: "string", Visibility: "private"},
+	} {
+		if err := store.Save(ctx, doc); err != nil {
+			t.Fatalf("save err=%v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, journalSnapshotName)); !os.IsNotExist(err) {
+		t.Fatalf("snapshot should not exist before compaction: %v", err)
+	}
+	// 第 4 条记录触发压缩：快照写出，日志清空。
+	if err := store.Delete(ctx, 9, "b"); err != nil {
+		t.Fatalf("delete err=%v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, journalLogName)); err != nil || info.Size() != 0 {
+		t.Fatalf("journal should be empty after compaction: info=%v err=%v", info, err)
+	}
+	if err := store.Save(ctx, varstore.VarDocument{Owner: 3, Name: "c", Value: "y", Type: "string", Visibility: "public"}); err != nil {
+		t.Fatalf("save after compaction err=%v", err)
+	}
+	journals.Close()
+	if err := store.Save(ctx, varstore.VarDocument{Owner: 3, Name: "d"}); err != errVarStoreJournalsClosed {
+		t.Fatalf("save after close err=%v", err)
+	}
+
+	// 重新打开：快照加日志回放得到关闭前的状态。
+	journals = newVarStoreJournals(cfg, nil)
+	defer journals.Close()
+	docs, err := newTestJournalPersistence(t, journals).LoadAll(ctx)
+	if err != nil || len(docs) != 2 {
+		t.Fatalf("replayed docs=%+v err=%v", docs, err)
+	}
+	if docs[0].Owner != 3 || docs[0].Value != "y" || docs[1].Owner != 7 || docs[1].Value != "2" || docs[1].Visibility != "private" {
+		t.Fatalf("replayed docs=%+v", docs)
+	}
+}
+
+func TestJournalVarStoreTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	good := `{"op":"set","owner":1,"name":"a","value":"1","type":"string","visibility":"public"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, journalLogName), []byte(good+`{"op":"set","owner":1,"na`), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	journals := newVarStoreJournals(config.NewMap(map[string]string{cfgVarStoreJournalDir: dir}), nil)
+	defer journals.Close()
+	store := newTestJournalPersistence(t, journals)
+	docs, err := store.LoadAll(context.Background())
+	if err != nil || len(docs) != 1 || docs[0].Value != "1" {
+		t.Fatalf("docs=%+v err=%v", docs, err)
+	}
+	if err := store.Delete(context.Background(), 1, "a"); err != nil {
+		t.Fatalf("delete err=%v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, journalLogName))
+	if err != nil || !strings.HasPrefix(string(raw), good) || strings.Count(string(raw), "\n") != 2 || strings.Contains(string(raw), `"na`+"\n") {
+		t.Fatalf("torn tail should be cut before appending: %q err=%v", raw, err)
+	}
+}
+
+func TestJournalVarStoreRejectsCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, journalLogName), []byte("not json\n"+`{"op":"revoke","owner":1,"name":"a"}`+"\n"), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	journals := newVarStoreJournals(config.NewMap(map[string]string{cfgVarStoreJournalDir: dir}), nil)
+	defer journals.Close()
+	if _, err := newTestJournalPersistence(t, journals).LoadAll(context.Background()); err == nil || !strings.Contains(err.Error(), "corrupt record at offset 0") {
+		t.Fatalf("expected corrupt record error, got %v", err)
+	}
+}
+
+func TestJournalVarStoreConfig(t *testing.T) {
+	for key, val := range map[string]string{
+		cfgVarStoreJournalFsync:           "sometimes",
+		cfgVarStoreJournalFsyncIntervalMs: "0",
+		cfgVarStoreJournalCompactRecords:  "x",
+	} {
+		journals := newVarStoreJournals(config.NewMap(map[string]string{key: val}), nil)
+		if _, err := newJournalVarStorePersistence(journals); err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("%s=%q err=%v", key, val, err)
+		}
+	}
+
+	// fsync=interval 时写入先返回，Close 停止后台刷盘并落盘。
+	dir := t.TempDir()
+	journals := newVarStoreJournals(config.NewMap(map[string]string{
+		cfgVarStoreJournalDir:             dir,
+		cfgVarStoreJournalFsync:           journalFsyncInterval,
+		cfgVarStoreJournalFsyncIntervalMs: "5",
+	}), nil)
+	store := newTestJournalPersistence(t, journals)
+	if err := store.Save(context.Background(), varstore.VarDocument{Owner: 1, Name: "a", Value: "1"}); err != nil {
+		t.Fatalf("save err=%v", err)
+	}
+	journals.Close()
+	journals = newVarStoreJournals(config.NewMap(map[string]string{cfgVarStoreJournalDir: dir}), nil)
+	defer journals.Close()
+	if docs, err := newTestJournalPersistence(t, journals).LoadAll(context.Background()); err != nil || len(docs) != 1 {
+		t.Fatalf("docs=%+v err=%v", docs, err)
+	}
+}
+
+func TestBuildWithJournalVarStoreUsesWorkDir(t *testing.T) {
+	root := t.TempDir()
+	bundle, err := Build(BuildOptions{
+		Config:      config.NewMap(map[string]string{cfgVarStoreBackend: backendJournal}),
+		ResolvePath: func(p string) string { return filepath.Join(root, p) },
+	})
+	if err != nil {
+		t.Fatalf("Build err=%v", err)
+	}
+	defer bundle.VarStoreJournals.Close()
+	store := newTestJournalPersistence(t, bundle.VarStoreJournals)
+	if err := store.Save(context.Background(), varstore.VarDocument{Owner: 1, Name: "a"}); err != nil {
+		t.Fatalf("save err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "state", "varstore", journalLogName)); err != nil {
+		t.Fatalf("default journal dir should live under the workdir: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_migrations` 相关的装配逻辑。
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+const (
+	cfgStatePGMigrationsTable = "state.pg.migrations_table"
+
+	defaultMigrationsTable = "myflowhub_schema_migrations"
+
+	// StateMigrationApplied / StateMigrationPending / StateMigrationMigrated / StateMigrationReapplied 是 StateMigration.State 的取值：
+	// 之前已执行、dry-run 下待执行、本次刚执行、因状态表缺失本次重放了已执行的版本。
+	StateMigrationApplied   = "applied"
+	StateMigrationPending   = "pending"
+	StateMigrationMigrated  = "migrated"
+	StateMigrationReapplied = "reapplied"
+)
+
+// pgStateTables 是 pg 状态后端使用的表名，均来自 state.pg.*_table。
+type pgStateTables struct {
+	Flow           string
+	VarStore       string
+	FlowRunArchive string
+	Migrations     string
+}
+
+func pgStateTablesFromConfig(cfg core.IConfig) (pgStateTables, error) {
+	var t pgStateTables
+	var err error
+	if t.Flow, err = normalizedPGTableName(cfg, cfgStatePGFlowTable, defaultFlowTable); err != nil {
+		return t, err
+	}
+	if t.VarStore, err = normalizedPGTableName(cfg, cfgStatePGVarTable, defaultVarTable); err != nil {
+		return t, err
+	}
+	if t.FlowRunArchive, err = normalizedPGTableName(cfg, cfgStatePGFlowRunArchiveTable, defaultFlowRunArchiveTable); err != nil {
+		return t, err
+	}
+	if t.Migrations, err = normalizedPGTableName(cfg, cfgStatePGMigrationsTable, defaultMigrationsTable); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// pgMigration 是一条 schema 迁移。Version 从 1 开始连续递增，已发布的迁移不可修改，只能追加新版本；
+// 语句应当幂等（IF NOT EXISTS 等），以兼容迁移子系统出现之前已由旧版本建好的表。
+type pgMigration struct {
+	Version int
+	Name    string
+	Up      func(pgStateTables) []string
+}
+
+// pgMigrations 是按版本排列的全部迁移。
+var pgMigrations = []pgMigration{
+	{
+		Version: 1,
+		Name:    "create_state_tables",
+		Up: func(t pgStateTables) []string {
+			return []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	flow_id TEXT PRIMARY KEY,
+	doc JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`, t.Flow),
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	owner BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	value_type TEXT NOT NULL,
+	visibility TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (owner, name)
+)`, t.VarStore),
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	flow_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	record JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (flow_id, run_id)
+)`, t.FlowRunArchive),
+			}
+		},
+	},
+}
+
+// StateMigration 描述一条迁移在目标库上的状态；Statements 只在 dry-run 的待执行迁移上给出。
+// 需要重放的已执行迁移在 dry-run 下同样报告为 pending，AppliedAt 保留首次执行的时间。
+type StateMigration struct {
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	AppliedAt  time.Time `json:"applied_at,omitzero"`
+	Statements []string  `json:"statements,omitempty"`
+}
+
+// pgTxBeginner 是 migratePGState 需要的最小能力，*pgx.Conn 与 *pgxpool.Pool 均满足。
+type pgTxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// pgMigrationLockKey 由版本表名派生 advisory lock 的键，使共用一个库但表名不同的部署互不阻塞。
+func pgMigrationLockKey(table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("myflowhub.state.migrations:" + table))
+	return int64(h.Sum64())
+}
+
+// migratePGState 在一个事务内持有 advisory lock，把 schema 迁移到最新版本：
+// 多个 hub 同时启动时只有一个执行迁移，其余在锁上等待后看到已完成的版本。
+// dryRun 时只报告状态并回滚事务，不留下任何改动（包括版本表本身）。
+// 库中记录的版本高于本程序已知的最新版本时报错，避免旧程序写入新 schema。
+//
+// 版本表只按版本号记录，不区分表名：修改 state.pg.*_table 后，已执行的迁移不会再为新表名建表。
+// 因此只要当前配置的任一状态表不存在，就按顺序重放全部已执行的迁移（语句幂等），补齐新表名下的完整 schema。
+func migratePGState(ctx context.Context, db pgTxBeginner, tables pgStateTables, dryRun bool) ([]StateMigration, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, pgMigrationLockKey(tables.Migrations)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`, tables.Migrations)); err != nil {
+		return nil, err
+	}
+	applied, err := loadAppliedPGMigrations(ctx, tx, tables.Migrations)
+	if err != nil {
+		return nil, err
+	}
+	latest := pgMigrations[len(pgMigrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return nil, fmt.Errorf("state schema version %d is newer than the latest known version %d", version, latest)
+		}
+	}
+
+	replay := false
+	if len(applied) > 0 {
+		missing, err := missingPGStateTables(ctx, tx, tables)
+		if err != nil {
+			return nil, err
+		}
+		replay = len(missing) > 0
+	}
+	out := make([]StateMigration, 0, len(pgMigrations))
+	for _, m := range pgMigrations {
+		item := StateMigration{Version: m.Version, Name: m.Name}
+		at, done := applied[m.Version]
+		if done {
+			item.AppliedAt = at
+			if !replay {
+				item.State = StateMigrationApplied
+				out = append(out, item)
+				continue
+			}
+		}
+		stmts := m.Up(tables)
+		if dryRun {
+			item.State, item.Statements = StateMigrationPending, stmts
+			out = append(out, item)
+			continue
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return nil, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+			}
+		}
+		if done {
+			item.State = StateMigrationReapplied
+			out = append(out, item)
+			continue
+		}
+		if err := tx.QueryRow(ctx, fmt.Sprintf(`INSERT INTO %s (version, name) VALUES ($1, $2) RETURNING applied_at`, tables.Migrations), m.Version, m.Name).Scan(&item.AppliedAt); err != nil {
+			return nil, err
+		}
+		item.State = StateMigrationMigrated
+		out = append(out, item)
+	}
+	if dryRun {
+		return out, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// missingPGStateTables 返回当前配置的状态表中在库里（按 search_path 解析）不存在的表名。
+func missingPGStateTables(ctx context.Context, tx pgx.Tx, tables pgStateTables) ([]string, error) {
+	var missing []string
+	for _, table := range []string{tables.Flow, tables.VarStore, tables.FlowRunArchive} {
+		var absent bool
+		if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NULL`, table).Scan(&absent); err != nil {
+			return nil, err
+		}
+		if absent {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
+func loadAppliedPGMigrations(ctx context.Context, tx pgx.Tx, table string) (map[int]time.Time, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf(`SELECT version, applied_at FROM %s`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// MigratePGState 用 state.pg.* 配置连接 pg 状态后端并执行 schema 迁移，返回每条迁移的状态；
+// dryRun 时只报告，不做改动。供 `hub_server state migrate` 在 hub 之外执行，hub 启动时也会自动执行同样的迁移。
+func MigratePGState(ctx context.Context, cfg core.IConfig, dryRun bool) ([]StateMigration, error) {
+	dsn, err := requiredConfigValue(cfg, cfgStatePGDSN)
+	if err != nil {
+		return nil, err
+	}
+	pools := newPGPools(cfg)
+	defer pools.Close()
+	pool, err := pools.pool(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return migratePGState(ctx, pool.Pool, pool.tables, dryRun)
+}
@@ -0,0 +1,186 @@
+package defaultset
+
+// 本文件覆盖 `defaultset` 中与 `state_migrations` 相关的行为。
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yttydcs/myflowhub-core/config"
+)
+
+func TestPGMigrationsAreOrderedAndUseConfiguredTables(t *testing.T) {
+	tables, err := pgStateTablesFromConfig(config.NewMap(map[string]string{
+		cfgStatePGFlowTable:           "hub_a_flows",
+		cfgStatePGVarTable:            "hub_a_vars",
+		cfgStatePGFlowRunArchiveTable: "hub_a_runs",
+		cfgStatePGMigrationsTable:     "hub_a_schema",
+	}))
+	if err != nil {
+		t.Fatalf("pgStateTablesFromConfig err=%v", err)
+	}
+	names := map[string]bool{}
+	var all string
+	for i, m := range pgMigrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration[%d] version=%d, want %d", i, m.Version, i+1)
+		}
+		if m.Name == "" || names[m.Name] {
+			t.Fatalf("migration %d name %q empty or duplicated", m.Version, m.Name)
+		}
+		names[m.Name] = true
+		stmts := m.Up(tables)
+		if len(stmts) == 0 {
+			t.Fatalf("migration %d has no statements", m.Version)
+		}
+		all += strings.Join(stmts, "\n")
+	}
+	for _, table := range []string{tables.Flow, tables.VarStore, tables.FlowRunArchive} {
+		if !strings.Contains(all, table) {
+			t.Fatalf("migrations never create %s", table)
+		}
+	}
+	if strings.Contains(all, defaultFlowTable) || strings.Contains(all, defaultVarTable) {
+		t.Fatalf("migrations should use configured table names")
+	}
+
+	if pgMigrationLockKey("hub_a_schema") != pgMigrationLockKey("hub_a_schema") || pgMigrationLockKey("hub_a_schema") == pgMigrationLockKey(defaultMigrationsTable) {
+		t.Fatalf("lock key should be stable and differ per migrations table")
+	}
+}
+
+func TestPGStateTablesFromConfigRejectsInvalidNames(t *testing.T) {
+	for _, key := range []string{cfgStatePGFlowTable, cfgStatePGVarTable, cfgStatePGFlowRunArchiveTable, cfgStatePGMigrationsTable} {
+		if _, err := pgStateTablesFromConfig(config.NewMap(map[string]string{key: "bad-name; DROP"})); err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected invalid %s error, got %v", key, err)
+		}
+	}
+}
+
+func TestMigratePGStateReturnsConnectionError(t *testing.T) {
+	if _, err := MigratePGState(context.Background(), config.NewMap(nil), true); err == nil || !strings.Contains(err.Error(), cfgStatePGDSN) {
+		t.Fatalf("expected missing dsn error, got %v", err)
+	}
+	cfg := config.NewMap(map[string]string{
+		cfgStatePGDSN: "postgres://127.0.0.1:1/myflowhub?sslmode=disable&connect_timeout=1",
+	})
+	if _, err := MigratePGState(context.Background(), cfg, true); err == nil {
+		t.Fatalf("expected pg connection error")
+	}
+}
+
+// fakePGTx 模拟迁移用到的事务能力：版本表中的已执行版本、库中已存在的状态表，并记录执行过的语句。
+type fakePGTx struct {
+	pgx.Tx
+	applied   []int
+	existing  map[string]bool
+	execs     []string
+	inserted  int
+	committed bool
+}
+
+func (tx *fakePGTx) Begin(context.Context) (pgx.Tx, error) { return tx, nil }
+
+func (tx *fakePGTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.execs = append(tx.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakePGTx) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return &fakePGRows{versions: tx.applied}, nil
+}
+
+func (tx *fakePGTx) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	return fakePGRow(func(dest ...any) error {
+		switch {
+		case strings.Contains(sql, "to_regclass"):
+			*dest[0].(*bool) = !tx.existing[args[0].(string)]
+		case strings.HasPrefix(sql, "INSERT"):
+			tx.inserted++
+			*dest[0].(*time.Time) = time.Now()
+		}
+		return nil
+	})
+}
+
+func (tx *fakePGTx) Commit(context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakePGTx) Rollback(context.Context) error { return nil }
+
+func (tx *fakePGTx) executed(table string) bool {
+	for _, stmt := range tx.execs {
+		if strings.Contains(stmt, "CREATE TABLE IF NOT EXISTS "+table) {
+			return true
+		}
+	}
+	return false
+}
+
+type fakePGRow func(dest ...any) error
+
+func (r fakePGRow) Scan(dest ...any) error { return r(dest...) }
+
+type fakePGRows struct {
+	pgx.Rows
+	versions []int
+	i        int
+}
+
+func (r *fakePGRows) Next() bool {
+	r.i++
+	return r.i <= len(r.versions)
+}
+
+func (r *fakePGRows) Scan(dest ...any) error {
+	*dest[0].(*int) = r.versions[r.i-1]
+	*dest[1].(*time.Time) = time.Unix(1, 0)
+	return nil
+}
+
+func (r *fakePGRows) Close()     {}
+func (r *fakePGRows) Err() error { return nil }
+
+func TestMigratePGStateReplaysAppliedMigrationsForRenamedTables(t *testing.T) {
+	tables, err := pgStateTablesFromConfig(config.NewMap(map[string]string{cfgStatePGFlowTable: "hub_b_flows"}))
+	if err != nil {
+		t.Fatalf("pgStateTablesFromConfig err=%v", err)
+	}
+	ctx := context.Background()
+
+	// 表都在：已执行的迁移不再执行。
+	tx := &fakePGTx{applied: []int{1}, existing: map[string]bool{tables.Flow: true, tables.VarStore: true, tables.FlowRunArchive: true}}
+	out, err := migratePGState(ctx, tx, tables, false)
+	if err != nil || len(out) != 1 || out[0].State != StateMigrationApplied {
+		t.Fatalf("migratePGState() = %+v, %v", out, err)
+	}
+	if tx.executed(tables.Flow) {
+		t.Fatalf("applied migration re-executed although all tables exist")
+	}
+
+	// 改了 flow 表名：v1 已记录为执行过，仍需为新表名建表。
+	tx = &fakePGTx{applied: []int{1}, existing: map[string]bool{tables.VarStore: true, tables.FlowRunArchive: true}}
+	out, err = migratePGState(ctx, tx, tables, true)
+	if err != nil || out[0].State != StateMigrationPending || out[0].AppliedAt.IsZero() || len(out[0].Statements) == 0 {
+		t.Fatalf("dry-run migratePGState() = %+v, %v", out, err)
+	}
+	if tx.executed(tables.Flow) || tx.committed {
+		t.Fatalf("dry-run must not change the schema")
+	}
+	out, err = migratePGState(ctx, tx, tables, false)
+	if err != nil || out[0].State != StateMigrationReapplied || out[0].AppliedAt != time.Unix(1, 0) {
+		t.Fatalf("migratePGState() = %+v, %v", out, err)
+	}
+	if !tx.executed("hub_b_flows") || !tx.committed {
+		t.Fatalf("renamed flow table not created: %v", tx.execs)
+	}
+	if tx.inserted != 0 {
+		t.Fatalf("reapplied migration must not be recorded again")
+	}
+}
@@ -0,0 +1,129 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_observer` 相关的装配逻辑。
+
+import (
+	"context"
+	"time"
+
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+const (
+	// StateKindFlow / StateKindVarStore / StateKindFlowRunArchive 是状态后端观测使用的数据类别。
+	StateKindFlow           = "flow"
+	StateKindVarStore       = "varstore"
+	StateKindFlowRunArchive = "flow_run_archive"
+)
+
+// StateObserver 接收状态后端每次操作的耗时与结果，供上层汇总成指标。
+// 实现必须并发安全且不可阻塞：它在 handler 的持久化调用路径上同步执行。
+type StateObserver interface {
+	ObserveStateOp(kind, backend, op string, elapsed time.Duration, err error)
+}
+
+type observedFlowPersistence struct {
+	inner    flowhandler.Persistence
+	backend  string
+	observer StateObserver
+}
+
+// observeFlowPersistence 在 observer 非空时为 flow 定义持久化包一层计时。
+func observeFlowPersistence(inner flowhandler.Persistence, backend string, observer StateObserver) flowhandler.Persistence {
+	if inner == nil || observer == nil {
+		return inner
+	}
+	return &observedFlowPersistence{inner: inner, backend: backend, observer: observer}
+}
+
+func (p *observedFlowPersistence) LoadAll(ctx context.Context) ([]flowhandler.FlowDocument, error) {
+	start := time.Now()
+	docs, err := p.inner.LoadAll(ctx)
+	p.observer.ObserveStateOp(StateKindFlow, p.backend, "load_all", time.Since(start), err)
+	return docs, err
+}
+
+func (p *observedFlowPersistence) Save(ctx context.Context, doc flowhandler.FlowDocument) error {
+	start := time.Now()
+	err := p.inner.Save(ctx, doc)
+	p.observer.ObserveStateOp(StateKindFlow, p.backend, "save", time.Since(start), err)
+	return err
+}
+
+func (p *observedFlowPersistence) Delete(ctx context.Context, flowID string) error {
+	start := time.Now()
+	err := p.inner.Delete(ctx, flowID)
+	p.observer.ObserveStateOp(StateKindFlow, p.backend, "delete", time.Since(start), err)
+	return err
+}
+
+type observedVarStorePersistence struct {
+	inner    varstore.Persistence
+	backend  string
+	observer StateObserver
+}
+
+// observeVarStorePersistence 在 observer 非空时为 varstore 持久化包一层计时。
+func observeVarStorePersistence(inner varstore.Persistence, backend string, observer StateObserver) varstore.Persistence {
+	if inner == nil || observer == nil {
+		return inner
+	}
+	return &observedVarStorePersistence{inner: inner, backend: backend, observer: observer}
+}
+
+func (p *observedVarStorePersistence) LoadAll(ctx context.Context) ([]varstore.VarDocument, error) {
+	start := time.Now()
+	docs, err := p.inner.LoadAll(ctx)
+	p.observer.ObserveStateOp(StateKindVarStore, p.backend, "load_all", time.Since(start), err)
+	return docs, err
+}
+
+func (p *observedVarStorePersistence) Save(ctx context.Context, doc varstore.VarDocument) error {
+	start := time.Now()
+	err := p.inner.Save(ctx, doc)
+	p.observer.ObserveStateOp(StateKindVarStore, p.backend, "save", time.Since(start), err)
+	return err
+}
+
+func (p *observedVarStorePersistence) Delete(ctx context.Context, owner uint32, name string) error {
+	start := time.Now()
+	err := p.inner.Delete(ctx, owner, name)
+	p.observer.ObserveStateOp(StateKindVarStore, p.backend, "delete", time.Since(start), err)
+	return err
+}
+
+type observedFlowRunArchiveStore struct {
+	inner    flowhandler.RunArchiveStore
+	backend  string
+	observer StateObserver
+}
+
+// observeFlowRunArchiveStore 在 observer 非空时为 run archive 存储包一层计时。
+func observeFlowRunArchiveStore(inner flowhandler.RunArchiveStore, backend string, observer StateObserver) flowhandler.RunArchiveStore {
+	if inner == nil || observer == nil {
+		return inner
+	}
+	return &observedFlowRunArchiveStore{inner: inner, backend: backend, observer: observer}
+}
+
+func (p *observedFlowRunArchiveStore) LoadAll(ctx context.Context) ([]flowhandler.ArchivedRunRecord, error) {
+	start := time.Now()
+	records, err := p.inner.LoadAll(ctx)
+	p.observer.ObserveStateOp(StateKindFlowRunArchive, p.backend, "load_all", time.Since(start), err)
+	return records, err
+}
+
+func (p *observedFlowRunArchiveStore) Save(ctx context.Context, record flowhandler.ArchivedRunRecord) error {
+	start := time.Now()
+	err := p.inner.Save(ctx, record)
+	p.observer.ObserveStateOp(StateKindFlowRunArchive, p.backend, "save", time.Since(start), err)
+	return err
+}
+
+func (p *observedFlowRunArchiveStore) Delete(ctx context.Context, flowID, runID string) error {
+	start := time.Now()
+	err := p.inner.Delete(ctx, flowID, runID)
+	p.observer.ObserveStateOp(StateKindFlowRunArchive, p.backend, "delete", time.Since(start), err)
+	return err
+}
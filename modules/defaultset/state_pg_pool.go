@@ -0,0 +1,165 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_pg_pool` 相关的装配逻辑。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+const (
+	cfgStatePGMaxConns          = "state.pg.max_conns"
+	cfgStatePGMinConns          = "state.pg.min_conns"
+	cfgStatePGMaxConnIdleMs     = "state.pg.max_conn_idle_ms"
+	cfgStatePGMaxConnLifetimeMs = "state.pg.max_conn_lifetime_ms"
+
+	defaultPGMaxConns          = 8
+	defaultPGMinConns          = 0
+	defaultPGMaxConnIdleMs     = 5 * 60 * 1000
+	defaultPGMaxConnLifetimeMs = 60 * 60 * 1000
+)
+
+var errPGPoolsClosed = errors.New("pg pools closed")
+
+// PGPools 按 DSN 持有 pg 状态后端共享的连接池：flow 定义、varstore 与 run 归档使用同一 DSN 时共用一个 pgxpool，
+// 并在首次使用时把该库的 schema 迁移到最新版本（见 state_migrations.go）。
+// 连接池在首个 pg 后端构造时创建（不会立即建连），由 Build 随 Bundle 返回，调用方在停止时 Close。
+type PGPools struct {
+	cfg core.IConfig
+
+	mu     sync.Mutex
+	pools  map[string]*pgPool
+	closed bool
+}
+
+func newPGPools(cfg core.IConfig) *PGPools {
+	return &PGPools{cfg: cfg, pools: make(map[string]*pgPool)}
+}
+
+// pool 返回 dsn 对应的连接池，不存在时按 state.pg.* 的池参数与表名创建。
+func (p *PGPools) pool(dsn string) (*pgPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errPGPoolsClosed
+	}
+	if pool, ok := p.pools[dsn]; ok {
+		return pool, nil
+	}
+	tables, err := pgStateTablesFromConfig(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg, err := pgPoolConfig(p.cfg, dsn)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	pool := &pgPool{Pool: inner, tables: tables}
+	p.pools[dsn] = pool
+	return pool, nil
+}
+
+// Close 关闭全部连接池；之后再构造 pg 后端会失败。p 为 nil 时什么也不做。
+func (p *PGPools) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	pools := p.pools
+	p.pools = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
+
+// pgPoolConfig 解析 dsn 并套用 state.pg.* 中的池大小、空闲与生命周期设置。
+func pgPoolConfig(cfg core.IConfig, dsn string) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", cfgStatePGDSN, err)
+	}
+	maxConns, err := intConfigValue(cfg, cfgStatePGMaxConns, defaultPGMaxConns, 1)
+	if err != nil {
+		return nil, err
+	}
+	minConns, err := intConfigValue(cfg, cfgStatePGMinConns, defaultPGMinConns, 0)
+	if err != nil {
+		return nil, err
+	}
+	if minConns > maxConns {
+		return nil, fmt.Errorf("%s must not exceed %s", cfgStatePGMinConns, cfgStatePGMaxConns)
+	}
+	idleMs, err := intConfigValue(cfg, cfgStatePGMaxConnIdleMs, defaultPGMaxConnIdleMs, 0)
+	if err != nil {
+		return nil, err
+	}
+	lifetimeMs, err := intConfigValue(cfg, cfgStatePGMaxConnLifetimeMs, defaultPGMaxConnLifetimeMs, 0)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MaxConns = int32(maxConns)
+	poolCfg.MinConns = int32(minConns)
+	poolCfg.MaxConnIdleTime = time.Duration(idleMs) * time.Millisecond
+	poolCfg.MaxConnLifetime = time.Duration(lifetimeMs) * time.Millisecond
+	return poolCfg, nil
+}
+
+// pgPool 是某个 DSN 的共享连接池：schema 迁移在首次使用时执行，成功后不再重复，失败时下次使用重试。
+type pgPool struct {
+	*pgxpool.Pool
+	tables pgStateTables
+
+	mu       sync.Mutex
+	migrated atomic.Bool
+}
+
+// prepare 补齐空 ctx 并确保 schema 已迁移到最新版本。handler 初始化时的 LoadAll 会最先触发迁移，因此正常情况下只在启动时执行一次。
+func (p *pgPool) prepare(ctx context.Context) (context.Context, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if p.migrated.Load() {
+		return ctx, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.migrated.Load() {
+		return ctx, nil
+	}
+	if _, err := migratePGState(ctx, p.Pool, p.tables, false); err != nil {
+		return ctx, err
+	}
+	p.migrated.Store(true)
+	return ctx, nil
+}
+
+// pgTable 是某张状态表在共享连接池上的句柄。
+type pgTable struct {
+	pool  *pgPool
+	table string
+}
+
+func newPGTable(pools *PGPools, dsn, table string) (*pgTable, error) {
+	pool, err := pools.pool(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &pgTable{pool: pool, table: table}, nil
+}
+
+func (t *pgTable) prepare(ctx context.Context) (context.Context, error) {
+	return t.pool.prepare(ctx)
+}
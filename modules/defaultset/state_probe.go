@@ -0,0 +1,40 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_probe` 相关的装配逻辑。
+
+import (
+	"context"
+
+	core "github.com/yttydcs/myflowhub-core"
+)
+
+// UsesPGStateBackend 判断当前配置下是否有状态数据落在 pg 后端。
+func UsesPGStateBackend(cfg core.IConfig) bool {
+	return backendValue(cfg, cfgFlowBackend, backendJSON) == backendPG ||
+		backendValue(cfg, cfgVarStoreBackend, backendMemory) == backendPG ||
+		flowRunArchiveBackendValue(cfg) == backendPG
+}
+
+// Probe 在共享连接池上执行 ping，检查 pg 状态后端是否可达；没有 pg 后端（p 为 nil 或尚未建池）时返回 nil。
+// 调用方应先用 UsesPGStateBackend 决定是否需要探活。
+func (p *PGPools) Probe(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errPGPoolsClosed
+	}
+	pools := make([]*pgPool, 0, len(p.pools))
+	for _, pool := range p.pools {
+		pools = append(pools, pool)
+	}
+	p.mu.Unlock()
+	for _, pool := range pools {
+		if err := pool.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
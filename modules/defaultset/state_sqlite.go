@@ -0,0 +1,416 @@
+package defaultset
+
+// 本文件承载默认模块集合中与 `state_sqlite` 相关的装配逻辑。
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	core "github.com/yttydcs/myflowhub-core"
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+const (
+	cfgStateSQLitePath = "state.sqlite.path"
+
+	defaultSQLitePath = "./state/hub.db"
+
+	// sqliteBusyTimeoutMs 是等待其他进程（如运维用 sqlite3 查询 run 归档）释放写锁的上限。
+	sqliteBusyTimeoutMs = 5000
+)
+
+var errSQLiteDBsClosed = errors.New("sqlite dbs closed")
+
+// SQLiteDBs 按文件路径持有 sqlite 状态后端共享的数据库句柄：flow 定义、varstore 与 run 归档使用 sqlite 时落在同一个文件。
+// 句柄在首个 sqlite 后端构造时打开，由 Build 随 Bundle 返回，调用方在停止时 Close。
+type SQLiteDBs struct {
+	cfg core.IConfig
+
+	mu     sync.Mutex
+	dbs    map[string]*sqliteDB
+	closed bool
+}
+
+// newSQLiteDBs 的 cfg 应带 ResolvePath 解析，使 state.sqlite.path 相对 runtime 的 WorkDir。
+func newSQLiteDBs(cfg core.IConfig) *SQLiteDBs {
+	return &SQLiteDBs{cfg: cfg, dbs: make(map[string]*sqliteDB)}
+}
+
+// db 返回 state.sqlite.path 对应的数据库句柄，不存在时创建目录并以 WAL 模式打开。
+func (s *SQLiteDBs) db() (*sqliteDB, error) {
+	if !sqliteBuiltin {
+		return nil, errors.New("sqlite backend not built in (nosqlite)")
+	}
+	path := defaultSQLitePath
+	if s.cfg != nil {
+		if raw, ok := s.cfg.Get(cfgStateSQLitePath); ok && strings.TrimSpace(raw) != "" {
+			path = strings.TrimSpace(raw)
+		}
+	}
+	path = filepath.Clean(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errSQLiteDBsClosed
+	}
+	if db, ok := s.dbs[path]; ok {
+		return db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfgStateSQLitePath, err)
+	}
+	dsn := fmt.Sprintf("%s?_txlock=immediate&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(%d)", path, sqliteBusyTimeoutMs)
+	inner, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// 单连接串行化本进程内的读写，避免 SQLITE_BUSY；WAL 仍允许外部进程并发只读。
+	inner.SetMaxOpenConns(1)
+	db := &sqliteDB{DB: inner, path: path}
+	s.dbs[path] = db
+	return db, nil
+}
+
+// Close 关闭全部数据库句柄；之后再构造 sqlite 后端会失败。s 为 nil 时什么也不做。
+func (s *SQLiteDBs) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	dbs := s.dbs
+	s.dbs = nil
+	s.closed = true
+	s.mu.Unlock()
+	for _, db := range dbs {
+		_ = db.Close()
+	}
+}
+
+// sqliteDB 是某个 sqlite 文件的句柄：schema 迁移在首次使用时执行，成功后不再重复，失败时下次使用重试。
+type sqliteDB struct {
+	*sql.DB
+	path string
+
+	mu       sync.Mutex
+	migrated atomic.Bool
+}
+
+// prepare 补齐空 ctx 并确保 schema 已迁移到最新版本，与 pgPool.prepare 相同。
+func (d *sqliteDB) prepare(ctx context.Context) (context.Context, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if d.migrated.Load() {
+		return ctx, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.migrated.Load() {
+		return ctx, nil
+	}
+	if err := migrateSQLiteState(ctx, d.DB); err != nil {
+		return ctx, err
+	}
+	d.migrated.Store(true)
+	return ctx, nil
+}
+
+// sqliteMigrations 与 pgMigrations 一一对应：表名取 pg 的缺省表名，列布局相同，
+// 类型换成 sqlite 的等价写法（JSONB / TIMESTAMPTZ 存为 TEXT）。已执行的版本记录在 PRAGMA user_version。
+var sqliteMigrations = []struct {
+	Version int
+	Name    string
+	Up      []string
+}{
+	{
+		Version: 1,
+		Name:    "create_state_tables",
+		Up: []string{
+			`
+CREATE TABLE IF NOT EXISTS ` + defaultFlowTable + ` (
+	flow_id TEXT PRIMARY KEY,
+	doc TEXT NOT NULL,
+	updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+			`
+CREATE TABLE IF NOT EXISTS ` + defaultVarTable + ` (
+	owner INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	value_type TEXT NOT NULL,
+	visibility TEXT NOT NULL,
+	updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (owner, name)
+)`,
+			`
+CREATE TABLE IF NOT EXISTS ` + defaultFlowRunArchiveTable + ` (
+	flow_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	record TEXT NOT NULL,
+	updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (flow_id, run_id)
+)`,
+		},
+	},
+}
+
+// migrateSQLiteState 在一个 BEGIN IMMEDIATE 事务内把 schema 迁移到最新版本；
+// 文件中的版本高于本程序已知的最新版本时报错。
+func migrateSQLiteState(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var current int
+	if err := tx.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&current); err != nil {
+		return err
+	}
+	latest := sqliteMigrations[len(sqliteMigrations)-1].Version
+	if current > latest {
+		return fmt.Errorf("state schema version %d is newer than the latest known version %d", current, latest)
+	}
+	for _, m := range sqliteMigrations {
+		if m.Version <= current {
+			continue
+		}
+		for _, stmt := range m.Up {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+			}
+		}
+	}
+	if current == latest {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, latest)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqliteFlowPersistence struct {
+	db *sqliteDB
+}
+
+func newSQLiteFlowPersistence(dbs *SQLiteDBs) (flowhandler.Persistence, error) {
+	db, err := dbs.db()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteFlowPersistence{db: db}, nil
+}
+
+func (p *sqliteFlowPersistence) LoadAll(ctx context.Context) ([]flowhandler.FlowDocument, error) {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.db.QueryContext(ctx, `SELECT flow_id, doc FROM `+defaultFlowTable+` ORDER BY flow_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs []flowhandler.FlowDocument
+	for rows.Next() {
+		var flowID, raw string
+		if err := rows.Scan(&flowID, &raw); err != nil {
+			return nil, err
+		}
+		var doc flowhandler.FlowDocument
+		if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(doc.FlowID) == "" {
+			doc.FlowID = flowID
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (p *sqliteFlowPersistence) Save(ctx context.Context, doc flowhandler.FlowDocument) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	ctx, err = p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `
+INSERT INTO `+defaultFlowTable+` (flow_id, doc, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (flow_id) DO UPDATE
+SET doc = excluded.doc,
+	updated_at = CURRENT_TIMESTAMP`, strings.TrimSpace(doc.FlowID), string(raw))
+	return err
+}
+
+func (p *sqliteFlowPersistence) Delete(ctx context.Context, flowID string) error {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `DELETE FROM `+defaultFlowTable+` WHERE flow_id = ?`, strings.TrimSpace(flowID))
+	return err
+}
+
+type sqliteVarStorePersistence struct {
+	db *sqliteDB
+}
+
+func newSQLiteVarStorePersistence(dbs *SQLiteDBs) (varstore.Persistence, error) {
+	db, err := dbs.db()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteVarStorePersistence{db: db}, nil
+}
+
+func (p *sqliteVarStorePersistence) LoadAll(ctx context.Context) ([]varstore.VarDocument, error) {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.db.QueryContext(ctx, `
+SELECT owner, name, value, value_type, visibility
+FROM `+defaultVarTable+`
+ORDER BY owner, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs []varstore.VarDocument
+	for rows.Next() {
+		var owner int64
+		var doc varstore.VarDocument
+		if err := rows.Scan(&owner, &doc.Name, &doc.Value, &doc.Type, &doc.Visibility); err != nil {
+			return nil, err
+		}
+		if owner < 0 || owner > int64(^uint32(0)) {
+			return nil, errors.New("owner out of range")
+		}
+		doc.Owner = uint32(owner)
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (p *sqliteVarStorePersistence) Save(ctx context.Context, doc varstore.VarDocument) error {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `
+INSERT INTO `+defaultVarTable+` (owner, name, value, value_type, visibility, updated_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (owner, name) DO UPDATE
+SET value = excluded.value,
+	value_type = excluded.value_type,
+	visibility = excluded.visibility,
+	updated_at = CURRENT_TIMESTAMP`, int64(doc.Owner), strings.TrimSpace(doc.Name), doc.Value, doc.Type, strings.TrimSpace(doc.Visibility))
+	return err
+}
+
+func (p *sqliteVarStorePersistence) Delete(ctx context.Context, owner uint32, name string) error {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `DELETE FROM `+defaultVarTable+` WHERE owner = ? AND name = ?`, int64(owner), strings.TrimSpace(name))
+	return err
+}
+
+type sqliteFlowRunArchiveStore struct {
+	db *sqliteDB
+}
+
+func newSQLiteFlowRunArchiveStore(dbs *SQLiteDBs) (flowhandler.RunArchiveStore, error) {
+	db, err := dbs.db()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteFlowRunArchiveStore{db: db}, nil
+}
+
+func (p *sqliteFlowRunArchiveStore) LoadAll(ctx context.Context) ([]flowhandler.ArchivedRunRecord, error) {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.db.QueryContext(ctx, `
+SELECT flow_id, run_id, record
+FROM `+defaultFlowRunArchiveTable+`
+ORDER BY flow_id, run_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []flowhandler.ArchivedRunRecord
+	for rows.Next() {
+		var flowID, runID, raw string
+		if err := rows.Scan(&flowID, &runID, &raw); err != nil {
+			return nil, err
+		}
+		var record flowhandler.ArchivedRunRecord
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(record.FlowID) == "" {
+			record.FlowID = flowID
+		}
+		if strings.TrimSpace(record.RunID) == "" {
+			record.RunID = runID
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p *sqliteFlowRunArchiveStore) Save(ctx context.Context, record flowhandler.ArchivedRunRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx, err = p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `
+INSERT INTO `+defaultFlowRunArchiveTable+` (flow_id, run_id, record, updated_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (flow_id, run_id) DO UPDATE
+SET record = excluded.record,
+	updated_at = CURRENT_TIMESTAMP`, strings.TrimSpace(record.FlowID), strings.TrimSpace(record.RunID), string(raw))
+	return err
+}
+
+func (p *sqliteFlowRunArchiveStore) Delete(ctx context.Context, flowID, runID string) error {
+	ctx, err := p.db.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `DELETE FROM `+defaultFlowRunArchiveTable+` WHERE flow_id = ? AND run_id = ?`, strings.TrimSpace(flowID), strings.TrimSpace(runID))
+	return err
+}
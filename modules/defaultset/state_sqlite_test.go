@@ -0,0 +1,134 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package defaultset
+
+// 本文件覆盖 `defaultset` 中与 `state_sqlite` 相关的行为。
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yttydcs/myflowhub-core/config"
+	flowhandler "github.com/yttydcs/myflowhub-subproto/flow"
+	"github.com/yttydcs/myflowhub-subproto/varstore"
+)
+
+func TestSQLiteBackendsRoundTripInOneFile(t *testing.T) {
+	root := t.TempDir()
+	cfg := withResolvedPaths(config.NewMap(map[string]string{
+		cfgStateSQLitePath: "data/state.db",
+	}), func(p string) string { return filepath.Join(root, p) })
+	ctx := context.Background()
+
+	dbs := newSQLiteDBs(cfg)
+	flows, err := newSQLiteFlowPersistence(dbs)
+	if err != nil {
+		t.Fatalf("newSQLiteFlowPersistence err=%v", err)
+	}
+	vars, err := newSQLiteVarStorePersistence(dbs)
+	if err != nil {
+		t.Fatalf("newSQLiteVarStorePersistence err=%v", err)
+	}
+	runs, err := newSQLiteFlowRunArchiveStore(dbs)
+	if err != nil {
+		t.Fatalf("newSQLiteFlowRunArchiveStore err=%v", err)
+	}
+	if flows.(*sqliteFlowPersistence).db != vars.(*sqliteVarStorePersistence).db || runs.(*sqliteFlowRunArchiveStore).db != vars.(*sqliteVarStorePersistence).db {
+		t.Fatalf("sqlite backends should share one database handle")
+	}
+
+	if err := flows.Save(ctx, flowhandler.FlowDocument{FlowID: "f1"}); err != nil {
+		t.Fatalf("flow save err=%v", err)
+	}
+	if err := flows.Save(ctx, flowhandler.FlowDocument{FlowID: "f2"}); err != nil {
+		t.Fatalf("flow save err=%v", err)
+	}
+	if err := flows.Delete(ctx, "f1"); err != nil {
+		t.Fatalf("flow delete err=%v", err)
+	}
+	for _, doc := range []varstore.VarDocument{
+		{Owner: 7, Name: "a", Value: "1", Type: "string", Visibility: "public"},
+		{Owner: 7, Name: "a", Value: "2", Type: "string", Visibility: "private"},
+		{Owner: 9, Name: "b", Value: "x", Type: "string", Visibility: "public"},
+	} {
+		if err := vars.Save(ctx, doc); err != nil {
+			t.Fatalf("var save err=%v", err)
+		}
+	}
+	if err := vars.Delete(ctx, 9, "b"); err != nil {
+		t.Fatalf("var delete err=%v", err)
+	}
+	if err := runs.Save(ctx, flowhandler.ArchivedRunRecord{FlowID: "f2", RunID: "r1"}); err != nil {
+		t.Fatalf("run save err=%v", err)
+	}
+
+	db := vars.(*sqliteVarStorePersistence).db
+	var mode string
+	var version int
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode=%q err=%v", mode, err)
+	}
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil || version != len(sqliteMigrations) {
+		t.Fatalf("user_version=%d err=%v", version, err)
+	}
+	dbs.Close()
+	if _, err := newSQLiteVarStorePersistence(dbs); err != errSQLiteDBsClosed {
+		t.Fatalf("backend after close err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "data", "state.db")); err != nil {
+		t.Fatalf("state.sqlite.path should resolve under the workdir: %v", err)
+	}
+
+	// 重新打开同一文件，数据与 schema 版本保持。
+	dbs = newSQLiteDBs(cfg)
+	defer dbs.Close()
+	flows, _ = newSQLiteFlowPersistence(dbs)
+	vars, _ = newSQLiteVarStorePersistence(dbs)
+	runs, _ = newSQLiteFlowRunArchiveStore(dbs)
+	if docs, err := flows.LoadAll(ctx); err != nil || len(docs) != 1 || docs[0].FlowID != "f2" {
+		t.Fatalf("flows=%+v err=%v", docs, err)
+	}
+	if docs, err := vars.LoadAll(ctx); err != nil || len(docs) != 1 || docs[0].Value != "2" || docs[0].Visibility != "private" {
+		t.Fatalf("vars=%+v err=%v", docs, err)
+	}
+	if records, err := runs.LoadAll(ctx); err != nil || len(records) != 1 || records[0].RunID != "r1" {
+		t.Fatalf("runs=%+v err=%v", records, err)
+	}
+}
+
+func TestSQLiteRejectsNewerSchemaVersion(t *testing.T) {
+	dbs := newSQLiteDBs(config.NewMap(map[string]string{cfgStateSQLitePath: filepath.Join(t.TempDir(), "state.db")}))
+	defer dbs.Close()
+	db, err := dbs.db()
+	if err != nil {
+		t.Fatalf("db err=%v", err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 99`); err != nil {
+		t.Fatalf("set user_version err=%v", err)
+	}
+	if _, err := db.prepare(context.Background()); err == nil {
+		t.Fatalf("expected newer schema version error")
+	}
+}
+
+func TestBuildWithSQLiteBackendsUsesWorkDir(t *testing.T) {
+	root := t.TempDir()
+	bundle, err := Build(BuildOptions{
+		Config: config.NewMap(map[string]string{
+			cfgFlowBackend:           backendSQLite,
+			cfgVarStoreBackend:       backendSQLite,
+			cfgFlowRunArchiveBackend: backendSQLite,
+		}),
+		ResolvePath: func(p string) string { return filepath.Join(root, p) },
+	})
+	if err != nil {
+		t.Fatalf("Build err=%v", err)
+	}
+	defer bundle.SQLiteDBs.Close()
+	if _, err := os.Stat(filepath.Join(root, "state")); err != nil {
+		t.Fatalf("default sqlite path should live under the workdir: %v", err)
+	}
+}
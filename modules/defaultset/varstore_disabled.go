@@ -12,6 +12,6 @@ import (
 	"github.com/yttydcs/myflowhub-subproto/exec/runtimedeps"
 )
 
-func newVarStoreHandler(cfg core.IConfig, deps runtimedeps.Deps, log *slog.Logger) (core.ISubProcess, error) {
+func newVarStoreHandler(cfg core.IConfig, deps runtimedeps.Deps, observer StateObserver, stores stateStores, log *slog.Logger) (core.ISubProcess, error) {
 	return nil, nil
 }
@@ -13,14 +13,14 @@ import (
 	varstorehandler "github.com/yttydcs/myflowhub-subproto/varstore"
 )
 
-func newVarStoreHandler(cfg core.IConfig, deps runtimedeps.Deps, log *slog.Logger) (core.ISubProcess, error) {
+func newVarStoreHandler(cfg core.IConfig, deps runtimedeps.Deps, observer StateObserver, stores stateStores, log *slog.Logger) (core.ISubProcess, error) {
 	// VarStore 先在装配层选定持久化后端，再把统一的运行时依赖交给子协议实现。
-	store, err := newVarStorePersistence(cfg)
+	store, err := newVarStorePersistence(cfg, stores)
 	if err != nil {
 		return nil, err
 	}
 	return varstorehandler.NewVarStoreHandlerWithOptions(cfg, varstorehandler.HandlerOptions{
 		RuntimeDeps: deps,
-		Persistence: store,
+		Persistence: observeVarStorePersistence(store, backendValue(cfg, cfgVarStoreBackend, backendMemory), observer),
 	}, log), nil
 }
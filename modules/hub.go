@@ -3,12 +3,14 @@ package modules
 // 本文件承载 Server 模块装配层中与 `hub` 相关的逻辑。
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 
 	core "github.com/yttydcs/myflowhub-core"
 	"github.com/yttydcs/myflowhub-server/modules/defaultset"
+	"github.com/yttydcs/myflowhub-subproto/exec/runtimedeps"
 )
 
 // Dispatcher 抽象 hub_server 装配所需的最小 dispatcher 能力。
@@ -19,23 +21,43 @@ type Dispatcher interface {
 
 // Set 表示一组可注册的子协议 handler 集合（以及默认 fallback）。
 // 注意：Set 仅负责装配与校验，不触发 handler.Init（由 Dispatcher 调用 RegisterHandler 时触发）。
+// Deps 为可选字段，记录 handlers 共享的运行期依赖，供配置热更新等运行期操作复用。
+// PGPools / SQLiteDBs / VarStoreJournals 为可选字段，是状态后端共享的连接池、数据库句柄与日志文件，
+// 由持有 Set 的一方在停止后经 CloseStateStores 关闭。
+// FileParts 为可选字段，由 DrainHooks 在 drain 时 checkpoint 未完成的 `.part` 文件。
 type Set struct {
-	Handlers []core.ISubProcess
-	Default  core.ISubProcess
+	Handlers         []core.ISubProcess
+	Default          core.ISubProcess
+	Deps             runtimedeps.Deps
+	PGPools          *defaultset.PGPools
+	SQLiteDBs        *defaultset.SQLiteDBs
+	VarStoreJournals *defaultset.VarStoreJournals
+	FileParts        *defaultset.FileParts
 }
 
 // DefaultHub 返回 hub_server 的默认启用模块集合。
 // DefaultHub 作为装配层稳定入口，委托 defaultset 构造默认 handler 集合并校验。
 func DefaultHub(cfg core.IConfig, log *slog.Logger) (Set, error) {
-	handlers, def, err := defaultset.DefaultHub(cfg, log)
+	return DefaultHubWithOptions(defaultset.BuildOptions{Config: cfg, Logger: log})
+}
+
+// DefaultHubWithOptions 与 DefaultHub 相同，但允许调用方传入完整的 defaultset 构造参数。
+func DefaultHubWithOptions(opts defaultset.BuildOptions) (Set, error) {
+	bundle, err := defaultset.Build(opts)
 	if err != nil {
 		return Set{}, err
 	}
 	set := Set{
-		Handlers: handlers,
-		Default:  def,
+		Handlers:         bundle.Handlers,
+		Default:          bundle.Default,
+		Deps:             bundle.Deps,
+		PGPools:          bundle.PGPools,
+		SQLiteDBs:        bundle.SQLiteDBs,
+		VarStoreJournals: bundle.VarStoreJournals,
+		FileParts:        bundle.FileParts,
 	}
 	if err := validateSet(set); err != nil {
+		CloseStateStores(set)
 		return Set{}, err
 	}
 	return set, nil
@@ -76,6 +98,38 @@ func BindServerHooks(srv core.IServer, set Set) {
 	}
 }
 
+type drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// DrainHooks 在 runtime 停止前对实现了 Drain(ctx) error 的 handler 依次执行 drain：
+// handler 应在 ctx 截止前结束或 checkpoint 在途会话并落盘持久化状态。
+// handlers 之后再 checkpoint Set.FileParts（file 子协议的 `.part` 文件）。
+// 单个 handler 失败不影响其余 handler，全部错误合并返回。
+func DrainHooks(ctx context.Context, set Set) error {
+	var errs []error
+	for _, h := range set.Handlers {
+		d, ok := h.(drainer)
+		if !ok {
+			continue
+		}
+		if err := d.Drain(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("drain %T: %w", h, err))
+		}
+	}
+	if err := set.FileParts.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain file parts: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// CloseStateStores 关闭 Set 持有的状态后端连接池、数据库句柄与日志文件；应在 handlers 停止访问状态后端之后调用。
+func CloseStateStores(set Set) {
+	set.PGPools.Close()
+	set.SQLiteDBs.Close()
+	set.VarStoreJournals.Close()
+}
+
 // validateSet 防御性检查 nil handler 与重复 SubProto，避免启动期才暴露装配错误。
 func validateSet(set Set) error {
 	if len(set.Handlers) == 0 {
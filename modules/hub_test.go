@@ -4,6 +4,7 @@ package modules
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	core "github.com/yttydcs/myflowhub-core"
@@ -35,6 +36,17 @@ func (h *bindHandler) BindServer(srv core.IServer) {
 	h.last = srv
 }
 
+type drainHandler struct {
+	stubHandler
+	calls int
+	err   error
+}
+
+func (h *drainHandler) Drain(context.Context) error {
+	h.calls++
+	return h.err
+}
+
 type dummyServer struct{}
 
 func (s dummyServer) Start(context.Context) error { return nil }
@@ -125,3 +137,20 @@ func TestBindServerHooks_OnlyBindableCalled(t *testing.T) {
 		t.Fatalf("BindServerHooks() last != srv")
 	}
 }
+
+func TestDrainHooks_CallsAllAndJoinsErrors(t *testing.T) {
+	ok := &drainHandler{stubHandler: stubHandler{sub: 1}}
+	bad := &drainHandler{stubHandler: stubHandler{sub: 2}, err: errors.New("flush failed")}
+	set := Set{
+		Handlers: []core.ISubProcess{bad, &stubHandler{sub: 3}, ok},
+		Default:  &stubHandler{sub: 0},
+	}
+
+	err := DrainHooks(context.Background(), set)
+	if !errors.Is(err, bad.err) {
+		t.Fatalf("DrainHooks() err=%v, want wrapping %v", err, bad.err)
+	}
+	if ok.calls != 1 || bad.calls != 1 {
+		t.Fatalf("DrainHooks() calls ok=%d bad=%d, want 1/1", ok.calls, bad.calls)
+	}
+}
@@ -0,0 +1,82 @@
+package modules
+
+// 本文件承载 Server 模块装配层中与 `reload` 相关的逻辑。
+
+import (
+	"strings"
+
+	core "github.com/yttydcs/myflowhub-core"
+	permission "github.com/yttydcs/myflowhub-core/kit/permission"
+)
+
+// configReloader 由需要感知运行期配置变化的 handler 可选实现。
+// keys 为 effective 值发生变化的配置键，handler 应自行从 cfg 回读新值。
+type configReloader interface {
+	ReloadConfig(cfg core.IConfig, keys []string)
+}
+
+// ReloadConfig 把运行期变更的配置键推送给关心它们的组件：
+//   - 任一 `auth.*` 键变化时，刷新 Set.Deps 与 cfg 共享的权限快照；
+//   - 实现了 ReloadConfig(core.IConfig, []string) 的 handler 收到完整的变更键列表。
+//
+// 该函数只在配置变化时调用，不进入报文热路径。
+func ReloadConfig(set Set, cfg core.IConfig, keys []string) {
+	if cfg == nil || len(keys) == 0 {
+		return
+	}
+	if hasKeyPrefix(keys, "auth.") {
+		reloadPermConfig(set.Deps.PermConfig, cfg)
+		reloadPermConfig(permission.SharedConfig(cfg), cfg)
+	}
+	for _, h := range set.Handlers {
+		if r, ok := h.(configReloader); ok {
+			r.ReloadConfig(cfg, keys)
+		}
+	}
+	if r, ok := set.Default.(configReloader); ok {
+		r.ReloadConfig(cfg, keys)
+	}
+}
+
+// reloadPermConfig 用配置中的角色定义刷新权限快照。
+// 角色权限、默认角色与默认权限完全以配置为准重建，配置中删除的角色或权限在重载后即被收回；
+// 节点角色则在配置之外保留运行期学到的部分（auth 在登录/同步阶段写入），避免热更新把它们清空。
+func reloadPermConfig(pc *permission.Config, cfg core.IConfig) {
+	if pc == nil || cfg == nil {
+		return
+	}
+	prev := pc.Snapshot()
+	fresh := permission.NewConfig(cfg).Snapshot()
+
+	nodeRoles := make(map[uint32]string, len(prev.NodeRoles)+len(fresh.NodeRoles))
+	for id, role := range prev.NodeRoles {
+		nodeRoles[id] = role
+	}
+	for id, role := range fresh.NodeRoles {
+		nodeRoles[id] = role
+	}
+	rolePerms := make(map[string][]string, len(fresh.RolePerms))
+	for role, perms := range fresh.RolePerms {
+		rolePerms[role] = perms
+	}
+	defaultPerms := fresh.DefaultPerms
+	if defaultPerms == nil {
+		// ApplySnapshot 对 nil 切片视为“不修改”，显式清空需要传空切片。
+		defaultPerms = []string{}
+	}
+	pc.ApplySnapshot(permission.Snapshot{
+		DefaultRole:  fresh.DefaultRole,
+		DefaultPerms: defaultPerms,
+		NodeRoles:    nodeRoles,
+		RolePerms:    rolePerms,
+	})
+}
+
+func hasKeyPrefix(keys []string, prefix string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package modules
+
+// 本文件覆盖 Server 模块装配层中与 `reload` 相关的行为。
+
+import (
+	"testing"
+
+	core "github.com/yttydcs/myflowhub-core"
+	coreconfig "github.com/yttydcs/myflowhub-core/config"
+	permission "github.com/yttydcs/myflowhub-core/kit/permission"
+	"github.com/yttydcs/myflowhub-subproto/exec/runtimedeps"
+)
+
+type reloadHandler struct {
+	stubHandler
+	keys []string
+}
+
+func (h *reloadHandler) ReloadConfig(_ core.IConfig, keys []string) {
+	h.keys = append(h.keys, keys...)
+}
+
+func TestReloadConfig_RefreshesPermsAndNotifiesHandlers(t *testing.T) {
+	cfg := coreconfig.NewMap(map[string]string{
+		coreconfig.KeyAuthRolePerms: "admin:p1",
+	})
+	perms := permission.NewConfig(cfg)
+	// Simulate a node role learned at login time; reload must not drop it.
+	perms.UpsertNode(42, "admin", nil)
+
+	h := &reloadHandler{stubHandler: stubHandler{sub: 3}}
+	set := Set{
+		Handlers: []core.ISubProcess{&stubHandler{sub: 1}, h},
+		Default:  &stubHandler{sub: 0},
+		Deps:     runtimedeps.Deps{PermConfig: perms},
+	}
+
+	cfg.Set(coreconfig.KeyAuthRolePerms, "admin:p1,p2")
+	ReloadConfig(set, cfg, []string{coreconfig.KeyAuthRolePerms})
+
+	if !perms.Has(42, "p2") {
+		t.Fatalf("role perms not reloaded or learned node role dropped")
+	}
+	if len(h.keys) != 1 || h.keys[0] != coreconfig.KeyAuthRolePerms {
+		t.Fatalf("handler keys=%v", h.keys)
+	}
+}
+
+func TestReloadConfig_RevokesRemovedRolePerms(t *testing.T) {
+	cfg := coreconfig.NewMap(map[string]string{
+		coreconfig.KeyAuthRolePerms: "admin:p1,p2;ops:p3",
+	})
+	perms := permission.NewConfig(cfg)
+	perms.UpsertNode(42, "admin", nil)
+	perms.UpsertNode(43, "ops", nil)
+	set := Set{
+		Handlers: []core.ISubProcess{&stubHandler{sub: 1}},
+		Default:  &stubHandler{sub: 0},
+		Deps:     runtimedeps.Deps{PermConfig: perms},
+	}
+
+	// 删除 admin 的 p2 与整个 ops 角色。
+	cfg.Set(coreconfig.KeyAuthRolePerms, "admin:p1")
+	ReloadConfig(set, cfg, []string{coreconfig.KeyAuthRolePerms})
+
+	if !perms.Has(42, "p1") {
+		t.Fatalf("remaining permission or learned node role lost")
+	}
+	if perms.Has(42, "p2") {
+		t.Fatalf("permission removed from config was not revoked")
+	}
+	if perms.Has(43, "p3") {
+		t.Fatalf("role removed from config was not revoked")
+	}
+}
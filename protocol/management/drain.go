@@ -0,0 +1,16 @@
+package management
+
+// 本文件承载 Server 仓内 `management` 协议中 drain 通知相关的类型定义。
+
+// node_draining 由正在停止的 hub 以 MajorCmd 逐跳发给直连子节点，无响应。
+// 子节点收到后应尽快切换到其他父端点；Server runtime 在 management handler 外层处理该 action。
+const ActionNodeDraining = "node_draining"
+
+// DrainingCode 是 hub drain 期间拒绝新的 flow run 与文件会话时使用的响应码。
+const DrainingCode = 4504
+
+// NodeDrainingReq 描述发起 drain 的节点；DeadlineMs 为 drain 截止时间（Unix 毫秒），此后父节点会断开全部连接。
+type NodeDrainingReq struct {
+	NodeID     uint32 `json:"node_id"`
+	DeadlineMs int64  `json:"deadline_ms,omitempty"`
+}
@@ -0,0 +1,46 @@
+package management
+
+// 本文件承载 Server 仓内 `management` 协议中配置来源解释相关的类型定义。
+
+// config_explain 与 config_schema 一样由 Server runtime 本地应答。
+const (
+	ActionConfigExplain     = "config_explain"
+	ActionConfigExplainResp = "config_explain_resp"
+)
+
+// 配置层名称，按优先级从低到高排列。
+const (
+	ConfigLayerDefault    = "default"
+	ConfigLayerFile       = "file"
+	ConfigLayerPersistent = "persistent"
+	ConfigLayerExplicit   = "explicit"
+	ConfigLayerRuntime    = "runtime"
+)
+
+// ConfigExplainReq 请求配置来源解释；Keys 非空时只解释这些键，否则按 Prefix 过滤全部键。
+type ConfigExplainReq struct {
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+}
+
+// ConfigLayerValue 是某一层给出的取值；Path 为文件层对应的文件。
+type ConfigLayerValue struct {
+	Layer string `json:"layer"`
+	Value string `json:"value"`
+	Path  string `json:"path,omitempty"`
+}
+
+// ConfigExplainItem 描述单个键的生效值、胜出层以及各层取值（按优先级从低到高）。
+type ConfigExplainItem struct {
+	Key    string             `json:"key"`
+	Found  bool               `json:"found"`
+	Value  string             `json:"value,omitempty"`
+	Source string             `json:"source,omitempty"`
+	Layers []ConfigLayerValue `json:"layers,omitempty"`
+}
+
+type ConfigExplainResp struct {
+	Code  int                 `json:"code"`
+	Msg   string              `json:"msg,omitempty"`
+	Items []ConfigExplainItem `json:"items,omitempty"`
+}
@@ -0,0 +1,57 @@
+package management
+
+// 本文件承载 Server 仓内 `management` 协议中配置历史相关的类型定义。
+
+// config_history / config_rollback 与 config_schema 一样由 Server runtime 在 management handler 外层应答。
+const (
+	ActionConfigHistory      = "config_history"
+	ActionConfigHistoryResp  = "config_history_resp"
+	ActionConfigRollback     = "config_rollback"
+	ActionConfigRollbackResp = "config_rollback_resp"
+)
+
+// 持久配置变更来源。
+const (
+	ConfigChangeSet      = "set"      // SetPersistent / management config_set
+	ConfigChangeRollback = "rollback" // config_rollback 回放
+	ConfigChangeReload   = "reload"   // 持久文件被外部修改后重读
+)
+
+// ConfigRevision 是一条持久配置变更；Old / New 为 nil 表示该键不存在。
+// 取值为持久层原文（Secret 键为引用）；历史文件中遗留的 Secret 明文以 ConfigRedactedValue 记录。
+type ConfigRevision struct {
+	Rev        int64   `json:"rev"`
+	TimeMs     int64   `json:"time_ms"`
+	Key        string  `json:"key"`
+	Old        *string `json:"old,omitempty"`
+	New        *string `json:"new,omitempty"`
+	Source     string  `json:"source"`
+	Actor      uint32  `json:"actor,omitempty"`
+	RollbackTo int64   `json:"rollback_to,omitempty"`
+}
+
+// ConfigHistoryReq 查询变更历史；Key 非空时只返回该键，Limit<=0 时返回全部保留的记录。
+type ConfigHistoryReq struct {
+	Key   string `json:"key,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// ConfigHistoryResp 按 Rev 从新到旧返回。
+type ConfigHistoryResp struct {
+	Code      int              `json:"code"`
+	Msg       string           `json:"msg,omitempty"`
+	Revisions []ConfigRevision `json:"revisions,omitempty"`
+}
+
+// ConfigRollbackReq 把持久配置恢复到 Rev 生效后的状态。
+type ConfigRollbackReq struct {
+	Rev int64 `json:"rev"`
+}
+
+// ConfigRollbackResp 返回实际回放的键。
+type ConfigRollbackResp struct {
+	Code int      `json:"code"`
+	Msg  string   `json:"msg,omitempty"`
+	Rev  int64    `json:"rev"`
+	Keys []string `json:"keys,omitempty"`
+}
@@ -0,0 +1,32 @@
+package management
+
+// 本文件承载 Server 仓内 `management` 协议中维护模式相关的类型定义。
+
+// maintenance_get / maintenance_set 与 config_schema 一样由 Server runtime 在 management handler 外层应答。
+const (
+	ActionMaintenanceGet     = "maintenance_get"
+	ActionMaintenanceGetResp = "maintenance_get_resp"
+	ActionMaintenanceSet     = "maintenance_set"
+	ActionMaintenanceSetResp = "maintenance_set_resp"
+)
+
+// MaintenanceCode 是维护模式下拒绝新的 register / login、flow run 与文件会话时使用的响应码。
+const MaintenanceCode = 4503
+
+// PermMaintenanceSet 是发起 maintenance_set 所需的权限节点；父链下发的 maintenance_set 不做判定。
+const PermMaintenanceSet = "management.maintenance_set"
+
+// MaintenanceSetReq 开启或关闭维护模式；Reason 随拒绝响应的 msg 返回给新接入的节点。
+type MaintenanceSetReq struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// MaintenanceResp 返回（设置后的）维护模式状态；SinceMs 为开启时间（Unix 毫秒），未开启时为 0。
+type MaintenanceResp struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	SinceMs int64  `json:"since_ms,omitempty"`
+}
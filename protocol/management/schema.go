@@ -0,0 +1,53 @@
+package management
+
+// 本文件承载 Server 仓内 `management` 协议中配置 schema 相关的类型定义。
+
+// config_schema 由 Server runtime 在 management handler 外层应答，独立协议仓库暂未收录；
+// wire 形态与其他 config_* action 保持一致（`{"action","data"}`，code=1 表示成功）。
+const (
+	ActionConfigSchema     = "config_schema"
+	ActionConfigSchemaResp = "config_schema_resp"
+)
+
+// 配置值类型。
+const (
+	ConfigTypeString = "string"
+	ConfigTypeInt    = "int"
+	ConfigTypeBool   = "bool"
+	ConfigTypeEnum   = "enum"
+	ConfigTypeList   = "list" // 逗号分隔
+	ConfigTypeJSON   = "json"
+)
+
+// 配置键的生效方式。
+const (
+	ConfigReloadLive    = "live"
+	ConfigReloadRestart = "restart"
+)
+
+// ConfigSchemaReq 请求配置 schema；Prefix 非空时只返回以其开头的键。
+type ConfigSchemaReq struct {
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// ConfigKeySchema 描述单个配置键，供 UI 渲染设置表单。
+type ConfigKeySchema struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	Default     string   `json:"default,omitempty"`
+	Allowed     []string `json:"allowed,omitempty"`
+	Min         *int64   `json:"min,omitempty"`
+	Max         *int64   `json:"max,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Secret      bool     `json:"secret,omitempty"`
+	Reload      string   `json:"reload"`
+}
+
+type ConfigSchemaResp struct {
+	Code int               `json:"code"`
+	Msg  string            `json:"msg,omitempty"`
+	Keys []ConfigKeySchema `json:"keys,omitempty"`
+}
+
+// ConfigRedactedValue 替代 Secret 键的取值出现在 config_get / config_set / config_explain 响应中。
+const ConfigRedactedValue = "<redacted>"